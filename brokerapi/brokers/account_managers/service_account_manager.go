@@ -15,7 +15,9 @@
 package account_managers
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -40,6 +42,7 @@ const (
 	roleResourcePrefix     = "roles/"
 	saResourcePrefix       = "serviceAccount:"
 	projectResourcePrefix  = "projects/"
+	retiredKeyField        = "ServiceAccountKey"
 	overridableBindMessage = `The role for the account without the "roles/" prefix.
 	See: https://cloud.google.com/iam/docs/understanding-roles for more details.
 	Note: The default enumeration may be overridden by your operator.`
@@ -122,6 +125,112 @@ func (sam *ServiceAccountManager) DeleteCredentials(ctx context.Context, binding
 	return nil
 }
 
+// CredentialsExist checks if the service account of the binding still exists
+// in Google.
+func (sam *ServiceAccountManager) CredentialsExist(ctx context.Context, binding models.ServiceBindingCredentials) (bool, error) {
+	var saCreds ServiceAccountInfo
+	if err := json.Unmarshal([]byte(binding.OtherDetails), &saCreds); err != nil {
+		return false, fmt.Errorf("Error unmarshalling credentials: %s", err)
+	}
+
+	iamService, err := iam.New(sam.HttpConfig.Client(ctx))
+	if err != nil {
+		return false, fmt.Errorf("Error creating IAM service: %s", err)
+	}
+
+	resourceName := projectResourcePrefix + sam.ProjectId + "/serviceAccounts/" + saCreds.UniqueId
+	if _, err := iam.NewProjectsServiceAccountsService(iamService).Get(resourceName).Do(); err != nil {
+		if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == http.StatusNotFound {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("error getting service account: %s", err)
+	}
+
+	return true, nil
+}
+
+// RotateCredentials creates a new key for the service account of the binding.
+// It returns the binding details with the new key, and the name of the key it
+// replaced so it can be deleted by RetireCredentials.
+func (sam *ServiceAccountManager) RotateCredentials(ctx context.Context, binding models.ServiceBindingCredentials) (map[string]interface{}, map[string]interface{}, error) {
+	var saCreds ServiceAccountInfo
+	if err := json.Unmarshal([]byte(binding.OtherDetails), &saCreds); err != nil {
+		return nil, nil, fmt.Errorf("Error unmarshalling credentials: %s", err)
+	}
+
+	oldKeyName, err := sam.serviceAccountKeyName(saCreds)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	account := &iam.ServiceAccount{Name: projectResourcePrefix + sam.ProjectId + "/serviceAccounts/" + saCreds.UniqueId}
+	newSAKey, err := sam.createServiceAccountKey(ctx, account)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error creating new service account key: %s", err)
+	}
+
+	sam.Logger.Info("rotate-service-account-key", lager.Data{
+		"service_account": saCreds.Email,
+		"retired_key":     oldKeyName,
+	})
+
+	saCreds.PrivateKeyData = newSAKey.PrivateKeyData
+	rotated, err := varcontext.Builder().MergeStruct(saCreds).BuildMap()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rotated, map[string]interface{}{retiredKeyField: oldKeyName}, nil
+}
+
+// RetireCredentials deletes a service account key replaced by
+// RotateCredentials. Keys that were already deleted, with their account or
+// otherwise, are ignored.
+func (sam *ServiceAccountManager) RetireCredentials(ctx context.Context, retired map[string]interface{}) error {
+	keyName, ok := retired[retiredKeyField].(string)
+	if !ok || keyName == "" {
+		return fmt.Errorf("retired credentials don't have a %s", retiredKeyField)
+	}
+
+	iamService, err := iam.New(sam.HttpConfig.Client(ctx))
+	if err != nil {
+		return fmt.Errorf("Error creating IAM service: %s", err)
+	}
+
+	if _, err := iam.NewProjectsServiceAccountsKeysService(iamService).Delete(keyName).Do(); err != nil {
+		if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == http.StatusNotFound {
+			return nil
+		}
+
+		return fmt.Errorf("error deleting service account key: %s", err)
+	}
+
+	return nil
+}
+
+// serviceAccountKeyName gets the resource name of the key in the credentials
+// from the private key ID in its key file.
+func (sam *ServiceAccountManager) serviceAccountKeyName(saCreds ServiceAccountInfo) (string, error) {
+	keyFile, err := base64.StdEncoding.DecodeString(saCreds.PrivateKeyData)
+	if err != nil {
+		return "", fmt.Errorf("Error decoding private key data: %s", err)
+	}
+
+	var key struct {
+		PrivateKeyId string `json:"private_key_id"`
+	}
+	if err := json.Unmarshal(keyFile, &key); err != nil {
+		return "", fmt.Errorf("Error unmarshalling private key data: %s", err)
+	}
+
+	if key.PrivateKeyId == "" {
+		return "", errors.New("the private key data doesn't have a private_key_id")
+	}
+
+	return projectResourcePrefix + sam.ProjectId + "/serviceAccounts/" + saCreds.UniqueId + "/keys/" + key.PrivateKeyId, nil
+}
+
 func (sam *ServiceAccountManager) createServiceAccount(ctx context.Context, accountId, displayName string) (*iam.ServiceAccount, error) {
 	client := sam.HttpConfig.Client(ctx)
 	iamService, err := iam.New(client)
@@ -15,6 +15,7 @@
 package account_managers
 
 import (
+	"encoding/base64"
 	"fmt"
 	"reflect"
 	"testing"
@@ -144,3 +145,46 @@ func TestServiceAccountBindInputVariables(t *testing.T) {
 
 	}
 }
+
+func TestServiceAccountManager_serviceAccountKeyName(t *testing.T) {
+	encode := func(keyFile string) string {
+		return base64.StdEncoding.EncodeToString([]byte(keyFile))
+	}
+
+	cases := map[string]struct {
+		PrivateKeyData string
+		Expected       string
+		ExpectError    bool
+	}{
+		"key file": {
+			PrivateKeyData: encode(`{"type":"service_account","private_key_id":"abc123"}`),
+			Expected:       "projects/my-project/serviceAccounts/1234/keys/abc123",
+		},
+		"missing key id": {
+			PrivateKeyData: encode(`{"type":"service_account"}`),
+			ExpectError:    true,
+		},
+		"not base64": {
+			PrivateKeyData: "{}",
+			ExpectError:    true,
+		},
+		"not json": {
+			PrivateKeyData: encode("key"),
+			ExpectError:    true,
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			sam := &ServiceAccountManager{ProjectId: "my-project"}
+			actual, err := sam.serviceAccountKeyName(ServiceAccountInfo{UniqueId: "1234", PrivateKeyData: tc.PrivateKeyData})
+			if (err != nil) != tc.ExpectError {
+				t.Fatalf("Expected error? %t, got: %v", tc.ExpectError, err)
+			}
+
+			if actual != tc.Expected {
+				t.Errorf("Expected key name %q, got %q", tc.Expected, actual)
+			}
+		})
+	}
+}
@@ -17,12 +17,16 @@ package bigquery
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/broker_base"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/varcontext"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/utils"
 	"github.com/pivotal-cf/brokerapi"
 	googlebigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/googleapi"
 )
 
 // BigQueryBroker is the service-broker back-end for creating and binding BigQuery instances.
@@ -59,14 +63,19 @@ func (b *BigQueryBroker) Provision(ctx context.Context, provisionContext *varcon
 		return models.ServiceInstanceDetails{}, fmt.Errorf("Error inserting new dataset: %s", err)
 	}
 
+	return datasetInstanceDetails(newDataset.DatasetReference.DatasetId, newDataset.SelfLink, newDataset.Location)
+}
+
+// datasetInstanceDetails creates the instance details of a dataset.
+func datasetInstanceDetails(datasetId, selfLink, location string) (models.ServiceInstanceDetails, error) {
 	ii := InstanceInformation{
-		DatasetId: newDataset.DatasetReference.DatasetId,
+		DatasetId: datasetId,
 	}
 
 	id := models.ServiceInstanceDetails{
-		Name:     newDataset.DatasetReference.DatasetId,
-		Url:      newDataset.SelfLink,
-		Location: newDataset.Location,
+		Name:     datasetId,
+		Url:      selfLink,
+		Location: location,
 	}
 
 	if err := id.SetOtherDetails(ii); err != nil {
@@ -91,6 +100,59 @@ func (b *BigQueryBroker) Deprovision(ctx context.Context, dataset models.Service
 	return nil, nil
 }
 
+// Exists checks if the dataset associated with the given instance still exists.
+func (b *BigQueryBroker) Exists(ctx context.Context, dataset models.ServiceInstanceDetails) (bool, error) {
+	service, err := b.createClient(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := service.Datasets.Get(b.ProjectId, dataset.Name).Do(); err != nil {
+		if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == http.StatusNotFound {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("Error getting dataset: %s", err)
+	}
+
+	return true, nil
+}
+
+// DescribeResources lists the datasets in the project that are labeled with
+// the ID of a service instance. The list doesn't include creation times, so
+// each labeled dataset is fetched to get its own.
+func (b *BigQueryBroker) DescribeResources(ctx context.Context) ([]models.ServiceInstanceDetails, error) {
+	service, err := b.createClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []models.ServiceInstanceDetails
+	err = service.Datasets.List(b.ProjectId).Filter("labels."+utils.InstanceIdLabel).Pages(ctx, func(page *googlebigquery.DatasetList) error {
+		for _, dataset := range page.Datasets {
+			details, err := service.Datasets.Get(b.ProjectId, dataset.DatasetReference.DatasetId).Context(ctx).Do()
+			if err != nil {
+				return err
+			}
+
+			resource, err := datasetInstanceDetails(details.DatasetReference.DatasetId, details.SelfLink, details.Location)
+			if err != nil {
+				return err
+			}
+			resource.ID = details.Labels[utils.InstanceIdLabel]
+			resource.CreatedAt = time.Unix(0, details.CreationTime*int64(time.Millisecond))
+			resources = append(resources, resource)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing datasets: %s", err)
+	}
+
+	return resources, nil
+}
+
 func (b *BigQueryBroker) createClient(ctx context.Context) (*googlebigquery.Service, error) {
 	service, err := googlebigquery.New(b.HttpConfig.Client(ctx))
 	if err != nil {
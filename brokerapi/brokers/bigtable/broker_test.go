@@ -15,6 +15,7 @@
 package bigtable
 
 import (
+	"context"
 	"encoding/json"
 	"reflect"
 	"testing"
@@ -90,7 +91,7 @@ func TestBigTableBroker_ProvisionVariables(t *testing.T) {
 			if err != nil {
 				t.Errorf("got error trying to find plan %s %v", tc.PlanId, err)
 			}
-			vars, err := service.ProvisionVariables("instance-id-here", details, *plan)
+			vars, err := service.ProvisionVariables(context.Background(), "instance-id-here", details, *plan)
 
 			if err != nil {
 				t.Errorf("got error while creating provision variables: %v", err)
@@ -32,6 +32,9 @@ import (
 type ServiceAccountManager interface {
 	CreateCredentials(ctx context.Context, vc *varcontext.VarContext) (map[string]interface{}, error)
 	DeleteCredentials(ctx context.Context, creds models.ServiceBindingCredentials) error
+	CredentialsExist(ctx context.Context, creds models.ServiceBindingCredentials) (bool, error)
+	RotateCredentials(ctx context.Context, creds models.ServiceBindingCredentials) (map[string]interface{}, map[string]interface{}, error)
+	RetireCredentials(ctx context.Context, retired map[string]interface{}) error
 }
 
 // NewBrokerBase creates a new broker base and account manager it uses from the
@@ -85,12 +88,24 @@ func (b *BrokerBase) Unbind(ctx context.Context, instance models.ServiceInstance
 	return b.AccountManager.DeleteCredentials(ctx, creds)
 }
 
+// Update returns brokerapi.ErrPlanChangeNotSupported because Base services
+// don't hold any updatable state.
+func (b *BrokerBase) Update(ctx context.Context, instance models.ServiceInstanceDetails, updateContext *varcontext.VarContext) (models.ServiceInstanceDetails, error) {
+	return models.ServiceInstanceDetails{}, brokerapi.ErrPlanChangeNotSupported
+}
+
 // PollInstance does nothing but return an error because Base services are
 // provisioned synchronously so this method should not be called.
 func (b *BrokerBase) PollInstance(ctx context.Context, instance models.ServiceInstanceDetails) (bool, error) {
 	return true, brokerapi.ErrAsyncRequired
 }
 
+// DescribeOperation gets a description of the pending operation on the
+// instance. This instance returns no description.
+func (b *BrokerBase) DescribeOperation(ctx context.Context, instance models.ServiceInstanceDetails) (string, error) {
+	return "", nil
+}
+
 // ProvisionsAsync indicates if provisioning must be done asynchronously.
 func (b *BrokerBase) ProvisionsAsync() bool {
 	return false
@@ -101,6 +116,73 @@ func (b *BrokerBase) DeprovisionsAsync() bool {
 	return false
 }
 
+// BindsAsync indicates if binding must be done asynchronously.
+func (b *BrokerBase) BindsAsync() bool {
+	return false
+}
+
+// PollBinding does nothing but return an error because Base services are
+// bound synchronously so this method should not be called.
+func (b *BrokerBase) PollBinding(ctx context.Context, instance models.ServiceInstanceDetails, binding models.ServiceBindingCredentials) (bool, error) {
+	return true, brokerapi.ErrAsyncRequired
+}
+
+// UpdateBindingDetails updates the binding with its credentials after an
+// asynchronous bind. This instance is a no-op method.
+func (b *BrokerBase) UpdateBindingDetails(ctx context.Context, instance models.ServiceInstanceDetails, binding *models.ServiceBindingCredentials) error {
+	return nil
+}
+
+// Exists checks if the resources of the instance still exist.
+// This instance doesn't know the resources of the service so it assumes they do.
+func (b *BrokerBase) Exists(ctx context.Context, instance models.ServiceInstanceDetails) (bool, error) {
+	return true, nil
+}
+
+// BindingExists checks if the service account created for the binding still
+// exists.
+func (b *BrokerBase) BindingExists(ctx context.Context, instance models.ServiceInstanceDetails, binding models.ServiceBindingCredentials) (bool, error) {
+	return b.AccountManager.CredentialsExist(ctx, binding)
+}
+
+// DescribeResources lists the labeled resources of the service.
+// This instance doesn't know the resources of the service so it returns none.
+func (b *BrokerBase) DescribeResources(ctx context.Context) ([]models.ServiceInstanceDetails, error) {
+	return nil, nil
+}
+
+// RotateCredentials creates a new key for the service account of the binding
+// and replaces the old one in its details. The old key is returned so it can
+// be deleted once applications use the new one.
+func (b *BrokerBase) RotateCredentials(ctx context.Context, instance models.ServiceInstanceDetails, binding *models.ServiceBindingCredentials) (map[string]interface{}, error) {
+	rotated, retired, err := b.AccountManager.RotateCredentials(ctx, *binding)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := varcontext.Builder().
+		MergeJsonObject(json.RawMessage(binding.OtherDetails)).
+		MergeMap(rotated).
+		BuildMap()
+	if err != nil {
+		return nil, err
+	}
+
+	serializedCreds, err := json.Marshal(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	binding.OtherDetails = string(serializedCreds)
+	return retired, nil
+}
+
+// RetireCredentials deletes a service account key replaced by
+// RotateCredentials.
+func (b *BrokerBase) RetireCredentials(ctx context.Context, instance models.ServiceInstanceDetails, retired map[string]interface{}) error {
+	return b.AccountManager.RetireCredentials(ctx, retired)
+}
+
 // UpdateInstanceDetails updates the ServiceInstanceDetails with the most recent state from GCP.
 // This instance is a no-op method.
 func (b *BrokerBase) UpdateInstanceDetails(ctx context.Context, instance *models.ServiceInstanceDetails) error {
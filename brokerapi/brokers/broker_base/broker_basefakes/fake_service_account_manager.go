@@ -25,6 +25,20 @@ type FakeServiceAccountManager struct {
 		result1 map[string]interface{}
 		result2 error
 	}
+	CredentialsExistStub        func(context.Context, models.ServiceBindingCredentials) (bool, error)
+	credentialsExistMutex       sync.RWMutex
+	credentialsExistArgsForCall []struct {
+		arg1 context.Context
+		arg2 models.ServiceBindingCredentials
+	}
+	credentialsExistReturns struct {
+		result1 bool
+		result2 error
+	}
+	credentialsExistReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
 	DeleteCredentialsStub        func(context.Context, models.ServiceBindingCredentials) error
 	deleteCredentialsMutex       sync.RWMutex
 	deleteCredentialsArgsForCall []struct {
@@ -37,6 +51,34 @@ type FakeServiceAccountManager struct {
 	deleteCredentialsReturnsOnCall map[int]struct {
 		result1 error
 	}
+	RetireCredentialsStub        func(context.Context, map[string]interface{}) error
+	retireCredentialsMutex       sync.RWMutex
+	retireCredentialsArgsForCall []struct {
+		arg1 context.Context
+		arg2 map[string]interface{}
+	}
+	retireCredentialsReturns struct {
+		result1 error
+	}
+	retireCredentialsReturnsOnCall map[int]struct {
+		result1 error
+	}
+	RotateCredentialsStub        func(context.Context, models.ServiceBindingCredentials) (map[string]interface{}, map[string]interface{}, error)
+	rotateCredentialsMutex       sync.RWMutex
+	rotateCredentialsArgsForCall []struct {
+		arg1 context.Context
+		arg2 models.ServiceBindingCredentials
+	}
+	rotateCredentialsReturns struct {
+		result1 map[string]interface{}
+		result2 map[string]interface{}
+		result3 error
+	}
+	rotateCredentialsReturnsOnCall map[int]struct {
+		result1 map[string]interface{}
+		result2 map[string]interface{}
+		result3 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -105,6 +147,70 @@ func (fake *FakeServiceAccountManager) CreateCredentialsReturnsOnCall(i int, res
 	}{result1, result2}
 }
 
+func (fake *FakeServiceAccountManager) CredentialsExist(arg1 context.Context, arg2 models.ServiceBindingCredentials) (bool, error) {
+	fake.credentialsExistMutex.Lock()
+	ret, specificReturn := fake.credentialsExistReturnsOnCall[len(fake.credentialsExistArgsForCall)]
+	fake.credentialsExistArgsForCall = append(fake.credentialsExistArgsForCall, struct {
+		arg1 context.Context
+		arg2 models.ServiceBindingCredentials
+	}{arg1, arg2})
+	fake.recordInvocation("CredentialsExist", []interface{}{arg1, arg2})
+	fake.credentialsExistMutex.Unlock()
+	if fake.CredentialsExistStub != nil {
+		return fake.CredentialsExistStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.credentialsExistReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeServiceAccountManager) CredentialsExistCallCount() int {
+	fake.credentialsExistMutex.RLock()
+	defer fake.credentialsExistMutex.RUnlock()
+	return len(fake.credentialsExistArgsForCall)
+}
+
+func (fake *FakeServiceAccountManager) CredentialsExistCalls(stub func(context.Context, models.ServiceBindingCredentials) (bool, error)) {
+	fake.credentialsExistMutex.Lock()
+	defer fake.credentialsExistMutex.Unlock()
+	fake.CredentialsExistStub = stub
+}
+
+func (fake *FakeServiceAccountManager) CredentialsExistArgsForCall(i int) (context.Context, models.ServiceBindingCredentials) {
+	fake.credentialsExistMutex.RLock()
+	defer fake.credentialsExistMutex.RUnlock()
+	argsForCall := fake.credentialsExistArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeServiceAccountManager) CredentialsExistReturns(result1 bool, result2 error) {
+	fake.credentialsExistMutex.Lock()
+	defer fake.credentialsExistMutex.Unlock()
+	fake.CredentialsExistStub = nil
+	fake.credentialsExistReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceAccountManager) CredentialsExistReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.credentialsExistMutex.Lock()
+	defer fake.credentialsExistMutex.Unlock()
+	fake.CredentialsExistStub = nil
+	if fake.credentialsExistReturnsOnCall == nil {
+		fake.credentialsExistReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.credentialsExistReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeServiceAccountManager) DeleteCredentials(arg1 context.Context, arg2 models.ServiceBindingCredentials) error {
 	fake.deleteCredentialsMutex.Lock()
 	ret, specificReturn := fake.deleteCredentialsReturnsOnCall[len(fake.deleteCredentialsArgsForCall)]
@@ -166,13 +272,147 @@ func (fake *FakeServiceAccountManager) DeleteCredentialsReturnsOnCall(i int, res
 	}{result1}
 }
 
+func (fake *FakeServiceAccountManager) RetireCredentials(arg1 context.Context, arg2 map[string]interface{}) error {
+	fake.retireCredentialsMutex.Lock()
+	ret, specificReturn := fake.retireCredentialsReturnsOnCall[len(fake.retireCredentialsArgsForCall)]
+	fake.retireCredentialsArgsForCall = append(fake.retireCredentialsArgsForCall, struct {
+		arg1 context.Context
+		arg2 map[string]interface{}
+	}{arg1, arg2})
+	fake.recordInvocation("RetireCredentials", []interface{}{arg1, arg2})
+	fake.retireCredentialsMutex.Unlock()
+	if fake.RetireCredentialsStub != nil {
+		return fake.RetireCredentialsStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.retireCredentialsReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeServiceAccountManager) RetireCredentialsCallCount() int {
+	fake.retireCredentialsMutex.RLock()
+	defer fake.retireCredentialsMutex.RUnlock()
+	return len(fake.retireCredentialsArgsForCall)
+}
+
+func (fake *FakeServiceAccountManager) RetireCredentialsCalls(stub func(context.Context, map[string]interface{}) error) {
+	fake.retireCredentialsMutex.Lock()
+	defer fake.retireCredentialsMutex.Unlock()
+	fake.RetireCredentialsStub = stub
+}
+
+func (fake *FakeServiceAccountManager) RetireCredentialsArgsForCall(i int) (context.Context, map[string]interface{}) {
+	fake.retireCredentialsMutex.RLock()
+	defer fake.retireCredentialsMutex.RUnlock()
+	argsForCall := fake.retireCredentialsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeServiceAccountManager) RetireCredentialsReturns(result1 error) {
+	fake.retireCredentialsMutex.Lock()
+	defer fake.retireCredentialsMutex.Unlock()
+	fake.RetireCredentialsStub = nil
+	fake.retireCredentialsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeServiceAccountManager) RetireCredentialsReturnsOnCall(i int, result1 error) {
+	fake.retireCredentialsMutex.Lock()
+	defer fake.retireCredentialsMutex.Unlock()
+	fake.RetireCredentialsStub = nil
+	if fake.retireCredentialsReturnsOnCall == nil {
+		fake.retireCredentialsReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.retireCredentialsReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeServiceAccountManager) RotateCredentials(arg1 context.Context, arg2 models.ServiceBindingCredentials) (map[string]interface{}, map[string]interface{}, error) {
+	fake.rotateCredentialsMutex.Lock()
+	ret, specificReturn := fake.rotateCredentialsReturnsOnCall[len(fake.rotateCredentialsArgsForCall)]
+	fake.rotateCredentialsArgsForCall = append(fake.rotateCredentialsArgsForCall, struct {
+		arg1 context.Context
+		arg2 models.ServiceBindingCredentials
+	}{arg1, arg2})
+	fake.recordInvocation("RotateCredentials", []interface{}{arg1, arg2})
+	fake.rotateCredentialsMutex.Unlock()
+	if fake.RotateCredentialsStub != nil {
+		return fake.RotateCredentialsStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	fakeReturns := fake.rotateCredentialsReturns
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *FakeServiceAccountManager) RotateCredentialsCallCount() int {
+	fake.rotateCredentialsMutex.RLock()
+	defer fake.rotateCredentialsMutex.RUnlock()
+	return len(fake.rotateCredentialsArgsForCall)
+}
+
+func (fake *FakeServiceAccountManager) RotateCredentialsCalls(stub func(context.Context, models.ServiceBindingCredentials) (map[string]interface{}, map[string]interface{}, error)) {
+	fake.rotateCredentialsMutex.Lock()
+	defer fake.rotateCredentialsMutex.Unlock()
+	fake.RotateCredentialsStub = stub
+}
+
+func (fake *FakeServiceAccountManager) RotateCredentialsArgsForCall(i int) (context.Context, models.ServiceBindingCredentials) {
+	fake.rotateCredentialsMutex.RLock()
+	defer fake.rotateCredentialsMutex.RUnlock()
+	argsForCall := fake.rotateCredentialsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeServiceAccountManager) RotateCredentialsReturns(result1 map[string]interface{}, result2 map[string]interface{}, result3 error) {
+	fake.rotateCredentialsMutex.Lock()
+	defer fake.rotateCredentialsMutex.Unlock()
+	fake.RotateCredentialsStub = nil
+	fake.rotateCredentialsReturns = struct {
+		result1 map[string]interface{}
+		result2 map[string]interface{}
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeServiceAccountManager) RotateCredentialsReturnsOnCall(i int, result1 map[string]interface{}, result2 map[string]interface{}, result3 error) {
+	fake.rotateCredentialsMutex.Lock()
+	defer fake.rotateCredentialsMutex.Unlock()
+	fake.RotateCredentialsStub = nil
+	if fake.rotateCredentialsReturnsOnCall == nil {
+		fake.rotateCredentialsReturnsOnCall = make(map[int]struct {
+			result1 map[string]interface{}
+			result2 map[string]interface{}
+			result3 error
+		})
+	}
+	fake.rotateCredentialsReturnsOnCall[i] = struct {
+		result1 map[string]interface{}
+		result2 map[string]interface{}
+		result3 error
+	}{result1, result2, result3}
+}
+
 func (fake *FakeServiceAccountManager) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.createCredentialsMutex.RLock()
 	defer fake.createCredentialsMutex.RUnlock()
+	fake.credentialsExistMutex.RLock()
+	defer fake.credentialsExistMutex.RUnlock()
 	fake.deleteCredentialsMutex.RLock()
 	defer fake.deleteCredentialsMutex.RUnlock()
+	fake.retireCredentialsMutex.RLock()
+	defer fake.retireCredentialsMutex.RUnlock()
+	fake.rotateCredentialsMutex.RLock()
+	defer fake.rotateCredentialsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -17,7 +17,9 @@ package brokers_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
+	"time"
 
 	. "github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/broker_base"
@@ -48,6 +50,7 @@ var _ = Describe("Brokers", func() {
 		serviceNameToId          map[string]string = make(map[string]string)
 		bqProvisionDetails       brokerapi.ProvisionDetails
 		cloudSqlProvisionDetails brokerapi.ProvisionDetails
+		cloudSqlUpdateDetails    brokerapi.UpdateDetails
 		storageProvisionDetails  brokerapi.ProvisionDetails
 		storageBindDetails       brokerapi.BindDetails
 		storageBadBindDetails    brokerapi.BindDetails
@@ -100,6 +103,7 @@ var _ = Describe("Brokers", func() {
 
 		var someBigQueryPlanId string
 		var someCloudSQLPlanId string
+		var otherCloudSQLPlanId string
 		var someStoragePlanId string
 		for _, service := range registry {
 			catalog, err := service.CatalogEntry()
@@ -111,6 +115,7 @@ var _ = Describe("Brokers", func() {
 			if service.Name == models.CloudsqlMySQLName {
 
 				someCloudSQLPlanId = catalog.Plans[0].ID
+				otherCloudSQLPlanId = catalog.Plans[1].ID
 			}
 			if service.Name == models.StorageName {
 				someStoragePlanId = catalog.Plans[0].ID
@@ -128,9 +133,21 @@ var _ = Describe("Brokers", func() {
 				ProvisionStub: func(ctx context.Context, vc *varcontext.VarContext) (models.ServiceInstanceDetails, error) {
 					return models.ServiceInstanceDetails{OtherDetails: "{\"mynameis\": \"instancename\"}"}, nil
 				},
+				ExistsStub: func(ctx context.Context, instance models.ServiceInstanceDetails) (bool, error) {
+					return true, nil
+				},
+				BindingExistsStub: func(ctx context.Context, instance models.ServiceInstanceDetails, binding models.ServiceBindingCredentials) (bool, error) {
+					return true, nil
+				},
 				BindStub: func(ctx context.Context, vc *varcontext.VarContext) (map[string]interface{}, error) {
 					return map[string]interface{}{"foo": "bar"}, nil
 				},
+				UpdateStub: func(ctx context.Context, instance models.ServiceInstanceDetails, vc *varcontext.VarContext) (models.ServiceInstanceDetails, error) {
+					if async {
+						instance.OperationId = "update-operation"
+					}
+					return instance, nil
+				},
 			}
 
 			serviceBrokerMap[serviceNameToId[service.Name]] = fakeProvider
@@ -149,6 +166,12 @@ var _ = Describe("Brokers", func() {
 			PlanID:    someCloudSQLPlanId,
 		}
 
+		cloudSqlUpdateDetails = brokerapi.UpdateDetails{
+			ServiceID:     serviceNameToId[models.CloudsqlMySQLName],
+			PlanID:        otherCloudSQLPlanId,
+			RawParameters: json.RawMessage(`{"authorized_networks":"10.0.0.0/8"}`),
+		}
+
 		storageProvisionDetails = brokerapi.ProvisionDetails{
 			ServiceID: serviceNameToId[models.StorageName],
 			PlanID:    someStoragePlanId,
@@ -243,6 +266,39 @@ var _ = Describe("Brokers", func() {
 			})
 		})
 
+		Context("when the same instance is provisioned while the provider is running", func() {
+			It("should return an error", func() {
+				bqId := serviceNameToId[models.BigqueryName]
+				var concurrentErr error
+				serviceBrokerMap[bqId].ProvisionStub = func(ctx context.Context, vc *varcontext.VarContext) (models.ServiceInstanceDetails, error) {
+					_, concurrentErr = gcpBroker.Provision(context.Background(), instanceId, bqProvisionDetails, true)
+					return models.ServiceInstanceDetails{}, nil
+				}
+
+				_, err := gcpBroker.Provision(context.Background(), instanceId, bqProvisionDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(concurrentErr).To(HaveOccurred())
+				Expect(serviceBrokerMap[bqId].ProvisionCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the provider fails to provision", func() {
+			It("should release the instance id", func() {
+				bqId := serviceNameToId[models.BigqueryName]
+				serviceBrokerMap[bqId].ProvisionReturns(models.ServiceInstanceDetails{}, errors.New("quota exceeded"))
+
+				_, err := gcpBroker.Provision(context.Background(), instanceId, bqProvisionDetails, true)
+				Expect(err).To(MatchError("quota exceeded"))
+
+				count, err := db_service.CountServiceInstanceDetailsById(context.Background(), instanceId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(count).To(Equal(0))
+
+				serviceBrokerMap[bqId].ProvisionReturns(models.ServiceInstanceDetails{}, nil)
+				_, err = gcpBroker.Provision(context.Background(), instanceId, bqProvisionDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
 	})
 
 	Describe("deprovision", func() {
@@ -278,6 +334,203 @@ var _ = Describe("Brokers", func() {
 		})
 	})
 
+	Describe("concurrent operations", func() {
+		BeforeEach(func() {
+			serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].ProvisionReturns(models.ServiceInstanceDetails{
+				OperationId:   "provision-operation",
+				OperationType: models.ProvisionOperationType,
+			}, nil)
+
+			_, err := gcpBroker.Provision(context.Background(), instanceId, cloudSqlProvisionDetails, true)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when an operation is pending", func() {
+			It("should reject deprovisions", func() {
+				_, err := gcpBroker.Deprovision(context.Background(), instanceId, brokerapi.DeprovisionDetails{
+					ServiceID: serviceNameToId[models.CloudsqlMySQLName],
+				}, true)
+				Expect(err).To(Equal(ErrConcurrentInstanceAccess))
+				Expect(serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].DeprovisionCallCount()).To(Equal(0))
+			})
+
+			It("should reject updates", func() {
+				_, err := gcpBroker.Update(context.Background(), instanceId, cloudSqlUpdateDetails, true)
+				Expect(err).To(Equal(ErrConcurrentInstanceAccess))
+				Expect(serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].UpdateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the pending operation completes", func() {
+			It("should accept new operations", func() {
+				serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].PollInstanceReturns(true, nil)
+				_, err := gcpBroker.LastOperation(context.Background(), instanceId, "provision-operation")
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = gcpBroker.Update(context.Background(), instanceId, cloudSqlUpdateDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the pending operation fails", func() {
+			It("should accept new operations", func() {
+				serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].PollInstanceReturns(false, errors.New("instance failed"))
+				op, err := gcpBroker.LastOperation(context.Background(), instanceId, "provision-operation")
+				Expect(err).To(HaveOccurred())
+				Expect(op.State).To(Equal(brokerapi.Failed))
+
+				_, err = gcpBroker.Deprovision(context.Background(), instanceId, brokerapi.DeprovisionDetails{
+					ServiceID: serviceNameToId[models.CloudsqlMySQLName],
+				}, true)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the pending operation failed without being polled", func() {
+			It("should let deprovisions take over", func() {
+				serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].PollInstanceReturns(false, errors.New("instance failed"))
+
+				_, err := gcpBroker.Deprovision(context.Background(), instanceId, brokerapi.DeprovisionDetails{
+					ServiceID: serviceNameToId[models.CloudsqlMySQLName],
+				}, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].DeprovisionCallCount()).To(Equal(1))
+			})
+
+			It("should still reject updates", func() {
+				serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].PollInstanceReturns(false, errors.New("instance failed"))
+
+				_, err := gcpBroker.Update(context.Background(), instanceId, cloudSqlUpdateDetails, true)
+				Expect(err).To(Equal(ErrConcurrentInstanceAccess))
+			})
+		})
+
+		Context("when the pending operation is stale", func() {
+			It("should let deprovisions take over", func() {
+				stale := time.Now().Add(-models.StaleOperationAge - time.Minute)
+				Expect(db_service.DbConnection.Model(&models.ServiceInstanceDetails{}).Where("id = ?", instanceId).UpdateColumn("updated_at", stale).Error).NotTo(HaveOccurred())
+
+				_, err := gcpBroker.Deprovision(context.Background(), instanceId, brokerapi.DeprovisionDetails{
+					ServiceID: serviceNameToId[models.CloudsqlMySQLName],
+				}, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].DeprovisionCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when an operator releases the instance", func() {
+			It("should accept new operations", func() {
+				Expect(gcpBroker.ReleaseInstance(context.Background(), instanceId)).NotTo(HaveOccurred())
+
+				instance, err := db_service.GetServiceInstanceDetailsById(context.Background(), instanceId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(instance.OperationType).To(Equal(models.ClearOperationType))
+				Expect(instance.OperationId).To(BeEmpty())
+
+				_, err = gcpBroker.Update(context.Background(), instanceId, cloudSqlUpdateDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the instance changes while the provider is running", func() {
+			It("should return a concurrency error", func() {
+				serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].PollInstanceReturns(true, nil)
+				_, err := gcpBroker.LastOperation(context.Background(), instanceId, "provision-operation")
+				Expect(err).NotTo(HaveOccurred())
+
+				serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].UpdateStub = func(ctx context.Context, instance models.ServiceInstanceDetails, vc *varcontext.VarContext) (models.ServiceInstanceDetails, error) {
+					// simulate another broker process changing the record
+					stored, err := db_service.GetServiceInstanceDetailsById(context.Background(), instanceId)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(db_service.SaveServiceInstanceDetails(context.Background(), stored)).NotTo(HaveOccurred())
+
+					instance.OperationId = "update-operation"
+					return instance, nil
+				}
+
+				_, err = gcpBroker.Update(context.Background(), instanceId, cloudSqlUpdateDetails, true)
+				Expect(err).To(Equal(ErrConcurrentInstanceAccess))
+			})
+		})
+	})
+
+	Describe("update", func() {
+		Context("when the instance doesn't exist", func() {
+			It("should return an error", func() {
+				_, err := gcpBroker.Update(context.Background(), instanceId, cloudSqlUpdateDetails, true)
+				Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+			})
+		})
+
+		Context("when the service doesn't allow plan changes", func() {
+			It("should return an error", func() {
+				_, err := gcpBroker.Provision(context.Background(), instanceId, storageProvisionDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = gcpBroker.Update(context.Background(), instanceId, brokerapi.UpdateDetails{
+					ServiceID: serviceNameToId[models.StorageName],
+					PlanID:    "some-other-plan",
+				}, true)
+				Expect(err).To(Equal(brokerapi.ErrPlanChangeNotSupported))
+				Expect(serviceBrokerMap[serviceNameToId[models.StorageName]].UpdateCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when async updating isn't allowed but the service requires it", func() {
+			It("should return an error", func() {
+				_, err := gcpBroker.Provision(context.Background(), instanceId, cloudSqlProvisionDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = gcpBroker.Update(context.Background(), instanceId, cloudSqlUpdateDetails, false)
+				Expect(err).To(Equal(brokerapi.ErrAsyncRequired))
+			})
+		})
+
+		Context("when an asynchronous service is updated", func() {
+			BeforeEach(func() {
+				cloudSqlProvisionDetails.RawParameters = json.RawMessage(`{"database_name":"foo"}`)
+				_, err := gcpBroker.Provision(context.Background(), instanceId, cloudSqlProvisionDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should call the provider update and track the operation", func() {
+				resp, err := gcpBroker.Update(context.Background(), instanceId, cloudSqlUpdateDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(resp.IsAsync).To(BeTrue())
+				Expect(resp.OperationData).To(Equal("update-operation"))
+				Expect(serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].UpdateCallCount()).To(Equal(1))
+
+				instance, err := db_service.GetServiceInstanceDetailsById(context.Background(), instanceId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(instance.PlanId).To(Equal(cloudSqlUpdateDetails.PlanID))
+				Expect(instance.OperationType).To(Equal(models.UpdateOperationType))
+				Expect(instance.OperationId).To(Equal("update-operation"))
+			})
+
+			It("should merge the update parameters with the original ones", func() {
+				_, err := gcpBroker.Update(context.Background(), instanceId, cloudSqlUpdateDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+
+				pr, err := db_service.GetProvisionRequestDetailsByServiceInstanceId(context.Background(), instanceId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pr.RequestDetails).To(MatchJSON(`{"database_name":"foo","authorized_networks":"10.0.0.0/8"}`))
+			})
+
+			It("should clear the operation once it completes", func() {
+				_, err := gcpBroker.Update(context.Background(), instanceId, cloudSqlUpdateDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+				serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].PollInstanceReturns(true, nil)
+
+				op, err := gcpBroker.LastOperation(context.Background(), instanceId, "update-operation")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(op.State).To(Equal(brokerapi.Succeeded))
+
+				instance, err := db_service.GetServiceInstanceDetailsById(context.Background(), instanceId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(instance.OperationType).To(Equal(models.ClearOperationType))
+				Expect(instance.PlanId).To(Equal(cloudSqlUpdateDetails.PlanID))
+			})
+		})
+	})
+
 	Describe("bind", func() {
 		Context("when bind is called on storage", func() {
 			It("it should call storage bind", func() {
@@ -345,6 +598,581 @@ var _ = Describe("Brokers", func() {
 		})
 	})
 
+	Describe("async bindings", func() {
+		var storageProvider *brokerfakes.FakeServiceProvider
+
+		BeforeEach(func() {
+			storageProvider = serviceBrokerMap[serviceNameToId[models.StorageName]]
+			storageProvider.BindsAsyncReturns(true)
+			storageProvider.UpdateBindingDetailsStub = func(ctx context.Context, instance models.ServiceInstanceDetails, binding *models.ServiceBindingCredentials) error {
+				binding.OtherDetails = `{"foo":"baz"}`
+				return nil
+			}
+
+			_, err := gcpBroker.Provision(context.Background(), instanceId, storageProvisionDetails, true)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when the platform accepts incomplete bindings", func() {
+			It("should track the bind until it completes", func() {
+				spec, err := gcpBroker.BindAsync(context.Background(), instanceId, bindingId, storageBindDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(spec.IsAsync).To(BeTrue())
+				Expect(spec.OperationData).To(Equal(models.BindOperationType))
+
+				_, err = gcpBroker.GetBinding(context.Background(), instanceId, bindingId)
+				Expect(err).To(Equal(brokerapi.ErrBindingDoesNotExist))
+
+				op, err := gcpBroker.LastBindingOperation(context.Background(), instanceId, bindingId, spec.OperationData)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(op.State).To(Equal(brokerapi.InProgress))
+
+				storageProvider.PollBindingReturns(true, nil)
+				op, err = gcpBroker.LastBindingOperation(context.Background(), instanceId, bindingId, spec.OperationData)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(op.State).To(Equal(brokerapi.Succeeded))
+				Expect(storageProvider.UpdateBindingDetailsCallCount()).To(Equal(1))
+
+				binding, err := db_service.GetServiceBindingCredentialsByServiceInstanceIdAndBindingId(context.Background(), instanceId, bindingId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(binding.OperationType).To(Equal(models.ClearOperationType))
+				Expect(binding.OtherDetails).To(MatchJSON(`{"foo":"baz"}`))
+
+				_, err = gcpBroker.GetBinding(context.Background(), instanceId, bindingId)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should delete the binding once the unbind completes", func() {
+				storageProvider.PollBindingReturns(true, nil)
+				_, err := gcpBroker.BindAsync(context.Background(), instanceId, bindingId, storageBindDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = gcpBroker.LastBindingOperation(context.Background(), instanceId, bindingId, models.BindOperationType)
+				Expect(err).NotTo(HaveOccurred())
+
+				spec, err := gcpBroker.UnbindAsync(context.Background(), instanceId, bindingId, storageUnbindDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(spec.IsAsync).To(BeTrue())
+				Expect(spec.OperationData).To(Equal(models.UnbindOperationType))
+
+				op, err := gcpBroker.LastBindingOperation(context.Background(), instanceId, bindingId, spec.OperationData)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(op.State).To(Equal(brokerapi.Succeeded))
+
+				count, err := db_service.CountServiceBindingCredentialsByServiceInstanceIdAndBindingId(context.Background(), instanceId, bindingId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(count).To(Equal(0))
+			})
+
+			It("should reject an unbind while the bind is pending", func() {
+				_, err := gcpBroker.BindAsync(context.Background(), instanceId, bindingId, storageBindDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = gcpBroker.UnbindAsync(context.Background(), instanceId, bindingId, storageUnbindDetails, true)
+				Expect(err).To(Equal(ErrConcurrentBindingAccess))
+				Expect(storageProvider.UnbindCallCount()).To(Equal(0))
+			})
+
+			It("should release the binding when the operation fails", func() {
+				_, err := gcpBroker.BindAsync(context.Background(), instanceId, bindingId, storageBindDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+
+				storageProvider.PollBindingReturns(true, errors.New("bind failed"))
+				op, err := gcpBroker.LastBindingOperation(context.Background(), instanceId, bindingId, models.BindOperationType)
+				Expect(err).To(HaveOccurred())
+				Expect(op.State).To(Equal(brokerapi.Failed))
+
+				binding, err := db_service.GetServiceBindingCredentialsByServiceInstanceIdAndBindingId(context.Background(), instanceId, bindingId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(binding.OperationType).To(Equal(models.ClearOperationType))
+			})
+		})
+
+		Context("when the platform doesn't accept incomplete bindings", func() {
+			It("should wait for the bind to complete", func() {
+				storageProvider.PollBindingReturns(true, nil)
+
+				_, err := gcpBroker.Bind(context.Background(), instanceId, bindingId, storageBindDetails)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(storageProvider.UpdateBindingDetailsCallCount()).To(Equal(1))
+
+				_, bindRecord, _ := storageProvider.BuildInstanceCredentialsArgsForCall(0)
+				Expect(bindRecord.OtherDetails).To(MatchJSON(`{"foo":"baz"}`))
+			})
+
+			It("should forget the binding if the bind fails", func() {
+				storageProvider.PollBindingReturns(true, errors.New("bind failed"))
+
+				_, err := gcpBroker.Bind(context.Background(), instanceId, bindingId, storageBindDetails)
+				Expect(err).To(HaveOccurred())
+
+				count, err := db_service.CountServiceBindingCredentialsByServiceInstanceIdAndBindingId(context.Background(), instanceId, bindingId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(count).To(Equal(0))
+			})
+
+			It("should wait for the unbind to complete", func() {
+				storageProvider.PollBindingReturns(true, nil)
+				_, err := gcpBroker.Bind(context.Background(), instanceId, bindingId, storageBindDetails)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = gcpBroker.Unbind(context.Background(), instanceId, bindingId, storageUnbindDetails)
+				Expect(err).NotTo(HaveOccurred())
+
+				count, err := db_service.CountServiceBindingCredentialsByServiceInstanceIdAndBindingId(context.Background(), instanceId, bindingId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(count).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("operation history", func() {
+		listHistory := func() []models.OperationHistory {
+			history, err := db_service.ListOperationHistoryByServiceInstanceId(context.Background(), instanceId)
+			Expect(err).NotTo(HaveOccurred())
+			return history
+		}
+
+		It("should record each request with its identity and redacted parameters", func() {
+			ctx := broker.WithOriginatingIdentity(context.Background(), &broker.OriginatingIdentity{
+				Platform: broker.CloudFoundryPlatform,
+				Value:    map[string]interface{}{"user_id": "abc"},
+			})
+			storageProvisionDetails.OrganizationGUID = "org-guid"
+			storageProvisionDetails.RawParameters = json.RawMessage(`{"name":"bucket","admin_password":"hunter2"}`)
+
+			_, err := gcpBroker.Provision(ctx, instanceId, storageProvisionDetails, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			provisionRecord, err := db_service.GetProvisionRequestDetailsByServiceInstanceId(context.Background(), instanceId)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(provisionRecord.OriginatingIdentity).To(MatchJSON(`{"platform":"cloudfoundry","value":{"user_id":"abc"}}`))
+
+			_, err = gcpBroker.Bind(ctx, instanceId, bindingId, storageBindDetails)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = gcpBroker.Deprovision(ctx, instanceId, brokerapi.DeprovisionDetails{ServiceID: storageProvisionDetails.ServiceID}, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			history := listHistory()
+			Expect(history).To(HaveLen(3))
+			Expect(history[0].Request).To(Equal(models.ProvisionOperationType))
+			Expect(history[0].Result).To(Equal(string(brokerapi.Succeeded)))
+			Expect(history[0].OrganizationGuid).To(Equal("org-guid"))
+			Expect(history[0].OriginatingIdentity).To(MatchJSON(`{"platform":"cloudfoundry","value":{"user_id":"abc"}}`))
+			Expect(history[0].Parameters).To(MatchJSON(`{"name":"bucket","admin_password":"<redacted>"}`))
+
+			Expect(history[1].Request).To(Equal(models.BindOperationType))
+			Expect(history[1].BindingId).To(Equal(bindingId))
+			Expect(history[1].OrganizationGuid).To(Equal("org-guid"))
+
+			Expect(history[2].Request).To(Equal(models.DeprovisionOperationType))
+		})
+
+		It("should record failed requests", func() {
+			_, err := gcpBroker.Provision(context.Background(), instanceId, storageProvisionDetails, true)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = gcpBroker.Provision(context.Background(), instanceId, storageProvisionDetails, true)
+			Expect(err).To(Equal(brokerapi.ErrInstanceAlreadyExists))
+
+			history := listHistory()
+			Expect(history).To(HaveLen(2))
+			Expect(history[1].Result).To(Equal(string(brokerapi.Failed)))
+			Expect(history[1].Message).To(Equal(brokerapi.ErrInstanceAlreadyExists.Error()))
+		})
+
+		It("should only record polls that saw the operation finish", func() {
+			serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].ProvisionReturns(models.ServiceInstanceDetails{
+				OperationId:   "provision-operation",
+				OperationType: models.ProvisionOperationType,
+			}, nil)
+
+			_, err := gcpBroker.Provision(context.Background(), instanceId, cloudSqlProvisionDetails, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = gcpBroker.LastOperation(context.Background(), instanceId, "provision-operation")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(listHistory()).To(HaveLen(1))
+
+			serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].PollInstanceReturns(true, nil)
+			_, err = gcpBroker.LastOperation(context.Background(), instanceId, "provision-operation")
+			Expect(err).NotTo(HaveOccurred())
+
+			history := listHistory()
+			Expect(history).To(HaveLen(2))
+			Expect(history[0].Result).To(Equal(string(brokerapi.InProgress)))
+			Expect(history[1].Request).To(Equal(models.LastOperationRequest))
+			Expect(history[1].OperationType).To(Equal(models.ProvisionOperationType))
+			Expect(history[1].Result).To(Equal(string(brokerapi.Succeeded)))
+		})
+	})
+
+	Describe("get instance", func() {
+		Context("when the instance exists", func() {
+			It("should return the service, plan and parameters", func() {
+				cloudSqlProvisionDetails.RawParameters = json.RawMessage(`{"database_name":"foo"}`)
+				_, err := gcpBroker.Provision(context.Background(), instanceId, cloudSqlProvisionDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+
+				instance, err := gcpBroker.GetInstance(context.Background(), instanceId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(instance.ServiceID).To(Equal(cloudSqlProvisionDetails.ServiceID))
+				Expect(instance.PlanID).To(Equal(cloudSqlProvisionDetails.PlanID))
+				Expect([]byte(instance.Parameters)).To(MatchJSON(`{"database_name":"foo"}`))
+			})
+		})
+
+		Context("when the instance doesn't exist", func() {
+			It("should return ErrInstanceDoesNotExist", func() {
+				_, err := gcpBroker.GetInstance(context.Background(), "does-not-exist")
+				Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+			})
+		})
+
+		Context("when the instance is still being provisioned", func() {
+			It("should return ErrInstanceDoesNotExist", func() {
+				serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].ProvisionReturns(models.ServiceInstanceDetails{
+					OperationId:   "provision-operation",
+					OperationType: models.ProvisionOperationType,
+				}, nil)
+				_, err := gcpBroker.Provision(context.Background(), instanceId, cloudSqlProvisionDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = gcpBroker.GetInstance(context.Background(), instanceId)
+				Expect(err).To(Equal(brokerapi.ErrInstanceDoesNotExist))
+			})
+		})
+
+		Context("when the instance is being updated", func() {
+			It("should return a concurrency error", func() {
+				_, err := gcpBroker.Provision(context.Background(), instanceId, cloudSqlProvisionDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = gcpBroker.Update(context.Background(), instanceId, cloudSqlUpdateDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = gcpBroker.GetInstance(context.Background(), instanceId)
+				Expect(err).To(Equal(ErrConcurrentInstanceAccess))
+			})
+		})
+	})
+
+	Describe("get binding", func() {
+		Context("when the binding exists", func() {
+			It("should rebuild the credentials", func() {
+				serviceBrokerMap[serviceNameToId[models.StorageName]].BuildInstanceCredentialsReturns(map[string]interface{}{"bucket_name": "foo"}, nil)
+				_, err = gcpBroker.Provision(context.Background(), instanceId, storageProvisionDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = gcpBroker.Bind(context.Background(), instanceId, bindingId, storageBindDetails)
+				Expect(err).NotTo(HaveOccurred())
+
+				binding, err := gcpBroker.GetBinding(context.Background(), instanceId, bindingId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(binding.Credentials).To(Equal(map[string]interface{}{"bucket_name": "foo"}))
+				Expect(serviceBrokerMap[serviceNameToId[models.StorageName]].BindCallCount()).To(Equal(1))
+				Expect(serviceBrokerMap[serviceNameToId[models.StorageName]].BuildInstanceCredentialsCallCount()).To(Equal(2))
+			})
+		})
+
+		Context("when the binding doesn't exist", func() {
+			It("should return ErrBindingDoesNotExist", func() {
+				_, err = gcpBroker.Provision(context.Background(), instanceId, storageProvisionDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err := gcpBroker.GetBinding(context.Background(), instanceId, "does-not-exist")
+				Expect(err).To(Equal(brokerapi.ErrBindingDoesNotExist))
+			})
+		})
+	})
+
+	Describe("reconcile", func() {
+		var storageProvider *brokerfakes.FakeServiceProvider
+
+		BeforeEach(func() {
+			storageProvider = serviceBrokerMap[serviceNameToId[models.StorageName]]
+			_, err := gcpBroker.Provision(context.Background(), instanceId, storageProvisionDetails, true)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = gcpBroker.Bind(context.Background(), instanceId, bindingId, storageBindDetails)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when the database matches GCP", func() {
+			It("should report nothing", func() {
+				report, err := gcpBroker.Reconcile(context.Background(), ReconcileOptions{Cleanup: true})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.DanglingInstances).To(BeEmpty())
+				Expect(report.DanglingBindings).To(BeEmpty())
+				Expect(report.OrphanedResources).To(BeEmpty())
+				Expect(report.Errors).To(BeEmpty())
+			})
+		})
+
+		Context("when the resources of an instance were deleted", func() {
+			BeforeEach(func() {
+				storageProvider.ExistsReturns(false, nil)
+			})
+
+			It("should report the instance without changing it", func() {
+				report, err := gcpBroker.Reconcile(context.Background(), ReconcileOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.DanglingInstances).To(Equal([]ReconcileItem{{
+					ServiceId:  serviceNameToId[models.StorageName],
+					InstanceId: instanceId,
+				}}))
+
+				count, err := db_service.CountServiceInstanceDetailsById(context.Background(), instanceId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(count).To(Equal(1))
+			})
+
+			It("should delete the records of the instance and its bindings when cleaning up", func() {
+				report, err := gcpBroker.Reconcile(context.Background(), ReconcileOptions{Cleanup: true})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.DanglingInstances).To(HaveLen(1))
+				Expect(report.DanglingInstances[0].CleanedUp).To(BeTrue())
+				Expect(storageProvider.UnbindCallCount()).To(Equal(0))
+
+				count, err := db_service.CountServiceInstanceDetailsById(context.Background(), instanceId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(count).To(Equal(0))
+
+				count, err = db_service.CountProvisionRequestDetailsByServiceInstanceId(context.Background(), instanceId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(count).To(Equal(0))
+
+				count, err = db_service.CountServiceBindingCredentialsByServiceInstanceIdAndBindingId(context.Background(), instanceId, bindingId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(count).To(Equal(0))
+			})
+		})
+
+		Context("when the resources of a binding were deleted", func() {
+			It("should delete the binding when cleaning up", func() {
+				storageProvider.BindingExistsReturns(false, nil)
+
+				report, err := gcpBroker.Reconcile(context.Background(), ReconcileOptions{Cleanup: true})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.DanglingInstances).To(BeEmpty())
+				Expect(report.DanglingBindings).To(Equal([]ReconcileItem{{
+					ServiceId:  serviceNameToId[models.StorageName],
+					InstanceId: instanceId,
+					BindingId:  bindingId,
+					CleanedUp:  true,
+				}}))
+
+				count, err := db_service.CountServiceBindingCredentialsByServiceInstanceIdAndBindingId(context.Background(), instanceId, bindingId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(count).To(Equal(0))
+			})
+		})
+
+		Context("when a labeled resource has no instance", func() {
+			deprovisionOrphans := ReconcileOptions{Cleanup: true, DeprovisionOrphans: true, MinOrphanAge: time.Hour}
+
+			BeforeEach(func() {
+				storageProvider.DescribeResourcesReturns([]models.ServiceInstanceDetails{
+					{ID: instanceId, Name: "known-bucket"},
+					{ID: "orphan", Name: "orphaned-bucket", CreatedAt: time.Now().Add(-2 * time.Hour)},
+				}, nil)
+			})
+
+			It("should report the resource", func() {
+				report, err := gcpBroker.Reconcile(context.Background(), ReconcileOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.OrphanedResources).To(Equal([]ReconcileItem{{
+					ServiceId:  serviceNameToId[models.StorageName],
+					InstanceId: "orphan",
+					Name:       "orphaned-bucket",
+				}}))
+				Expect(storageProvider.DeprovisionCallCount()).To(Equal(0))
+			})
+
+			It("should only report the resource when cleaning up records", func() {
+				report, err := gcpBroker.Reconcile(context.Background(), ReconcileOptions{Cleanup: true})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.OrphanedResources).To(HaveLen(1))
+				Expect(report.OrphanedResources[0].CleanedUp).To(BeFalse())
+				Expect(storageProvider.DeprovisionCallCount()).To(Equal(0))
+			})
+
+			It("should deprovision the resource when deprovisioning orphans", func() {
+				report, err := gcpBroker.Reconcile(context.Background(), deprovisionOrphans)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.OrphanedResources).To(HaveLen(1))
+				Expect(report.OrphanedResources[0].CleanedUp).To(BeTrue())
+				Expect(storageProvider.DeprovisionCallCount()).To(Equal(1))
+
+				_, resource, _ := storageProvider.DeprovisionArgsForCall(0)
+				Expect(resource.Name).To(Equal("orphaned-bucket"))
+			})
+
+			It("should skip resources that are too new", func() {
+				storageProvider.DescribeResourcesReturns([]models.ServiceInstanceDetails{
+					{ID: "orphan", Name: "orphaned-bucket", CreatedAt: time.Now().Add(-time.Minute)},
+					{ID: "unknown-age", Name: "unknown-age-bucket"},
+				}, nil)
+
+				report, err := gcpBroker.Reconcile(context.Background(), deprovisionOrphans)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.OrphanedResources).To(HaveLen(2))
+				Expect(report.OrphanedResources[0].Skipped).To(ContainSubstring("less than 1h0m0s old"))
+				Expect(report.OrphanedResources[1].Skipped).To(ContainSubstring("age of the resource is unknown"))
+				Expect(storageProvider.DeprovisionCallCount()).To(Equal(0))
+			})
+
+			It("should skip resources whose instance was saved while reconciling", func() {
+				storageProvider.DescribeResourcesStub = func(ctx context.Context) ([]models.ServiceInstanceDetails, error) {
+					Expect(db_service.CreateServiceInstanceDetails(ctx, &models.ServiceInstanceDetails{ID: "orphan"})).NotTo(HaveOccurred())
+					return []models.ServiceInstanceDetails{
+						{ID: "orphan", Name: "orphaned-bucket", CreatedAt: time.Now().Add(-2 * time.Hour)},
+					}, nil
+				}
+
+				report, err := gcpBroker.Reconcile(context.Background(), deprovisionOrphans)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.OrphanedResources).To(HaveLen(1))
+				Expect(report.OrphanedResources[0].Skipped).To(Equal("the resource has an instance record"))
+				Expect(storageProvider.DeprovisionCallCount()).To(Equal(0))
+			})
+
+			It("should skip resources of deleted instances", func() {
+				Expect(db_service.CreateServiceInstanceDetails(context.Background(), &models.ServiceInstanceDetails{ID: "orphan"})).NotTo(HaveOccurred())
+				Expect(db_service.DeleteServiceInstanceDetailsById(context.Background(), "orphan")).NotTo(HaveOccurred())
+
+				report, err := gcpBroker.Reconcile(context.Background(), deprovisionOrphans)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.OrphanedResources).To(HaveLen(1))
+				Expect(report.OrphanedResources[0].Skipped).To(Equal("the resource has an instance record"))
+				Expect(storageProvider.DeprovisionCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when an operation is pending on the instance", func() {
+			It("should skip the instance", func() {
+				instance, err := db_service.GetServiceInstanceDetailsById(context.Background(), instanceId)
+				Expect(err).NotTo(HaveOccurred())
+				instance.OperationType = models.UpdateOperationType
+				Expect(db_service.SaveServiceInstanceDetails(context.Background(), instance)).NotTo(HaveOccurred())
+				storageProvider.ExistsReturns(false, nil)
+
+				report, err := gcpBroker.Reconcile(context.Background(), ReconcileOptions{Cleanup: true})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.DanglingInstances).To(BeEmpty())
+				Expect(storageProvider.ExistsCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("rotate credentials", func() {
+		var storageProvider *brokerfakes.FakeServiceProvider
+
+		BeforeEach(func() {
+			storageProvider = serviceBrokerMap[serviceNameToId[models.StorageName]]
+			storageProvider.RotateCredentialsStub = func(ctx context.Context, instance models.ServiceInstanceDetails, binding *models.ServiceBindingCredentials) (map[string]interface{}, error) {
+				binding.OtherDetails = `{"foo":"rotated"}`
+				return map[string]interface{}{"key": "old"}, nil
+			}
+
+			_, err := gcpBroker.Provision(context.Background(), instanceId, storageProvisionDetails, true)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = gcpBroker.Bind(context.Background(), instanceId, bindingId, storageBindDetails)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return the new credentials when the binding is fetched", func() {
+			report, err := gcpBroker.RotateCredentials(context.Background(), RotationOptions{GracePeriod: time.Hour})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Errors).To(BeEmpty())
+			Expect(report.Rotated).To(HaveLen(1))
+			Expect(report.Rotated[0].BindingId).To(Equal(bindingId))
+			Expect(report.Rotated[0].RetireAt).NotTo(BeNil())
+			Expect(report.Retired).To(BeEmpty())
+			Expect(storageProvider.RetireCredentialsCallCount()).To(Equal(0))
+
+			_, err = gcpBroker.GetBinding(context.Background(), instanceId, bindingId)
+			Expect(err).NotTo(HaveOccurred())
+			_, binding, _ := storageProvider.BuildInstanceCredentialsArgsForCall(storageProvider.BuildInstanceCredentialsCallCount() - 1)
+			Expect(binding.OtherDetails).To(Equal(`{"foo":"rotated"}`))
+			Expect(binding.CredentialsRotatedAt).NotTo(BeNil())
+		})
+
+		It("should retire the old credentials after the grace period", func() {
+			report, err := gcpBroker.RotateCredentials(context.Background(), RotationOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Errors).To(BeEmpty())
+			Expect(report.Retired).To(Equal([]RotationItem{{
+				ServiceId:  serviceNameToId[models.StorageName],
+				InstanceId: instanceId,
+				BindingId:  bindingId,
+			}}))
+			Expect(storageProvider.RetireCredentialsCallCount()).To(Equal(1))
+
+			_, _, retired := storageProvider.RetireCredentialsArgsForCall(0)
+			Expect(retired).To(Equal(map[string]interface{}{"key": "old"}))
+
+			due, err := db_service.ListRetiredCredentialsDue(context.Background(), time.Now())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(due).To(BeEmpty())
+		})
+
+		It("should lock the binding while it's rotated", func() {
+			storageProvider.RotateCredentialsStub = func(ctx context.Context, instance models.ServiceInstanceDetails, binding *models.ServiceBindingCredentials) (map[string]interface{}, error) {
+				Expect(gcpBroker.Unbind(ctx, instanceId, bindingId, storageUnbindDetails)).To(Equal(ErrConcurrentBindingAccess))
+				return nil, nil
+			}
+
+			report, err := gcpBroker.RotateCredentials(context.Background(), RotationOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Rotated).To(HaveLen(1))
+			Expect(gcpBroker.Unbind(context.Background(), instanceId, bindingId, storageUnbindDetails)).NotTo(HaveOccurred())
+		})
+
+		Context("when the binding is deleted while it's rotated", func() {
+			It("should report the new credentials instead of saving the binding again", func() {
+				storageProvider.RotateCredentialsStub = func(ctx context.Context, instance models.ServiceInstanceDetails, binding *models.ServiceBindingCredentials) (map[string]interface{}, error) {
+					Expect(db_service.DeleteServiceBindingCredentialsByServiceInstanceIdAndBindingId(ctx, instanceId, bindingId)).NotTo(HaveOccurred())
+					binding.OtherDetails = `{"foo":"rotated"}`
+					return map[string]interface{}{"key": "old"}, nil
+				}
+
+				report, err := gcpBroker.RotateCredentials(context.Background(), RotationOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Rotated).To(BeEmpty())
+				Expect(report.Errors).To(HaveLen(1))
+				Expect(report.Errors[0]).To(ContainSubstring("deleted while it was rotated"))
+
+				_, err = db_service.GetServiceBindingCredentialsByServiceInstanceIdAndBindingId(context.Background(), instanceId, bindingId)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Context("when the credentials are newer than the max age", func() {
+			It("should leave them alone", func() {
+				report, err := gcpBroker.RotateCredentials(context.Background(), RotationOptions{MaxAge: time.Hour})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Rotated).To(BeEmpty())
+				Expect(report.Skipped).To(BeEmpty())
+				Expect(storageProvider.RotateCredentialsCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the service doesn't support rotation", func() {
+			It("should skip the binding and release it", func() {
+				storageProvider.RotateCredentialsStub = nil
+				storageProvider.RotateCredentialsReturns(nil, broker.ErrCredentialRotationNotSupported)
+
+				report, err := gcpBroker.RotateCredentials(context.Background(), RotationOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Errors).To(BeEmpty())
+				Expect(report.Rotated).To(BeEmpty())
+				Expect(report.Skipped).To(HaveLen(1))
+				Expect(report.Skipped[0].Reason).To(Equal(broker.ErrCredentialRotationNotSupported.Error()))
+
+				binding, err := db_service.GetServiceBindingCredentialsByServiceInstanceIdAndBindingId(context.Background(), instanceId, bindingId)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(binding.OperationType).To(Equal(models.ClearOperationType))
+				Expect(binding.CredentialsRotatedAt).To(BeNil())
+			})
+		})
+	})
+
 	Describe("lastOperation", func() {
 		Context("when last operation is called on a service that doesn't exist", func() {
 			It("should throw an error", func() {
@@ -370,6 +1198,18 @@ var _ = Describe("Brokers", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].PollInstanceCallCount()).To(Equal(1))
 			})
+
+			It("should describe the pending operation", func() {
+				serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].PollInstanceReturns(false, nil)
+				serviceBrokerMap[serviceNameToId[models.CloudsqlMySQLName]].DescribeOperationReturns("provision queued", nil)
+
+				_, err = gcpBroker.Provision(context.Background(), instanceId, cloudSqlProvisionDetails, true)
+				Expect(err).NotTo(HaveOccurred())
+				op, err := gcpBroker.LastOperation(context.Background(), instanceId, "operationtoken")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(op.State).To(Equal(brokerapi.InProgress))
+				Expect(op.Description).To(Equal("provision queued"))
+			})
 		})
 
 	})
@@ -449,6 +1289,27 @@ var _ = Describe("AccountManagers", func() {
 		})
 	})
 
+	Describe("rotate credentials", func() {
+		Context("when rotate is called on an iam-style broker", func() {
+			It("should replace the key in the binding and return the old one", func() {
+				accountManager.RotateCredentialsReturns(
+					map[string]interface{}{"PrivateKeyData": "new-key"},
+					map[string]interface{}{"ServiceAccountKey": "old-key"},
+					nil)
+
+				binding := models.ServiceBindingCredentials{OtherDetails: `{"Email":"sa@example.com","PrivateKeyData":"old-key-data"}`}
+				retired, err := iamStyleBroker.RotateCredentials(context.Background(), models.ServiceInstanceDetails{}, &binding)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(retired).To(Equal(map[string]interface{}{"ServiceAccountKey": "old-key"}))
+				Expect(binding.OtherDetails).To(MatchJSON(`{"Email":"sa@example.com","PrivateKeyData":"new-key"}`))
+
+				Expect(iamStyleBroker.RetireCredentials(context.Background(), models.ServiceInstanceDetails{}, retired)).NotTo(HaveOccurred())
+				_, retiredArg := accountManager.RetireCredentialsArgsForCall(0)
+				Expect(retiredArg).To(Equal(retired))
+			})
+		})
+	})
+
 	Describe("async", func() {
 		Context("with a pubsub broker", func() {
 			It("should return false", func() {
@@ -0,0 +1,223 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/varcontext"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/utils"
+	"github.com/jinzhu/gorm"
+	googlecloudsql "google.golang.org/api/sqladmin/v1beta4"
+)
+
+// The operations an update can run. CloudSQL instances only run one operation
+// at a time so each update runs exactly one of them.
+const (
+	patchSettingsAction = "change the settings"
+	createBackupAction  = "take a backup"
+	restoreBackupAction = "restore a backup"
+)
+
+// updateAction picks the operation an update runs. Updates that ask for more
+// than one are rejected rather than running only one of them, because the
+// parameters of an update are kept and the skipped operation would run on the
+// next unrelated update instead.
+func updateAction(ii InstanceInformation, backup, restoreBackupId string, settingsChanged bool) (string, error) {
+	var actions []string
+	if settingsChanged {
+		actions = append(actions, patchSettingsAction)
+	}
+
+	if backup != "" && backup != ii.LastBackup {
+		actions = append(actions, createBackupAction)
+	}
+
+	if restoreBackupId != "" && restoreBackupId != ii.LastRestoredBackupId {
+		actions = append(actions, restoreBackupAction)
+	}
+
+	switch len(actions) {
+	case 0:
+		return patchSettingsAction, nil
+	case 1:
+		return actions[0], nil
+	default:
+		return "", fmt.Errorf("an update can't %s at the same time, make them separate updates", strings.Join(actions, " and "))
+	}
+}
+
+// cloneInstance starts creating the instance as a clone of the broker-managed
+// instance with the GUID in source_instance_id. The clone uses the database
+// of its source.
+func (b *CloudSQLBroker) cloneInstance(ctx context.Context, client *googlecloudsql.Service, vars *varcontext.VarContext, destinationName string, ii *InstanceInformation) (*googlecloudsql.Operation, error) {
+	sourceId := vars.GetString("source_instance_id")
+	source, err := db_service.GetServiceInstanceDetailsById(ctx, sourceId)
+	if err == gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("the source instance %q doesn't exist", sourceId)
+	} else if err != nil {
+		return nil, fmt.Errorf("Error getting the source instance: %s", err)
+	}
+
+	request, err := createCloneRequest(vars, *source, destinationName)
+	if err != nil {
+		return nil, err
+	}
+
+	var sourceInfo InstanceInformation
+	if err := source.GetOtherDetails(&sourceInfo); err != nil {
+		return nil, err
+	}
+
+	if sourceInfo.DatabaseName != "" {
+		ii.DatabaseName = sourceInfo.DatabaseName
+	}
+	ii.ClonedFrom = source.Name
+
+	op, err := client.Instances.Clone(b.ProjectId, source.Name, request).Do()
+	if err != nil {
+		return nil, fmt.Errorf("Error cloning CloudSQL instance: %s", err)
+	}
+
+	return op, nil
+}
+
+// createCloneRequest checks the source instance can be cloned by the
+// requester and creates the request to clone it.
+func createCloneRequest(vars *varcontext.VarContext, source models.ServiceInstanceDetails, destinationName string) (*googlecloudsql.InstancesCloneRequest, error) {
+	pointInTime := vars.GetString("point_in_time")
+	serviceId := vars.GetString("service_id")
+	planId := vars.GetString("plan_id")
+	organizationGuid := vars.GetString("organization_guid")
+	spaceGuid := vars.GetString("space_guid")
+	if err := vars.Error(); err != nil {
+		return nil, err
+	}
+
+	if source.ServiceId != serviceId || source.PlanId != planId {
+		return nil, fmt.Errorf("the source instance %q must be of the same service and plan", source.ID)
+	}
+
+	if source.OrganizationGuid != organizationGuid || source.SpaceGuid != spaceGuid {
+		return nil, fmt.Errorf("the source instance %q must be in the same org and space", source.ID)
+	}
+
+	cloneContext := &googlecloudsql.CloneContext{DestinationInstanceName: destinationName}
+	if pointInTime != "" {
+		timestamp, err := time.Parse(time.RFC3339, pointInTime)
+		if err != nil {
+			return nil, fmt.Errorf("point_in_time must be in RFC 3339 format: %s", err)
+		}
+
+		cloneContext.PitrTimestampMs = timestamp.UnixNano() / int64(time.Millisecond)
+	}
+
+	return &googlecloudsql.InstancesCloneRequest{CloneContext: cloneContext}, nil
+}
+
+// relabelClone replaces the instance ID label a clone copied from its source
+// with its own so it isn't mistaken for the source. Like the read replicas,
+// the patch is started by one poll and waited for by the next ones, so its
+// state is read back from the API. It returns true once the label is set.
+func (b *CloudSQLBroker) relabelClone(ctx context.Context, instance models.ServiceInstanceDetails) (bool, error) {
+	client, err := b.createClient(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	clouddb, err := client.Instances.Get(b.ProjectId, instance.Name).Do()
+	if err != nil {
+		return false, fmt.Errorf("Error getting instance from API: %s", err)
+	}
+
+	instanceIdLabel := utils.SanitizeLabelValue(instance.ID)
+	if clouddb.Settings.UserLabels[utils.InstanceIdLabel] == instanceIdLabel {
+		return true, nil
+	}
+
+	ops, err := client.Operations.List(b.ProjectId, instance.Name).MaxResults(1).Do()
+	if err != nil {
+		return false, fmt.Errorf("Error listing operations of the clone: %s", err)
+	}
+
+	// the clone itself is the only operation before the patch
+	if len(ops.Items) > 0 && ops.Items[0].OperationType == "UPDATE" {
+		done, err := b.pollOperation(ctx, ops.Items[0].Name)
+		if err != nil {
+			return true, fmt.Errorf("Error relabeling clone: %s", err)
+		}
+
+		return done, nil
+	}
+
+	labels := map[string]string{}
+	for key, value := range clouddb.Settings.UserLabels {
+		labels[key] = value
+	}
+	labels[utils.InstanceIdLabel] = instanceIdLabel
+
+	b.Logger.Info("relabeling clone", lager.Data{"instance": instance.Name})
+	patch := &googlecloudsql.DatabaseInstance{Settings: &googlecloudsql.Settings{UserLabels: labels}}
+	if _, err := client.Instances.Patch(b.ProjectId, instance.Name, patch).Do(); err != nil {
+		return true, fmt.Errorf("Error relabeling clone: %s", err)
+	}
+
+	return false, nil
+}
+
+// createBackup starts an on-demand backup of the instance.
+func (b *CloudSQLBroker) createBackup(ctx context.Context, client *googlecloudsql.Service, instanceName, description string) (*googlecloudsql.Operation, error) {
+	backupRun := &googlecloudsql.BackupRun{
+		Description: description,
+		Instance:    instanceName,
+	}
+
+	op, err := client.BackupRuns.Insert(b.ProjectId, instanceName, backupRun).Do()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating backup: %s", err)
+	}
+
+	return op, nil
+}
+
+// restoreBackup starts restoring a backup of the instance over its current
+// data.
+func (b *CloudSQLBroker) restoreBackup(ctx context.Context, client *googlecloudsql.Service, instanceName, backupRunId string) (*googlecloudsql.Operation, error) {
+	id, err := strconv.ParseInt(backupRunId, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("restore_backup_id must be a number: %s", err)
+	}
+
+	request := &googlecloudsql.InstancesRestoreBackupRequest{
+		RestoreBackupContext: &googlecloudsql.RestoreBackupContext{
+			BackupRunId: id,
+			InstanceId:  instanceName,
+		},
+	}
+
+	op, err := client.Instances.RestoreBackup(b.ProjectId, instanceName, request).Do()
+	if err != nil {
+		return nil, fmt.Errorf("Error restoring backup: %s", err)
+	}
+
+	return op, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsql
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/varcontext"
+)
+
+func TestCreateCloneRequest(t *testing.T) {
+	source := models.ServiceInstanceDetails{
+		ID:               "source-id",
+		Name:             "source",
+		ServiceId:        "service-1",
+		PlanId:           "plan-1",
+		OrganizationGuid: "org-1",
+		SpaceGuid:        "space-1",
+	}
+
+	cases := map[string]struct {
+		Vars                    map[string]interface{}
+		ExpectedPitrTimestampMs int64
+		ErrContains             string
+	}{
+		"current state": {
+			Vars: map[string]interface{}{},
+		},
+		"point in time": {
+			Vars:                    map[string]interface{}{"point_in_time": "2018-10-26T18:00:00Z"},
+			ExpectedPitrTimestampMs: 1540576800000,
+		},
+		"bad point in time": {
+			Vars:        map[string]interface{}{"point_in_time": "yesterday"},
+			ErrContains: "RFC 3339",
+		},
+		"different service": {
+			Vars:        map[string]interface{}{"service_id": "service-2"},
+			ErrContains: "same service and plan",
+		},
+		"different plan": {
+			Vars:        map[string]interface{}{"plan_id": "plan-2"},
+			ErrContains: "same service and plan",
+		},
+		"different org": {
+			Vars:        map[string]interface{}{"organization_guid": "org-2"},
+			ErrContains: "same org and space",
+		},
+		"different space": {
+			Vars:        map[string]interface{}{"space_guid": "space-2"},
+			ErrContains: "same org and space",
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			vars, err := varcontext.Builder().
+				MergeMap(map[string]interface{}{
+					"point_in_time":     "",
+					"service_id":        "service-1",
+					"plan_id":           "plan-1",
+					"organization_guid": "org-1",
+					"space_guid":        "space-1",
+				}).
+				MergeMap(tc.Vars).
+				Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request, err := createCloneRequest(vars, source, "destination")
+			if tc.ErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ErrContains) {
+					t.Fatalf("Expected error containing %q, got %v", tc.ErrContains, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("got unexpected error while creating clone request: %v", err)
+			}
+
+			if request.CloneContext.DestinationInstanceName != "destination" {
+				t.Errorf("Expected destination to be destination got %s", request.CloneContext.DestinationInstanceName)
+			}
+
+			if request.CloneContext.PitrTimestampMs != tc.ExpectedPitrTimestampMs {
+				t.Errorf("Expected point in time %d got %d", tc.ExpectedPitrTimestampMs, request.CloneContext.PitrTimestampMs)
+			}
+		})
+	}
+}
+
+func TestUpdateAction(t *testing.T) {
+	ii := InstanceInformation{LastBackup: "nightly", LastRestoredBackupId: "1"}
+
+	cases := map[string]struct {
+		Backup          string
+		RestoreBackupId string
+		SettingsChanged bool
+		Expected        string
+		ErrContains     string
+	}{
+		"nothing changed": {
+			Backup:          "nightly",
+			RestoreBackupId: "1",
+			Expected:        patchSettingsAction,
+		},
+		"settings": {
+			SettingsChanged: true,
+			Expected:        patchSettingsAction,
+		},
+		"backup": {
+			Backup:   "before-migration",
+			Expected: createBackupAction,
+		},
+		"restore": {
+			RestoreBackupId: "2",
+			Expected:        restoreBackupAction,
+		},
+		"backup and settings": {
+			Backup:          "before-migration",
+			SettingsChanged: true,
+			ErrContains:     "can't change the settings and take a backup",
+		},
+		"backup and restore": {
+			Backup:          "before-migration",
+			RestoreBackupId: "2",
+			ErrContains:     "can't take a backup and restore a backup",
+		},
+		"restore and settings": {
+			Backup:          "nightly",
+			RestoreBackupId: "2",
+			SettingsChanged: true,
+			ErrContains:     "can't change the settings and restore a backup",
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			actual, err := updateAction(ii, tc.Backup, tc.RestoreBackupId, tc.SettingsChanged)
+			if tc.ErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ErrContains) {
+					t.Fatalf("Expected error containing %q, got %v", tc.ErrContains, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+
+			if actual != tc.Expected {
+				t.Errorf("Expected action %q, got %q", tc.Expected, actual)
+			}
+		})
+	}
+}
@@ -18,19 +18,24 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"reflect"
 	"strings"
 	"time"
 
 	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/broker_base"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/varcontext"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/utils"
 	"github.com/pivotal-cf/brokerapi"
 
 	"context"
 
 	"code.cloudfoundry.org/lager"
 	multierror "github.com/hashicorp/go-multierror"
+	"google.golang.org/api/googleapi"
 	googlecloudsql "google.golang.org/api/sqladmin/v1beta4"
 )
 
@@ -53,26 +58,72 @@ type InstanceInformation struct {
 	Host         string `json:"host"`
 	Region       string `json:"region"`
 
+	PrivateHost    string `json:"private_host,omitempty"`
+	ConnectionName string `json:"connection_name,omitempty"`
+
 	LastMasterOperationId string `json:"last_master_operation_id"`
+
+	ReadReplicaNames []string `json:"read_replica_names,omitempty"`
+	ReadReplicaHosts []string `json:"read_replica_hosts,omitempty"`
+
+	// SharedInstance is set if the database was created in the shared
+	// instance of a plan rather than a dedicated instance.
+	SharedInstance bool `json:"shared_instance,omitempty"`
+
+	// ClonedFrom is the name of the instance this one was cloned from.
+	ClonedFrom string `json:"cloned_from,omitempty"`
+
+	// LastBackup and LastRestoredBackupId hold the last values of the backup
+	// and restore_backup_id parameters so each value is only acted on once.
+	LastBackup           string `json:"last_backup,omitempty"`
+	LastRestoredBackupId string `json:"last_restored_backup_id,omitempty"`
+
+	// PendingBackup and PendingRestoredBackupId hold the values of an update
+	// that's still running. They only become LastBackup and
+	// LastRestoredBackupId once the update succeeds so an update with the same
+	// value retries a backup or restore that failed.
+	PendingBackup           string `json:"pending_backup,omitempty"`
+	PendingRestoredBackupId string `json:"pending_restored_backup_id,omitempty"`
 }
 
 // Provision creates a new CloudSQL instance from the settings in the user-provided details and service plan.
+// If a source instance is given the new instance is cloned from it instead, and
+// shared plans create a database in the shared instance of the plan.
 func (b *CloudSQLBroker) Provision(ctx context.Context, provisionContext *varcontext.VarContext) (models.ServiceInstanceDetails, error) {
 	di, ii, err := createProvisionRequest(provisionContext)
 	if err != nil {
 		return models.ServiceInstanceDetails{}, err
 	}
 
+	si, err := sharedInstanceForPlan(provisionContext.GetString("service_id"), provisionContext.GetString("plan_id"))
+	if err != nil {
+		return models.ServiceInstanceDetails{}, err
+	}
+
 	// init sqladmin service
 	sqlService, err := b.createClient(ctx)
 	if err != nil {
 		return models.ServiceInstanceDetails{}, err
 	}
 
-	// make insert request
-	op, err := sqlService.Instances.Insert(b.ProjectId, di).Do()
-	if err != nil {
-		return models.ServiceInstanceDetails{}, fmt.Errorf("Error creating new CloudSQL instance: %s", err)
+	var op *googlecloudsql.Operation
+	if si != nil {
+		di.Name = si.Name
+		op, err = b.createSharedDatabase(ctx, sqlService, provisionContext, si, ii)
+		if err != nil {
+			return models.ServiceInstanceDetails{}, err
+		}
+	} else if provisionContext.GetString("source_instance_id") != "" {
+		op, err = b.cloneInstance(ctx, sqlService, provisionContext, di.Name, ii)
+		if err != nil {
+			return models.ServiceInstanceDetails{}, err
+		}
+	} else {
+		// make insert request
+		op, err = sqlService.Instances.Insert(b.ProjectId, di).Do()
+		if err != nil {
+			return models.ServiceInstanceDetails{}, fmt.Errorf("Error creating new CloudSQL instance: %s", err)
+		}
 	}
 
 	b.Logger.Debug("updating details", lager.Data{"from": "{}", "to": ii})
@@ -90,6 +141,133 @@ func (b *CloudSQLBroker) Provision(ctx context.Context, provisionContext *varcon
 	return id, nil
 }
 
+// Update patches the settings of an existing CloudSQL instance to match the
+// user-provided details and service plan. Only the instance settings are
+// changed; the name, region, and database version are fixed at provision time.
+// CloudSQL instances only run one operation at a time so updates that take or
+// restore a backup can't change the settings or do both. Read replicas are
+// only created at provision time so their number can't change either.
+func (b *CloudSQLBroker) Update(ctx context.Context, instance models.ServiceInstanceDetails, updateContext *varcontext.VarContext) (models.ServiceInstanceDetails, error) {
+	di, ii, err := createProvisionRequest(updateContext)
+	if err != nil {
+		return models.ServiceInstanceDetails{}, err
+	}
+
+	backup := updateContext.GetString("backup")
+	restoreBackupId := updateContext.GetString("restore_backup_id")
+	if err := updateContext.Error(); err != nil {
+		return models.ServiceInstanceDetails{}, err
+	}
+
+	var instanceInfo InstanceInformation
+	if err := instance.GetOtherDetails(&instanceInfo); err != nil {
+		return models.ServiceInstanceDetails{}, err
+	}
+
+	// the settings of shared instances belong to the operator
+	si, err := sharedInstanceForPlan(updateContext.GetString("service_id"), updateContext.GetString("plan_id"))
+	if err != nil {
+		return models.ServiceInstanceDetails{}, err
+	}
+
+	if si != nil || instanceInfo.SharedInstance {
+		return models.ServiceInstanceDetails{}, errors.New("instances of shared plans can't be updated")
+	}
+
+	if len(ii.ReadReplicaNames) != len(instanceInfo.ReadReplicaNames) {
+		return models.ServiceInstanceDetails{}, fmt.Errorf("read_replicas can't be changed from %d by an update", len(instanceInfo.ReadReplicaNames))
+	}
+
+	sqlService, err := b.createClient(ctx)
+	if err != nil {
+		return models.ServiceInstanceDetails{}, err
+	}
+
+	changed, err := settingsChanged(ctx, instance, updateContext, di.Settings)
+	if err != nil {
+		return models.ServiceInstanceDetails{}, err
+	}
+
+	action, err := updateAction(instanceInfo, backup, restoreBackupId, changed)
+	if err != nil {
+		return models.ServiceInstanceDetails{}, err
+	}
+
+	var op *googlecloudsql.Operation
+	instanceInfo.PendingBackup = ""
+	instanceInfo.PendingRestoredBackupId = ""
+	switch action {
+	case restoreBackupAction:
+		op, err = b.restoreBackup(ctx, sqlService, instance.Name, restoreBackupId)
+		instanceInfo.PendingRestoredBackupId = restoreBackupId
+	case createBackupAction:
+		op, err = b.createBackup(ctx, sqlService, instance.Name, backup)
+		instanceInfo.PendingBackup = backup
+	default:
+		patch := &googlecloudsql.DatabaseInstance{Settings: di.Settings}
+		op, err = sqlService.Instances.Patch(b.ProjectId, instance.Name, patch).Do()
+		if err != nil {
+			err = fmt.Errorf("Error updating CloudSQL instance: %s", err)
+		}
+	}
+	if err != nil {
+		return models.ServiceInstanceDetails{}, err
+	}
+
+	if err := instance.SetOtherDetails(instanceInfo); err != nil {
+		return models.ServiceInstanceDetails{}, err
+	}
+
+	instance.OperationType = models.UpdateOperationType
+	instance.OperationId = op.Name
+
+	return instance, nil
+}
+
+// settingsChanged checks if an update changes the settings of the instance by
+// comparing them with the settings built from the parameters it was last
+// provisioned or updated with. Labels are left out because the broker computes
+// them from the request.
+func settingsChanged(ctx context.Context, instance models.ServiceInstanceDetails, updateContext *varcontext.VarContext, settings *googlecloudsql.Settings) (bool, error) {
+	planId := updateContext.GetString("plan_id")
+	if err := updateContext.Error(); err != nil {
+		return false, err
+	}
+
+	if planId != instance.PlanId {
+		return true, nil
+	}
+
+	svc, err := broker.GetServiceById(instance.ServiceId)
+	if err != nil {
+		return false, err
+	}
+
+	plan, err := svc.GetPlanById(planId)
+	if err != nil {
+		return false, err
+	}
+
+	previousRequest, err := db_service.GetProvisionRequestDetailsByServiceInstanceId(ctx, instance.ID)
+	if err != nil {
+		return false, fmt.Errorf("Error retrieving provision request details: %s", err)
+	}
+
+	previousContext, err := svc.UpdateVariables(ctx, instance, brokerapi.UpdateDetails{ServiceID: instance.ServiceId, PlanID: planId}, *previousRequest, *plan)
+	if err != nil {
+		return false, err
+	}
+
+	previous, _, err := createProvisionRequest(previousContext)
+	if err != nil {
+		return false, err
+	}
+
+	current := *settings
+	current.UserLabels = previous.Settings.UserLabels
+	return !reflect.DeepEqual(previous.Settings, &current), nil
+}
+
 func createProvisionRequest(vars *varcontext.VarContext) (*googlecloudsql.DatabaseInstance, *InstanceInformation, error) {
 
 	// set up database information
@@ -108,16 +286,49 @@ func createProvisionRequest(vars *varcontext.VarContext) (*googlecloudsql.Databa
 		StartTime:        vars.GetString("backup_start_time"),
 		BinaryLogEnabled: vars.GetBool("binlog"),
 	}
-	di.Settings.IpConfiguration.AuthorizedNetworks = varctxGetAcls(vars)
+	if di.Settings.IpConfiguration.Ipv4Enabled {
+		di.Settings.IpConfiguration.AuthorizedNetworks = varctxGetAcls(vars)
+	}
 	di.Settings.UserLabels = vars.GetStringMapString("labels")
 
 	// Set up instance information
 	ii := InstanceInformation{
-		InstanceName: instanceName,
-		DatabaseName: vars.GetString("database_name"),
+		InstanceName:     instanceName,
+		DatabaseName:     vars.GetString("database_name"),
+		ReadReplicaNames: readReplicaNames(instanceName, vars.GetInt("read_replicas")),
+
+		// backups are only taken and restored by updates
+		LastBackup:           vars.GetString("backup"),
+		LastRestoredBackupId: vars.GetString("restore_backup_id"),
+	}
+
+	if err := vars.Error(); err != nil {
+		return nil, nil, err
+	}
+
+	ipConfiguration := di.Settings.IpConfiguration
+	if !ipConfiguration.Ipv4Enabled && ipConfiguration.PrivateNetwork == "" {
+		return nil, nil, errors.New("instances without a public IP address must have a private_network")
+	}
+
+	if len(ii.ReadReplicaNames) > 0 && strings.HasPrefix(di.DatabaseVersion, "MYSQL") {
+		backups := di.Settings.BackupConfiguration
+		if !backups.Enabled || !backups.BinaryLogEnabled {
+			return nil, nil, errors.New("MySQL read replicas require backups and binary logging to be enabled")
+		}
+	}
+
+	return di, &ii, nil
+}
+
+// readReplicaNames generates the names of the read replicas of an instance.
+func readReplicaNames(instanceName string, count int) []string {
+	var names []string
+	for i := 1; i <= count; i++ {
+		names = append(names, fmt.Sprintf("%s-replica-%d", instanceName, i))
 	}
 
-	return di, &ii, vars.Error()
+	return names
 }
 
 func varctxGetAcls(vars *varcontext.VarContext) []*googlecloudsql.AclEntry {
@@ -159,8 +370,10 @@ func createInstanceRequest(vars *varcontext.VarContext) *googlecloudsql.Database
 	return &googlecloudsql.DatabaseInstance{
 		Settings: &googlecloudsql.Settings{
 			IpConfiguration: &googlecloudsql.IpConfiguration{
-				RequireSsl:  true,
-				Ipv4Enabled: true,
+				RequireSsl:      true,
+				Ipv4Enabled:     vars.GetBool("public_ip"),
+				PrivateNetwork:  vars.GetString("private_network"),
+				ForceSendFields: []string{"Ipv4Enabled"},
 			},
 			Tier:           vars.GetString("tier"),
 			DataDiskSizeGb: int64(vars.GetInt("disk_size")),
@@ -175,6 +388,7 @@ func createInstanceRequest(vars *varcontext.VarContext) *googlecloudsql.Database
 				ForceSendFields: []string{"Day", "Hour"},
 			},
 			PricingPlan:       secondGenPricingPlan,
+			AvailabilityType:  vars.GetString("availability_type"),
 			ActivationPolicy:  vars.GetString("activation_policy"),
 			ReplicationType:   vars.GetString("replication_type"),
 			StorageAutoResize: &autoResize,
@@ -187,17 +401,30 @@ func createInstanceRequest(vars *varcontext.VarContext) *googlecloudsql.Database
 	}
 }
 
-// Bind creates a new username, password, and set of ssl certs for the given instance.
-// The function may be slow to return because CloudSQL operations are asynchronous.
-// The default PCF service broker timeout may need to be raised to 90 or 120 seconds to accommodate the long bind time.
+// Bind creates a service account and starts creating a new username and
+// password for the given instance. The ssl certs are created by
+// UpdateBindingDetails once the user exists.
 func (b *CloudSQLBroker) Bind(ctx context.Context, vc *varcontext.VarContext) (map[string]interface{}, error) {
 	// get context before trying to create anything to catch errors early
 	combinedCreds := varcontext.Builder()
 	useJdbcFormat := vc.GetBool("jdbc_uri_format")
+	bindMode := vc.GetString("bind_mode")
+	access := vc.GetString("access")
+	serviceId := vc.GetString("service_id")
+	planId := vc.GetString("plan_id")
 	if err := vc.Error(); err != nil {
 		return nil, err
 	}
 
+	si, err := sharedInstanceForPlan(serviceId, planId)
+	if err != nil {
+		return nil, err
+	}
+
+	if si == nil && access != readWriteAccess {
+		return nil, fmt.Errorf("%s access is only supported by shared plans", access)
+	}
+
 	// Create the service account
 	saCreds, err := b.BrokerBase.Bind(ctx, vc)
 	if err != nil {
@@ -205,7 +432,18 @@ func (b *CloudSQLBroker) Bind(ctx context.Context, vc *varcontext.VarContext) (m
 	}
 	combinedCreds.MergeMap(saCreds)
 
-	sqlCreds, err := b.createSqlCredentials(ctx, vc)
+	// the Cloud SQL proxy connects with the service account alone
+	if bindMode == proxyBindMode {
+		combinedCreds.MergeStruct(sqlBindMode{BindMode: proxyBindMode})
+		return combinedCreds.BuildMap()
+	}
+
+	var sqlCreds map[string]interface{}
+	if si != nil {
+		sqlCreds, err = b.createSharedSqlCredentials(ctx, vc, si)
+	} else {
+		sqlCreds, err = b.createSqlCredentials(ctx, vc)
+	}
 	if err != nil {
 		return saCreds, err
 	}
@@ -225,6 +463,16 @@ func (b *CloudSQLBroker) BuildInstanceCredentials(ctx context.Context, bindRecor
 	if err != nil {
 		return nil, err
 	}
+	combinedCreds, err := b.BrokerBase.BuildInstanceCredentials(ctx, bindRecord, instanceRecord)
+	if err != nil {
+		return nil, err
+	}
+
+	// there's no user to build a connection string for
+	if isProxyBinding(bindRecord) {
+		return combinedCreds, nil
+	}
+
 	uriFormat := ""
 	switch service.Name {
 	case models.CloudsqlMySQLName:
@@ -235,11 +483,6 @@ func (b *CloudSQLBroker) BuildInstanceCredentials(ctx context.Context, bindRecor
 		return map[string]interface{}{}, errors.New("Unknown service")
 	}
 
-	combinedCreds, err := b.BrokerBase.BuildInstanceCredentials(ctx, bindRecord, instanceRecord)
-	if err != nil {
-		return nil, err
-	}
-
 	return varcontext.Builder().
 		MergeMap(combinedCreds).
 		MergeEvalResult("uri", uriFormat).
@@ -248,13 +491,26 @@ func (b *CloudSQLBroker) BuildInstanceCredentials(ctx context.Context, bindRecor
 
 // Unbind deletes the database user, service account and invalidates the ssl certs associated with this binding.
 func (b *CloudSQLBroker) Unbind(ctx context.Context, instance models.ServiceInstanceDetails, binding models.ServiceBindingCredentials) error {
+	if isProxyBinding(binding) {
+		return b.BrokerBase.Unbind(ctx, instance, binding)
+	}
+
+	var instanceInfo InstanceInformation
+	if err := instance.GetOtherDetails(&instanceInfo); err != nil {
+		return err
+	}
+
 	var accumulator error
 
 	if err := b.deleteSqlSslCert(ctx, binding, instance); err != nil {
 		accumulator = multierror.Append(accumulator, err)
 	}
 
-	if err := b.deleteSqlUserAccount(ctx, binding, instance); err != nil {
+	if instanceInfo.SharedInstance {
+		if err := b.deleteSharedSqlUserAccount(ctx, binding, instance, instanceInfo); err != nil {
+			accumulator = multierror.Append(accumulator, err)
+		}
+	} else if err := b.deleteSqlUserAccount(ctx, binding, instance); err != nil {
 		accumulator = multierror.Append(accumulator, err)
 	}
 
@@ -265,6 +521,66 @@ func (b *CloudSQLBroker) Unbind(ctx context.Context, instance models.ServiceInst
 	return accumulator
 }
 
+// PollBinding checks the status of the operation inserting the user of the
+// binding. Unbinds are finished by the time Unbind returns.
+func (b *CloudSQLBroker) PollBinding(ctx context.Context, instance models.ServiceInstanceDetails, binding models.ServiceBindingCredentials) (bool, error) {
+	if binding.OperationType != models.BindOperationType || isProxyBinding(binding) {
+		return true, nil
+	}
+
+	var pending pendingSqlCredentials
+	if err := json.Unmarshal([]byte(binding.OtherDetails), &pending); err != nil {
+		return false, fmt.Errorf("Error unmarshalling credentials: %s", err)
+	}
+
+	// users in shared instances are created by Bind
+	if pending.UserOperationId == "" {
+		return true, nil
+	}
+
+	return b.pollOperation(ctx, pending.UserOperationId)
+}
+
+// UpdateBindingDetails creates the ssl certs of the binding once its user
+// exists.
+func (b *CloudSQLBroker) UpdateBindingDetails(ctx context.Context, instance models.ServiceInstanceDetails, binding *models.ServiceBindingCredentials) error {
+	return b.finishSqlCredentials(ctx, instance, binding)
+}
+
+// RotateCredentials replaces the service account key, database user and ssl
+// certs of the binding. The new user is created alongside the old one so
+// applications keep working while they pick up the new credentials.
+// Users in shared instances and PostgreSQL users can't be rotated because the
+// new user would need the grants of the one it replaces, and PostgreSQL won't
+// drop a user that still owns the objects it created.
+func (b *CloudSQLBroker) RotateCredentials(ctx context.Context, instance models.ServiceInstanceDetails, binding *models.ServiceBindingCredentials) (map[string]interface{}, error) {
+	var instanceInfo InstanceInformation
+	if err := instance.GetOtherDetails(&instanceInfo); err != nil {
+		return nil, err
+	}
+
+	if instanceInfo.SharedInstance {
+		return nil, broker.ErrCredentialRotationNotSupported
+	}
+
+	service, err := broker.GetServiceById(instance.ServiceId)
+	if err != nil {
+		return nil, err
+	}
+
+	if service.Name == models.CloudsqlPostgresName && !isProxyBinding(*binding) {
+		return nil, broker.ErrCredentialRotationNotSupported
+	}
+
+	return b.rotateSqlCredentials(ctx, instance, binding)
+}
+
+// RetireCredentials deletes the database user, ssl certs and service account
+// key replaced by RotateCredentials.
+func (b *CloudSQLBroker) RetireCredentials(ctx context.Context, instance models.ServiceInstanceDetails, retired map[string]interface{}) error {
+	return b.retireSqlCredentials(ctx, instance, retired)
+}
+
 // PollInstance gets the last operation for this instance and checks its status.
 func (b *CloudSQLBroker) PollInstance(ctx context.Context, instance models.ServiceInstanceDetails) (bool, error) {
 	b.Logger.Info("PollInstance", lager.Data{
@@ -290,14 +606,135 @@ func (b *CloudSQLBroker) PollInstance(ctx context.Context, instance models.Servi
 			return true, err
 		}
 
-		return true, b.createDatabase(ctx, &instance)
+		var instanceInfo InstanceInformation
+		if err := instance.GetOtherDetails(&instanceInfo); err != nil {
+			return true, err
+		}
+
+		if instanceInfo.SharedInstance {
+			return true, b.finishSharedDatabase(ctx, instance, instanceInfo)
+		}
+
+		if instanceInfo.ClonedFrom != "" {
+			if done, err := b.relabelClone(ctx, instance); !done || err != nil {
+				return done, err
+			}
+		}
+
+		if err := b.createDatabase(ctx, &instance); err != nil {
+			return true, err
+		}
+
+		return b.pollReadReplicas(ctx, instance)
+	}
+
+	if instance.OperationType == models.DeprovisionOperationType {
+		return b.pollDeprovision(ctx, instance)
 	}
 
 	return true, nil
 }
 
+// pollReadReplicas creates the read replicas of the instance one at a time,
+// chaining each creation onto the previous one. Replicas are only created
+// after the primary and its database exist, so the state of the chain is
+// read back from the API on each poll rather than stored in the details.
+func (b *CloudSQLBroker) pollReadReplicas(ctx context.Context, instance models.ServiceInstanceDetails) (bool, error) {
+	var instanceInfo InstanceInformation
+	if err := instance.GetOtherDetails(&instanceInfo); err != nil {
+		return true, err
+	}
+
+	if len(instanceInfo.ReadReplicaNames) == 0 {
+		return true, nil
+	}
+
+	client, err := b.createClient(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, replicaName := range instanceInfo.ReadReplicaNames {
+		_, err := client.Instances.Get(b.ProjectId, replicaName).Do()
+		if isNotFoundError(err) {
+			if err := b.createReadReplica(ctx, client, instance.Name, replicaName); err != nil {
+				return true, err
+			}
+
+			return false, nil
+		}
+
+		if err != nil {
+			return false, fmt.Errorf("Error getting read replica %q from API: %s", replicaName, err)
+		}
+
+		ops, err := client.Operations.List(b.ProjectId, replicaName).MaxResults(1).Do()
+		if err != nil {
+			return false, fmt.Errorf("Error listing operations of read replica %q: %s", replicaName, err)
+		}
+
+		if len(ops.Items) == 0 {
+			continue
+		}
+
+		done, err := b.pollOperation(ctx, ops.Items[0].Name)
+		if err != nil {
+			return true, fmt.Errorf("Error creating read replica %q: %s", replicaName, err)
+		}
+
+		if !done {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// createReadReplica starts creating a read replica of the primary instance
+// with the same tier, disk and network settings.
+func (b *CloudSQLBroker) createReadReplica(ctx context.Context, client *googlecloudsql.Service, primaryName, replicaName string) error {
+	primary, err := client.Instances.Get(b.ProjectId, primaryName).Do()
+	if err != nil {
+		return fmt.Errorf("Error getting instance from API: %s", err)
+	}
+
+	// the public IP setting is omitted when it's false
+	var ipConfiguration *googlecloudsql.IpConfiguration
+	if primary.Settings.IpConfiguration != nil {
+		copied := *primary.Settings.IpConfiguration
+		copied.ForceSendFields = []string{"Ipv4Enabled"}
+		ipConfiguration = &copied
+	}
+
+	replica := &googlecloudsql.DatabaseInstance{
+		Name:               replicaName,
+		MasterInstanceName: primary.Name,
+		DatabaseVersion:    primary.DatabaseVersion,
+		Region:             primary.Region,
+		Settings: &googlecloudsql.Settings{
+			Tier:               primary.Settings.Tier,
+			DataDiskSizeGb:     primary.Settings.DataDiskSizeGb,
+			DataDiskType:       primary.Settings.DataDiskType,
+			IpConfiguration:    ipConfiguration,
+			LocationPreference: primary.Settings.LocationPreference,
+			PricingPlan:        primary.Settings.PricingPlan,
+			StorageAutoResize:  primary.Settings.StorageAutoResize,
+			UserLabels:         primary.Settings.UserLabels,
+		},
+	}
+
+	b.Logger.Info("creating read replica", lager.Data{"instance": primaryName, "replica": replicaName})
+	if _, err := client.Instances.Insert(b.ProjectId, replica).Do(); err != nil {
+		return fmt.Errorf("Error creating read replica %q: %s", replicaName, err)
+	}
+
+	return nil
+}
+
 // refreshServiceInstanceDetails fetches the settings for the instance from GCP
 // and upates the provided instance with the refreshed info.
+// The broker only calls it once an operation succeeds, so it also records the
+// backup or restore of a finished update.
 func (b *CloudSQLBroker) UpdateInstanceDetails(ctx context.Context, instance *models.ServiceInstanceDetails) error {
 	var instanceInfo InstanceInformation
 	if err := instance.GetOtherDetails(&instanceInfo); err != nil {
@@ -319,12 +756,63 @@ func (b *CloudSQLBroker) UpdateInstanceDetails(ctx context.Context, instance *mo
 	instance.Location = clouddb.Region
 
 	// update instance information
-	instanceInfo.Host = clouddb.IpAddresses[0].IpAddress
+	instanceInfo.Host, instanceInfo.PrivateHost = instanceHosts(clouddb)
 	instanceInfo.Region = clouddb.Region
+	instanceInfo.ConnectionName = clouddb.ConnectionName
+
+	if instanceInfo.PendingBackup != "" {
+		instanceInfo.LastBackup = instanceInfo.PendingBackup
+		instanceInfo.PendingBackup = ""
+	}
+
+	if instanceInfo.PendingRestoredBackupId != "" {
+		instanceInfo.LastRestoredBackupId = instanceInfo.PendingRestoredBackupId
+		instanceInfo.PendingRestoredBackupId = ""
+	}
+
+	instanceInfo.ReadReplicaHosts = nil
+	for _, replicaName := range instanceInfo.ReadReplicaNames {
+		replica, err := client.Instances.Get(b.ProjectId, replicaName).Do()
+		if isNotFoundError(err) {
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("Error getting read replica %q from API: %s", replicaName, err)
+		}
+
+		if host, _ := instanceHosts(replica); host != "" {
+			instanceInfo.ReadReplicaHosts = append(instanceInfo.ReadReplicaHosts, host)
+		}
+	}
 
 	return instance.SetOtherDetails(instanceInfo)
 }
 
+// instanceHosts gets the address applications should connect to and the
+// private IP address of the instance. Instances without a public IP address
+// are connected to on their private one.
+func instanceHosts(clouddb *googlecloudsql.DatabaseInstance) (host, privateHost string) {
+	for _, ip := range clouddb.IpAddresses {
+		switch {
+		case ip.Type == "PRIMARY" && host == "":
+			host = ip.IpAddress
+		case ip.Type == "PRIVATE" && privateHost == "":
+			privateHost = ip.IpAddress
+		}
+	}
+
+	if host == "" {
+		host = privateHost
+	}
+
+	if host == "" && len(clouddb.IpAddresses) > 0 {
+		host = clouddb.IpAddresses[0].IpAddress
+	}
+
+	return host, privateHost
+}
+
 // createDatabase creates tha database on the instance referenced by ServiceInstanceDetails.
 func (b *CloudSQLBroker) createDatabase(ctx context.Context, instance *models.ServiceInstanceDetails) error {
 	var instanceInfo InstanceInformation
@@ -337,6 +825,12 @@ func (b *CloudSQLBroker) createDatabase(ctx context.Context, instance *models.Se
 		return err
 	}
 
+	// the database may already exist if the instance is still waiting on its
+	// read replicas
+	if _, err := client.Databases.Get(b.ProjectId, instance.Name, instanceInfo.DatabaseName).Do(); err == nil {
+		return nil
+	}
+
 	d := googlecloudsql.Database{Name: instanceInfo.DatabaseName}
 	op, err := client.Databases.Insert(b.ProjectId, instance.Name, &d).Do()
 	if err != nil {
@@ -403,13 +897,45 @@ func (b *CloudSQLBroker) pollOperationUntilDone(ctx context.Context, op *googlec
 	}
 }
 
-// Deprovision issues a delete call on the database instance.
+// Deprovision issues a delete call on the database instance. CloudSQL won't
+// delete an instance that still has replicas so any read replicas are
+// deleted first and the delete of the primary is chained onto them by
+// PollInstance.
 func (b *CloudSQLBroker) Deprovision(ctx context.Context, instance models.ServiceInstanceDetails, details brokerapi.DeprovisionDetails) (*string, error) {
 	sqlService, err := b.createClient(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	var instanceInfo InstanceInformation
+	if err := instance.GetOtherDetails(&instanceInfo); err != nil {
+		return nil, err
+	}
+
+	if instanceInfo.SharedInstance {
+		return nil, b.deleteSharedDatabase(ctx, sqlService, instance, instanceInfo)
+	}
+
+	var firstOp *googlecloudsql.Operation
+	for _, replicaName := range instanceInfo.ReadReplicaNames {
+		op, err := sqlService.Instances.Delete(b.ProjectId, replicaName).Do()
+		if isNotFoundError(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Error deleting read replica %q: %s", replicaName, err)
+		}
+
+		if firstOp == nil {
+			firstOp = op
+		}
+	}
+
+	if firstOp != nil {
+		return &firstOp.Name, nil
+	}
+
 	// delete the instance from google
 	op, err := sqlService.Instances.Delete(b.ProjectId, instance.Name).Do()
 	if err != nil {
@@ -419,6 +945,166 @@ func (b *CloudSQLBroker) Deprovision(ctx context.Context, instance models.Servic
 	return &op.Name, nil
 }
 
+// pollDeprovision waits for the read replicas of the instance to be deleted
+// and then deletes the primary. Like pollReadReplicas, the state of the chain
+// is read back from the API on each poll.
+func (b *CloudSQLBroker) pollDeprovision(ctx context.Context, instance models.ServiceInstanceDetails) (bool, error) {
+	var instanceInfo InstanceInformation
+	if err := instance.GetOtherDetails(&instanceInfo); err != nil {
+		return true, err
+	}
+
+	if len(instanceInfo.ReadReplicaNames) == 0 {
+		return true, nil
+	}
+
+	client, err := b.createClient(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, replicaName := range instanceInfo.ReadReplicaNames {
+		done, err := b.pollInstanceDelete(ctx, client, replicaName)
+		if err != nil {
+			return true, fmt.Errorf("Error deleting read replica %q: %s", replicaName, err)
+		}
+
+		if !done {
+			return false, nil
+		}
+	}
+
+	done, err := b.pollInstanceDelete(ctx, client, instance.Name)
+	if err != nil {
+		return true, fmt.Errorf("Error deleting instance: %s", err)
+	}
+
+	return done, nil
+}
+
+// pollInstanceDelete reports whether the CloudSQL instance is gone, starting
+// its delete if it isn't already running.
+func (b *CloudSQLBroker) pollInstanceDelete(ctx context.Context, client *googlecloudsql.Service, instanceName string) (bool, error) {
+	_, err := client.Instances.Get(b.ProjectId, instanceName).Do()
+	if isNotFoundError(err) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	ops, err := client.Operations.List(b.ProjectId, instanceName).MaxResults(1).Do()
+	if err != nil {
+		return false, err
+	}
+
+	if len(ops.Items) > 0 && ops.Items[0].OperationType == "DELETE" {
+		// the instance can still be found for a moment after it's deleted
+		return b.pollOperation(ctx, ops.Items[0].Name)
+	}
+
+	b.Logger.Info("deleting instance", lager.Data{"instance": instanceName})
+	if _, err := client.Instances.Delete(b.ProjectId, instanceName).Do(); err != nil {
+		return false, err
+	}
+
+	return false, nil
+}
+
+// Exists checks if the CloudSQL instance still exists.
+func (b *CloudSQLBroker) Exists(ctx context.Context, instance models.ServiceInstanceDetails) (bool, error) {
+	sqlService, err := b.createClient(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var instanceInfo InstanceInformation
+	if err := instance.GetOtherDetails(&instanceInfo); err != nil {
+		return false, err
+	}
+
+	// the shared instance outlives the databases in it
+	if instanceInfo.SharedInstance {
+		if _, err := sqlService.Databases.Get(b.ProjectId, instance.Name, instanceInfo.DatabaseName).Do(); err != nil {
+			if isNotFoundError(err) {
+				return false, nil
+			}
+
+			return false, fmt.Errorf("Error getting database from API: %s", err)
+		}
+
+		return true, nil
+	}
+
+	if _, err := sqlService.Instances.Get(b.ProjectId, instance.Name).Do(); err != nil {
+		if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == http.StatusNotFound {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("Error getting instance from API: %s", err)
+	}
+
+	return true, nil
+}
+
+// DescribeResources lists the CloudSQL instances in the project that are
+// labeled with the ID of a service instance. The name of the database created
+// on the instance isn't known from the instance alone so it's left empty.
+// The API doesn't report when instances were created, so the creation time of
+// the server CA certificate, which is created with the instance and only
+// replaced by newer ones, is used instead.
+func (b *CloudSQLBroker) DescribeResources(ctx context.Context) ([]models.ServiceInstanceDetails, error) {
+	sqlService, err := b.createClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []models.ServiceInstanceDetails
+	err = sqlService.Instances.List(b.ProjectId).Pages(ctx, func(page *googlecloudsql.InstancesListResponse) error {
+		for _, clouddb := range page.Items {
+			// replicas carry the labels of their primary instance
+			if clouddb.Settings == nil || clouddb.MasterInstanceName != "" {
+				continue
+			}
+
+			instanceId, ok := clouddb.Settings.UserLabels[utils.InstanceIdLabel]
+			if !ok {
+				continue
+			}
+
+			ii := InstanceInformation{
+				InstanceName:   clouddb.Name,
+				Region:         clouddb.Region,
+				ConnectionName: clouddb.ConnectionName,
+			}
+			ii.Host, ii.PrivateHost = instanceHosts(clouddb)
+
+			resource := models.ServiceInstanceDetails{
+				ID:       instanceId,
+				Name:     clouddb.Name,
+				Url:      clouddb.SelfLink,
+				Location: clouddb.Region,
+			}
+			if clouddb.ServerCaCert != nil {
+				resource.CreatedAt, _ = time.Parse(time.RFC3339, clouddb.ServerCaCert.CreateTime)
+			}
+			if err := resource.SetOtherDetails(ii); err != nil {
+				return err
+			}
+
+			resources = append(resources, resource)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error listing instances: %s", err)
+	}
+
+	return resources, nil
+}
+
 // ProvisionsAsync indicates that CloudSQL uses asynchronous provisioning.
 func (b *CloudSQLBroker) ProvisionsAsync() bool {
 	return true
@@ -429,6 +1115,11 @@ func (b *CloudSQLBroker) DeprovisionsAsync() bool {
 	return true
 }
 
+// BindsAsync indicates that CloudSQL uses asynchronous binding.
+func (b *CloudSQLBroker) BindsAsync() bool {
+	return true
+}
+
 func (b *CloudSQLBroker) createClient(ctx context.Context) (*googlecloudsql.Service, error) {
 	client, err := googlecloudsql.New(b.HttpConfig.Client(ctx))
 	if err != nil {
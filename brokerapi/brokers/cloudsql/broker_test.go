@@ -15,10 +15,13 @@
 package cloudsql
 
 import (
+	"context"
 	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
 	"github.com/pivotal-cf/brokerapi"
 	"github.com/spf13/viper"
@@ -239,6 +242,132 @@ func TestCreateProvisionRequest(t *testing.T) {
 			Validate:    func(t *testing.T, di googlecloudsql.DatabaseInstance, ii InstanceInformation) {},
 			ErrContains: "disk size",
 		},
+
+		"postgres regional availability": {
+			Service:    postgresServiceDefinition(),
+			PlanId:     postgresPlan,
+			UserParams: `{"availability_type":"REGIONAL"}`,
+			Validate: func(t *testing.T, di googlecloudsql.DatabaseInstance, ii InstanceInformation) {
+				if di.Settings.AvailabilityType != "REGIONAL" {
+					t.Errorf("Expected availability type to be REGIONAL got %s", di.Settings.AvailabilityType)
+				}
+			},
+		},
+
+		"mysql regional availability creates a failover replica": {
+			Service:    mysqlServiceDefinition(),
+			PlanId:     mysqlSecondGenPlan,
+			UserParams: `{"availability_type":"REGIONAL", "instance_name": "bar"}`,
+			Validate: func(t *testing.T, di googlecloudsql.DatabaseInstance, ii InstanceInformation) {
+				if di.FailoverReplica.Name != "bar-failover" {
+					t.Errorf("Expected failover replica to be bar-failover got %s", di.FailoverReplica.Name)
+				}
+
+				if di.Settings.AvailabilityType != "" {
+					t.Errorf("Expected no availability type for MySQL got %s", di.Settings.AvailabilityType)
+				}
+			},
+		},
+
+		"mysql regional availability keeps user failover replica name": {
+			Service:    mysqlServiceDefinition(),
+			PlanId:     mysqlSecondGenPlan,
+			UserParams: `{"availability_type":"REGIONAL", "failover_replica_name": "foo"}`,
+			Validate: func(t *testing.T, di googlecloudsql.DatabaseInstance, ii InstanceInformation) {
+				if di.FailoverReplica.Name != "foo" {
+					t.Errorf("Expected failover replica to be foo got %s", di.FailoverReplica.Name)
+				}
+			},
+		},
+
+		"no read replicas by default": {
+			Service:    postgresServiceDefinition(),
+			PlanId:     postgresPlan,
+			UserParams: `{}`,
+			Validate: func(t *testing.T, di googlecloudsql.DatabaseInstance, ii InstanceInformation) {
+				if len(ii.ReadReplicaNames) != 0 {
+					t.Errorf("Expected no read replicas got %v", ii.ReadReplicaNames)
+				}
+			},
+		},
+
+		"read replicas are named after the instance": {
+			Service:    postgresServiceDefinition(),
+			PlanId:     postgresPlan,
+			UserParams: `{"read_replicas":"2", "instance_name": "bar"}`,
+			Validate: func(t *testing.T, di googlecloudsql.DatabaseInstance, ii InstanceInformation) {
+				expected := []string{"bar-replica-1", "bar-replica-2"}
+				if !reflect.DeepEqual(ii.ReadReplicaNames, expected) {
+					t.Errorf("Expected read replicas %v got %v", expected, ii.ReadReplicaNames)
+				}
+			},
+		},
+
+		"mysql read replicas require binary logging": {
+			Service:     mysqlServiceDefinition(),
+			PlanId:      mysqlSecondGenPlan,
+			UserParams:  `{"read_replicas":"1", "binlog":"false"}`,
+			Validate:    func(t *testing.T, di googlecloudsql.DatabaseInstance, ii InstanceInformation) {},
+			ErrContains: "binary logging",
+		},
+
+		"public ip by default": {
+			Service:    postgresServiceDefinition(),
+			PlanId:     postgresPlan,
+			UserParams: `{"authorized_networks":"10.0.0.0/8"}`,
+			Validate: func(t *testing.T, di googlecloudsql.DatabaseInstance, ii InstanceInformation) {
+				if !di.Settings.IpConfiguration.Ipv4Enabled {
+					t.Error("Expected a public IP by default")
+				}
+
+				if len(di.Settings.IpConfiguration.AuthorizedNetworks) != 1 {
+					t.Errorf("Expected 1 authorized network got %v", len(di.Settings.IpConfiguration.AuthorizedNetworks))
+				}
+			},
+		},
+
+		"private ip only": {
+			Service:    mysqlServiceDefinition(),
+			PlanId:     mysqlSecondGenPlan,
+			UserParams: `{"public_ip":"false", "private_network":"projects/foo/global/networks/bar", "authorized_networks":"10.0.0.0/8"}`,
+			Validate: func(t *testing.T, di googlecloudsql.DatabaseInstance, ii InstanceInformation) {
+				if di.Settings.IpConfiguration.Ipv4Enabled {
+					t.Error("Expected no public IP")
+				}
+
+				if di.Settings.IpConfiguration.PrivateNetwork != "projects/foo/global/networks/bar" {
+					t.Errorf("Expected private network to be projects/foo/global/networks/bar got %s", di.Settings.IpConfiguration.PrivateNetwork)
+				}
+
+				if len(di.Settings.IpConfiguration.AuthorizedNetworks) != 0 {
+					t.Error("Expected no authorized networks without a public IP")
+				}
+			},
+		},
+
+		"no public ip requires a private network": {
+			Service:     postgresServiceDefinition(),
+			PlanId:      postgresPlan,
+			UserParams:  `{"public_ip":"false"}`,
+			Validate:    func(t *testing.T, di googlecloudsql.DatabaseInstance, ii InstanceInformation) {},
+			ErrContains: "private_network",
+		},
+
+		"first-gen mysql private ip fails": {
+			Service:     mysqlServiceDefinition(),
+			PlanId:      mysqlFirstgenPlan,
+			UserParams:  `{"private_network":"projects/foo/global/networks/bar"}`,
+			Validate:    func(t *testing.T, di googlecloudsql.DatabaseInstance, ii InstanceInformation) {},
+			ErrContains: "private IP",
+		},
+
+		"first-gen mysql read replicas fail": {
+			Service:     mysqlServiceDefinition(),
+			PlanId:      mysqlFirstgenPlan,
+			UserParams:  `{"read_replicas":"1"}`,
+			Validate:    func(t *testing.T, di googlecloudsql.DatabaseInstance, ii InstanceInformation) {},
+			ErrContains: "1st generation",
+		},
 	}
 
 	for tn, tc := range cases {
@@ -257,7 +386,7 @@ func TestCreateProvisionRequest(t *testing.T) {
 				t.Fatalf("Expected plan with id %s to not be nil", tc.PlanId)
 			}
 
-			vars, err := tc.Service.ProvisionVariables("instance-id-here", details, *plan)
+			vars, err := tc.Service.ProvisionVariables(context.Background(), "instance-id-here", details, *plan)
 			if err != nil {
 				if tc.ErrContains != "" && strings.Contains(err.Error(), tc.ErrContains) {
 					return
@@ -268,6 +397,9 @@ func TestCreateProvisionRequest(t *testing.T) {
 
 			request, instanceInfo, err := createProvisionRequest(vars)
 			if err != nil {
+				if tc.ErrContains != "" && strings.Contains(err.Error(), tc.ErrContains) {
+					return
+				}
 
 				t.Fatalf("got unexpected error while creating provision request: %v", err)
 			}
@@ -279,3 +411,74 @@ func TestCreateProvisionRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestInstanceHosts(t *testing.T) {
+	cases := map[string]struct {
+		IpAddresses         []*googlecloudsql.IpMapping
+		ExpectedHost        string
+		ExpectedPrivateHost string
+	}{
+		"no addresses": {
+			IpAddresses:         nil,
+			ExpectedHost:        "",
+			ExpectedPrivateHost: "",
+		},
+		"public only": {
+			IpAddresses:         []*googlecloudsql.IpMapping{{Type: "PRIMARY", IpAddress: "1.2.3.4"}},
+			ExpectedHost:        "1.2.3.4",
+			ExpectedPrivateHost: "",
+		},
+		"private only": {
+			IpAddresses:         []*googlecloudsql.IpMapping{{Type: "PRIVATE", IpAddress: "10.0.0.2"}},
+			ExpectedHost:        "10.0.0.2",
+			ExpectedPrivateHost: "10.0.0.2",
+		},
+		"public and private": {
+			IpAddresses: []*googlecloudsql.IpMapping{
+				{Type: "PRIVATE", IpAddress: "10.0.0.2"},
+				{Type: "PRIMARY", IpAddress: "1.2.3.4"},
+			},
+			ExpectedHost:        "1.2.3.4",
+			ExpectedPrivateHost: "10.0.0.2",
+		},
+		"untyped": {
+			IpAddresses:         []*googlecloudsql.IpMapping{{IpAddress: "1.2.3.4"}},
+			ExpectedHost:        "1.2.3.4",
+			ExpectedPrivateHost: "",
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			host, privateHost := instanceHosts(&googlecloudsql.DatabaseInstance{IpAddresses: tc.IpAddresses})
+			if host != tc.ExpectedHost {
+				t.Errorf("Expected host %q got %q", tc.ExpectedHost, host)
+			}
+
+			if privateHost != tc.ExpectedPrivateHost {
+				t.Errorf("Expected private host %q got %q", tc.ExpectedPrivateHost, privateHost)
+			}
+		})
+	}
+}
+
+func TestIsProxyBinding(t *testing.T) {
+	cases := map[string]struct {
+		OtherDetails string
+		Expected     bool
+	}{
+		"legacy":   {OtherDetails: `{"Username":"foo"}`, Expected: false},
+		"empty":    {OtherDetails: ``, Expected: false},
+		"password": {OtherDetails: `{"BindMode":"password"}`, Expected: false},
+		"proxy":    {OtherDetails: `{"BindMode":"proxy"}`, Expected: true},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			actual := isProxyBinding(models.ServiceBindingCredentials{OtherDetails: tc.OtherDetails})
+			if actual != tc.Expected {
+				t.Errorf("Expected %v got %v", tc.Expected, actual)
+			}
+		})
+	}
+}
@@ -36,7 +36,7 @@ func mysqlServiceDefinition() *broker.ServiceDefinition {
 		    "description": "Google Cloud SQL is a fully-managed MySQL database service.",
 		    "name": "google-cloudsql-mysql",
 		    "bindable": true,
-		    "plan_updateable": false,
+		    "plan_updateable": true,
 		    "metadata": {
 		      "displayName": "Google CloudSQL MySQL",
 		      "longDescription": "Google Cloud SQL is a fully-managed MySQL database service.",
@@ -232,7 +232,7 @@ func mysqlServiceDefinition() *broker.ServiceDefinition {
 				},
 			},
 		}, commonProvisionVariables()...),
-		ProvisionComputedVariables: []varcontext.DefaultVariable{
+		ProvisionComputedVariables: append([]varcontext.DefaultVariable{
 			{Name: "labels", Default: `${json.marshal(request.default_labels)}`, Overwrite: true},
 
 			// legacy behavior dictates that empty values get defaults
@@ -243,14 +243,21 @@ func mysqlServiceDefinition() *broker.ServiceDefinition {
 			{Name: "version", Default: `${is_first_gen ? "MYSQL_5_6" : "MYSQL_5_7"}`, Overwrite: false},
 			{Name: "binlog", Default: `${is_first_gen ? false : true}`, Overwrite: false},
 
+			// MySQL instances are made highly available with a failover replica
+			// rather than the availability type of the instance.
+			{Name: "failover_replica_name", Default: `${availability_type == "REGIONAL" && failover_replica_name == "" ? "${instance_name}-failover" : failover_replica_name}`, Overwrite: true},
+			{Name: "availability_type", Default: ``, Overwrite: true},
+
 			// validation
 			{Name: "_", Default: `${assert(disk_size <= max_disk_size, "disk size (${disk_size}) is greater than max allowed disk size for this plan (${max_disk_size})")}`, Overwrite: true},
-		},
+			{Name: "_", Default: `${assert(!is_first_gen || (read_replicas == 0 && failover_replica_name == ""), "1st generation instances don't support failover or read replicas")}`, Overwrite: true},
+			{Name: "_", Default: `${assert(!is_first_gen || private_network == "", "1st generation instances don't support private IP addresses")}`, Overwrite: true},
+		}, requestComputedVariables()...),
 		DefaultRoleWhitelist:  roleWhitelist(),
 		BindInputVariables:    commonBindVariables(models.CloudsqlMySQLName),
 		BindOutputVariables:   commonBindOutputVariables(),
 		BindComputedVariables: commonBindComputedVariables(),
-		PlanVariables: []broker.BrokerVariable{
+		PlanVariables: append([]broker.BrokerVariable{
 			{
 				FieldName: "tier",
 				Type:      broker.JsonTypeString,
@@ -275,7 +282,7 @@ func mysqlServiceDefinition() *broker.ServiceDefinition {
 				Default:   "10",
 				Required:  true,
 			},
-		},
+		}, sharedPlanVariables()...),
 		Examples: []broker.ServiceExample{
 			{
 				Name:        "Development Sandbox",
@@ -36,7 +36,7 @@ func postgresServiceDefinition() *broker.ServiceDefinition {
         "description": "Google Cloud SQL is a fully-managed PostgreSQL database service.",
         "name": "google-cloudsql-postgres",
         "bindable": true,
-        "plan_updateable": false,
+        "plan_updateable": true,
         "metadata": {
 	          "displayName": "Google CloudSQL PostgreSQL",
 	          "longDescription": "Google Cloud SQL is a fully-managed MySQL database service.",
@@ -185,7 +185,7 @@ func postgresServiceDefinition() *broker.ServiceDefinition {
 				},
 			},
 		}, commonProvisionVariables()...),
-		ProvisionComputedVariables: []varcontext.DefaultVariable{
+		ProvisionComputedVariables: append([]varcontext.DefaultVariable{
 			{Name: "labels", Default: `${json.marshal(request.default_labels)}`, Overwrite: true},
 
 			// legacy behavior dictates that empty values get defaults
@@ -198,13 +198,13 @@ func postgresServiceDefinition() *broker.ServiceDefinition {
 
 			// validation
 			{Name: "_", Default: `${assert(disk_size <= max_disk_size, "disk size (${disk_size}) is greater than max allowed disk size for this plan (${max_disk_size})")}`, Overwrite: true},
-		},
+		}, requestComputedVariables()...),
 
 		DefaultRoleWhitelist:  roleWhitelist(),
 		BindInputVariables:    commonBindVariables(models.CloudsqlPostgresName),
 		BindOutputVariables:   commonBindOutputVariables(),
 		BindComputedVariables: commonBindComputedVariables(),
-		PlanVariables: []broker.BrokerVariable{
+		PlanVariables: append([]broker.BrokerVariable{
 			{
 				FieldName: "tier",
 				Type:      broker.JsonTypeString,
@@ -227,7 +227,7 @@ func postgresServiceDefinition() *broker.ServiceDefinition {
 				Default:   "10",
 				Required:  true,
 			},
-		},
+		}, sharedPlanVariables()...),
 		Examples: []broker.ServiceExample{
 			{
 				Name:        "Development Sandbox",
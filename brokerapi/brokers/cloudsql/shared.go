@@ -0,0 +1,411 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/varcontext"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	googlecloudsql "google.golang.org/api/sqladmin/v1beta4"
+)
+
+const (
+	readWriteAccess = "read_write"
+	readOnlyAccess  = "read_only"
+
+	sharedInstanceNameProp     = "shared_instance_name"
+	sharedInstanceAddressProp  = "shared_instance_address"
+	sharedInstanceUsernameProp = "shared_instance_username"
+	sharedInstancePasswordProp = "shared_instance_password"
+)
+
+// sharedInstance is a pre-existing CloudSQL instance that the instances of a
+// shared plan are created as databases in. The broker manages the users of the
+// databases over a SQL connection to Address, e.g. a Cloud SQL proxy running
+// next to the broker.
+type sharedInstance struct {
+	Name     string
+	Address  string
+	Username string
+	Password string
+
+	dialect sqlDialect
+}
+
+// sharedInstanceForPlan gets the shared instance configured by the operator
+// for the plan, or nil if the plan creates dedicated instances.
+// The settings are read from the plan rather than the request variables so
+// users can't point their databases at other instances.
+func sharedInstanceForPlan(serviceId, planId string) (*sharedInstance, error) {
+	svc, err := broker.GetServiceById(serviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := svc.GetPlanById(planId)
+	if err != nil {
+		return nil, err
+	}
+
+	props := plan.ServiceProperties
+	if props[sharedInstanceNameProp] == "" {
+		return nil, nil
+	}
+
+	si := &sharedInstance{
+		Name:     props[sharedInstanceNameProp],
+		Address:  props[sharedInstanceAddressProp],
+		Username: props[sharedInstanceUsernameProp],
+		Password: props[sharedInstancePasswordProp],
+	}
+
+	if si.Address == "" || si.Username == "" {
+		return nil, fmt.Errorf("plan %q must set %s and %s to use a shared instance", plan.Name, sharedInstanceAddressProp, sharedInstanceUsernameProp)
+	}
+
+	switch svc.Name {
+	case models.CloudsqlMySQLName:
+		si.dialect = mysqlDialect{}
+	case models.CloudsqlPostgresName:
+		si.dialect = postgresDialect{}
+	default:
+		return nil, fmt.Errorf("shared instances aren't supported for %s", svc.Name)
+	}
+
+	return si, nil
+}
+
+// sharedInstanceForInstance gets the shared instance of a service instance
+// created by a shared plan.
+func sharedInstanceForInstance(instance models.ServiceInstanceDetails) (*sharedInstance, error) {
+	si, err := sharedInstanceForPlan(instance.ServiceId, instance.PlanId)
+	if err != nil {
+		return nil, err
+	}
+
+	if si == nil {
+		return nil, fmt.Errorf("the plan of instance %q no longer has a %s", instance.ID, sharedInstanceNameProp)
+	}
+
+	return si, nil
+}
+
+// createSharedDatabase starts creating the database of a service instance in
+// the shared instance of its plan.
+func (b *CloudSQLBroker) createSharedDatabase(ctx context.Context, client *googlecloudsql.Service, vars *varcontext.VarContext, si *sharedInstance, ii *InstanceInformation) (*googlecloudsql.Operation, error) {
+	if vars.GetString("source_instance_id") != "" {
+		return nil, errors.New("instances of shared plans can't be cloned")
+	}
+
+	if len(ii.ReadReplicaNames) > 0 {
+		return nil, errors.New("instances of shared plans can't have read replicas")
+	}
+
+	op, err := client.Databases.Insert(b.ProjectId, si.Name, &googlecloudsql.Database{Name: ii.DatabaseName}).Do()
+	if err != nil {
+		return nil, fmt.Errorf("Error creating database: %s", err)
+	}
+
+	ii.InstanceName = si.Name
+	ii.SharedInstance = true
+
+	return op, nil
+}
+
+// finishSharedDatabase isolates a newly created database from the other
+// databases in the shared instance.
+func (b *CloudSQLBroker) finishSharedDatabase(ctx context.Context, instance models.ServiceInstanceDetails, ii InstanceInformation) error {
+	si, err := sharedInstanceForInstance(instance)
+	if err != nil {
+		return err
+	}
+
+	return runSharedInstanceStatements(ctx, si, ii.DatabaseName, si.dialect.provisionStatements(ii.DatabaseName))
+}
+
+// deleteSharedDatabase deletes the database of a service instance from the
+// shared instance of its plan.
+func (b *CloudSQLBroker) deleteSharedDatabase(ctx context.Context, client *googlecloudsql.Service, instance models.ServiceInstanceDetails, ii InstanceInformation) error {
+	si, err := sharedInstanceForInstance(instance)
+	if err != nil {
+		return err
+	}
+
+	op, err := client.Databases.Delete(b.ProjectId, instance.Name, ii.DatabaseName).Do()
+	if err != nil && !isNotFoundError(err) {
+		return fmt.Errorf("Error deleting database: %s", err)
+	}
+
+	if op != nil {
+		if err := b.pollOperationUntilDone(ctx, op, b.ProjectId); err != nil {
+			return fmt.Errorf("Error deleting database: %s", err)
+		}
+	}
+
+	return runSharedInstanceStatements(ctx, si, "", si.dialect.deprovisionStatements(ii.DatabaseName))
+}
+
+// createSharedSqlCredentials creates a user with the requested access to the
+// database of the service instance, and its ssl certs.
+func (b *CloudSQLBroker) createSharedSqlCredentials(ctx context.Context, vars *varcontext.VarContext, si *sharedInstance) (map[string]interface{}, error) {
+	userAccount := sqlUserAccount{
+		Username: vars.GetString("username"),
+		Password: vars.GetString("password"),
+	}
+	certName := vars.GetString("certname")
+	access := vars.GetString("access")
+	instanceId := vars.GetString("instance_id")
+	if err := vars.Error(); err != nil {
+		return nil, err
+	}
+
+	instance, err := db_service.GetServiceInstanceDetailsById(ctx, instanceId)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting instance: %s", err)
+	}
+
+	var ii InstanceInformation
+	if err := instance.GetOtherDetails(&ii); err != nil {
+		return nil, err
+	}
+
+	statements := si.dialect.bindStatements(ii.DatabaseName, userAccount.Username, userAccount.Password, access)
+	if err := runSharedInstanceStatements(ctx, si, ii.DatabaseName, statements); err != nil {
+		return nil, err
+	}
+
+	sslCert, err := b.createSqlSslCert(ctx, si.Name, certName)
+	if err != nil {
+		statements := si.dialect.unbindStatements(ii.DatabaseName, userAccount.Username)
+		if err := runSharedInstanceStatements(ctx, si, ii.DatabaseName, statements); err != nil {
+			b.Logger.Error("delete-shared-user", err, lager.Data{"instance_id": instanceId})
+		}
+
+		return nil, err
+	}
+
+	return varcontext.Builder().MergeStruct(userAccount).MergeStruct(sslCert).BuildMap()
+}
+
+// deleteSharedSqlUserAccount deletes the user of a binding from the database
+// of the service instance.
+func (b *CloudSQLBroker) deleteSharedSqlUserAccount(ctx context.Context, binding models.ServiceBindingCredentials, instance models.ServiceInstanceDetails, ii InstanceInformation) error {
+	var creds sqlUserAccount
+	if err := json.Unmarshal([]byte(binding.OtherDetails), &creds); err != nil {
+		return fmt.Errorf("Error unmarshalling credentials: %s", err)
+	}
+
+	si, err := sharedInstanceForInstance(instance)
+	if err != nil {
+		return err
+	}
+
+	return runSharedInstanceStatements(ctx, si, ii.DatabaseName, si.dialect.unbindStatements(ii.DatabaseName, creds.Username))
+}
+
+// runSharedInstanceStatements runs statements one at a time on a database of
+// the shared instance, or its default database if database is empty.
+// The statements hold passwords so they're left out of errors.
+func runSharedInstanceStatements(ctx context.Context, si *sharedInstance, database string, statements []string) error {
+	if len(statements) == 0 {
+		return nil
+	}
+
+	db, err := sql.Open(si.dialect.driverName(), si.dialect.dataSourceName(si, database))
+	if err != nil {
+		return fmt.Errorf("Error connecting to shared instance %q: %s", si.Name, err)
+	}
+	defer db.Close()
+
+	for i, statement := range statements {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("Error running statement %d of %d on shared instance %q: %s", i+1, len(statements), si.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// sqlDialect builds the statements that isolate the databases of a shared
+// instance from each other and grant bindings access to them.
+type sqlDialect interface {
+	driverName() string
+	dataSourceName(si *sharedInstance, database string) string
+
+	// provisionStatements run on a newly created database.
+	provisionStatements(database string) []string
+	// deprovisionStatements run on the default database once a database is
+	// deleted.
+	deprovisionStatements(database string) []string
+	// bindStatements run on the database to create a user for a binding.
+	bindStatements(database, username, password, access string) []string
+	// unbindStatements run on the database to delete the user of a binding.
+	unbindStatements(database, username string) []string
+}
+
+// mysqlDialect grants privileges on the database to each user. Users created
+// through the CloudSQL API can access every database so they're created
+// with SQL instead.
+type mysqlDialect struct{}
+
+var _ sqlDialect = mysqlDialect{}
+
+func (mysqlDialect) driverName() string {
+	return "mysql"
+}
+
+func (mysqlDialect) dataSourceName(si *sharedInstance, database string) string {
+	cfg := mysql.NewConfig()
+	cfg.User = si.Username
+	cfg.Passwd = si.Password
+	cfg.Net = "tcp"
+	cfg.Addr = si.Address
+	cfg.DBName = database
+
+	return cfg.FormatDSN()
+}
+
+func (mysqlDialect) provisionStatements(database string) []string {
+	return nil
+}
+
+func (mysqlDialect) deprovisionStatements(database string) []string {
+	return nil
+}
+
+func (mysqlDialect) bindStatements(database, username, password, access string) []string {
+	user := mysqlQuoteLiteral(username) + "@'%'"
+	privileges := "ALL PRIVILEGES"
+	if access == readOnlyAccess {
+		privileges = "SELECT, SHOW VIEW"
+	}
+
+	return []string{
+		fmt.Sprintf("CREATE USER %s IDENTIFIED BY %s", user, mysqlQuoteLiteral(password)),
+		fmt.Sprintf("GRANT %s ON %s.* TO %s", privileges, mysqlQuoteIdentifier(database), user),
+	}
+}
+
+func (mysqlDialect) unbindStatements(database, username string) []string {
+	return []string{
+		fmt.Sprintf("DROP USER %s@'%%'", mysqlQuoteLiteral(username)),
+	}
+}
+
+func mysqlQuoteIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+func mysqlQuoteLiteral(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+// postgresDialect makes each database owned by a role of the same name that
+// read-write users are members of, so the objects they create belong to the
+// database rather than the binding. Read-only users are granted SELECT on
+// the objects of that role.
+type postgresDialect struct{}
+
+var _ sqlDialect = postgresDialect{}
+
+func (postgresDialect) driverName() string {
+	return "postgres"
+}
+
+func (postgresDialect) dataSourceName(si *sharedInstance, database string) string {
+	if database == "" {
+		database = "postgres"
+	}
+
+	connUrl := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(si.Username, si.Password),
+		Host:   si.Address,
+		Path:   "/" + database,
+
+		// the connection is expected to go through a local Cloud SQL proxy
+		RawQuery: url.Values{"sslmode": []string{"disable"}}.Encode(),
+	}
+
+	return connUrl.String()
+}
+
+// provisionStatements may run again if the provision is polled again before
+// it is recorded as finished, so the owner role is only created if it doesn't
+// exist yet.
+func (postgresDialect) provisionStatements(database string) []string {
+	owner := pq.QuoteIdentifier(database)
+
+	return []string{
+		fmt.Sprintf("DO $$ BEGIN IF NOT EXISTS (SELECT FROM pg_roles WHERE rolname = %s) THEN CREATE ROLE %s NOLOGIN; END IF; END $$", postgresQuoteLiteral(database), owner),
+		fmt.Sprintf("GRANT %s TO CURRENT_USER", owner),
+		fmt.Sprintf("ALTER DATABASE %s OWNER TO %s", owner, owner),
+		fmt.Sprintf("REVOKE ALL ON DATABASE %s FROM PUBLIC", owner),
+	}
+}
+
+func (postgresDialect) deprovisionStatements(database string) []string {
+	return []string{
+		fmt.Sprintf("DROP ROLE IF EXISTS %s", pq.QuoteIdentifier(database)),
+	}
+}
+
+func (postgresDialect) bindStatements(database, username, password, access string) []string {
+	owner := pq.QuoteIdentifier(database)
+	user := pq.QuoteIdentifier(username)
+
+	if access == readOnlyAccess {
+		return []string{
+			fmt.Sprintf("CREATE ROLE %s WITH LOGIN PASSWORD %s", user, postgresQuoteLiteral(password)),
+			fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO %s", owner, user),
+			fmt.Sprintf("GRANT SELECT ON ALL TABLES IN SCHEMA public TO %s", user),
+			fmt.Sprintf("ALTER DEFAULT PRIVILEGES FOR ROLE %s IN SCHEMA public GRANT SELECT ON TABLES TO %s", owner, user),
+		}
+	}
+
+	return []string{
+		fmt.Sprintf("CREATE ROLE %s WITH LOGIN PASSWORD %s IN ROLE %s", user, postgresQuoteLiteral(password), owner),
+		fmt.Sprintf("ALTER ROLE %s SET role = %s", user, owner),
+	}
+}
+
+func (postgresDialect) unbindStatements(database, username string) []string {
+	owner := pq.QuoteIdentifier(database)
+	user := pq.QuoteIdentifier(username)
+
+	return []string{
+		fmt.Sprintf("GRANT %s TO CURRENT_USER", user),
+		fmt.Sprintf("REASSIGN OWNED BY %s TO %s", user, owner),
+		fmt.Sprintf("DROP OWNED BY %s", user),
+		fmt.Sprintf("DROP ROLE %s", user),
+	}
+}
+
+func postgresQuoteLiteral(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
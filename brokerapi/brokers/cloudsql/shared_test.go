@@ -0,0 +1,283 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudsql
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// The shared instance integration test runs the statements of the dialect
+// selected by TEST_SHARED_INSTANCE_TYPE (mysql or postgres) against the server
+// at TEST_SHARED_INSTANCE_ADDRESS, e.g. a database in a local container.
+// The user must be able to create databases and users.
+const (
+	testSharedInstanceTypeEnv     = "TEST_SHARED_INSTANCE_TYPE"
+	testSharedInstanceAddressEnv  = "TEST_SHARED_INSTANCE_ADDRESS"
+	testSharedInstanceUsernameEnv = "TEST_SHARED_INSTANCE_USERNAME"
+	testSharedInstancePasswordEnv = "TEST_SHARED_INSTANCE_PASSWORD"
+)
+
+func TestSharedInstanceForPlan(t *testing.T) {
+	viper.Set("service.google-cloudsql-mysql.plans", `[{
+      "tier": "db-n1-standard-1",
+      "max_disk_size": "512",
+      "id": "00000000-0000-0000-0000-000000000011",
+      "name": "dedicated",
+      "pricing_plan": "PACKAGE"
+  },{
+      "tier": "db-n1-standard-1",
+      "max_disk_size": "512",
+      "id": "00000000-0000-0000-0000-000000000012",
+      "name": "shared",
+      "pricing_plan": "PACKAGE",
+      "shared_instance_name": "shared-mysql",
+      "shared_instance_address": "127.0.0.1:3306",
+      "shared_instance_username": "admin",
+      "shared_instance_password": "secret"
+  },{
+      "tier": "db-n1-standard-1",
+      "max_disk_size": "512",
+      "id": "00000000-0000-0000-0000-000000000013",
+      "name": "shared-without-address",
+      "pricing_plan": "PACKAGE",
+      "shared_instance_name": "shared-mysql"
+  }]`)
+	defer viper.Set("service.google-cloudsql-mysql.plans", "")
+
+	viper.Set("service.google-cloudsql-postgres.plans", `[{
+      "tier": "db-custom-1-3840",
+      "max_disk_size": "512",
+      "id": "00000000-0000-0000-0000-000000000014",
+      "name": "shared",
+      "pricing_plan": "PER_USE",
+      "shared_instance_name": "shared-postgres",
+      "shared_instance_address": "127.0.0.1:5432",
+      "shared_instance_username": "admin"
+  }]`)
+	defer viper.Set("service.google-cloudsql-postgres.plans", "")
+
+	mysqlService, err := mysqlServiceDefinition().CatalogEntry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	postgresService, err := postgresServiceDefinition().CatalogEntry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		ServiceId   string
+		PlanId      string
+		Expected    *sharedInstance
+		ErrContains string
+	}{
+		"dedicated plan": {
+			ServiceId: mysqlService.ID,
+			PlanId:    "00000000-0000-0000-0000-000000000011",
+			Expected:  nil,
+		},
+		"mysql shared plan": {
+			ServiceId: mysqlService.ID,
+			PlanId:    "00000000-0000-0000-0000-000000000012",
+			Expected: &sharedInstance{
+				Name:     "shared-mysql",
+				Address:  "127.0.0.1:3306",
+				Username: "admin",
+				Password: "secret",
+				dialect:  mysqlDialect{},
+			},
+		},
+		"postgres shared plan": {
+			ServiceId: postgresService.ID,
+			PlanId:    "00000000-0000-0000-0000-000000000014",
+			Expected: &sharedInstance{
+				Name:     "shared-postgres",
+				Address:  "127.0.0.1:5432",
+				Username: "admin",
+				dialect:  postgresDialect{},
+			},
+		},
+		"missing address": {
+			ServiceId:   mysqlService.ID,
+			PlanId:      "00000000-0000-0000-0000-000000000013",
+			ErrContains: "shared_instance_address",
+		},
+		"unknown plan": {
+			ServiceId:   mysqlService.ID,
+			PlanId:      "does-not-exist",
+			ErrContains: "could not be found",
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			actual, err := sharedInstanceForPlan(tc.ServiceId, tc.PlanId)
+			if tc.ErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ErrContains) {
+					t.Fatalf("Expected error containing %q, got %v", tc.ErrContains, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(actual, tc.Expected) {
+				t.Errorf("Expected shared instance %#v got %#v", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestSqlDialect_BindStatements(t *testing.T) {
+	cases := map[string]struct {
+		Dialect  sqlDialect
+		Access   string
+		Password string
+		Expected []string
+	}{
+		"mysql read-write": {
+			Dialect:  mysqlDialect{},
+			Access:   readWriteAccess,
+			Password: "pass",
+			Expected: []string{
+				"CREATE USER 'user'@'%' IDENTIFIED BY 'pass'",
+				"GRANT ALL PRIVILEGES ON `db`.* TO 'user'@'%'",
+			},
+		},
+		"mysql read-only": {
+			Dialect:  mysqlDialect{},
+			Access:   readOnlyAccess,
+			Password: "pass",
+			Expected: []string{
+				"CREATE USER 'user'@'%' IDENTIFIED BY 'pass'",
+				"GRANT SELECT, SHOW VIEW ON `db`.* TO 'user'@'%'",
+			},
+		},
+		"mysql escapes passwords": {
+			Dialect:  mysqlDialect{},
+			Access:   readWriteAccess,
+			Password: `it's\`,
+			Expected: []string{
+				`CREATE USER 'user'@'%' IDENTIFIED BY 'it''s\\'`,
+				"GRANT ALL PRIVILEGES ON `db`.* TO 'user'@'%'",
+			},
+		},
+		"postgres read-write": {
+			Dialect:  postgresDialect{},
+			Access:   readWriteAccess,
+			Password: "pass",
+			Expected: []string{
+				`CREATE ROLE "user" WITH LOGIN PASSWORD 'pass' IN ROLE "db"`,
+				`ALTER ROLE "user" SET role = "db"`,
+			},
+		},
+		"postgres read-only": {
+			Dialect:  postgresDialect{},
+			Access:   readOnlyAccess,
+			Password: "pass",
+			Expected: []string{
+				`CREATE ROLE "user" WITH LOGIN PASSWORD 'pass'`,
+				`GRANT CONNECT ON DATABASE "db" TO "user"`,
+				`GRANT SELECT ON ALL TABLES IN SCHEMA public TO "user"`,
+				`ALTER DEFAULT PRIVILEGES FOR ROLE "db" IN SCHEMA public GRANT SELECT ON TABLES TO "user"`,
+			},
+		},
+		"postgres escapes passwords": {
+			Dialect:  postgresDialect{},
+			Access:   readWriteAccess,
+			Password: `it's\`,
+			Expected: []string{
+				`CREATE ROLE "user" WITH LOGIN PASSWORD 'it''s\' IN ROLE "db"`,
+				`ALTER ROLE "user" SET role = "db"`,
+			},
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			actual := tc.Dialect.bindStatements("db", "user", tc.Password, tc.Access)
+			if !reflect.DeepEqual(actual, tc.Expected) {
+				t.Errorf("Expected statements %q got %q", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestSharedInstanceStatements_Integration(t *testing.T) {
+	si := &sharedInstance{
+		Name:     "test",
+		Address:  os.Getenv(testSharedInstanceAddressEnv),
+		Username: os.Getenv(testSharedInstanceUsernameEnv),
+		Password: os.Getenv(testSharedInstancePasswordEnv),
+	}
+
+	switch instanceType := os.Getenv(testSharedInstanceTypeEnv); instanceType {
+	case "":
+		t.Skipf("%s isn't set", testSharedInstanceTypeEnv)
+	case "mysql":
+		si.dialect = mysqlDialect{}
+	case "postgres":
+		si.dialect = postgresDialect{}
+	default:
+		t.Fatalf("unknown %s %q", testSharedInstanceTypeEnv, instanceType)
+	}
+
+	ctx := context.Background()
+	suffix := time.Now().UnixNano()
+	database := fmt.Sprintf("gsb_test_%d", suffix)
+	writer := fmt.Sprintf("gsb_rw_%d", suffix)
+	reader := fmt.Sprintf("gsb_ro_%d", suffix)
+
+	asUser := func(username string) *sharedInstance {
+		return &sharedInstance{Name: si.Name, Address: si.Address, Username: username, Password: "password", dialect: si.dialect}
+	}
+
+	mustRun := func(si *sharedInstance, database string, statements ...string) {
+		t.Helper()
+		if err := runSharedInstanceStatements(ctx, si, database, statements); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// CloudSQL creates databases through its API
+	mustRun(si, "", "CREATE DATABASE "+database)
+	mustRun(si, database, si.dialect.provisionStatements(database)...)
+	// the statements run again if the provision is polled again before it is recorded as finished
+	mustRun(si, database, si.dialect.provisionStatements(database)...)
+	mustRun(si, database, si.dialect.bindStatements(database, writer, "password", readWriteAccess)...)
+	mustRun(si, database, si.dialect.bindStatements(database, reader, "password", readOnlyAccess)...)
+
+	mustRun(asUser(writer), database, "CREATE TABLE things (id INT)", "INSERT INTO things VALUES (1)")
+	mustRun(asUser(reader), database, "SELECT id FROM things")
+	if err := runSharedInstanceStatements(ctx, asUser(reader), database, []string{"INSERT INTO things VALUES (2)"}); err == nil {
+		t.Error("Expected read-only user to be unable to insert")
+	}
+
+	mustRun(si, database, si.dialect.unbindStatements(database, reader)...)
+	mustRun(si, database, si.dialect.unbindStatements(database, writer)...)
+	mustRun(si, "", "DROP DATABASE "+database)
+	mustRun(si, "", si.dialect.deprovisionStatements(database)...)
+}
@@ -18,26 +18,213 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"time"
 
+	"code.cloudfoundry.org/lager"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/varcontext"
+	multierror "github.com/hashicorp/go-multierror"
+	"google.golang.org/api/googleapi"
 	googlecloudsql "google.golang.org/api/sqladmin/v1beta4"
 )
 
-// inserts a new user into the database and creates new ssl certs
+// starts inserting a new user into the database, the ssl certs are created by
+// finishSqlCredentials once the user exists because CloudSQL instances only
+// run one operation at a time
 func (broker *CloudSQLBroker) createSqlCredentials(ctx context.Context, vars *varcontext.VarContext) (map[string]interface{}, error) {
+	certName := vars.GetString("certname")
 
-	userAccount, err := broker.createSqlUserAccount(ctx, vars)
+	userAccount, op, err := broker.createSqlUserAccount(ctx, vars)
 	if err != nil {
 		return nil, err
 	}
 
-	sslCert, err := broker.createSqlSslCert(ctx, vars)
+	pending := pendingSqlCredentials{
+		UserOperationId: op.Name,
+		CertName:        certName,
+	}
+
+	return varcontext.Builder().MergeStruct(userAccount).MergeStruct(pending).BuildMap()
+}
+
+// finishSqlCredentials creates the ssl certs of a binding whose user was
+// inserted and replaces the pending details of the binding with them.
+func (broker *CloudSQLBroker) finishSqlCredentials(ctx context.Context, instance models.ServiceInstanceDetails, binding *models.ServiceBindingCredentials) error {
+	if isProxyBinding(*binding) {
+		return nil
+	}
+
+	var pending pendingSqlCredentials
+	if err := json.Unmarshal([]byte(binding.OtherDetails), &pending); err != nil {
+		return fmt.Errorf("Error unmarshalling credentials: %s", err)
+	}
+
+	// users in shared instances are created with their certs by Bind
+	if pending.UserOperationId == "" {
+		return nil
+	}
+
+	var creds map[string]interface{}
+	if err := json.Unmarshal([]byte(binding.OtherDetails), &creds); err != nil {
+		return fmt.Errorf("Error unmarshalling credentials: %s", err)
+	}
+	delete(creds, "UserOperationId")
+	delete(creds, "CertName")
+
+	sslCert, err := broker.createSqlSslCert(ctx, instance.Name, pending.CertName)
+	if err != nil {
+		return err
+	}
+
+	combinedCreds, err := varcontext.Builder().MergeMap(creds).MergeStruct(sslCert).BuildMap()
+	if err != nil {
+		return err
+	}
+
+	serializedCreds, err := json.Marshal(combinedCreds)
+	if err != nil {
+		return err
+	}
+
+	binding.OtherDetails = string(serializedCreds)
+	return nil
+}
+
+// rotateSqlCredentials replaces the service account key, user and ssl certs
+// of the binding with new ones. The old user and certs keep working until
+// they're deleted by retireSqlCredentials.
+// If it fails part way through, the new service account key is left to be
+// deleted with its account when the binding is deleted.
+func (broker *CloudSQLBroker) rotateSqlCredentials(ctx context.Context, instance models.ServiceInstanceDetails, binding *models.ServiceBindingCredentials) (map[string]interface{}, error) {
+	if isProxyBinding(*binding) {
+		return broker.BrokerBase.RotateCredentials(ctx, instance, binding)
+	}
+
+	var oldUser sqlUserAccount
+	if err := json.Unmarshal([]byte(binding.OtherDetails), &oldUser); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling credentials: %s", err)
+	}
+
+	var oldCert sqlSslCert
+	if err := json.Unmarshal([]byte(binding.OtherDetails), &oldCert); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling credentials: %s", err)
+	}
+
+	retired, err := broker.BrokerBase.RotateCredentials(ctx, instance, binding)
+	if err != nil {
+		return nil, err
+	}
+
+	vars, err := varcontext.Builder().
+		MergeEvalResult("username", usernameTemplate).
+		MergeEvalResult("password", passwordTemplate).
+		MergeMap(map[string]interface{}{"db_name": instance.Name}).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	newUser, op, err := broker.createSqlUserAccount(ctx, vars)
 	if err != nil {
 		return nil, err
 	}
 
-	return varcontext.Builder().MergeStruct(userAccount).MergeStruct(sslCert).BuildMap()
+	// CloudSQL instances only run one operation at a time
+	if err := broker.pollOperationUntilDone(ctx, op, broker.ProjectId); err != nil {
+		return nil, fmt.Errorf("Error encountered waiting for operation %q to finish: %s", op.Name, err)
+	}
+
+	// certs need a unique name per instance
+	certName := fmt.Sprintf("%.10scert%d", binding.BindingId, time.Now().UnixNano())
+	newCert, err := broker.createSqlSslCert(ctx, instance.Name, certName)
+	if err != nil {
+		if err := broker.deleteSqlUser(ctx, instance.Name, newUser.Username, false); err != nil {
+			broker.Logger.Error("delete-rotated-user", err, lager.Data{"binding_id": binding.BindingId})
+		}
+
+		return nil, err
+	}
+
+	var creds map[string]interface{}
+	if err := json.Unmarshal([]byte(binding.OtherDetails), &creds); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling credentials: %s", err)
+	}
+
+	combinedCreds, err := varcontext.Builder().MergeMap(creds).MergeStruct(newUser).MergeStruct(newCert).BuildMap()
+	if err != nil {
+		return nil, err
+	}
+
+	serializedCreds, err := json.Marshal(combinedCreds)
+	if err != nil {
+		return nil, err
+	}
+	binding.OtherDetails = string(serializedCreds)
+
+	retired[retiredUsernameField] = oldUser.Username
+	if oldCert.CaCert != "" {
+		retired[retiredSha1FingerprintField] = oldCert.Sha1Fingerprint
+	}
+
+	return retired, nil
+}
+
+// retireSqlCredentials deletes the user, ssl certs and service account key
+// replaced by rotateSqlCredentials. Ones that were already deleted are
+// ignored.
+func (broker *CloudSQLBroker) retireSqlCredentials(ctx context.Context, instance models.ServiceInstanceDetails, retired map[string]interface{}) error {
+	var accumulator error
+
+	if fingerprint, ok := retired[retiredSha1FingerprintField].(string); ok && fingerprint != "" {
+		if err := broker.deleteSqlSslCertByFingerprint(ctx, instance.Name, fingerprint, true); err != nil {
+			accumulator = multierror.Append(accumulator, err)
+		}
+	}
+
+	if username, ok := retired[retiredUsernameField].(string); ok && username != "" {
+		if err := broker.deleteSqlUser(ctx, instance.Name, username, true); err != nil {
+			accumulator = multierror.Append(accumulator, err)
+		}
+	}
+
+	if err := broker.BrokerBase.RetireCredentials(ctx, instance, retired); err != nil {
+		accumulator = multierror.Append(accumulator, err)
+	}
+
+	return accumulator
+}
+
+const (
+	retiredUsernameField        = "Username"
+	retiredSha1FingerprintField = "Sha1Fingerprint"
+)
+
+func isNotFoundError(err error) bool {
+	gerr, ok := err.(*googleapi.Error)
+	return ok && gerr.Code == http.StatusNotFound
+}
+
+const (
+	passwordBindMode = "password"
+	proxyBindMode    = "proxy"
+)
+
+// sqlBindMode is stored with bindings that don't use the default password
+// bind mode.
+type sqlBindMode struct {
+	BindMode string `json:"BindMode"`
+}
+
+// isProxyBinding checks if the binding only holds a service account for the
+// Cloud SQL proxy.
+func isProxyBinding(binding models.ServiceBindingCredentials) bool {
+	var mode sqlBindMode
+	if err := json.Unmarshal([]byte(binding.OtherDetails), &mode); err != nil {
+		return false
+	}
+
+	return mode.BindMode == proxyBindMode
 }
 
 type sqlUserAccount struct {
@@ -45,6 +232,12 @@ type sqlUserAccount struct {
 	Password string `json:"Password"`
 }
 
+// pendingSqlCredentials are stored with a binding until its user is inserted.
+type pendingSqlCredentials struct {
+	UserOperationId string `json:"UserOperationId"`
+	CertName        string `json:"CertName"`
+}
+
 type sqlSslCert struct {
 	CaCert          string `json:"CaCert"`
 	ClientCert      string `json:"ClientCert"`
@@ -52,7 +245,7 @@ type sqlSslCert struct {
 	Sha1Fingerprint string `json:"Sha1Fingerprint"`
 }
 
-func (broker *CloudSQLBroker) createSqlUserAccount(ctx context.Context, vars *varcontext.VarContext) (*sqlUserAccount, error) {
+func (broker *CloudSQLBroker) createSqlUserAccount(ctx context.Context, vars *varcontext.VarContext) (*sqlUserAccount, *googlecloudsql.Operation, error) {
 	request := &googlecloudsql.User{
 		Name:     vars.GetString("username"),
 		Password: vars.GetString("password"),
@@ -60,29 +253,24 @@ func (broker *CloudSQLBroker) createSqlUserAccount(ctx context.Context, vars *va
 	instanceName := vars.GetString("db_name")
 
 	if err := vars.Error(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// create username, pw with grants
 	client, err := broker.createClient(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	op, err := client.Users.Insert(broker.ProjectId, instanceName, request).Do()
 	if err != nil {
-		return nil, fmt.Errorf("Error creating new database user: %s", err)
-	}
-
-	// poll for the user creation operation to be completed
-	if err := broker.pollOperationUntilDone(ctx, op, broker.ProjectId); err != nil {
-		return nil, fmt.Errorf("Error encountered waiting for operation %q to finish: %s", op.Name, err)
+		return nil, nil, fmt.Errorf("Error creating new database user: %s", err)
 	}
 
 	return &sqlUserAccount{
 		Username: request.Name,
 		Password: request.Password,
-	}, nil
+	}, op, nil
 }
 
 func (broker *CloudSQLBroker) deleteSqlUserAccount(ctx context.Context, binding models.ServiceBindingCredentials, instance models.ServiceInstanceDetails) error {
@@ -91,13 +279,23 @@ func (broker *CloudSQLBroker) deleteSqlUserAccount(ctx context.Context, binding
 		return fmt.Errorf("Error unmarshalling credentials: %s", err)
 	}
 
+	return broker.deleteSqlUser(ctx, instance.Name, creds.Username, false)
+}
+
+// deleteSqlUser deletes the user from the instance. If ignoreMissing is set,
+// users that don't exist aren't an error.
+func (broker *CloudSQLBroker) deleteSqlUser(ctx context.Context, instanceName, username string, ignoreMissing bool) error {
 	client, err := broker.createClient(ctx)
 	if err != nil {
 		return err
 	}
 
-	op, err := client.Users.Delete(broker.ProjectId, instance.Name, "", creds.Username).Do()
+	op, err := client.Users.Delete(broker.ProjectId, instanceName, "", username).Do()
 	if err != nil {
+		if ignoreMissing && isNotFoundError(err) {
+			return nil
+		}
+
 		return fmt.Errorf("Error deleting user: %s", err)
 	}
 
@@ -108,17 +306,11 @@ func (broker *CloudSQLBroker) deleteSqlUserAccount(ctx context.Context, binding
 	return nil
 }
 
-func (broker *CloudSQLBroker) createSqlSslCert(ctx context.Context, vars *varcontext.VarContext) (*sqlSslCert, error) {
+func (broker *CloudSQLBroker) createSqlSslCert(ctx context.Context, instanceName, certName string) (*sqlSslCert, error) {
 	request := &googlecloudsql.SslCertsInsertRequest{
-		CommonName: vars.GetString("certname"),
-	}
-	instanceName := vars.GetString("db_name")
-
-	if err := vars.Error(); err != nil {
-		return nil, err
+		CommonName: certName,
 	}
 
-	// create username, pw with grants
 	client, err := broker.createClient(ctx)
 	if err != nil {
 		return nil, err
@@ -144,18 +336,28 @@ func (broker *CloudSQLBroker) deleteSqlSslCert(ctx context.Context, binding mode
 		return fmt.Errorf("Error unmarshalling credentials: %s", err)
 	}
 
-	client, err := broker.createClient(ctx)
-	if err != nil {
-		return err
-	}
-
 	// If we didn't generate SSL certs for this binding, then we cannot delete them
 	if creds.CaCert == "" {
 		return nil
 	}
 
-	op, err := client.SslCerts.Delete(broker.ProjectId, instance.Name, creds.Sha1Fingerprint).Do()
+	return broker.deleteSqlSslCertByFingerprint(ctx, instance.Name, creds.Sha1Fingerprint, false)
+}
+
+// deleteSqlSslCertByFingerprint deletes the ssl cert from the instance. If
+// ignoreMissing is set, certs that don't exist aren't an error.
+func (broker *CloudSQLBroker) deleteSqlSslCertByFingerprint(ctx context.Context, instanceName, sha1Fingerprint string, ignoreMissing bool) error {
+	client, err := broker.createClient(ctx)
 	if err != nil {
+		return err
+	}
+
+	op, err := client.SslCerts.Delete(broker.ProjectId, instanceName, sha1Fingerprint).Do()
+	if err != nil {
+		if ignoreMissing && isNotFoundError(err) {
+			return nil
+		}
+
 		return fmt.Errorf("Error deleting ssl cert: %s", err)
 	}
 
@@ -16,9 +16,13 @@ package brokers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"time"
 
 	"code.cloudfoundry.org/lager"
+	"github.com/jinzhu/gorm"
 	"github.com/pivotal-cf/brokerapi"
 	"golang.org/x/oauth2/jwt"
 	"google.golang.org/api/googleapi"
@@ -28,6 +32,7 @@ import (
 	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/varcontext"
 
 	// import the brokers to register them
 	_ "github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/api_service"
@@ -45,6 +50,15 @@ import (
 	_ "github.com/GoogleCloudPlatform/gcp-service-broker/pkg/providers/tf"
 )
 
+// ErrConcurrentInstanceAccess is returned when an operation on a service
+// instance is requested while another one is pending, or when two operations
+// race to change the instance. Platforms retry the request later.
+var ErrConcurrentInstanceAccess = brokerapi.NewFailureResponseBuilder(
+	errors.New("Another operation for this service instance is in progress"),
+	http.StatusUnprocessableEntity,
+	"concurrent-instance-access",
+).WithErrorKey("ConcurrencyError").Build()
+
 // GCPServiceBroker is a brokerapi.ServiceBroker that can be used to generate an OSB compatible service broker.
 type GCPServiceBroker struct {
 	enableInputValidation bool
@@ -100,13 +114,23 @@ func (gcpBroker *GCPServiceBroker) getDefinitionAndProvider(serviceId string) (*
 
 // Provision creates a new instance of a service.
 // It is bound to the `PUT /v2/service_instances/:instance_id` endpoint and can be called using the `cf create-service` command.
-func (gcpBroker *GCPServiceBroker) Provision(ctx context.Context, instanceID string, details brokerapi.ProvisionDetails, clientSupportsAsync bool) (brokerapi.ProvisionedServiceSpec, error) {
+func (gcpBroker *GCPServiceBroker) Provision(ctx context.Context, instanceID string, details brokerapi.ProvisionDetails, clientSupportsAsync bool) (response brokerapi.ProvisionedServiceSpec, err error) {
 	gcpBroker.Logger.Info("Provisioning", lager.Data{
 		"instanceId":         instanceID,
 		"accepts_incomplete": clientSupportsAsync,
 		"details":            details,
 	})
 
+	history := newHistoryEntry(ctx, models.ProvisionOperationType, models.ProvisionOperationType, instanceID, "")
+	history.setInstance(&models.ServiceInstanceDetails{
+		ServiceId:        details.ServiceID,
+		PlanId:           details.PlanID,
+		SpaceGuid:        details.SpaceGUID,
+		OrganizationGuid: details.OrganizationGUID,
+	})
+	history.setParameters(details.RawParameters)
+	defer func() { gcpBroker.recordHistory(ctx, history, requestResult(response.IsAsync), err) }()
+
 	// make sure that instance hasn't already been provisioned
 	count, err := db_service.CountServiceInstanceDetailsById(ctx, instanceID)
 	if err != nil {
@@ -140,14 +164,37 @@ func (gcpBroker *GCPServiceBroker) Provision(ctx context.Context, instanceID str
 		}
 	}
 
-	vars, err := brokerService.ProvisionVariables(instanceID, details, *plan)
+	vars, err := brokerService.ProvisionVariables(ctx, instanceID, details, *plan)
 	if err != nil {
 		return brokerapi.ProvisionedServiceSpec{}, err
 	}
 
+	// reserve the instance ID before creating anything so concurrent requests
+	// for the same ID can't both provision resources
+	reservation := models.ServiceInstanceDetails{
+		ID:               instanceID,
+		ServiceId:        details.ServiceID,
+		PlanId:           details.PlanID,
+		SpaceGuid:        details.SpaceGUID,
+		OrganizationGuid: details.OrganizationGUID,
+		OperationType:    models.ProvisionOperationType,
+	}
+	if err := db_service.CreateServiceInstanceDetails(ctx, &reservation); err != nil {
+		if count, countErr := db_service.CountServiceInstanceDetailsById(ctx, instanceID); countErr == nil && count > 0 {
+			return brokerapi.ProvisionedServiceSpec{}, ErrConcurrentInstanceAccess
+		}
+
+		return brokerapi.ProvisionedServiceSpec{}, fmt.Errorf("Database error reserving instance: %s", err)
+	}
+
 	// get instance details
 	instanceDetails, err := serviceHelper.Provision(ctx, vars)
 	if err != nil {
+		// free up the ID so the platform can retry the provision
+		if purgeErr := db_service.PurgeServiceInstanceDetails(ctx, &reservation); purgeErr != nil {
+			gcpBroker.Logger.Error("release-instance-id", purgeErr, lager.Data{"instance_id": instanceID})
+		}
+
 		return brokerapi.ProvisionedServiceSpec{}, err
 	}
 
@@ -157,16 +204,19 @@ func (gcpBroker *GCPServiceBroker) Provision(ctx context.Context, instanceID str
 	instanceDetails.PlanId = details.PlanID
 	instanceDetails.SpaceGuid = details.SpaceGUID
 	instanceDetails.OrganizationGuid = details.OrganizationGUID
+	instanceDetails.CreatedAt = reservation.CreatedAt
+	instanceDetails.Version = reservation.Version
 
-	err = db_service.CreateServiceInstanceDetails(ctx, &instanceDetails)
+	err = db_service.SaveServiceInstanceDetails(ctx, &instanceDetails)
 	if err != nil {
 		return brokerapi.ProvisionedServiceSpec{}, fmt.Errorf("Error saving instance details to database: %s. WARNING: this instance cannot be deprovisioned through cf. Contact your operator for cleanup", err)
 	}
 
 	// save provision request details
 	pr := models.ProvisionRequestDetails{
-		ServiceInstanceId: instanceID,
-		RequestDetails:    string(details.RawParameters),
+		ServiceInstanceId:   instanceID,
+		RequestDetails:      string(details.RawParameters),
+		OriginatingIdentity: broker.OriginatingIdentityFromContext(ctx).Serialize(),
 	}
 	if err = db_service.CreateProvisionRequestDetails(ctx, &pr); err != nil {
 		return brokerapi.ProvisionedServiceSpec{}, fmt.Errorf("Error saving provision request details to database: %s. Services relying on async provisioning will not be able to complete provisioning", err)
@@ -217,11 +267,15 @@ func (gcpBroker *GCPServiceBroker) Deprovision(ctx context.Context, instanceID s
 		"details":            details,
 	})
 
+	history := newHistoryEntry(ctx, models.DeprovisionOperationType, models.DeprovisionOperationType, instanceID, "")
+	defer func() { gcpBroker.recordHistory(ctx, history, requestResult(response.IsAsync), err) }()
+
 	// make sure that instance actually exists
 	instance, err := db_service.GetServiceInstanceDetailsById(ctx, instanceID)
 	if err != nil {
 		return response, brokerapi.ErrInstanceDoesNotExist
 	}
+	history.setInstance(instance)
 
 	_, serviceProvider, err := gcpBroker.getDefinitionAndProvider(instance.ServiceId)
 	if err != nil {
@@ -233,8 +287,14 @@ func (gcpBroker *GCPServiceBroker) Deprovision(ctx context.Context, instanceID s
 		return response, brokerapi.ErrAsyncRequired
 	}
 
+	gcpBroker.takeOverFailedOperation(ctx, serviceProvider, instance)
+	if err := claimInstance(ctx, instance, models.DeprovisionOperationType); err != nil {
+		return response, err
+	}
+
 	operationId, err := serviceProvider.Deprovision(ctx, *instance, details)
 	if err != nil {
+		gcpBroker.releaseInstance(ctx, instance)
 		return response, err
 	}
 
@@ -242,7 +302,7 @@ func (gcpBroker *GCPServiceBroker) Deprovision(ctx context.Context, instanceID s
 		// soft-delete instance details from the db if this is a synchronous operation
 		// if it's an async operation we can't delete from the db until we're sure delete succeeded, so this is
 		// handled internally to LastOperation
-		if err := db_service.DeleteServiceInstanceDetailsById(ctx, instanceID); err != nil {
+		if err := db_service.DeleteServiceInstanceDetails(ctx, instance); err != nil {
 			return response, fmt.Errorf("Error deleting instance details from database: %s. WARNING: this instance will remain visible in cf. Contact your operator for cleanup", err)
 		}
 		return response, nil
@@ -250,7 +310,6 @@ func (gcpBroker *GCPServiceBroker) Deprovision(ctx context.Context, instanceID s
 		response.IsAsync = true
 		response.OperationData = *operationId
 
-		instance.OperationType = models.DeprovisionOperationType
 		instance.OperationId = *operationId
 		if err := db_service.SaveServiceInstanceDetails(ctx, instance); err != nil {
 			return response, fmt.Errorf("Error saving instance details to database: %s. WARNING: this instance will remain visible in cf. Contact your operator for cleanup.", err)
@@ -259,56 +318,99 @@ func (gcpBroker *GCPServiceBroker) Deprovision(ctx context.Context, instanceID s
 	}
 }
 
+// BindingSpec is the result of a bind. Asynchronous binds don't have
+// credentials yet; they can be fetched with GetBinding once the operation
+// finishes.
+type BindingSpec struct {
+	brokerapi.Binding
+	IsAsync       bool
+	OperationData string
+}
+
+// UnbindSpec is the result of an unbind.
+type UnbindSpec struct {
+	IsAsync       bool
+	OperationData string
+}
+
+// bindingPollInterval is how often waitForBindingOperation checks the
+// operation.
+var bindingPollInterval = time.Second
+
+// ErrConcurrentBindingAccess is returned when an operation on a binding is
+// requested while another one is pending. Platforms retry the request later.
+var ErrConcurrentBindingAccess = brokerapi.NewFailureResponseBuilder(
+	errors.New("Another operation for this binding is in progress"),
+	http.StatusUnprocessableEntity,
+	"concurrent-binding-access",
+).WithErrorKey("ConcurrencyError").Build()
+
 // Bind creates an account with credentials to access an instance of a service.
 // It is bound to the `PUT /v2/service_instances/:instance_id/service_bindings/:binding_id` endpoint and can be called using the `cf bind-service` command.
+// Bindings of services that bind asynchronously are waited on.
 func (gcpBroker *GCPServiceBroker) Bind(ctx context.Context, instanceID, bindingID string, details brokerapi.BindDetails) (brokerapi.Binding, error) {
+	binding, err := gcpBroker.BindAsync(ctx, instanceID, bindingID, details, false)
+	return binding.Binding, err
+}
+
+// BindAsync creates an account with credentials to access an instance of a
+// service. If the service binds asynchronously and asyncAllowed is set, the
+// returned BindingSpec has no credentials and the operation can be tracked
+// with LastBindingOperation. Otherwise the operation is waited on.
+func (gcpBroker *GCPServiceBroker) BindAsync(ctx context.Context, instanceID, bindingID string, details brokerapi.BindDetails, asyncAllowed bool) (response BindingSpec, err error) {
 	gcpBroker.Logger.Info("Binding", lager.Data{
-		"instance_id": instanceID,
-		"binding_id":  bindingID,
-		"details":     details,
+		"instance_id":        instanceID,
+		"binding_id":         bindingID,
+		"details":            details,
+		"accepts_incomplete": asyncAllowed,
 	})
 
+	history := newHistoryEntry(ctx, models.BindOperationType, models.BindOperationType, instanceID, bindingID)
+	history.setParameters(details.RawParameters)
+	defer func() { gcpBroker.recordHistory(ctx, history, requestResult(response.IsAsync), err) }()
+
 	// check for existing binding
 	count, err := db_service.CountServiceBindingCredentialsByServiceInstanceIdAndBindingId(ctx, instanceID, bindingID)
 	if err != nil {
-		return brokerapi.Binding{}, fmt.Errorf("Error checking for existing binding: %s", err)
+		return BindingSpec{}, fmt.Errorf("Error checking for existing binding: %s", err)
 	}
 	if count > 0 {
-		return brokerapi.Binding{}, brokerapi.ErrBindingAlreadyExists
+		return BindingSpec{}, brokerapi.ErrBindingAlreadyExists
 	}
 
 	// get existing service instance details
 	instanceRecord, err := db_service.GetServiceInstanceDetailsById(ctx, instanceID)
 	if err != nil {
-		return brokerapi.Binding{}, fmt.Errorf("Error retrieving service instance details: %s", err)
+		return BindingSpec{}, fmt.Errorf("Error retrieving service instance details: %s", err)
 	}
+	history.setInstance(instanceRecord)
 
 	serviceDefinition, serviceProvider, err := gcpBroker.getDefinitionAndProvider(instanceRecord.ServiceId)
 	if err != nil {
-		return brokerapi.Binding{}, err
+		return BindingSpec{}, err
 	}
 
 	if gcpBroker.enableInputValidation {
 		// validate parameters meet the service's schema
 		if err := gcpBroker.validateBindVariables(details); err != nil {
-			return brokerapi.Binding{}, err
+			return BindingSpec{}, err
 		}
 	}
 
-	vars, err := serviceDefinition.BindVariables(*instanceRecord, bindingID, details)
+	vars, err := serviceDefinition.BindVariables(ctx, *instanceRecord, bindingID, details)
 	if err != nil {
-		return brokerapi.Binding{}, err
+		return BindingSpec{}, err
 	}
 
 	// create binding
 	credsDetails, err := serviceProvider.Bind(ctx, vars)
 	if err != nil {
-		return brokerapi.Binding{}, err
+		return BindingSpec{}, err
 	}
 
 	serializedCreds, err := json.Marshal(credsDetails)
 	if err != nil {
-		return brokerapi.Binding{}, fmt.Errorf("Error serializing credentials: %s. WARNING: these credentials cannot be unbound through cf. Please contact your operator for cleanup", err)
+		return BindingSpec{}, fmt.Errorf("Error serializing credentials: %s. WARNING: these credentials cannot be unbound through cf. Please contact your operator for cleanup", err)
 	}
 
 	// save binding to database
@@ -319,62 +421,319 @@ func (gcpBroker *GCPServiceBroker) Bind(ctx context.Context, instanceID, binding
 		OtherDetails:      string(serializedCreds),
 	}
 
+	bindsAsync := serviceProvider.BindsAsync()
+	if bindsAsync {
+		newCreds.OperationType = models.BindOperationType
+	}
+
 	if err := db_service.CreateServiceBindingCredentials(ctx, &newCreds); err != nil {
-		return brokerapi.Binding{}, fmt.Errorf("Error saving credentials to database: %s. WARNING: these credentials cannot be unbound through cf. Please contact your operator for cleanup",
+		return BindingSpec{}, fmt.Errorf("Error saving credentials to database: %s. WARNING: these credentials cannot be unbound through cf. Please contact your operator for cleanup",
 			err)
 	}
 
+	if bindsAsync {
+		if asyncAllowed {
+			return BindingSpec{IsAsync: true, OperationData: models.BindOperationType}, nil
+		}
+
+		if err := gcpBroker.waitForBindingOperation(ctx, instanceID, bindingID); err != nil {
+			// the platform doesn't know about the binding so it won't unbind it
+			if err := db_service.DeleteServiceBindingCredentials(ctx, &newCreds); err != nil {
+				gcpBroker.Logger.Error("delete-failed-binding", err, lager.Data{"binding_id": bindingID})
+			}
+
+			return BindingSpec{}, err
+		}
+
+		completedCreds, err := db_service.GetServiceBindingCredentialsByServiceInstanceIdAndBindingId(ctx, instanceID, bindingID)
+		if err != nil {
+			return BindingSpec{}, fmt.Errorf("Error retrieving binding: %s", err)
+		}
+		newCreds = *completedCreds
+	}
+
 	updatedCreds, err := serviceProvider.BuildInstanceCredentials(ctx, newCreds, *instanceRecord)
 	if err != nil {
-		return brokerapi.Binding{}, err
+		return BindingSpec{}, err
 	}
 
-	return brokerapi.Binding{Credentials: updatedCreds}, nil
+	return BindingSpec{Binding: brokerapi.Binding{Credentials: updatedCreds}}, nil
 }
 
 // Unbind destroys an account and credentials with access to an instance of a service.
 // It is bound to the `DELETE /v2/service_instances/:instance_id/service_bindings/:binding_id` endpoint and can be called using the `cf unbind-service` command.
+// Unbinds of services that bind asynchronously are waited on.
 func (gcpBroker *GCPServiceBroker) Unbind(ctx context.Context, instanceID, bindingID string, details brokerapi.UnbindDetails) error {
+	_, err := gcpBroker.UnbindAsync(ctx, instanceID, bindingID, details, false)
+	return err
+}
+
+// UnbindAsync destroys an account and credentials with access to an instance
+// of a service. If the service binds asynchronously and asyncAllowed is set,
+// the binding is deleted once LastBindingOperation reports the operation
+// finished. Otherwise the operation is waited on.
+func (gcpBroker *GCPServiceBroker) UnbindAsync(ctx context.Context, instanceID, bindingID string, details brokerapi.UnbindDetails, asyncAllowed bool) (response UnbindSpec, err error) {
 	gcpBroker.Logger.Info("Unbinding", lager.Data{
-		"instance_id": instanceID,
-		"binding_id":  bindingID,
-		"details":     details,
+		"instance_id":        instanceID,
+		"binding_id":         bindingID,
+		"details":            details,
+		"accepts_incomplete": asyncAllowed,
 	})
 
+	history := newHistoryEntry(ctx, models.UnbindOperationType, models.UnbindOperationType, instanceID, bindingID)
+	defer func() { gcpBroker.recordHistory(ctx, history, requestResult(response.IsAsync), err) }()
+
 	_, serviceProvider, err := gcpBroker.getDefinitionAndProvider(details.ServiceID)
 	if err != nil {
-		return err
+		return UnbindSpec{}, err
 	}
 
 	// validate existence of binding
 	existingBinding, err := db_service.GetServiceBindingCredentialsByServiceInstanceIdAndBindingId(ctx, instanceID, bindingID)
 	if err != nil {
-		return brokerapi.ErrBindingDoesNotExist
+		return UnbindSpec{}, brokerapi.ErrBindingDoesNotExist
+	}
+
+	if existingBinding.OperationType != models.ClearOperationType {
+		return UnbindSpec{}, ErrConcurrentBindingAccess
 	}
 
 	// get existing service instance details
 	instance, err := db_service.GetServiceInstanceDetailsById(ctx, instanceID)
 	if err != nil {
-		return fmt.Errorf("Error retrieving service instance details: %s", err)
+		return UnbindSpec{}, fmt.Errorf("Error retrieving service instance details: %s", err)
 	}
+	history.setInstance(instance)
 
 	// remove binding from Google
 	if err := serviceProvider.Unbind(ctx, *instance, *existingBinding); err != nil {
-		return err
+		return UnbindSpec{}, err
+	}
+
+	if serviceProvider.BindsAsync() {
+		existingBinding.OperationType = models.UnbindOperationType
+		if err := db_service.SaveServiceBindingCredentials(ctx, existingBinding); err != nil {
+			return UnbindSpec{}, fmt.Errorf("Error saving binding to database: %s", err)
+		}
+
+		if asyncAllowed {
+			return UnbindSpec{IsAsync: true, OperationData: models.UnbindOperationType}, nil
+		}
+
+		return UnbindSpec{}, gcpBroker.waitForBindingOperation(ctx, instanceID, bindingID)
 	}
 
 	// remove binding from database
 	if err := db_service.DeleteServiceBindingCredentials(ctx, existingBinding); err != nil {
-		return fmt.Errorf("Error soft-deleting credentials from database: %s. WARNING: these credentials will remain visible in cf. Contact your operator for cleanup", err)
+		return UnbindSpec{}, fmt.Errorf("Error soft-deleting credentials from database: %s. WARNING: these credentials will remain visible in cf. Contact your operator for cleanup", err)
 	}
 
-	return nil
+	return UnbindSpec{}, nil
+}
+
+// LastBindingOperation fetches the status of the last asynchronous bind or
+// unbind of a binding.
+// It is bound to the `GET /v2/service_instances/:instance_id/service_bindings/:binding_id/last_operation` endpoint.
+func (gcpBroker *GCPServiceBroker) LastBindingOperation(ctx context.Context, instanceID, bindingID, operationData string) (response brokerapi.LastOperation, err error) {
+	gcpBroker.Logger.Info("Last Binding Operation", lager.Data{
+		"instance_id":    instanceID,
+		"binding_id":     bindingID,
+		"operation_data": operationData,
+	})
+
+	binding, err := db_service.GetServiceBindingCredentialsByServiceInstanceIdAndBindingId(ctx, instanceID, bindingID)
+	if err != nil {
+		return brokerapi.LastOperation{}, brokerapi.ErrBindingDoesNotExist
+	}
+
+	instance, err := db_service.GetServiceInstanceDetailsById(ctx, instanceID)
+	if err != nil {
+		return brokerapi.LastOperation{}, fmt.Errorf("Error retrieving service instance details: %s", err)
+	}
+
+	_, serviceProvider, err := gcpBroker.getDefinitionAndProvider(instance.ServiceId)
+	if err != nil {
+		return brokerapi.LastOperation{}, err
+	}
+
+	if !serviceProvider.BindsAsync() {
+		return brokerapi.LastOperation{}, brokerapi.ErrAsyncRequired
+	}
+
+	lastOperationType := binding.OperationType
+	if lastOperationType == models.ClearOperationType {
+		return brokerapi.LastOperation{}, errors.New("Couldn't find any pending operations for this binding")
+	}
+
+	// only record polls that saw the operation finish
+	history := newHistoryEntry(ctx, models.LastBindingOperationRequest, lastOperationType, instanceID, bindingID)
+	history.setInstance(instance)
+	defer func() {
+		if response.State != brokerapi.InProgress {
+			gcpBroker.recordHistory(ctx, history, response.State, err)
+		}
+	}()
+
+	done, err := serviceProvider.PollBinding(ctx, *instance, *binding)
+	if err != nil {
+		// this is a retryable error
+		if gerr, ok := err.(*googleapi.Error); ok {
+			if gerr.Code == 503 {
+				return brokerapi.LastOperation{State: brokerapi.InProgress}, err
+			}
+		}
+
+		// This is not a retryable error. Clear the operation so the binding can
+		// be unbound and return fail.
+		binding.OperationType = models.ClearOperationType
+		if err := db_service.SaveServiceBindingCredentials(ctx, binding); err != nil {
+			gcpBroker.Logger.Error("release-binding", err, lager.Data{"binding_id": bindingID})
+		}
+
+		return brokerapi.LastOperation{State: brokerapi.Failed}, err
+	}
+
+	if !done {
+		return brokerapi.LastOperation{State: brokerapi.InProgress}, nil
+	}
+
+	if lastOperationType == models.UnbindOperationType {
+		if err := db_service.DeleteServiceBindingCredentials(ctx, binding); err != nil {
+			return brokerapi.LastOperation{State: brokerapi.Succeeded}, fmt.Errorf("Error soft-deleting credentials from database: %s. WARNING: these credentials will remain visible in cf. Contact your operator for cleanup", err)
+		}
+
+		return brokerapi.LastOperation{State: brokerapi.Succeeded}, nil
+	}
+
+	if err := serviceProvider.UpdateBindingDetails(ctx, *instance, binding); err != nil {
+		return brokerapi.LastOperation{State: brokerapi.Succeeded}, fmt.Errorf("Error getting the binding details from GCP: %v", err)
+	}
+
+	binding.OperationType = models.ClearOperationType
+	if err := db_service.SaveServiceBindingCredentials(ctx, binding); err != nil {
+		return brokerapi.LastOperation{State: brokerapi.Succeeded}, fmt.Errorf("Error saving binding to database: %v", err)
+	}
+
+	return brokerapi.LastOperation{State: brokerapi.Succeeded}, nil
+}
+
+// waitForBindingOperation polls the pending operation on a binding until it
+// finishes, for platforms that don't support asynchronous bindings.
+func (gcpBroker *GCPServiceBroker) waitForBindingOperation(ctx context.Context, instanceID, bindingID string) error {
+	for {
+		op, err := gcpBroker.LastBindingOperation(ctx, instanceID, bindingID, "")
+		if op.State != brokerapi.InProgress {
+			return err
+		}
+
+		if err != nil {
+			gcpBroker.Logger.Error("poll-binding", err, lager.Data{"binding_id": bindingID})
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(bindingPollInterval):
+		}
+	}
+}
+
+// GetInstanceDetailsSpec is the response to fetching a service instance.
+type GetInstanceDetailsSpec struct {
+	ServiceID    string          `json:"service_id"`
+	PlanID       string          `json:"plan_id"`
+	DashboardURL string          `json:"dashboard_url,omitempty"`
+	Parameters   json.RawMessage `json:"parameters,omitempty"`
+}
+
+// GetBindingSpec is the response to fetching a service binding.
+type GetBindingSpec struct {
+	Credentials interface{} `json:"credentials"`
+}
+
+// GetInstance fetches an existing instance of a service with the parameters
+// it was provisioned and updated with.
+// It is bound to the `GET /v2/service_instances/:instance_id` endpoint.
+// Instances that are still being provisioned don't exist yet, and instances
+// that are being updated return ErrConcurrentInstanceAccess because their
+// parameters are changing.
+func (gcpBroker *GCPServiceBroker) GetInstance(ctx context.Context, instanceID string) (GetInstanceDetailsSpec, error) {
+	gcpBroker.Logger.Info("Getting instance", lager.Data{
+		"instance_id": instanceID,
+	})
+
+	instance, err := db_service.GetServiceInstanceDetailsById(ctx, instanceID)
+	if err == gorm.ErrRecordNotFound {
+		return GetInstanceDetailsSpec{}, brokerapi.ErrInstanceDoesNotExist
+	} else if err != nil {
+		return GetInstanceDetailsSpec{}, fmt.Errorf("Error retrieving service instance details: %s", err)
+	}
+
+	switch instance.OperationType {
+	case models.ProvisionOperationType:
+		return GetInstanceDetailsSpec{}, brokerapi.ErrInstanceDoesNotExist
+	case models.UpdateOperationType:
+		return GetInstanceDetailsSpec{}, ErrConcurrentInstanceAccess
+	}
+
+	provisionRequest, err := db_service.GetProvisionRequestDetailsByServiceInstanceId(ctx, instanceID)
+	if err != nil {
+		return GetInstanceDetailsSpec{}, fmt.Errorf("Error retrieving provision request details: %s", err)
+	}
+
+	return GetInstanceDetailsSpec{
+		ServiceID:  instance.ServiceId,
+		PlanID:     instance.PlanId,
+		Parameters: json.RawMessage(provisionRequest.RequestDetails),
+	}, nil
+}
+
+// GetBinding fetches an existing binding, rebuilding its credentials the same
+// way Bind does. Bindings that are still being created don't exist yet.
+// It is bound to the `GET /v2/service_instances/:instance_id/service_bindings/:binding_id` endpoint.
+func (gcpBroker *GCPServiceBroker) GetBinding(ctx context.Context, instanceID, bindingID string) (GetBindingSpec, error) {
+	gcpBroker.Logger.Info("Getting binding", lager.Data{
+		"instance_id": instanceID,
+		"binding_id":  bindingID,
+	})
+
+	binding, err := db_service.GetServiceBindingCredentialsByServiceInstanceIdAndBindingId(ctx, instanceID, bindingID)
+	if err == gorm.ErrRecordNotFound {
+		return GetBindingSpec{}, brokerapi.ErrBindingDoesNotExist
+	} else if err != nil {
+		return GetBindingSpec{}, fmt.Errorf("Error retrieving binding details: %s", err)
+	}
+
+	// bindings don't exist until their bind finishes
+	switch binding.OperationType {
+	case models.BindOperationType:
+		return GetBindingSpec{}, brokerapi.ErrBindingDoesNotExist
+	case models.UnbindOperationType:
+		return GetBindingSpec{}, ErrConcurrentBindingAccess
+	}
+
+	instance, err := db_service.GetServiceInstanceDetailsById(ctx, instanceID)
+	if err != nil {
+		return GetBindingSpec{}, fmt.Errorf("Error retrieving service instance details: %s", err)
+	}
+
+	_, serviceProvider, err := gcpBroker.getDefinitionAndProvider(instance.ServiceId)
+	if err != nil {
+		return GetBindingSpec{}, err
+	}
+
+	credentials, err := serviceProvider.BuildInstanceCredentials(ctx, *binding, *instance)
+	if err != nil {
+		return GetBindingSpec{}, err
+	}
+
+	return GetBindingSpec{Credentials: credentials}, nil
 }
 
 // Unbind destroys an account and credentials with access to an instance of a service.
 // It is bound to the `GET /v2/service_instances/:instance_id/last_operation` endpoint.
 // It is called by `cf create-service` or `cf delete-service` if the operation was asynchronous.
-func (gcpBroker *GCPServiceBroker) LastOperation(ctx context.Context, instanceID, operationData string) (brokerapi.LastOperation, error) {
+func (gcpBroker *GCPServiceBroker) LastOperation(ctx context.Context, instanceID, operationData string) (response brokerapi.LastOperation, err error) {
 	gcpBroker.Logger.Info("Last Operation", lager.Data{
 		"instance_id":    instanceID,
 		"operation_data": operationData,
@@ -397,6 +756,15 @@ func (gcpBroker *GCPServiceBroker) LastOperation(ctx context.Context, instanceID
 
 	lastOperationType := instance.OperationType
 
+	// only record polls that saw the operation finish
+	history := newHistoryEntry(ctx, models.LastOperationRequest, lastOperationType, instanceID, "")
+	history.setInstance(instance)
+	defer func() {
+		if response.State != brokerapi.InProgress {
+			gcpBroker.recordHistory(ctx, history, response.State, err)
+		}
+	}()
+
 	done, err := serviceProvider.PollInstance(ctx, *instance)
 	if err != nil {
 		// this is a retryable error
@@ -405,12 +773,20 @@ func (gcpBroker *GCPServiceBroker) LastOperation(ctx context.Context, instanceID
 				return brokerapi.LastOperation{State: brokerapi.InProgress}, err
 			}
 		}
-		// This is not a retryable error. Return fail
+		// This is not a retryable error. Clear the operation so the instance can
+		// be updated or deprovisioned and return fail.
+		gcpBroker.releaseInstance(ctx, instance)
 		return brokerapi.LastOperation{State: brokerapi.Failed}, err
 	}
 
 	if !done {
-		return brokerapi.LastOperation{State: brokerapi.InProgress}, nil
+		// the description is informational so failing to get it shouldn't fail the poll
+		description, err := serviceProvider.DescribeOperation(ctx, *instance)
+		if err != nil {
+			gcpBroker.Logger.Error("describe-operation", err, lager.Data{"instance_id": instanceID})
+		}
+
+		return brokerapi.LastOperation{State: brokerapi.InProgress, Description: description}, nil
 	}
 
 	// the instance may have been invalidated, so we pass its primary key rather than the
@@ -422,20 +798,23 @@ func (gcpBroker *GCPServiceBroker) LastOperation(ctx context.Context, instanceID
 // updateStateOnOperationCompletion handles updating/cleaning-up resources that need to be changed
 // once lastOperation finishes successfully.
 func (gcpBroker *GCPServiceBroker) updateStateOnOperationCompletion(ctx context.Context, service broker.ServiceProvider, lastOperationType, instanceID string) error {
+	details, err := db_service.GetServiceInstanceDetailsById(ctx, instanceID)
+	if err != nil {
+		return fmt.Errorf("Error getting instance details from database %v", err)
+	}
+
 	if lastOperationType == models.DeprovisionOperationType {
-		if err := db_service.DeleteServiceInstanceDetailsById(ctx, instanceID); err != nil {
+		if err := db_service.DeleteServiceInstanceDetails(ctx, details); err == db_service.ErrConcurrentModification {
+			return ErrConcurrentInstanceAccess
+		} else if err != nil {
 			return fmt.Errorf("Error deleting instance details from database: %s. WARNING: this instance will remain visible in cf. Contact your operator for cleanup", err)
 		}
 
 		return nil
 	}
 
-	// If the operation was not a delete, clear out the ID and type and update
-	// any changed (or finalized) state like IP addresses, selflinks, etc.
-	details, err := db_service.GetServiceInstanceDetailsById(ctx, instanceID)
-	if err != nil {
-		return fmt.Errorf("Error getting instance details from database %v", err)
-	}
+	// If the operation was a provision or update, clear out the ID and type and
+	// update any changed (or finalized) state like IP addresses, selflinks, etc.
 
 	if err := service.UpdateInstanceDetails(ctx, details); err != nil {
 		return fmt.Errorf("Error getting new instance details from GCP: %v", err)
@@ -443,15 +822,289 @@ func (gcpBroker *GCPServiceBroker) updateStateOnOperationCompletion(ctx context.
 
 	details.OperationId = ""
 	details.OperationType = models.ClearOperationType
-	if err := db_service.SaveServiceInstanceDetails(ctx, details); err != nil {
+	if err := db_service.SaveServiceInstanceDetails(ctx, details); err == db_service.ErrConcurrentModification {
+		return ErrConcurrentInstanceAccess
+	} else if err != nil {
 		return fmt.Errorf("Error saving instance details to database %v", err)
 	}
 
 	return nil
 }
 
-// Update a service instance plan.
-// This functionality is not implemented and will return an error indicating that plan changes are not supported.
-func (gcpBroker *GCPServiceBroker) Update(ctx context.Context, instanceID string, details brokerapi.UpdateDetails, asyncAllowed bool) (brokerapi.UpdateServiceSpec, error) {
-	return brokerapi.UpdateServiceSpec{}, brokerapi.ErrPlanChangeNotSupported
+// claimInstance marks an operation as pending on the instance so other
+// operations are rejected until it finishes. It returns
+// ErrConcurrentInstanceAccess if another operation is already pending or the
+// instance was changed since it was read.
+func claimInstance(ctx context.Context, instance *models.ServiceInstanceDetails, operationType string) error {
+	if instance.OperationType != models.ClearOperationType {
+		return ErrConcurrentInstanceAccess
+	}
+
+	instance.OperationType = operationType
+	if err := db_service.SaveServiceInstanceDetails(ctx, instance); err != nil {
+		instance.OperationType = models.ClearOperationType
+		if err == db_service.ErrConcurrentModification {
+			return ErrConcurrentInstanceAccess
+		}
+
+		return fmt.Errorf("Error saving instance details to database: %s", err)
+	}
+
+	return nil
+}
+
+// takeOverFailedOperation clears the pending operation of an instance so a
+// deprovision can claim it if the operation failed, or if it's older than
+// models.StaleOperationAge. Operations that are still running, or that
+// couldn't be checked, are left alone so the claim is rejected.
+func (gcpBroker *GCPServiceBroker) takeOverFailedOperation(ctx context.Context, serviceProvider broker.ServiceProvider, instance *models.ServiceInstanceDetails) {
+	if instance.OperationType == models.ClearOperationType {
+		return
+	}
+
+	if time.Since(instance.UpdatedAt) < models.StaleOperationAge {
+		// synchronous operations can't be polled
+		if instance.OperationId == "" {
+			return
+		}
+
+		_, err := serviceProvider.PollInstance(ctx, *instance)
+		if err == nil {
+			return
+		}
+
+		if gerr, ok := err.(*googleapi.Error); ok && gerr.Code == http.StatusServiceUnavailable {
+			return
+		}
+	}
+
+	gcpBroker.Logger.Info("taking-over-operation", lager.Data{
+		"instance_id":    instance.ID,
+		"operation_type": instance.OperationType,
+		"operation_id":   instance.OperationId,
+	})
+	instance.OperationId = ""
+	instance.OperationType = models.ClearOperationType
+}
+
+// ReleaseInstance clears the pending operation of an instance regardless of
+// its state so it can be updated or deprovisioned again. It's meant for
+// operators to release instances whose operation is known to be dead.
+func (gcpBroker *GCPServiceBroker) ReleaseInstance(ctx context.Context, instanceID string) error {
+	instance, err := db_service.GetServiceInstanceDetailsById(ctx, instanceID)
+	if err != nil {
+		return fmt.Errorf("Error retrieving service instance details: %s", err)
+	}
+
+	if instance.OperationType == models.ClearOperationType {
+		return nil
+	}
+
+	gcpBroker.Logger.Info("releasing-instance", lager.Data{
+		"instance_id":    instance.ID,
+		"operation_type": instance.OperationType,
+		"operation_id":   instance.OperationId,
+	})
+	instance.OperationId = ""
+	instance.OperationType = models.ClearOperationType
+	if err := db_service.SaveServiceInstanceDetails(ctx, instance); err == db_service.ErrConcurrentModification {
+		return ErrConcurrentInstanceAccess
+	} else if err != nil {
+		return fmt.Errorf("Error saving instance details to database: %s", err)
+	}
+
+	return nil
+}
+
+// releaseInstance clears the pending operation of an instance after it failed
+// so new operations are accepted again. Failures are logged because the
+// original error is more useful to the caller.
+func (gcpBroker *GCPServiceBroker) releaseInstance(ctx context.Context, instance *models.ServiceInstanceDetails) {
+	if instance.OperationType == models.ClearOperationType {
+		return
+	}
+
+	instance.OperationId = ""
+	instance.OperationType = models.ClearOperationType
+	if err := db_service.SaveServiceInstanceDetails(ctx, instance); err != nil {
+		gcpBroker.Logger.Error("release-instance", err, lager.Data{"instance_id": instance.ID})
+	}
+}
+
+// Update changes the plan or parameters of an existing instance of a service.
+// It is bound to the `PATCH /v2/service_instances/:instance_id` endpoint and can be called using the `cf update-service` command.
+// If an update is asynchronous, the returned UpdateServiceSpec will contain the operation ID for tracking its progress.
+func (gcpBroker *GCPServiceBroker) Update(ctx context.Context, instanceID string, details brokerapi.UpdateDetails, asyncAllowed bool) (response brokerapi.UpdateServiceSpec, err error) {
+	gcpBroker.Logger.Info("Updating", lager.Data{
+		"instance_id":        instanceID,
+		"accepts_incomplete": asyncAllowed,
+		"details":            details,
+	})
+
+	history := newHistoryEntry(ctx, models.UpdateOperationType, models.UpdateOperationType, instanceID, "")
+	history.setParameters(details.RawParameters)
+	defer func() { gcpBroker.recordHistory(ctx, history, requestResult(response.IsAsync), err) }()
+
+	// make sure that instance actually exists
+	instance, err := db_service.GetServiceInstanceDetailsById(ctx, instanceID)
+	if err != nil {
+		return brokerapi.UpdateServiceSpec{}, brokerapi.ErrInstanceDoesNotExist
+	}
+	history.setInstance(instance)
+
+	brokerService, serviceProvider, err := gcpBroker.getDefinitionAndProvider(instance.ServiceId)
+	if err != nil {
+		return brokerapi.UpdateServiceSpec{}, err
+	}
+
+	// the plan is optional in update requests, fall back to the current one
+	if details.PlanID == "" {
+		details.PlanID = instance.PlanId
+	}
+	history.record.PlanId = details.PlanID
+
+	if details.PlanID != instance.PlanId {
+		catalogEntry, err := brokerService.CatalogEntry()
+		if err != nil {
+			return brokerapi.UpdateServiceSpec{}, err
+		}
+
+		if !catalogEntry.PlanUpdatable {
+			return brokerapi.UpdateServiceSpec{}, brokerapi.ErrPlanChangeNotSupported
+		}
+	}
+
+	plan, err := brokerService.GetPlanById(details.PlanID)
+	if err != nil {
+		return brokerapi.UpdateServiceSpec{}, err
+	}
+
+	// verify async updating is allowed if it is required
+	if serviceProvider.ProvisionsAsync() && !asyncAllowed {
+		return brokerapi.UpdateServiceSpec{}, brokerapi.ErrAsyncRequired
+	}
+
+	if gcpBroker.enableInputValidation {
+		// validate parameters meet the service's schema
+		if err := gcpBroker.validateUpdateVariables(instance.ServiceId, details); err != nil {
+			return brokerapi.UpdateServiceSpec{}, err
+		}
+	}
+
+	provisionRequest, err := db_service.GetProvisionRequestDetailsByServiceInstanceId(ctx, instanceID)
+	if err != nil {
+		return brokerapi.UpdateServiceSpec{}, fmt.Errorf("Error retrieving provision request details: %s", err)
+	}
+
+	// reject changes that can't be made without replacing the instance
+	if err := validateUpdatableVariables(brokerService, provisionRequest.RequestDetails, details); err != nil {
+		return brokerapi.UpdateServiceSpec{}, err
+	}
+
+	vars, err := brokerService.UpdateVariables(ctx, *instance, details, *provisionRequest, *plan)
+	if err != nil {
+		return brokerapi.UpdateServiceSpec{}, err
+	}
+
+	mergedParams, err := mergeUpdateParameters(provisionRequest.RequestDetails, details.RawParameters)
+	if err != nil {
+		return brokerapi.UpdateServiceSpec{}, err
+	}
+
+	if err := claimInstance(ctx, instance, models.UpdateOperationType); err != nil {
+		return brokerapi.UpdateServiceSpec{}, err
+	}
+
+	updatedInstance, err := serviceProvider.Update(ctx, *instance, vars)
+	if err != nil {
+		gcpBroker.releaseInstance(ctx, instance)
+		return brokerapi.UpdateServiceSpec{}, err
+	}
+
+	// the broker owns the identity of the instance, providers may only change
+	// the details GCP reports about it
+	updatedInstance.ID = instance.ID
+	updatedInstance.CreatedAt = instance.CreatedAt
+	updatedInstance.ServiceId = instance.ServiceId
+	updatedInstance.SpaceGuid = instance.SpaceGuid
+	updatedInstance.OrganizationGuid = instance.OrganizationGuid
+	updatedInstance.PlanId = details.PlanID
+	updatedInstance.Version = instance.Version
+
+	isAsync := updatedInstance.OperationId != ""
+	if isAsync {
+		updatedInstance.OperationType = models.UpdateOperationType
+	} else {
+		updatedInstance.OperationType = models.ClearOperationType
+	}
+
+	if err := db_service.SaveServiceInstanceDetails(ctx, &updatedInstance); err == db_service.ErrConcurrentModification {
+		return brokerapi.UpdateServiceSpec{}, ErrConcurrentInstanceAccess
+	} else if err != nil {
+		return brokerapi.UpdateServiceSpec{}, fmt.Errorf("Error saving instance details to database: %s. WARNING: the instance was updated but cf may show stale values. Contact your operator for cleanup", err)
+	}
+
+	// save the merged parameters so subsequent updates build on this one
+	provisionRequest.RequestDetails = mergedParams
+	if err := db_service.SaveProvisionRequestDetails(ctx, provisionRequest); err != nil {
+		return brokerapi.UpdateServiceSpec{}, fmt.Errorf("Error saving provision request details to database: %s. Subsequent updates may not include these parameters", err)
+	}
+
+	return brokerapi.UpdateServiceSpec{IsAsync: isAsync, OperationData: updatedInstance.OperationId}, nil
+}
+
+func (gcpBroker *GCPServiceBroker) validateUpdateVariables(serviceId string, details brokerapi.UpdateDetails) error {
+	serviceDefinition, err := gcpBroker.registry.GetServiceById(serviceId)
+	if err != nil {
+		return err
+	}
+
+	params := make(map[string]interface{})
+	if len(details.RawParameters) > 0 {
+		if err := json.Unmarshal([]byte(details.RawParameters), &params); err != nil {
+			return err
+		}
+	}
+
+	return broker.ValidateVariables(params, serviceDefinition.ProvisionInputVariables)
+}
+
+// validateUpdatableVariables checks the update request doesn't change any
+// parameters that force the replacement of the instance.
+func validateUpdatableVariables(serviceDefinition *broker.ServiceDefinition, originalParams string, details brokerapi.UpdateDetails) error {
+	original := make(map[string]interface{})
+	if len(originalParams) > 0 {
+		if err := json.Unmarshal([]byte(originalParams), &original); err != nil {
+			return err
+		}
+	}
+
+	params := make(map[string]interface{})
+	if len(details.RawParameters) > 0 {
+		if err := json.Unmarshal([]byte(details.RawParameters), &params); err != nil {
+			return err
+		}
+	}
+
+	return broker.ValidateUpdatableVariables(original, params, serviceDefinition.ProvisionInputVariables)
+}
+
+// mergeUpdateParameters overlays the parameters of an update request on the
+// parameters the instance was provisioned with and returns the result as a
+// JSON object.
+func mergeUpdateParameters(original string, update json.RawMessage) (string, error) {
+	merged, err := varcontext.Builder().
+		MergeJsonObject(json.RawMessage(original)).
+		MergeJsonObject(update).
+		BuildMap()
+	if err != nil {
+		return "", err
+	}
+
+	out, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
 }
@@ -0,0 +1,150 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
+	"github.com/pivotal-cf/brokerapi"
+)
+
+// redactedValue replaces the values of parameters that look like secrets in
+// the operation history.
+const redactedValue = "<redacted>"
+
+// sensitiveParameterNames are the substrings of parameter names whose values
+// are redacted in the operation history.
+var sensitiveParameterNames = []string{"password", "passwd", "secret", "token", "credential", "key"}
+
+// historyEntry collects the details of a request to the broker so it can be
+// added to the operation history once the request finishes.
+type historyEntry struct {
+	record models.OperationHistory
+	start  time.Time
+}
+
+func newHistoryEntry(ctx context.Context, request, operationType, instanceID, bindingID string) *historyEntry {
+	return &historyEntry{
+		record: models.OperationHistory{
+			ServiceInstanceId:   instanceID,
+			BindingId:           bindingID,
+			Request:             request,
+			OperationType:       operationType,
+			OriginatingIdentity: broker.OriginatingIdentityFromContext(ctx).Serialize(),
+		},
+		start: time.Now(),
+	}
+}
+
+// setInstance fills in the service, plan, org and space of the request from
+// the instance it operates on.
+func (entry *historyEntry) setInstance(instance *models.ServiceInstanceDetails) {
+	entry.record.ServiceId = instance.ServiceId
+	entry.record.PlanId = instance.PlanId
+	entry.record.OrganizationGuid = instance.OrganizationGuid
+	entry.record.SpaceGuid = instance.SpaceGuid
+}
+
+// setParameters stores the user-defined parameters of the request with their
+// secrets redacted.
+func (entry *historyEntry) setParameters(params json.RawMessage) {
+	entry.record.Parameters = redactParameters(params)
+}
+
+// recordHistory adds the entry to the operation history with the result of
+// the request. Failures are logged because the history mustn't fail requests.
+func (gcpBroker *GCPServiceBroker) recordHistory(ctx context.Context, entry *historyEntry, result brokerapi.LastOperationState, err error) {
+	entry.record.Result = string(result)
+	entry.record.DurationMillis = int64(time.Since(entry.start) / time.Millisecond)
+	if err != nil {
+		entry.record.Result = string(brokerapi.Failed)
+		entry.record.Message = err.Error()
+	}
+
+	if err := db_service.CreateOperationHistory(ctx, &entry.record); err != nil {
+		gcpBroker.Logger.Error("record-history", err, lager.Data{
+			"instance_id": entry.record.ServiceInstanceId,
+			"request":     entry.record.Request,
+		})
+	}
+}
+
+// requestResult gets the result to record for a request that started an
+// operation.
+func requestResult(isAsync bool) brokerapi.LastOperationState {
+	if isAsync {
+		return brokerapi.InProgress
+	}
+
+	return brokerapi.Succeeded
+}
+
+// redactParameters serializes the parameters with the values of any keys that
+// look like secrets replaced. Parameters that aren't valid JSON are redacted
+// entirely because they can't be inspected.
+func redactParameters(params json.RawMessage) string {
+	if len(params) == 0 {
+		return ""
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal(params, &parsed); err != nil {
+		return redactedValue
+	}
+
+	redacted, err := json.Marshal(redactValue(parsed))
+	if err != nil {
+		return redactedValue
+	}
+
+	return string(redacted)
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if isSensitiveParameter(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactValue(child)
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactValue(child)
+		}
+	}
+
+	return value
+}
+
+func isSensitiveParameter(name string) bool {
+	name = strings.ToLower(name)
+	for _, sensitive := range sensitiveParameterNames {
+		if strings.Contains(name, sensitive) {
+			return true
+		}
+	}
+
+	return false
+}
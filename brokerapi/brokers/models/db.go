@@ -16,6 +16,7 @@ package models
 
 import (
 	"encoding/json"
+	"time"
 )
 
 const (
@@ -27,14 +28,34 @@ const (
 	DeprovisionOperationType = "deprovision"
 	UpdateOperationType      = "update"
 	ClearOperationType       = ""
+
+	// The following operation types correspond to asynchronous bind/unbind
+	// calls and will exist on a ServiceBindingCredentials.
+	BindOperationType   = "bind"
+	UnbindOperationType = "unbind"
+
+	// RotateOperationType locks a ServiceBindingCredentials while its
+	// credentials are replaced. It's never reported to the platform.
+	RotateOperationType = "rotate"
+
+	// The following request types are only recorded in the OperationHistory
+	// for polls that saw an operation finish.
+	LastOperationRequest        = "last_operation"
+	LastBindingOperationRequest = "last_binding_operation"
 )
 
+// StaleOperationAge is how long the pending operation of an instance can go
+// without the instance being saved before it's considered abandoned, e.g.
+// because the broker stopped between locking the instance and starting the
+// operation.
+const StaleOperationAge = 24 * time.Hour
+
 // ServiceBindingCredentials holds credentials returned to the users after
 // binding to a service.
-type ServiceBindingCredentials ServiceBindingCredentialsV1
+type ServiceBindingCredentials ServiceBindingCredentialsV3
 
 // ServiceInstanceDetails holds information about provisioned services.
-type ServiceInstanceDetails ServiceInstanceDetailsV2
+type ServiceInstanceDetails ServiceInstanceDetailsV3
 
 // SetOtherDetails marshals the value passed in into a JSON string and sets
 // OtherDetails to it if marshalling was successful.
@@ -60,7 +81,7 @@ func (si ServiceInstanceDetails) GetOtherDetails(v interface{}) error {
 
 // ProvisionRequestDetails holds user-defined properties passed to a call
 // to provision a service.
-type ProvisionRequestDetails ProvisionRequestDetailsV1
+type ProvisionRequestDetails ProvisionRequestDetailsV2
 
 // Migration represents the mgirations table. It holds a monotonically
 // increasing number that gets incremented with every database schema revision.
@@ -72,4 +93,15 @@ type CloudOperation CloudOperationV1
 
 // TerraformDeployment holds Terraform state and plan information for resources
 // that use that execution system.
-type TerraformDeployment TerraformDeploymentV1
+type TerraformDeployment TerraformDeploymentV3
+
+// TerraformDeploymentLog holds a chunk of the Terraform output for an
+// operation on a TerraformDeployment.
+type TerraformDeploymentLog TerraformDeploymentLogV1
+
+// OperationHistory is an audit record of a request made to the broker.
+type OperationHistory OperationHistoryV1
+
+// RetiredCredentials holds binding credentials that were replaced and are
+// waiting to be deleted.
+type RetiredCredentials RetiredCredentialsV1
@@ -46,6 +46,59 @@ func (ServiceBindingCredentialsV1) TableName() string {
 	return "service_binding_credentials"
 }
 
+// ServiceBindingCredentialsV2 holds credentials returned to the users after
+// binding to a service. It adds the state of asynchronous bind and unbind
+// operations.
+type ServiceBindingCredentialsV2 struct {
+	gorm.Model
+
+	OtherDetails string `gorm:"type:text"`
+
+	ServiceId         string
+	ServiceInstanceId string
+	BindingId         string
+
+	// OperationType holds the kind of asynchronous operation pending on the
+	// binding. It's cleared once the operation finishes and the binding is
+	// "locked" for editing while it's set.
+	OperationType string
+}
+
+// TableName returns a consistent table name (`service_binding_credentials`) for
+// gorm so multiple structs from different versions of the database all operate
+// on the same table.
+func (ServiceBindingCredentialsV2) TableName() string {
+	return "service_binding_credentials"
+}
+
+// ServiceBindingCredentialsV3 holds credentials returned to the users after
+// binding to a service. It adds the time the credentials were last rotated.
+type ServiceBindingCredentialsV3 struct {
+	gorm.Model
+
+	OtherDetails string `gorm:"type:text"`
+
+	ServiceId         string
+	ServiceInstanceId string
+	BindingId         string
+
+	// OperationType holds the kind of asynchronous operation pending on the
+	// binding. It's cleared once the operation finishes and the binding is
+	// "locked" for editing while it's set.
+	OperationType string
+
+	// CredentialsRotatedAt is the last time the credentials of the binding
+	// were replaced, it's nil if they're the ones created by the bind.
+	CredentialsRotatedAt *time.Time
+}
+
+// TableName returns a consistent table name (`service_binding_credentials`) for
+// gorm so multiple structs from different versions of the database all operate
+// on the same table.
+func (ServiceBindingCredentialsV3) TableName() string {
+	return "service_binding_credentials"
+}
+
 // ServiceInstanceDetailsV1 holds information about provisioned services.
 type ServiceInstanceDetailsV1 struct {
 	ID        string `gorm:"primary_key;type:varchar(255);not null"`
@@ -107,6 +160,47 @@ func (ServiceInstanceDetailsV2) TableName() string {
 	return "service_instance_details"
 }
 
+// ServiceInstanceDetailsV3 holds information about provisioned services.
+// It adds a version so concurrent changes to an instance can be detected.
+type ServiceInstanceDetailsV3 struct {
+	ID        string `gorm:"primary_key;type:varchar(255);not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+
+	Name         string
+	Location     string
+	Url          string
+	OtherDetails string `gorm:"type:text"`
+
+	ServiceId        string
+	PlanId           string
+	SpaceGuid        string
+	OrganizationGuid string
+
+	// OperationType holds a string corresponding to what kind of operation
+	// OperationId is referencing. The object is "locked" for editing if
+	// an operation is pending.
+	OperationType string
+
+	// OperationId holds a string referencing an operation specific to a broker.
+	// Operations in GCP all have a unique ID.
+	// The OperationId will be cleared after a successful operation.
+	// This string MAY be sent to users and MUST NOT leak confidential information.
+	OperationId string `gorm:"type:varchar(1024)"`
+
+	// Version is incremented every time the record is saved. Saves of a copy
+	// read before the last save are rejected.
+	Version int `gorm:"not null;default:0"`
+}
+
+// TableName returns a consistent table name (`service_instance_details`) for
+// gorm so multiple structs from different versions of the database all operate
+// on the same table.
+func (ServiceInstanceDetailsV3) TableName() string {
+	return "service_instance_details"
+}
+
 // ProvisionRequestDetailsV1 holds user-defined properties passed to a call
 // to provision a service.
 type ProvisionRequestDetailsV1 struct {
@@ -124,12 +218,34 @@ func (ProvisionRequestDetailsV1) TableName() string {
 	return "provision_request_details"
 }
 
+// ProvisionRequestDetailsV2 holds user-defined properties passed to a call
+// to provision a service. It adds the identity of the user that provisioned
+// the service.
+type ProvisionRequestDetailsV2 struct {
+	gorm.Model
+
+	ServiceInstanceId string
+	// is a json.Marshal of models.ProvisionDetails
+	RequestDetails string
+
+	// OriginatingIdentity is the JSON serialized identity of the platform user
+	// that made the request, see broker.OriginatingIdentity.
+	OriginatingIdentity string `gorm:"type:text"`
+}
+
+// TableName returns a consistent table name (`provision_request_details`) for
+// gorm so multiple structs from different versions of the database all operate
+// on the same table.
+func (ProvisionRequestDetailsV2) TableName() string {
+	return "provision_request_details"
+}
+
 // MigrationV1 represents the mgirations table. It holds a monotonically
 // increasing number that gets incremented with every database schema revision.
 type MigrationV1 struct {
 	gorm.Model
 
-	MigrationId int `gorm:"type:int(10)"`
+	MigrationId int
 }
 
 // TableName returns a consistent table name (`migrations`) for gorm so
@@ -194,7 +310,7 @@ type TerraformDeploymentV1 struct {
 	DeletedAt *time.Time
 
 	// Workspace contains a JSON serialized version of the Terraform workspace.
-	Workspace string `sql:"type:clob"`
+	Workspace string `gorm:"type:text"`
 
 	// LastOperationType describes the last operation being performed on the resource.
 	LastOperationType string
@@ -213,3 +329,177 @@ type TerraformDeploymentV1 struct {
 func (TerraformDeploymentV1) TableName() string {
 	return "terraform_deployments"
 }
+
+// TerraformDeploymentV2 describes the state of a Terraform resource deployment.
+// It adds a lease so operations can be tracked across broker restarts.
+type TerraformDeploymentV2 struct {
+	ID        string `gorm:"primary_key;type:varchar(1024)"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+
+	// Workspace contains a JSON serialized version of the Terraform workspace.
+	Workspace string `gorm:"type:text"`
+
+	// LastOperationType describes the last operation being performed on the resource.
+	LastOperationType string
+
+	// LastOperationState holds one of the following strings "in progress", "succeeded", "failed".
+	// These mirror the OSB API.
+	LastOperationState string
+
+	// LastOperationMessage is a description that can be passed back to the user.
+	LastOperationMessage string
+
+	// LeaseOwner identifies the broker process running the current operation.
+	// It is empty if no operation is running.
+	LeaseOwner string
+
+	// LeaseExpiration is the time the lease must be renewed by. Operations with
+	// expired leases were abandoned by their owner and can be recovered.
+	LeaseExpiration *time.Time
+}
+
+// TableName returns a consistent table name (`tf_deployment`) for gorm so
+// multiple structs from different versions of the database all operate on the
+// same table.
+func (TerraformDeploymentV2) TableName() string {
+	return "terraform_deployments"
+}
+
+// TerraformDeploymentV3 describes the state of a Terraform resource deployment.
+// It adds the time the last operation was queued so the order of the job
+// queue survives broker restarts.
+type TerraformDeploymentV3 struct {
+	ID        string `gorm:"primary_key;type:varchar(1024)"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+
+	// Workspace contains a JSON serialized version of the Terraform workspace.
+	Workspace string `gorm:"type:text"`
+
+	// LastOperationType describes the last operation being performed on the resource.
+	LastOperationType string
+
+	// LastOperationState holds one of the following strings "queued",
+	// "in progress", "succeeded", "failed".
+	LastOperationState string
+
+	// LastOperationMessage is a description that can be passed back to the user.
+	LastOperationMessage string
+
+	// LeaseOwner identifies the broker process running the current operation.
+	// It is empty if no operation is running.
+	LeaseOwner string
+
+	// LeaseExpiration is the time the lease must be renewed by. Operations with
+	// expired leases were abandoned by their owner and can be recovered.
+	LeaseExpiration *time.Time
+
+	// QueuedAt is the time the last operation was queued. Jobs waiting for a
+	// slot in the job pool are started oldest first.
+	QueuedAt *time.Time
+}
+
+// TableName returns a consistent table name (`tf_deployment`) for gorm so
+// multiple structs from different versions of the database all operate on the
+// same table.
+func (TerraformDeploymentV3) TableName() string {
+	return "terraform_deployments"
+}
+
+// TerraformDeploymentLogV1 holds a chunk of the output Terraform printed while
+// running an operation on a deployment.
+type TerraformDeploymentLogV1 struct {
+	gorm.Model
+
+	// TerraformDeploymentId is the ID of the deployment the operation ran on.
+	TerraformDeploymentId string `gorm:"index"`
+
+	// OperationId uniquely identifies the run of the operation the output
+	// belongs to because the same type of operation may run several times.
+	OperationId string
+
+	// OperationType is the type of the operation that produced the output.
+	OperationType string
+
+	// Output holds the combined stdout and stderr of Terraform.
+	Output string `gorm:"type:text"`
+}
+
+// TableName returns a consistent table name (`terraform_deployment_logs`) for
+// gorm so multiple structs from different versions of the database all operate
+// on the same table.
+func (TerraformDeploymentLogV1) TableName() string {
+	return "terraform_deployment_logs"
+}
+
+// OperationHistoryV1 is an audit record of a request made to the broker.
+type OperationHistoryV1 struct {
+	gorm.Model
+
+	ServiceInstanceId string `gorm:"index"`
+	BindingId         string
+
+	// Request is the OSB call that was made, one of: provision, update,
+	// deprovision, bind, unbind, last_operation or last_binding_operation.
+	Request string
+
+	// OperationType is the operation the request started or polled.
+	OperationType string
+
+	ServiceId        string
+	PlanId           string
+	OrganizationGuid string
+	SpaceGuid        string
+
+	// OriginatingIdentity is the JSON serialized identity of the platform user
+	// that made the request, see broker.OriginatingIdentity.
+	OriginatingIdentity string `gorm:"type:text"`
+
+	// Parameters holds the user-defined parameters of the request with any
+	// secrets redacted.
+	Parameters string `gorm:"type:text"`
+
+	// Result holds one of the following strings "in progress", "succeeded",
+	// "failed". These mirror the OSB API.
+	Result string
+
+	// Message describes why the request failed.
+	Message string `gorm:"type:text"`
+
+	// DurationMillis is how long the broker took to respond to the request.
+	DurationMillis int64
+}
+
+// TableName returns a consistent table name (`operation_histories`) for gorm so
+// multiple structs from different versions of the database all operate on the
+// same table.
+func (OperationHistoryV1) TableName() string {
+	return "operation_histories"
+}
+
+// RetiredCredentialsV1 holds the details of binding credentials that were
+// replaced by a rotation and will be deleted once their grace period ends.
+type RetiredCredentialsV1 struct {
+	gorm.Model
+
+	ServiceId         string
+	ServiceInstanceId string
+	BindingId         string `gorm:"index"`
+
+	// Details holds the JSON object the service provider returned to identify
+	// the credentials when it rotated them.
+	Details string `gorm:"type:text"`
+
+	// RetireAt is when the credentials stop being usable.
+	RetireAt time.Time `gorm:"index"`
+}
+
+// TableName returns a consistent table name (`retired_credentials`) for gorm so
+// multiple structs from different versions of the database all operate on the
+// same table.
+func (RetiredCredentialsV1) TableName() string {
+	return "retired_credentials"
+}
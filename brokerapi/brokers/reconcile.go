@@ -0,0 +1,320 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/providers/tf"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/utils"
+	"github.com/pivotal-cf/brokerapi"
+)
+
+// DefaultMinOrphanAge is how old orphaned resources must be before the
+// reconcile command deprovisions them by default.
+const DefaultMinOrphanAge = time.Hour
+
+// ReconcileOptions control what Reconcile cleans up.
+type ReconcileOptions struct {
+	// Cleanup deletes the records of dangling instances and bindings.
+	Cleanup bool
+	// DeprovisionOrphans deprovisions orphaned resources. Another broker, or
+	// a provision that finished after the instances were listed, may own
+	// them, so each one is checked against the database again first and only
+	// resources older than MinOrphanAge are deprovisioned.
+	DeprovisionOrphans bool
+	// MinOrphanAge is how old an orphaned resource must be to be deprovisioned.
+	MinOrphanAge time.Duration
+}
+
+// ReconcileReport lists the differences Reconcile found between the broker's
+// database and GCP.
+type ReconcileReport struct {
+	// DanglingInstances are instance records whose resources no longer exist.
+	DanglingInstances []ReconcileItem `json:"dangling_instances"`
+	// DanglingBindings are binding records whose resources or instance no
+	// longer exist.
+	DanglingBindings []ReconcileItem `json:"dangling_bindings"`
+	// OrphanedResources are labeled resources without an instance record.
+	OrphanedResources []ReconcileItem `json:"orphaned_resources"`
+	// Errors are the problems that stopped records or services from being
+	// checked or cleaned up.
+	Errors []string `json:"errors"`
+}
+
+// ReconcileItem identifies a record or resource in a ReconcileReport.
+type ReconcileItem struct {
+	ServiceId  string `json:"service_id"`
+	InstanceId string `json:"instance_id"`
+	BindingId  string `json:"binding_id,omitempty"`
+	Name       string `json:"name,omitempty"`
+	// CleanedUp is true if the record was deleted or the resource deprovisioned.
+	CleanedUp bool `json:"cleaned_up"`
+	// Skipped explains why an orphaned resource wasn't deprovisioned.
+	Skipped string `json:"skipped,omitempty"`
+}
+
+func (report *ReconcileReport) addError(format string, a ...interface{}) {
+	report.Errors = append(report.Errors, fmt.Sprintf(format, a...))
+}
+
+// Reconcile compares the instances and bindings in the database to the
+// resources in GCP. It reports records whose resources were deleted outside of
+// the broker, and resources labeled with an instance ID that have no record,
+// for example because saving the record failed after they were created.
+//
+// See ReconcileOptions for what gets cleaned up.
+// Instances and bindings with a pending operation are skipped because their
+// resources may not exist yet or may be in the middle of being deleted.
+func (gcpBroker *GCPServiceBroker) Reconcile(ctx context.Context, opts ReconcileOptions) (*ReconcileReport, error) {
+	instances, err := db_service.ListServiceInstanceDetails(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing instances: %s", err)
+	}
+
+	bindings, err := db_service.ListServiceBindingCredentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing bindings: %s", err)
+	}
+
+	report := &ReconcileReport{}
+	instanceBindings := make(map[string][]models.ServiceBindingCredentials)
+	for _, binding := range bindings {
+		instanceBindings[binding.ServiceInstanceId] = append(instanceBindings[binding.ServiceInstanceId], binding)
+	}
+
+	// labels only hold some characters, so compare against the IDs as they'd be labeled
+	knownInstances := make(map[string]models.ServiceInstanceDetails)
+	for _, instance := range instances {
+		knownInstances[utils.SanitizeLabelValue(instance.ID)] = instance
+	}
+
+	for _, instance := range instances {
+		gcpBroker.reconcileInstance(ctx, instance, instanceBindings[instance.ID], opts.Cleanup, report)
+	}
+
+	for _, binding := range bindings {
+		if _, ok := knownInstances[utils.SanitizeLabelValue(binding.ServiceInstanceId)]; ok {
+			continue
+		}
+
+		// the instance is gone so the binding can't be unbound, only forgotten
+		item := ReconcileItem{ServiceId: binding.ServiceId, InstanceId: binding.ServiceInstanceId, BindingId: binding.BindingId}
+		if opts.Cleanup {
+			item.CleanedUp = gcpBroker.deleteBindingRecord(ctx, binding, report)
+		}
+		report.DanglingBindings = append(report.DanglingBindings, item)
+	}
+
+	gcpBroker.reconcileResources(ctx, knownInstances, opts, report)
+
+	return report, nil
+}
+
+// reconcileInstance checks the resources of the instance and its bindings.
+func (gcpBroker *GCPServiceBroker) reconcileInstance(ctx context.Context, instance models.ServiceInstanceDetails, bindings []models.ServiceBindingCredentials, cleanup bool, report *ReconcileReport) {
+	if instance.OperationType != models.ClearOperationType {
+		return
+	}
+
+	_, provider, err := gcpBroker.getDefinitionAndProvider(instance.ServiceId)
+	if err != nil {
+		report.addError("instance %q: %s", instance.ID, err)
+		return
+	}
+
+	exists, err := provider.Exists(ctx, instance)
+	if err != nil {
+		report.addError("instance %q: %s", instance.ID, err)
+		return
+	}
+
+	if !exists {
+		item := ReconcileItem{ServiceId: instance.ServiceId, InstanceId: instance.ID, Name: instance.Name}
+		if cleanup {
+			item.CleanedUp = gcpBroker.deleteInstanceRecord(ctx, instance, bindings, report)
+		}
+		report.DanglingInstances = append(report.DanglingInstances, item)
+
+		// the bindings were handled with the instance
+		return
+	}
+
+	for _, binding := range bindings {
+		if binding.OperationType != models.ClearOperationType {
+			continue
+		}
+
+		exists, err := provider.BindingExists(ctx, instance, binding)
+		if err != nil {
+			report.addError("binding %q: %s", binding.BindingId, err)
+			continue
+		}
+
+		if exists {
+			continue
+		}
+
+		item := ReconcileItem{ServiceId: binding.ServiceId, InstanceId: instance.ID, BindingId: binding.BindingId}
+		if cleanup {
+			item.CleanedUp = gcpBroker.deleteBindingRecord(ctx, binding, report)
+		}
+		report.DanglingBindings = append(report.DanglingBindings, item)
+	}
+}
+
+// deleteInstanceRecord deletes the records of an instance whose resources are
+// gone along with the records of its bindings. The bindings aren't unbound
+// because their credentials were granted on resources that no longer exist.
+// It returns true if everything was cleaned up.
+func (gcpBroker *GCPServiceBroker) deleteInstanceRecord(ctx context.Context, instance models.ServiceInstanceDetails, bindings []models.ServiceBindingCredentials, report *ReconcileReport) bool {
+	for _, binding := range bindings {
+		if !gcpBroker.deleteBindingRecord(ctx, binding, report) {
+			return false
+		}
+	}
+
+	if err := db_service.DeleteProvisionRequestDetailsByServiceInstanceId(ctx, instance.ID); err != nil {
+		report.addError("deleting the provision request of the dangling instance %q: %s", instance.ID, err)
+		return false
+	}
+
+	if err := db_service.DeleteTerraformDeploymentById(ctx, tf.DeploymentId(instance.ID, "")); err != nil {
+		report.addError("deleting the Terraform deployment of the dangling instance %q: %s", instance.ID, err)
+		return false
+	}
+
+	if err := db_service.DeleteServiceInstanceDetails(ctx, &instance); err != nil {
+		report.addError("deleting the dangling instance %q: %s", instance.ID, err)
+		return false
+	}
+
+	gcpBroker.Logger.Info("reconcile-deleted-instance", lager.Data{"instance_id": instance.ID})
+	return true
+}
+
+// deleteBindingRecord deletes the record of a binding whose resources are
+// gone, and its Terraform deployment if it has one. It returns true if the
+// records were deleted.
+func (gcpBroker *GCPServiceBroker) deleteBindingRecord(ctx context.Context, binding models.ServiceBindingCredentials, report *ReconcileReport) bool {
+	if err := db_service.DeleteTerraformDeploymentById(ctx, tf.DeploymentId(binding.ServiceInstanceId, binding.BindingId)); err != nil {
+		report.addError("deleting the Terraform deployment of the dangling binding %q: %s", binding.BindingId, err)
+		return false
+	}
+
+	if err := db_service.DeleteServiceBindingCredentials(ctx, &binding); err != nil {
+		report.addError("deleting the dangling binding %q: %s", binding.BindingId, err)
+		return false
+	}
+
+	gcpBroker.Logger.Info("reconcile-deleted-binding", lager.Data{"binding_id": binding.BindingId})
+	return true
+}
+
+// reconcileResources looks for labeled resources of the enabled services that
+// don't belong to a known instance.
+func (gcpBroker *GCPServiceBroker) reconcileResources(ctx context.Context, knownInstances map[string]models.ServiceInstanceDetails, opts ReconcileOptions, report *ReconcileReport) {
+	services, err := gcpBroker.registry.GetEnabledServices()
+	if err != nil {
+		report.addError("listing services: %s", err)
+		return
+	}
+
+	// services backed by the same provider, like the CloudSQL ones, list the
+	// same resources
+	seen := make(map[string]bool)
+	for _, service := range services {
+		entry, err := service.CatalogEntry()
+		if err != nil {
+			report.addError("service %q: %s", service.Name, err)
+			continue
+		}
+
+		_, provider, err := gcpBroker.getDefinitionAndProvider(entry.ID)
+		if err != nil {
+			report.addError("service %q: %s", service.Name, err)
+			continue
+		}
+
+		resources, err := provider.DescribeResources(ctx)
+		if err != nil {
+			report.addError("service %q: %s", service.Name, err)
+			continue
+		}
+
+		for _, resource := range resources {
+			key := fmt.Sprintf("%T/%s/%s", provider, resource.ID, resource.Name)
+			if _, ok := knownInstances[resource.ID]; ok || seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			item := ReconcileItem{ServiceId: entry.ID, InstanceId: resource.ID, Name: resource.Name}
+			if opts.DeprovisionOrphans {
+				resource.ServiceId = entry.ID
+				item.Skipped = gcpBroker.checkOrphan(ctx, resource, opts.MinOrphanAge)
+				if item.Skipped == "" {
+					item.CleanedUp = gcpBroker.deprovisionResource(ctx, provider, resource, report)
+				}
+			}
+			report.OrphanedResources = append(report.OrphanedResources, item)
+		}
+	}
+}
+
+// checkOrphan makes sure a resource is safe to deprovision. It returns why the
+// resource must be skipped, or an empty string if it can be deprovisioned.
+func (gcpBroker *GCPServiceBroker) checkOrphan(ctx context.Context, resource models.ServiceInstanceDetails, minAge time.Duration) string {
+	if resource.CreatedAt.IsZero() {
+		return "the age of the resource is unknown"
+	}
+
+	if time.Since(resource.CreatedAt) < minAge {
+		return fmt.Sprintf("the resource is less than %s old", minAge)
+	}
+
+	// the instance may have been provisioned after the instances were listed,
+	// or deleted while its resources are still being deprovisioned
+	instances, err := db_service.ListServiceInstanceDetailsWithDeleted(ctx)
+	if err != nil {
+		return fmt.Sprintf("couldn't check for an instance record: %s", err)
+	}
+
+	for _, instance := range instances {
+		if utils.SanitizeLabelValue(instance.ID) == resource.ID {
+			return "the resource has an instance record"
+		}
+	}
+
+	return ""
+}
+
+// deprovisionResource deletes a resource that has no instance record. It
+// returns true if the deletion succeeded or was started.
+func (gcpBroker *GCPServiceBroker) deprovisionResource(ctx context.Context, provider broker.ServiceProvider, resource models.ServiceInstanceDetails, report *ReconcileReport) bool {
+	if _, err := provider.Deprovision(ctx, resource, brokerapi.DeprovisionDetails{ServiceID: resource.ServiceId}); err != nil {
+		report.addError("deprovisioning the orphaned resource %q: %s", resource.Name, err)
+		return false
+	}
+
+	gcpBroker.Logger.Info("reconcile-deprovisioned-resource", lager.Data{"instance_id": resource.ID, "name": resource.Name})
+	return true
+}
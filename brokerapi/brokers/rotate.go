@@ -0,0 +1,266 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package brokers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
+)
+
+// RotationOptions selects the bindings RotateCredentials replaces the
+// credentials of.
+type RotationOptions struct {
+	// InstanceId limits the rotation to the bindings of an instance.
+	InstanceId string
+	// BindingId limits the rotation to a single binding.
+	BindingId string
+	// MaxAge is how old credentials can get before they're rotated, bindings
+	// with newer credentials are left alone.
+	MaxAge time.Duration
+	// GracePeriod is how long the replaced credentials keep working.
+	GracePeriod time.Duration
+}
+
+// RotationReport lists the credentials RotateCredentials replaced and
+// deleted.
+type RotationReport struct {
+	// Rotated are the bindings that got new credentials.
+	Rotated []RotationItem `json:"rotated"`
+	// Retired are the bindings whose replaced credentials were deleted.
+	Retired []RotationItem `json:"retired"`
+	// Skipped are the bindings that were due for rotation but couldn't be
+	// rotated.
+	Skipped []RotationItem `json:"skipped"`
+	// Errors are the problems that stopped credentials from being rotated or
+	// retired.
+	Errors []string `json:"errors"`
+}
+
+// RotationItem identifies a binding in a RotationReport.
+type RotationItem struct {
+	ServiceId  string `json:"service_id"`
+	InstanceId string `json:"instance_id"`
+	BindingId  string `json:"binding_id"`
+	// RetireAt is when the replaced credentials of a rotated binding will be
+	// deleted.
+	RetireAt *time.Time `json:"retire_at,omitempty"`
+	// Reason explains why a binding was skipped.
+	Reason string `json:"reason,omitempty"`
+}
+
+func (report *RotationReport) addError(format string, a ...interface{}) {
+	report.Errors = append(report.Errors, fmt.Sprintf(format, a...))
+}
+
+// RotateCredentials replaces the credentials of the bindings that are older
+// than opts.MaxAge, then deletes the credentials whose grace period is over.
+// The new credentials are returned when the bindings are fetched, and the old
+// ones keep working for opts.GracePeriod so applications can pick them up.
+//
+// Bindings with a pending operation, bindings of instances with a pending
+// operation, and bindings of services that don't support rotation are
+// skipped. Bindings are locked while they're rotated so they can't be unbound
+// or rotated by someone else at the same time.
+func (gcpBroker *GCPServiceBroker) RotateCredentials(ctx context.Context, opts RotationOptions) (*RotationReport, error) {
+	bindings, err := db_service.ListServiceBindingCredentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing bindings: %s", err)
+	}
+
+	report := &RotationReport{}
+	for _, binding := range bindings {
+		if opts.InstanceId != "" && binding.ServiceInstanceId != opts.InstanceId {
+			continue
+		}
+
+		if opts.BindingId != "" && binding.BindingId != opts.BindingId {
+			continue
+		}
+
+		lastRotated := binding.CreatedAt
+		if binding.CredentialsRotatedAt != nil {
+			lastRotated = *binding.CredentialsRotatedAt
+		}
+
+		if time.Since(lastRotated) < opts.MaxAge {
+			continue
+		}
+
+		gcpBroker.rotateBinding(ctx, binding, opts.GracePeriod, report)
+	}
+
+	gcpBroker.retireCredentials(ctx, report)
+
+	return report, nil
+}
+
+// rotateBinding replaces the credentials of the binding and records the old
+// ones so they're retired after the grace period.
+func (gcpBroker *GCPServiceBroker) rotateBinding(ctx context.Context, binding models.ServiceBindingCredentials, gracePeriod time.Duration, report *RotationReport) {
+	item := RotationItem{ServiceId: binding.ServiceId, InstanceId: binding.ServiceInstanceId, BindingId: binding.BindingId}
+	skip := func(reason string) {
+		item.Reason = reason
+		report.Skipped = append(report.Skipped, item)
+	}
+
+	if binding.OperationType != models.ClearOperationType {
+		skip("the binding has a pending operation")
+		return
+	}
+
+	instance, err := db_service.GetServiceInstanceDetailsById(ctx, binding.ServiceInstanceId)
+	if err != nil {
+		report.addError("binding %q: Error retrieving service instance details: %s", binding.BindingId, err)
+		return
+	}
+
+	if instance.OperationType != models.ClearOperationType {
+		skip("the instance has a pending operation")
+		return
+	}
+
+	_, provider, err := gcpBroker.getDefinitionAndProvider(instance.ServiceId)
+	if err != nil {
+		report.addError("binding %q: %s", binding.BindingId, err)
+		return
+	}
+
+	// the binding is only locked if nothing else claimed or deleted it since
+	// it was listed
+	binding.OperationType = models.RotateOperationType
+	if err := db_service.SaveServiceBindingCredentialsIfOperation(ctx, &binding, models.ClearOperationType); err == db_service.ErrConcurrentModification {
+		skip("the binding was changed by another operation")
+		return
+	} else if err != nil {
+		report.addError("locking the binding %q: %s", binding.BindingId, err)
+		return
+	}
+
+	rotated := binding
+	retired, err := provider.RotateCredentials(ctx, *instance, &rotated)
+	if err != nil {
+		binding.OperationType = models.ClearOperationType
+		if err := db_service.SaveServiceBindingCredentialsIfOperation(ctx, &binding, models.RotateOperationType); err != nil {
+			report.addError("releasing the binding %q: %s", binding.BindingId, err)
+		}
+
+		if err == broker.ErrCredentialRotationNotSupported {
+			skip(err.Error())
+		} else {
+			report.addError("rotating the credentials of %q: %s", binding.BindingId, err)
+		}
+		return
+	}
+
+	rotatedAt := time.Now()
+	rotated.OperationType = models.ClearOperationType
+	rotated.CredentialsRotatedAt = &rotatedAt
+	if err := db_service.SaveServiceBindingCredentialsIfOperation(ctx, &rotated, models.RotateOperationType); err == db_service.ErrConcurrentModification {
+		report.addError("saving the rotated credentials of %q: the binding was deleted while it was rotated. WARNING: its new credentials must be cleaned up manually", binding.BindingId)
+		return
+	} else if err != nil {
+		report.addError("saving the rotated credentials of %q: %s. WARNING: the binding is still locked and its new credentials must be cleaned up manually", binding.BindingId, err)
+		return
+	}
+
+	if len(retired) > 0 {
+		retireAt := rotatedAt.Add(gracePeriod)
+		item.RetireAt = &retireAt
+
+		if err := gcpBroker.saveRetiredCredentials(ctx, binding, retired, retireAt); err != nil {
+			report.addError("saving the replaced credentials of %q: %s. WARNING: they must be deleted manually", binding.BindingId, err)
+		}
+	}
+
+	gcpBroker.Logger.Info("rotated-credentials", lager.Data{"binding_id": binding.BindingId, "retire_at": item.RetireAt})
+	report.Rotated = append(report.Rotated, item)
+}
+
+// saveRetiredCredentials records credentials replaced by a rotation.
+func (gcpBroker *GCPServiceBroker) saveRetiredCredentials(ctx context.Context, binding models.ServiceBindingCredentials, retired map[string]interface{}, retireAt time.Time) error {
+	details, err := json.Marshal(retired)
+	if err != nil {
+		return err
+	}
+
+	return db_service.CreateRetiredCredentials(ctx, &models.RetiredCredentials{
+		ServiceId:         binding.ServiceId,
+		ServiceInstanceId: binding.ServiceInstanceId,
+		BindingId:         binding.BindingId,
+		Details:           string(details),
+		RetireAt:          retireAt,
+	})
+}
+
+// retireCredentials deletes the replaced credentials whose grace period is
+// over. Credentials that can't be deleted are kept to be retried.
+func (gcpBroker *GCPServiceBroker) retireCredentials(ctx context.Context, report *RotationReport) {
+	due, err := db_service.ListRetiredCredentialsDue(ctx, time.Now())
+	if err != nil {
+		report.addError("listing retired credentials: %s", err)
+		return
+	}
+
+	for _, record := range due {
+		if gcpBroker.retire(ctx, record, report) {
+			report.Retired = append(report.Retired, RotationItem{ServiceId: record.ServiceId, InstanceId: record.ServiceInstanceId, BindingId: record.BindingId})
+		}
+	}
+}
+
+// retire deletes the retired credentials and their record. It returns true if
+// both are gone.
+func (gcpBroker *GCPServiceBroker) retire(ctx context.Context, record models.RetiredCredentials, report *RotationReport) bool {
+	instance, err := db_service.GetServiceInstanceDetailsById(ctx, record.ServiceInstanceId)
+	if err != nil {
+		// the credentials of deprovisioned instances were deleted with them
+		if deleted, derr := db_service.CheckDeletedServiceInstanceDetailsById(ctx, record.ServiceInstanceId); derr != nil || !deleted {
+			report.addError("retiring the credentials of %q: Error retrieving service instance details: %s", record.BindingId, err)
+			return false
+		}
+	} else {
+		_, provider, err := gcpBroker.getDefinitionAndProvider(instance.ServiceId)
+		if err != nil {
+			report.addError("retiring the credentials of %q: %s", record.BindingId, err)
+			return false
+		}
+
+		var details map[string]interface{}
+		if err := json.Unmarshal([]byte(record.Details), &details); err != nil {
+			report.addError("retiring the credentials of %q: Error unmarshalling credentials: %s", record.BindingId, err)
+			return false
+		}
+
+		if err := provider.RetireCredentials(ctx, *instance, details); err != nil {
+			report.addError("retiring the credentials of %q: %s", record.BindingId, err)
+			return false
+		}
+	}
+
+	if err := db_service.DeleteRetiredCredentials(ctx, &record); err != nil {
+		report.addError("deleting the retired credentials record of %q: %s", record.BindingId, err)
+		return false
+	}
+
+	gcpBroker.Logger.Info("retired-credentials", lager.Data{"binding_id": record.BindingId})
+	return true
+}
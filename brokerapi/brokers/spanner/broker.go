@@ -25,6 +25,7 @@ import (
 	"github.com/pivotal-cf/brokerapi"
 	"google.golang.org/api/option"
 	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
+	"google.golang.org/genproto/protobuf/field_mask"
 )
 
 // SpannerBroker is the service-broker back-end for creating Spanner databases
@@ -91,32 +92,75 @@ func (s *SpannerBroker) Provision(ctx context.Context, provisionContext *varcont
 	return id, nil
 }
 
+// Update changes the node count and labels of an existing Spanner instance.
+// The instance configuration (location) can't be changed and the display name
+// is left alone because its default is derived from a generated name.
+func (s *SpannerBroker) Update(ctx context.Context, instance models.ServiceInstanceDetails, updateContext *varcontext.VarContext) (models.ServiceInstanceDetails, error) {
+	updateRequest := instancepb.UpdateInstanceRequest{
+		Instance: &instancepb.Instance{
+			Name:      s.qualifiedInstanceName(instance.Name),
+			NodeCount: int32(updateContext.GetInt("num_nodes")),
+			Labels:    updateContext.GetStringMapString("labels"),
+		},
+		FieldMask: &field_mask.FieldMask{
+			Paths: []string{"node_count", "labels"},
+		},
+	}
+
+	if err := updateContext.Error(); err != nil {
+		return models.ServiceInstanceDetails{}, err
+	}
+
+	client, err := s.createAdminClient(ctx)
+	if err != nil {
+		return models.ServiceInstanceDetails{}, err
+	}
+
+	op, err := client.UpdateInstance(ctx, &updateRequest)
+	if err != nil {
+		return models.ServiceInstanceDetails{}, fmt.Errorf("Error updating instance: %s", err)
+	}
+
+	instance.OperationType = models.UpdateOperationType
+	instance.OperationId = op.Name()
+
+	return instance, nil
+}
+
 // PollInstance gets the last operation for this instance and polls its status.
 func (s *SpannerBroker) PollInstance(ctx context.Context, instance models.ServiceInstanceDetails) (bool, error) {
 	if instance.OperationType == models.ClearOperationType {
 		return false, fmt.Errorf("No pending operations could be found for this Spanner instance.")
 	}
 
-	if instance.OperationType != models.ProvisionOperationType {
-		return false, fmt.Errorf("Couldn't poll Spanner instance, unknown operation type: %s", instance.OperationType)
-	}
-
 	client, err := s.createAdminClient(ctx)
 	if err != nil {
 		return false, err
 	}
 
 	// From https://godoc.org/cloud.google.com/go/spanner/admin/instance/apiv1#CreateInstanceOperation.Poll
-	spannerOp := client.CreateInstanceOperation(instance.OperationId)
-	_, err = spannerOp.Poll(ctx)
-	done := spannerOp.Done()
+	var done bool
+	switch instance.OperationType {
+	case models.ProvisionOperationType:
+		spannerOp := client.CreateInstanceOperation(instance.OperationId)
+		_, err = spannerOp.Poll(ctx)
+		done = spannerOp.Done()
+
+	case models.UpdateOperationType:
+		spannerOp := client.UpdateInstanceOperation(instance.OperationId)
+		_, err = spannerOp.Poll(ctx)
+		done = spannerOp.Done()
+
+	default:
+		return false, fmt.Errorf("Couldn't poll Spanner instance, unknown operation type: %s", instance.OperationType)
+	}
 
 	switch {
 	case err != nil && !done: // There was a failure polling
 		return false, fmt.Errorf("Error checking operation status: %s", err)
 
 	case err != nil && done: // The operation completed in error
-		return true, fmt.Errorf("Error provisioning instance: %v", err)
+		return true, fmt.Errorf("Error completing %s of instance: %v", instance.OperationType, err)
 
 	case err == nil && done: // The operation was successful
 		return true, nil
@@ -45,7 +45,7 @@ func serviceDefinition() *broker.ServiceDefinition {
 			"description": "The first horizontally scalable, globally consistent, relational database service.",
 			"name": "google-spanner",
 			"bindable": true,
-			"plan_updateable": false,
+			"plan_updateable": true,
 			"metadata": {
 				"displayName": "Google Spanner",
 				"longDescription": "The first horizontally scalable, globally consistent, relational database service.",
@@ -21,8 +21,10 @@ import (
 	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/broker_base"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/varcontext"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/utils"
 	"github.com/pivotal-cf/brokerapi"
 	"golang.org/x/net/context"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
@@ -62,6 +64,11 @@ func (b *StorageBroker) Provision(ctx context.Context, provisionContext *varcont
 		return models.ServiceInstanceDetails{}, fmt.Errorf("Error creating new bucket: %s", err)
 	}
 
+	return bucketInstanceDetails(&attrs)
+}
+
+// bucketInstanceDetails creates the instance details of a bucket.
+func bucketInstanceDetails(attrs *googlestorage.BucketAttrs) (models.ServiceInstanceDetails, error) {
 	ii := InstanceInformation{
 		BucketName: attrs.Name,
 	}
@@ -93,6 +100,58 @@ func (b *StorageBroker) Deprovision(ctx context.Context, bucket models.ServiceIn
 	return nil, nil
 }
 
+// Exists checks if the bucket associated with the given instance still exists.
+func (b *StorageBroker) Exists(ctx context.Context, bucket models.ServiceInstanceDetails) (bool, error) {
+	storageService, err := b.createClient(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = storageService.Bucket(bucket.Name).Attrs(ctx)
+	switch {
+	case err == googlestorage.ErrBucketNotExist:
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("Error getting bucket: %s", err)
+	default:
+		return true, nil
+	}
+}
+
+// DescribeResources lists the buckets in the project that are labeled with
+// the ID of a service instance.
+func (b *StorageBroker) DescribeResources(ctx context.Context) ([]models.ServiceInstanceDetails, error) {
+	storageService, err := b.createClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []models.ServiceInstanceDetails
+	buckets := storageService.Buckets(ctx, b.ProjectId)
+	for {
+		attrs, err := buckets.Next()
+		if err == iterator.Done {
+			return resources, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error listing buckets: %s", err)
+		}
+
+		instanceId, ok := attrs.Labels[utils.InstanceIdLabel]
+		if !ok {
+			continue
+		}
+
+		resource, err := bucketInstanceDetails(attrs)
+		if err != nil {
+			return nil, err
+		}
+		resource.ID = instanceId
+		resource.CreatedAt = attrs.Created
+		resources = append(resources, resource)
+	}
+}
+
 func (b *StorageBroker) createClient(ctx context.Context) (*googlestorage.Client, error) {
 	co := option.WithUserAgent(models.CustomUserAgent)
 	ct := option.WithTokenSource(b.HttpConfig.TokenSource(ctx))
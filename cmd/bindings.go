@@ -0,0 +1,128 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	rotateIntervalProp    = "bindings.rotate.interval"
+	rotateMaxAgeProp      = "bindings.rotate.max_age"
+	rotateGracePeriodProp = "bindings.rotate.grace_period"
+)
+
+func init() {
+	viper.SetDefault(rotateMaxAgeProp, "2160h")
+	viper.SetDefault(rotateGracePeriodProp, "24h")
+
+	bindingsCmd := &cobra.Command{
+		Use:   "bindings",
+		Short: "Manage the credentials of service bindings",
+		Long:  `Manage the credentials of service bindings`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	rootCmd.AddCommand(bindingsCmd)
+
+	var opts brokers.RotationOptions
+	rotateCmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Replace the credentials of bindings",
+		Long: `Replace the credentials of bindings without unbinding them and print a
+report as JSON.
+
+Bindings whose credentials are older than bindings.rotate.max_age (default
+90 days) get new credentials, which are returned when the binding is fetched.
+The old credentials keep working for bindings.rotate.grace_period (default
+24h) so applications can pick up the new ones, and are deleted by the first
+rotation that runs after that.
+
+Services that don't support rotation, and bindings or instances with a
+pending operation, are skipped.
+
+The broker can run this periodically by setting bindings.rotate.interval to a
+duration like 24h.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if !cmd.Flags().Changed("max-age") {
+				opts.MaxAge = viper.GetDuration(rotateMaxAgeProp)
+			}
+
+			if !cmd.Flags().Changed("grace-period") {
+				opts.GracePeriod = viper.GetDuration(rotateGracePeriodProp)
+			}
+
+			logger := lager.NewLogger("rotate-cmd")
+			logger.RegisterSink(lager.NewWriterSink(os.Stderr, lager.INFO))
+			db_service.New(logger)
+
+			gcpBroker := newBrokerOrExit(logger)
+			report, err := gcpBroker.RotateCredentials(context.Background(), opts)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			utils.PrettyPrintOrExit(report)
+		},
+	}
+	rotateCmd.Flags().StringVarP(&opts.InstanceId, "instance", "", "", "only rotate the bindings of this service instance")
+	rotateCmd.Flags().StringVarP(&opts.BindingId, "binding", "", "", "only rotate this binding")
+	rotateCmd.Flags().DurationVarP(&opts.MaxAge, "max-age", "", 0, "rotate credentials older than this, 0 rotates all of them (default bindings.rotate.max_age)")
+	rotateCmd.Flags().DurationVarP(&opts.GracePeriod, "grace-period", "", 0, "how long the replaced credentials keep working (default bindings.rotate.grace_period)")
+	bindingsCmd.AddCommand(rotateCmd)
+}
+
+// startRotationLoop rotates the credentials of bindings in the background
+// every bindings.rotate.interval if it's set.
+func startRotationLoop(gcpBroker *brokers.GCPServiceBroker, logger lager.Logger) {
+	interval := viper.GetDuration(rotateIntervalProp)
+	if interval <= 0 {
+		return
+	}
+
+	opts := brokers.RotationOptions{
+		MaxAge:      viper.GetDuration(rotateMaxAgeProp),
+		GracePeriod: viper.GetDuration(rotateGracePeriodProp),
+	}
+	logger.Info("starting credential rotation loop", lager.Data{
+		"interval":     interval.String(),
+		"max_age":      opts.MaxAge.String(),
+		"grace_period": opts.GracePeriod.String(),
+	})
+
+	go func() {
+		for range time.Tick(interval) {
+			report, err := gcpBroker.RotateCredentials(context.Background(), opts)
+			if err != nil {
+				logger.Error("rotate-credentials", err)
+				continue
+			}
+
+			logger.Info("rotated credentials", lager.Data{"report": report})
+		}
+	}()
+}
@@ -104,10 +104,22 @@ user-defined plans.
 		return client.LastOperation(instanceId)
 	})
 
+	lastBindingCmd := newClientCommand("last-binding", "Get the status of the last operation on a binding", func(client *client.Client) *client.BrokerResponse {
+		return client.LastBindingOperation(instanceId, bindingId)
+	})
+
 	updateCmd := newClientCommand("update", "Update the instance details", func(client *client.Client) *client.BrokerResponse {
 		return client.Update(instanceId, serviceId, planId, json.RawMessage(parametersJson))
 	})
 
+	getInstanceCmd := newClientCommand("get-instance", "Fetch a service instance", func(client *client.Client) *client.BrokerResponse {
+		return client.GetInstance(instanceId)
+	})
+
+	getBindingCmd := newClientCommand("get-binding", "Fetch a binding and its credentials", func(client *client.Client) *client.BrokerResponse {
+		return client.GetBinding(instanceId, bindingId)
+	})
+
 	runExamplesCmd := &cobra.Command{
 		Use:   "run-examples",
 		Short: "Run all examples in the use command.",
@@ -129,7 +141,7 @@ user-defined plans.
 		},
 	}
 
-	clientCmd.AddCommand(clientCatalogCmd, provisionCmd, deprovisionCmd, bindCmd, unbindCmd, lastCmd, runExamplesCmd, updateCmd)
+	clientCmd.AddCommand(clientCatalogCmd, provisionCmd, deprovisionCmd, bindCmd, unbindCmd, lastCmd, lastBindingCmd, runExamplesCmd, updateCmd, getInstanceCmd, getBindingCmd)
 
 	bindFlag := func(dest *string, name, description string, commands ...*cobra.Command) {
 		for _, sc := range commands {
@@ -138,10 +150,10 @@ user-defined plans.
 		}
 	}
 
-	bindFlag(&instanceId, "instanceid", "id of the service instance to operate on (user defined)", provisionCmd, deprovisionCmd, bindCmd, unbindCmd, lastCmd, updateCmd)
+	bindFlag(&instanceId, "instanceid", "id of the service instance to operate on (user defined)", provisionCmd, deprovisionCmd, bindCmd, unbindCmd, lastCmd, lastBindingCmd, updateCmd, getInstanceCmd, getBindingCmd)
 	bindFlag(&serviceId, "serviceid", "GUID of the service instanceid references (see catalog)", provisionCmd, deprovisionCmd, bindCmd, unbindCmd, updateCmd)
 	bindFlag(&planId, "planid", "GUID of the service instanceid references (see catalog entry for the associated serviceid)", provisionCmd, deprovisionCmd, bindCmd, unbindCmd, updateCmd)
-	bindFlag(&bindingId, "bindingid", "GUID of the binding to work on (user defined)", bindCmd, unbindCmd)
+	bindFlag(&bindingId, "bindingid", "GUID of the binding to work on (user defined)", bindCmd, unbindCmd, lastBindingCmd, getBindingCmd)
 
 	for _, sc := range []*cobra.Command{provisionCmd, bindCmd, updateCmd} {
 		sc.Flags().StringVarP(&parametersJson, "params", "", "{}", "JSON string of user-defined parameters to pass to the request")
@@ -103,7 +103,6 @@ out.toml:
   [db]
     name = "servicebroker"
     password = "pass"
-    port = "3306"
 `,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -0,0 +1,62 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	instancesCmd := &cobra.Command{
+		Use:   "instances",
+		Short: "Manage the records of service instances",
+		Long:  `Manage the records of service instances`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+	rootCmd.AddCommand(instancesCmd)
+
+	instancesCmd.AddCommand(&cobra.Command{
+		Use:   "release <instance-id>",
+		Short: "Clear the pending operation of a service instance",
+		Long: `Clear the pending operation of a service instance so it can be updated
+or deprovisioned again.
+
+Instances are locked while an operation is pending and are released when the
+operation finishes or fails. Use this if the broker lost track of an operation,
+for example because it stopped while running it. Deprovisions take over
+operations that failed or are older than 24h on their own.
+
+Make sure the operation isn't running anymore, the broker can't stop it.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := lager.NewLogger("instances-cmd")
+			logger.RegisterSink(lager.NewWriterSink(os.Stderr, lager.INFO))
+			db_service.New(logger)
+
+			gcpBroker := newBrokerOrExit(logger)
+			if err := gcpBroker.ReleaseInstance(context.Background(), args[0]); err != nil {
+				log.Fatal(err)
+			}
+		},
+	})
+}
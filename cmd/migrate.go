@@ -15,6 +15,8 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"os"
 
 	"code.cloudfoundry.org/lager"
@@ -23,7 +25,7 @@ import (
 )
 
 func init() {
-	rootCmd.AddCommand(&cobra.Command{
+	migrateCmd := &cobra.Command{
 		Use:   "migrate",
 		Short: "Upgrade your database",
 		Long:  `Upgrade your database to be compatible with this service broker.`,
@@ -41,5 +43,53 @@ func init() {
 
 			logger.Debug("Finished migration")
 		},
+	}
+
+	rootCmd.AddCommand(migrateCmd)
+
+	migrateCmd.AddCommand(&cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt the secrets stored in plaintext",
+		Long: `Encrypt the binding credentials and Terraform workspaces that are still
+stored in plaintext with the primary key in db.encryption.keys.
+
+Records are readable whether or not they're encrypted, and records the broker
+writes while this runs are re-read rather than overwritten, so this can be run
+while the broker is serving requests.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			resealSecrets("encrypt-cmd", true)
+		},
 	})
+
+	migrateCmd.AddCommand(&cobra.Command{
+		Use:   "rotate-keys",
+		Short: "Re-encrypt the secrets with the primary key",
+		Long: `Re-encrypt the binding credentials and Terraform workspaces that are stored
+in plaintext or encrypted with an old key with the primary key in
+db.encryption.keys.
+
+Once this finishes, the old keys can be removed from db.encryption.keys.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			resealSecrets("rotate-keys-cmd", false)
+		},
+	})
+}
+
+func resealSecrets(component string, plaintextOnly bool) {
+	logger := lager.NewLogger(component)
+	logger.RegisterSink(lager.NewWriterSink(os.Stderr, lager.DEBUG))
+
+	db_service.New(logger)
+	if db_service.DbKeyring == nil {
+		logger.Fatal("No encryption keys are configured", errors.New("db.encryption.keys is empty"))
+	}
+
+	updated, err := db_service.ResealSecrets(context.Background(), plaintextOnly)
+	if err != nil {
+		logger.Fatal("Error encrypting secrets", err, lager.Data{"updated": updated})
+	}
+
+	logger.Info("Finished encrypting secrets", lager.Data{"updated": updated})
 }
@@ -0,0 +1,121 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	reconcileIntervalProp = "reconcile.interval"
+	reconcileCleanupProp  = "reconcile.cleanup"
+)
+
+func init() {
+	opts := brokers.ReconcileOptions{}
+	reconcileCmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Compare the broker's database with GCP",
+		Long: `Compare the service instances and bindings in the database with the
+resources in GCP and print a report as JSON.
+
+The report lists records whose resources were deleted outside of the broker,
+and resources labeled with a pcf-instance-id that has no record, for example
+because the broker failed to save it after creating the resource. Services
+that don't implement these checks are assumed to be consistent.
+
+With --cleanup, the records of dangling instances and their bindings are
+deleted, dangling bindings are deleted, and orphaned resources older than
+--min-orphan-age are deprovisioned unless an instance record was created for
+them in the meantime.
+
+The broker can run this periodically by setting reconcile.interval to a
+duration like 24h, and reconcile.cleanup to clean up while doing so. The
+background runs only delete dangling records, orphaned resources are only
+deprovisioned by this command.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			logger := lager.NewLogger("reconcile-cmd")
+			logger.RegisterSink(lager.NewWriterSink(os.Stderr, lager.INFO))
+			db_service.New(logger)
+
+			gcpBroker := newBrokerOrExit(logger)
+			opts.DeprovisionOrphans = opts.Cleanup
+			report, err := gcpBroker.Reconcile(context.Background(), opts)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			utils.PrettyPrintOrExit(report)
+		},
+	}
+	reconcileCmd.Flags().BoolVar(&opts.Cleanup, "cleanup", false, "delete dangling records and deprovision orphaned resources")
+	reconcileCmd.Flags().DurationVar(&opts.MinOrphanAge, "min-orphan-age", brokers.DefaultMinOrphanAge, "only deprovision orphaned resources older than this")
+
+	rootCmd.AddCommand(reconcileCmd)
+}
+
+// newBrokerOrExit creates a GCPServiceBroker from the environment.
+func newBrokerOrExit(logger lager.Logger) *brokers.GCPServiceBroker {
+	cfg, err := brokers.NewBrokerConfigFromEnv()
+	if err != nil {
+		logger.Fatal("Error initializing service broker config: %s", err)
+	}
+
+	gcpBroker, err := brokers.New(cfg, logger)
+	if err != nil {
+		logger.Fatal("Error initializing service broker: %s", err)
+	}
+
+	return gcpBroker
+}
+
+// startReconcileLoop reconciles the database with GCP in the background every
+// reconcile.interval if it's set. Orphaned resources are only reported because
+// they may belong to a provision that's still being saved or to another broker.
+func startReconcileLoop(gcpBroker *brokers.GCPServiceBroker, logger lager.Logger) {
+	interval := viper.GetDuration(reconcileIntervalProp)
+	if interval <= 0 {
+		return
+	}
+
+	opts := brokers.ReconcileOptions{Cleanup: viper.GetBool(reconcileCleanupProp)}
+	logger.Info("starting reconcile loop", lager.Data{
+		"interval": interval.String(),
+		"cleanup":  opts.Cleanup,
+	})
+
+	go func() {
+		for range time.Tick(interval) {
+			report, err := gcpBroker.Reconcile(context.Background(), opts)
+			if err != nil {
+				logger.Error("reconcile", err)
+				continue
+			}
+
+			logger.Info("reconciled", lager.Data{"report": report})
+		}
+	}()
+}
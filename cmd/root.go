@@ -19,6 +19,7 @@ import (
 	"log"
 	"os"
 
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/providers/tf"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -61,13 +62,17 @@ func init() {
 }
 
 func initConfig() {
-	if cfgFile == "" {
-		return
-	}
+	if cfgFile != "" {
+		viper.SetConfigFile(cfgFile)
 
-	viper.SetConfigFile(cfgFile)
+		if err := viper.ReadInConfig(); err != nil {
+			log.Fatalf("Can't read config: %v\n", err)
+		}
+	}
 
-	if err := viper.ReadInConfig(); err != nil {
-		log.Fatalf("Can't read config: %v\n", err)
+	// The services are registered after the config is read because their
+	// location can be set in it.
+	if err := tf.RegisterServiceDefinitionsFromConfig(); err != nil {
+		log.Fatalf("Can't load service definitions: %v\n", err)
 	}
 }
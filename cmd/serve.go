@@ -18,14 +18,18 @@ import (
 	"context"
 	"net/http"
 	"os"
+	"time"
 
 	"code.cloudfoundry.org/lager"
-	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/compatibility"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/providers/tf"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/server"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/toggles"
 	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/brokerapi/auth"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -34,6 +38,8 @@ const (
 	apiUserProp     = "api.user"
 	apiPasswordProp = "api.password"
 	apiPortProp     = "api.port"
+
+	metricsPortProp = "metrics.port"
 )
 
 var v3CompatibilityToggle = toggles.Compatibility.Toggle("three-to-four.legacy-plans", false, `Enable compatibility with the GCP Service Broker v3.x.
@@ -41,6 +47,12 @@ var v3CompatibilityToggle = toggles.Compatibility.Toggle("three-to-four.legacy-p
 	This option installs a compatibility layer which checks if a service is using the correct plan GUID.
 	If the service does not use the correct GUID, the request will fail with a message about how to upgrade.`)
 
+var resumeTerraformJobsToggle = toggles.Feature.Toggle("terraform.resume-orphaned-jobs", true, `Resume Terraform operations that were interrupted by a broker restart.
+	If disabled, the interrupted operations are marked as failed with their partial state recovered.`)
+
+var metricsToggle = toggles.Feature.Toggle("metrics", false, `Serve Prometheus metrics about requests, Terraform jobs and the database on /metrics.
+	The endpoint uses the broker's credentials unless metrics.port is set, in which case it is served without auth on that port.`)
+
 func init() {
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "serve",
@@ -68,15 +80,13 @@ func serve() {
 	db_service.New(logger)
 
 	// init broker
-	cfg, err := brokers.NewBrokerConfigFromEnv()
-	if err != nil {
-		logger.Fatal("Error initializing service broker config: %s", err)
-	}
-	var serviceBroker brokerapi.ServiceBroker
-	serviceBroker, err = brokers.New(cfg, logger)
-	if err != nil {
-		logger.Fatal("Error initializing service broker: %s", err)
-	}
+	gcpBroker := newBrokerOrExit(logger)
+	var serviceBroker brokerapi.ServiceBroker = gcpBroker
+	var fetcher server.Fetcher = gcpBroker
+
+	recoverTerraformJobs(logger)
+	startReconcileLoop(gcpBroker, logger)
+	startRotationLoop(gcpBroker, logger)
 
 	username := viper.GetString(apiUserProp)
 	password := viper.GetString(apiPasswordProp)
@@ -99,13 +109,77 @@ func serve() {
 		serviceBroker = compatibility.NewLegacyPlanUpgrader(serviceBroker)
 	}
 
+	if metricsToggle.IsActive() {
+		logger.Info("Enabling metrics")
+
+		db_service.InstrumentDb(db_service.DbConnection)
+		instrumented := server.NewInstrumentedBroker(serviceBroker, fetcher)
+		serviceBroker, fetcher = instrumented, instrumented
+		serveMetrics(logger, credentials)
+	}
+
 	services, err := serviceBroker.Services(context.Background())
 	if err != nil {
 		logger.Error("creating service catalog", err)
 	}
 	logger.Info("service catalog", lager.Data{"catalog": services})
 
-	brokerAPI := brokerapi.New(serviceBroker, logger, credentials)
+	brokerAPI := server.NewBrokerHandler(serviceBroker, fetcher, logger, credentials)
 	http.Handle("/", brokerAPI)
 	http.ListenAndServe(":"+port, nil)
 }
+
+// serveMetrics exposes the metrics endpoint. It's served on its own port
+// without auth if metrics.port is set, otherwise it's served alongside the
+// broker behind the broker's credentials.
+func serveMetrics(logger lager.Logger, credentials brokerapi.BrokerCredentials) {
+	metricsPort := viper.GetString(metricsPortProp)
+	if metricsPort == "" {
+		http.Handle("/metrics", auth.NewWrapper(credentials.Username, credentials.Password).Wrap(promhttp.Handler()))
+		return
+	}
+
+	logger.Info("Serving metrics", lager.Data{"port": metricsPort})
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		if err := http.ListenAndServe(":"+metricsPort, mux); err != nil {
+			logger.Error("serving metrics", err)
+		}
+	}()
+}
+
+// recoverTerraformJobs takes over Terraform operations that were abandoned by
+// a broker process that stopped while running them. Abandoned operations are
+// only recovered once their lease expires, so recovery is run at startup and
+// then every tf.RecoveryInterval to catch the leases of a process that was
+// restarted.
+func recoverTerraformJobs(logger lager.Logger) {
+	jobRunner, err := tf.NewTfJobRunerFromEnv()
+	if err != nil {
+		logger.Error("creating terraform job runner for recovery", err)
+		return
+	}
+
+	recoverOrphans := func() {
+		recovered, err := jobRunner.RecoverOrphans(context.Background(), resumeTerraformJobsToggle.IsActive())
+		if err != nil {
+			logger.Error("recovering terraform jobs", err)
+		}
+
+		if len(recovered) > 0 {
+			logger.Info("recovered terraform jobs", lager.Data{
+				"deployments": recovered,
+				"resumed":     resumeTerraformJobsToggle.IsActive(),
+			})
+		}
+	}
+
+	recoverOrphans()
+	go func() {
+		for range time.Tick(tf.RecoveryInterval) {
+			recoverOrphans()
+		}
+	}()
+}
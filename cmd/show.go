@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -42,7 +43,33 @@ func init() {
 	addDumpTableCommand(showCmd, "migrations", &[]models.Migration{})
 	addDumpTableCommand(showCmd, "operations", &[]models.CloudOperationV1{})
 	addDumpTableCommand(showCmd, "provisions", &[]models.ProvisionRequestDetails{})
+	addDumpTableCommand(showCmd, "retired-credentials", &[]models.RetiredCredentials{})
 	addDumpTableCommand(showCmd, "terraform", &[]models.TerraformDeployment{})
+
+	var historyInstanceId string
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show the requests made for a service instance as JSON",
+		Long: `Show the requests made for a service instance and its bindings, oldest
+first. Each entry holds the originating identity of the request, the org and
+space of the instance, the parameters with secrets redacted, the result and how
+long the broker took to respond.
+
+Polls of last_operation are only included if they saw the operation finish.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			db_service.New(lager.NewLogger("show-command"))
+
+			history, err := db_service.ListOperationHistoryByServiceInstanceId(context.Background(), historyInstanceId)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			utils.PrettyPrintOrExit(history)
+		},
+	}
+	historyCmd.Flags().StringVarP(&historyInstanceId, "instance", "", "", "id of the service instance to show the history of")
+	historyCmd.MarkFlagRequired("instance")
+	showCmd.AddCommand(historyCmd)
 }
 
 func addDumpTableCommand(parent *cobra.Command, name string, value interface{}) {
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
 	"text/tabwriter"
 	"time"
 
@@ -27,8 +28,10 @@ import (
 	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/providers/tf"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/providers/tf/wrapper"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/utils"
 	"github.com/jinzhu/gorm"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func init() {
@@ -103,4 +106,244 @@ func init() {
 			w.Flush()
 		},
 	})
+
+	tfCmd.AddCommand(&cobra.Command{
+		Use:   "logs <id>",
+		Short: "show the Terraform output of the operations on a workspace",
+		Long: `Show the output Terraform printed while running the operations on a
+workspace, oldest first. Each operation only keeps the end of its output if it
+grew past terraform.max_log_bytes, and only the newest
+terraform.max_log_operations operations are kept.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			logs, err := db_service.ListTerraformDeploymentLogs(context.Background(), args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			lastOperation := ""
+			for _, chunk := range logs {
+				if chunk.OperationId != lastOperation {
+					fmt.Printf("# %s %s\n", chunk.OperationType, chunk.OperationId)
+					lastOperation = chunk.OperationId
+				}
+
+				fmt.Print(chunk.Output)
+			}
+		},
+	})
+
+	var planJson bool
+	planCmd := &cobra.Command{
+		Use:   "plan <id>",
+		Short: "check a Terraform workspace for changes made outside the broker",
+		Long: `Run terraform plan against the stored state and configuration of a
+workspace and show the output followed by a summary of the drift.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			report := jobRunner.Plan(context.Background(), args[0])
+			if planJson {
+				utils.PrettyPrintOrExit(report)
+				return
+			}
+
+			fmt.Println(report.Output)
+			printDriftReports([]tf.DriftReport{report})
+		},
+	}
+	planCmd.Flags().BoolVar(&planJson, "json", false, "print the report as JSON")
+	tfCmd.AddCommand(planCmd)
+
+	var driftJson bool
+	driftCmd := &cobra.Command{
+		Use:   "drift",
+		Short: "check every Terraform workspace for changes made outside the broker",
+		Long: `Run terraform plan against every workspace that has resources and report
+which ones have drifted from their configuration. Workspaces with a pending
+operation are reported as errors rather than checked.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			reports, err := jobRunner.Drift(context.Background())
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			if driftJson {
+				utils.PrettyPrintOrExit(reports)
+				return
+			}
+
+			printDriftReports(reports)
+		},
+	}
+	driftCmd.Flags().BoolVar(&driftJson, "json", false, "print the reports as JSON")
+	tfCmd.AddCommand(driftCmd)
+
+	var migrateTo string
+	migrateCmd := &cobra.Command{
+		Use:   "migrate-state [id...]",
+		Short: "move the Terraform state of workspaces to another backend",
+		Long: `Move the Terraform state of the given workspaces, or all of them if none are
+given, to another backend. By default the state is moved to the backend set by
+terraform.state.backend. Workspaces with an operation in progress are skipped.
+The state is copied, the copy in the previous backend is left in place.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if migrateTo == "" {
+				migrateTo = viper.GetString(tf.StateBackendProp)
+			}
+
+			ids := args
+			if len(ids) == 0 {
+				deployments, err := db_service.ListTerraformDeployments(context.Background())
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				for _, deployment := range deployments {
+					ids = append(ids, deployment.ID)
+				}
+			}
+
+			failed := false
+			for _, id := range ids {
+				migrated, err := jobRunner.MigrateState(context.Background(), id, migrateTo)
+				switch {
+				case err != nil:
+					failed = true
+					fmt.Printf("%q: %v\n", id, err)
+				case migrated:
+					fmt.Printf("%q: moved to %s\n", id, migrateTo)
+				default:
+					fmt.Printf("%q: already uses %s\n", id, migrateTo)
+				}
+			}
+
+			if failed {
+				os.Exit(1)
+			}
+		},
+	}
+	migrateCmd.Flags().StringVar(&migrateTo, "to", "", "the backend to move the state to: database, local or http")
+	tfCmd.AddCommand(migrateCmd)
+
+	// The definition commands work offline so they don't need the database or
+	// GCP credentials the other commands set up.
+	offline := func(cmd *cobra.Command, args []string) error { return nil }
+
+	var validateJson bool
+	validateCmd := &cobra.Command{
+		Use:   "validate-definition <file>",
+		Short: "check a Terraform service definition without running Terraform",
+		Long: `Check a Terraform service definition file the same way the broker does when
+loading it, then evaluate the computed inputs of every plan and the provision
+and bind variables of every example. Nothing is created and GCP isn't
+contacted.`,
+		Args:              cobra.ExactArgs(1),
+		PersistentPreRunE: offline,
+		Run: func(cmd *cobra.Command, args []string) {
+			printDefinitionReport(tf.CheckServiceDefinitionFile(args[0], nil), validateJson)
+		},
+	}
+	validateCmd.Flags().BoolVar(&validateJson, "json", false, "print the report as JSON")
+	tfCmd.AddCommand(validateCmd)
+
+	var testJson bool
+	var pluginDir, terraformPath string
+	testCmd := &cobra.Command{
+		Use:   "test <file>",
+		Short: "check a Terraform service definition and validate its templates",
+		Long: `Run the checks of validate-definition, then run terraform validate on the
+templates with the variables of every plan and example. Terraform uses the
+provider plugins in --plugin-dir rather than downloading them so this works
+offline.`,
+		Args:              cobra.ExactArgs(1),
+		PersistentPreRunE: offline,
+		Run: func(cmd *cobra.Command, args []string) {
+			if terraformPath == "" {
+				path, err := exec.LookPath("terraform")
+				if err != nil {
+					log.Fatalf("couldn't find terraform, set --terraform: %v", err)
+				}
+				terraformPath = path
+			}
+
+			// only init takes the plugin flags, validate rejects them
+			initExecutor := wrapper.CustomTerraformExecutor(terraformPath, pluginDir, wrapper.DefaultExecutor)
+			executor := func(c *exec.Cmd) error {
+				if c.Args[1] == "init" {
+					return initExecutor(c)
+				}
+
+				c.Path = terraformPath
+				return wrapper.DefaultExecutor(c)
+			}
+
+			printDefinitionReport(tf.CheckServiceDefinitionFile(args[0], executor), testJson)
+		},
+	}
+	testCmd.Flags().BoolVar(&testJson, "json", false, "print the report as JSON")
+	testCmd.Flags().StringVar(&pluginDir, "plugin-dir", "", "the directory holding the Terraform provider plugins")
+	testCmd.Flags().StringVar(&terraformPath, "terraform", "", "the Terraform binary to use, found on the PATH by default")
+	testCmd.MarkFlagRequired("plugin-dir")
+	tfCmd.AddCommand(testCmd)
+
+	var resume, force bool
+	recoverCmd := &cobra.Command{
+		Use:   "recover <id>",
+		Short: "recover a Terraform job abandoned by a stopped broker",
+		Long: `Recover a Terraform job that was in progress when the broker running it
+stopped. By default, the job is marked as failed and the partial Terraform
+state is kept so the resources can be destroyed. With --resume, the job is run
+again from the partial state and the command waits for it to finish. Jobs that
+were still queued never ran Terraform so they're always queued again.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := jobRunner.Recover(context.Background(), args[0], resume, force); err != nil {
+				log.Fatal(err)
+			}
+
+			if resume {
+				if err := jobRunner.Wait(context.Background(), args[0]); err != nil {
+					log.Fatal(err)
+				}
+			}
+		},
+	}
+	recoverCmd.Flags().BoolVar(&resume, "resume", false, "run the job again rather than marking it as failed")
+	recoverCmd.Flags().BoolVar(&force, "force", false, "take over the job even if its lease hasn't expired")
+	tfCmd.AddCommand(recoverCmd)
+}
+
+func printDriftReports(reports []tf.DriftReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.StripEscape)
+	fmt.Fprintln(w, "ID\tDrifted\tSummary\tError")
+
+	for _, report := range reports {
+		fmt.Fprintf(w, "%q\t%t\t%s\t%s\n", report.ID, report.Drifted, report.Summary, report.Error)
+	}
+	w.Flush()
+}
+
+// printDefinitionReport prints the report and exits with a non-zero status if
+// any of the checks failed.
+func printDefinitionReport(report *tf.DefinitionReport, asJson bool) {
+	if asJson {
+		utils.PrettyPrintOrExit(report)
+	} else {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.StripEscape)
+		fmt.Fprintln(w, "Check\tResult\tError")
+
+		for _, check := range report.Checks {
+			result := "PASS"
+			if !check.Passed {
+				result = "FAIL"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", check.Name, result, check.Error)
+		}
+		w.Flush()
+	}
+
+	if !report.Passed() {
+		os.Exit(1)
+	}
 }
@@ -40,7 +40,15 @@ func (ds *SqlDatastore) CreateServiceInstanceDetails(ctx context.Context, object
 // SaveServiceInstanceDetails updates an existing record in the database.
 func SaveServiceInstanceDetails(ctx context.Context, object *models.ServiceInstanceDetails) error { return defaultDatastore().SaveServiceInstanceDetails(ctx, object) }
 func (ds *SqlDatastore) SaveServiceInstanceDetails(ctx context.Context, object *models.ServiceInstanceDetails) error {
-	return ds.db.Save(object).Error
+	// the record is only updated if nobody else saved it since it was read
+	version := object.Version
+	object.Version++
+	if err := ds.updateIfVersion(object, version); err != nil {
+		object.Version = version
+		return err
+	}
+
+	return nil
 }
 // DeleteServiceInstanceDetailsById soft-deletes the record by its key (id).
 func DeleteServiceInstanceDetailsById(ctx context.Context, id string) error { return defaultDatastore().DeleteServiceInstanceDetailsById(ctx, id) }
@@ -53,7 +61,13 @@ func (ds *SqlDatastore) DeleteServiceInstanceDetailsById(ctx context.Context, id
 // DeleteServiceInstanceDetails soft-deletes the record.
 func DeleteServiceInstanceDetails(ctx context.Context, record *models.ServiceInstanceDetails) error { return defaultDatastore().DeleteServiceInstanceDetails(ctx, record) }
 func (ds *SqlDatastore) DeleteServiceInstanceDetails(ctx context.Context, record *models.ServiceInstanceDetails) error {
-	return ds.db.Delete(record).Error
+	// the record is only deleted if nobody else saved it since it was read
+	result := ds.db.Where("version = ?", record.Version).Delete(record)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrConcurrentModification
+	}
+
+	return result.Error
 }
 // GetServiceInstanceDetailsById gets an instance of ServiceInstanceDetails by its key (id).
 func GetServiceInstanceDetailsById(ctx context.Context, id string) (*models.ServiceInstanceDetails, error) { return defaultDatastore().GetServiceInstanceDetailsById(ctx, id) }
@@ -109,13 +123,58 @@ func (ds *SqlDatastore) CountServiceBindingCredentialsById(ctx context.Context,
 // CreateServiceBindingCredentials creates a new record in the database and assigns it a primary key.
 func CreateServiceBindingCredentials(ctx context.Context, object *models.ServiceBindingCredentials) error { return defaultDatastore().CreateServiceBindingCredentials(ctx, object) }
 func (ds *SqlDatastore) CreateServiceBindingCredentials(ctx context.Context, object *models.ServiceBindingCredentials) error {
-	return ds.db.Create(object).Error
+	sealed, err := ds.sealServiceBindingCredentials(object)
+	if err != nil {
+		return err
+	}
+
+	if err := ds.db.Create(sealed).Error; err != nil {
+		return err
+	}
+
+	// keep the fields the database assigned, but not the sealed secrets
+	sealed.OtherDetails = object.OtherDetails
+	*object = *sealed
+	return nil
 }
 
 // SaveServiceBindingCredentials updates an existing record in the database.
 func SaveServiceBindingCredentials(ctx context.Context, object *models.ServiceBindingCredentials) error { return defaultDatastore().SaveServiceBindingCredentials(ctx, object) }
 func (ds *SqlDatastore) SaveServiceBindingCredentials(ctx context.Context, object *models.ServiceBindingCredentials) error {
-	return ds.db.Save(object).Error
+	sealed, err := ds.sealServiceBindingCredentials(object)
+	if err != nil {
+		return err
+	}
+
+	if err := ds.db.Save(sealed).Error; err != nil {
+		return err
+	}
+
+	// keep the fields the database assigned, but not the sealed secrets
+	sealed.OtherDetails = object.OtherDetails
+	*object = *sealed
+	return nil
+}
+
+// sealServiceBindingCredentials creates a copy of the object with its secrets sealed.
+func (ds *SqlDatastore) sealServiceBindingCredentials(object *models.ServiceBindingCredentials) (*models.ServiceBindingCredentials, error) {
+	sealed := *object
+	var err error
+	if sealed.OtherDetails, err = ds.keyring.Seal(object.OtherDetails); err != nil {
+		return nil, err
+	}
+
+	return &sealed, nil
+}
+
+// openServiceBindingCredentials opens the sealed secrets of the record in place.
+func (ds *SqlDatastore) openServiceBindingCredentials(record *models.ServiceBindingCredentials) error {
+	var err error
+	if record.OtherDetails, err = ds.keyring.Open(record.OtherDetails); err != nil {
+		return err
+	}
+
+	return nil
 }
 // DeleteServiceBindingCredentialsByServiceInstanceIdAndBindingId soft-deletes the record by its key (serviceInstanceId, bindingId).
 func DeleteServiceBindingCredentialsByServiceInstanceIdAndBindingId(ctx context.Context, serviceInstanceId string, bindingId string) error { return defaultDatastore().DeleteServiceBindingCredentialsByServiceInstanceIdAndBindingId(ctx, serviceInstanceId, bindingId) }
@@ -150,6 +209,10 @@ func (ds *SqlDatastore) GetServiceBindingCredentialsByServiceInstanceIdAndBindin
 		return nil, err
 	}
 
+	if err := ds.openServiceBindingCredentials(&record); err != nil {
+		return nil, err
+	}
+
 	return &record, nil
 }
 
@@ -172,6 +235,10 @@ func (ds *SqlDatastore) GetServiceBindingCredentialsByBindingId(ctx context.Cont
 		return nil, err
 	}
 
+	if err := ds.openServiceBindingCredentials(&record); err != nil {
+		return nil, err
+	}
+
 	return &record, nil
 }
 
@@ -194,6 +261,10 @@ func (ds *SqlDatastore) GetServiceBindingCredentialsById(ctx context.Context, id
 		return nil, err
 	}
 
+	if err := ds.openServiceBindingCredentials(&record); err != nil {
+		return nil, err
+	}
+
 	return &record, nil
 }
 
@@ -211,6 +282,15 @@ func (ds *SqlDatastore) CheckDeletedServiceBindingCredentialsById(ctx context.Co
 
 
 
+// CountProvisionRequestDetailsByServiceInstanceId gets the count of ProvisionRequestDetails by its key (serviceInstanceId) in the datastore (0 or 1)
+func CountProvisionRequestDetailsByServiceInstanceId(ctx context.Context, serviceInstanceId string) (int, error) { return defaultDatastore().CountProvisionRequestDetailsByServiceInstanceId(ctx, serviceInstanceId) }
+func (ds *SqlDatastore) CountProvisionRequestDetailsByServiceInstanceId(ctx context.Context, serviceInstanceId string) (int, error) {
+	var count int
+	err := ds.db.Model(&models.ProvisionRequestDetails{}).Where("service_instance_id = ?", serviceInstanceId).Count(&count).Error
+	return count, err
+}
+
+
 // CountProvisionRequestDetailsById gets the count of ProvisionRequestDetails by its key (id) in the datastore (0 or 1)
 func CountProvisionRequestDetailsById(ctx context.Context, id uint) (int, error) { return defaultDatastore().CountProvisionRequestDetailsById(ctx, id) }
 func (ds *SqlDatastore) CountProvisionRequestDetailsById(ctx context.Context, id uint) (int, error) {
@@ -230,6 +310,12 @@ func SaveProvisionRequestDetails(ctx context.Context, object *models.ProvisionRe
 func (ds *SqlDatastore) SaveProvisionRequestDetails(ctx context.Context, object *models.ProvisionRequestDetails) error {
 	return ds.db.Save(object).Error
 }
+// DeleteProvisionRequestDetailsByServiceInstanceId soft-deletes the record by its key (serviceInstanceId).
+func DeleteProvisionRequestDetailsByServiceInstanceId(ctx context.Context, serviceInstanceId string) error { return defaultDatastore().DeleteProvisionRequestDetailsByServiceInstanceId(ctx, serviceInstanceId) }
+func (ds *SqlDatastore) DeleteProvisionRequestDetailsByServiceInstanceId(ctx context.Context, serviceInstanceId string) error {
+	return ds.db.Where("service_instance_id = ?", serviceInstanceId).Delete(&models.ProvisionRequestDetails{}).Error
+}
+
 // DeleteProvisionRequestDetailsById soft-deletes the record by its key (id).
 func DeleteProvisionRequestDetailsById(ctx context.Context, id uint) error { return defaultDatastore().DeleteProvisionRequestDetailsById(ctx, id) }
 func (ds *SqlDatastore) DeleteProvisionRequestDetailsById(ctx context.Context, id uint) error {
@@ -243,6 +329,28 @@ func DeleteProvisionRequestDetails(ctx context.Context, record *models.Provision
 func (ds *SqlDatastore) DeleteProvisionRequestDetails(ctx context.Context, record *models.ProvisionRequestDetails) error {
 	return ds.db.Delete(record).Error
 }
+// GetProvisionRequestDetailsByServiceInstanceId gets an instance of ProvisionRequestDetails by its key (serviceInstanceId).
+func GetProvisionRequestDetailsByServiceInstanceId(ctx context.Context, serviceInstanceId string) (*models.ProvisionRequestDetails, error) { return defaultDatastore().GetProvisionRequestDetailsByServiceInstanceId(ctx, serviceInstanceId) }
+func (ds *SqlDatastore) GetProvisionRequestDetailsByServiceInstanceId(ctx context.Context, serviceInstanceId string) (*models.ProvisionRequestDetails, error) {
+	record := models.ProvisionRequestDetails{}
+	if err := ds.db.Where("service_instance_id = ?", serviceInstanceId).First(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// CheckDeletedProvisionRequestDetailsByServiceInstanceId checks to see if an instance of ProvisionRequestDetails was soft deleted by its key (serviceInstanceId).
+func CheckDeletedProvisionRequestDetailsByServiceInstanceId(ctx context.Context, serviceInstanceId string) (bool, error) { return defaultDatastore().CheckDeletedProvisionRequestDetailsByServiceInstanceId(ctx, serviceInstanceId) }
+func (ds *SqlDatastore) CheckDeletedProvisionRequestDetailsByServiceInstanceId(ctx context.Context, serviceInstanceId string) (bool, error) {
+	record := models.ProvisionRequestDetails{}
+	if err := ds.db.Unscoped().Where("service_instance_id = ?", serviceInstanceId).First(&record).Error; err != nil {
+		return false, err
+	}
+
+	return record.DeletedAt != nil, nil
+}
+
 // GetProvisionRequestDetailsById gets an instance of ProvisionRequestDetails by its key (id).
 func GetProvisionRequestDetailsById(ctx context.Context, id uint) (*models.ProvisionRequestDetails, error) { return defaultDatastore().GetProvisionRequestDetailsById(ctx, id) }
 func (ds *SqlDatastore) GetProvisionRequestDetailsById(ctx context.Context, id uint) (*models.ProvisionRequestDetails, error) {
@@ -373,13 +481,58 @@ func (ds *SqlDatastore) CountTerraformDeploymentById(ctx context.Context, id str
 // CreateTerraformDeployment creates a new record in the database and assigns it a primary key.
 func CreateTerraformDeployment(ctx context.Context, object *models.TerraformDeployment) error { return defaultDatastore().CreateTerraformDeployment(ctx, object) }
 func (ds *SqlDatastore) CreateTerraformDeployment(ctx context.Context, object *models.TerraformDeployment) error {
-	return ds.db.Create(object).Error
+	sealed, err := ds.sealTerraformDeployment(object)
+	if err != nil {
+		return err
+	}
+
+	if err := ds.db.Create(sealed).Error; err != nil {
+		return err
+	}
+
+	// keep the fields the database assigned, but not the sealed secrets
+	sealed.Workspace = object.Workspace
+	*object = *sealed
+	return nil
 }
 
 // SaveTerraformDeployment updates an existing record in the database.
 func SaveTerraformDeployment(ctx context.Context, object *models.TerraformDeployment) error { return defaultDatastore().SaveTerraformDeployment(ctx, object) }
 func (ds *SqlDatastore) SaveTerraformDeployment(ctx context.Context, object *models.TerraformDeployment) error {
-	return ds.db.Save(object).Error
+	sealed, err := ds.sealTerraformDeployment(object)
+	if err != nil {
+		return err
+	}
+
+	if err := ds.db.Save(sealed).Error; err != nil {
+		return err
+	}
+
+	// keep the fields the database assigned, but not the sealed secrets
+	sealed.Workspace = object.Workspace
+	*object = *sealed
+	return nil
+}
+
+// sealTerraformDeployment creates a copy of the object with its secrets sealed.
+func (ds *SqlDatastore) sealTerraformDeployment(object *models.TerraformDeployment) (*models.TerraformDeployment, error) {
+	sealed := *object
+	var err error
+	if sealed.Workspace, err = ds.keyring.Seal(object.Workspace); err != nil {
+		return nil, err
+	}
+
+	return &sealed, nil
+}
+
+// openTerraformDeployment opens the sealed secrets of the record in place.
+func (ds *SqlDatastore) openTerraformDeployment(record *models.TerraformDeployment) error {
+	var err error
+	if record.Workspace, err = ds.keyring.Open(record.Workspace); err != nil {
+		return err
+	}
+
+	return nil
 }
 // DeleteTerraformDeploymentById soft-deletes the record by its key (id).
 func DeleteTerraformDeploymentById(ctx context.Context, id string) error { return defaultDatastore().DeleteTerraformDeploymentById(ctx, id) }
@@ -402,6 +555,10 @@ func (ds *SqlDatastore) GetTerraformDeploymentById(ctx context.Context, id strin
 		return nil, err
 	}
 
+	if err := ds.openTerraformDeployment(&record); err != nil {
+		return nil, err
+	}
+
 	return &record, nil
 }
 
@@ -417,3 +574,223 @@ func (ds *SqlDatastore) CheckDeletedTerraformDeploymentById(ctx context.Context,
 }
 
 
+
+
+// CountTerraformDeploymentLogById gets the count of TerraformDeploymentLog by its key (id) in the datastore (0 or 1)
+func CountTerraformDeploymentLogById(ctx context.Context, id uint) (int, error) { return defaultDatastore().CountTerraformDeploymentLogById(ctx, id) }
+func (ds *SqlDatastore) CountTerraformDeploymentLogById(ctx context.Context, id uint) (int, error) {
+	var count int
+	err := ds.db.Model(&models.TerraformDeploymentLog{}).Where("id = ?", id).Count(&count).Error
+	return count, err
+}
+
+// CreateTerraformDeploymentLog creates a new record in the database and assigns it a primary key.
+func CreateTerraformDeploymentLog(ctx context.Context, object *models.TerraformDeploymentLog) error { return defaultDatastore().CreateTerraformDeploymentLog(ctx, object) }
+func (ds *SqlDatastore) CreateTerraformDeploymentLog(ctx context.Context, object *models.TerraformDeploymentLog) error {
+	return ds.db.Create(object).Error
+}
+
+// SaveTerraformDeploymentLog updates an existing record in the database.
+func SaveTerraformDeploymentLog(ctx context.Context, object *models.TerraformDeploymentLog) error { return defaultDatastore().SaveTerraformDeploymentLog(ctx, object) }
+func (ds *SqlDatastore) SaveTerraformDeploymentLog(ctx context.Context, object *models.TerraformDeploymentLog) error {
+	return ds.db.Save(object).Error
+}
+// DeleteTerraformDeploymentLogById soft-deletes the record by its key (id).
+func DeleteTerraformDeploymentLogById(ctx context.Context, id uint) error { return defaultDatastore().DeleteTerraformDeploymentLogById(ctx, id) }
+func (ds *SqlDatastore) DeleteTerraformDeploymentLogById(ctx context.Context, id uint) error {
+	return ds.db.Where("id = ?", id).Delete(&models.TerraformDeploymentLog{}).Error
+}
+
+
+
+// DeleteTerraformDeploymentLog soft-deletes the record.
+func DeleteTerraformDeploymentLog(ctx context.Context, record *models.TerraformDeploymentLog) error { return defaultDatastore().DeleteTerraformDeploymentLog(ctx, record) }
+func (ds *SqlDatastore) DeleteTerraformDeploymentLog(ctx context.Context, record *models.TerraformDeploymentLog) error {
+	return ds.db.Delete(record).Error
+}
+// GetTerraformDeploymentLogById gets an instance of TerraformDeploymentLog by its key (id).
+func GetTerraformDeploymentLogById(ctx context.Context, id uint) (*models.TerraformDeploymentLog, error) { return defaultDatastore().GetTerraformDeploymentLogById(ctx, id) }
+func (ds *SqlDatastore) GetTerraformDeploymentLogById(ctx context.Context, id uint) (*models.TerraformDeploymentLog, error) {
+	record := models.TerraformDeploymentLog{}
+	if err := ds.db.Where("id = ?", id).First(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// CheckDeletedTerraformDeploymentLogById checks to see if an instance of TerraformDeploymentLog was soft deleted by its key (id).
+func CheckDeletedTerraformDeploymentLogById(ctx context.Context, id uint) (bool, error) { return defaultDatastore().CheckDeletedTerraformDeploymentLogById(ctx, id) }
+func (ds *SqlDatastore) CheckDeletedTerraformDeploymentLogById(ctx context.Context, id uint) (bool, error) {
+	record := models.TerraformDeploymentLog{}
+	if err := ds.db.Unscoped().Where("id = ?", id).First(&record).Error; err != nil {
+		return false, err
+	}
+
+	return record.DeletedAt != nil, nil
+}
+
+
+
+
+// CountOperationHistoryById gets the count of OperationHistory by its key (id) in the datastore (0 or 1)
+func CountOperationHistoryById(ctx context.Context, id uint) (int, error) { return defaultDatastore().CountOperationHistoryById(ctx, id) }
+func (ds *SqlDatastore) CountOperationHistoryById(ctx context.Context, id uint) (int, error) {
+	var count int
+	err := ds.db.Model(&models.OperationHistory{}).Where("id = ?", id).Count(&count).Error
+	return count, err
+}
+
+// CreateOperationHistory creates a new record in the database and assigns it a primary key.
+func CreateOperationHistory(ctx context.Context, object *models.OperationHistory) error { return defaultDatastore().CreateOperationHistory(ctx, object) }
+func (ds *SqlDatastore) CreateOperationHistory(ctx context.Context, object *models.OperationHistory) error {
+	return ds.db.Create(object).Error
+}
+
+// SaveOperationHistory updates an existing record in the database.
+func SaveOperationHistory(ctx context.Context, object *models.OperationHistory) error { return defaultDatastore().SaveOperationHistory(ctx, object) }
+func (ds *SqlDatastore) SaveOperationHistory(ctx context.Context, object *models.OperationHistory) error {
+	return ds.db.Save(object).Error
+}
+// DeleteOperationHistoryById soft-deletes the record by its key (id).
+func DeleteOperationHistoryById(ctx context.Context, id uint) error { return defaultDatastore().DeleteOperationHistoryById(ctx, id) }
+func (ds *SqlDatastore) DeleteOperationHistoryById(ctx context.Context, id uint) error {
+	return ds.db.Where("id = ?", id).Delete(&models.OperationHistory{}).Error
+}
+
+
+
+// DeleteOperationHistory soft-deletes the record.
+func DeleteOperationHistory(ctx context.Context, record *models.OperationHistory) error { return defaultDatastore().DeleteOperationHistory(ctx, record) }
+func (ds *SqlDatastore) DeleteOperationHistory(ctx context.Context, record *models.OperationHistory) error {
+	return ds.db.Delete(record).Error
+}
+// GetOperationHistoryById gets an instance of OperationHistory by its key (id).
+func GetOperationHistoryById(ctx context.Context, id uint) (*models.OperationHistory, error) { return defaultDatastore().GetOperationHistoryById(ctx, id) }
+func (ds *SqlDatastore) GetOperationHistoryById(ctx context.Context, id uint) (*models.OperationHistory, error) {
+	record := models.OperationHistory{}
+	if err := ds.db.Where("id = ?", id).First(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// CheckDeletedOperationHistoryById checks to see if an instance of OperationHistory was soft deleted by its key (id).
+func CheckDeletedOperationHistoryById(ctx context.Context, id uint) (bool, error) { return defaultDatastore().CheckDeletedOperationHistoryById(ctx, id) }
+func (ds *SqlDatastore) CheckDeletedOperationHistoryById(ctx context.Context, id uint) (bool, error) {
+	record := models.OperationHistory{}
+	if err := ds.db.Unscoped().Where("id = ?", id).First(&record).Error; err != nil {
+		return false, err
+	}
+
+	return record.DeletedAt != nil, nil
+}
+
+
+
+
+// CountRetiredCredentialsById gets the count of RetiredCredentials by its key (id) in the datastore (0 or 1)
+func CountRetiredCredentialsById(ctx context.Context, id uint) (int, error) { return defaultDatastore().CountRetiredCredentialsById(ctx, id) }
+func (ds *SqlDatastore) CountRetiredCredentialsById(ctx context.Context, id uint) (int, error) {
+	var count int
+	err := ds.db.Model(&models.RetiredCredentials{}).Where("id = ?", id).Count(&count).Error
+	return count, err
+}
+
+// CreateRetiredCredentials creates a new record in the database and assigns it a primary key.
+func CreateRetiredCredentials(ctx context.Context, object *models.RetiredCredentials) error { return defaultDatastore().CreateRetiredCredentials(ctx, object) }
+func (ds *SqlDatastore) CreateRetiredCredentials(ctx context.Context, object *models.RetiredCredentials) error {
+	sealed, err := ds.sealRetiredCredentials(object)
+	if err != nil {
+		return err
+	}
+
+	if err := ds.db.Create(sealed).Error; err != nil {
+		return err
+	}
+
+	// keep the fields the database assigned, but not the sealed secrets
+	sealed.Details = object.Details
+	*object = *sealed
+	return nil
+}
+
+// SaveRetiredCredentials updates an existing record in the database.
+func SaveRetiredCredentials(ctx context.Context, object *models.RetiredCredentials) error { return defaultDatastore().SaveRetiredCredentials(ctx, object) }
+func (ds *SqlDatastore) SaveRetiredCredentials(ctx context.Context, object *models.RetiredCredentials) error {
+	sealed, err := ds.sealRetiredCredentials(object)
+	if err != nil {
+		return err
+	}
+
+	if err := ds.db.Save(sealed).Error; err != nil {
+		return err
+	}
+
+	// keep the fields the database assigned, but not the sealed secrets
+	sealed.Details = object.Details
+	*object = *sealed
+	return nil
+}
+
+// sealRetiredCredentials creates a copy of the object with its secrets sealed.
+func (ds *SqlDatastore) sealRetiredCredentials(object *models.RetiredCredentials) (*models.RetiredCredentials, error) {
+	sealed := *object
+	var err error
+	if sealed.Details, err = ds.keyring.Seal(object.Details); err != nil {
+		return nil, err
+	}
+
+	return &sealed, nil
+}
+
+// openRetiredCredentials opens the sealed secrets of the record in place.
+func (ds *SqlDatastore) openRetiredCredentials(record *models.RetiredCredentials) error {
+	var err error
+	if record.Details, err = ds.keyring.Open(record.Details); err != nil {
+		return err
+	}
+
+	return nil
+}
+// DeleteRetiredCredentialsById soft-deletes the record by its key (id).
+func DeleteRetiredCredentialsById(ctx context.Context, id uint) error { return defaultDatastore().DeleteRetiredCredentialsById(ctx, id) }
+func (ds *SqlDatastore) DeleteRetiredCredentialsById(ctx context.Context, id uint) error {
+	return ds.db.Where("id = ?", id).Delete(&models.RetiredCredentials{}).Error
+}
+
+
+
+// DeleteRetiredCredentials soft-deletes the record.
+func DeleteRetiredCredentials(ctx context.Context, record *models.RetiredCredentials) error { return defaultDatastore().DeleteRetiredCredentials(ctx, record) }
+func (ds *SqlDatastore) DeleteRetiredCredentials(ctx context.Context, record *models.RetiredCredentials) error {
+	return ds.db.Delete(record).Error
+}
+// GetRetiredCredentialsById gets an instance of RetiredCredentials by its key (id).
+func GetRetiredCredentialsById(ctx context.Context, id uint) (*models.RetiredCredentials, error) { return defaultDatastore().GetRetiredCredentialsById(ctx, id) }
+func (ds *SqlDatastore) GetRetiredCredentialsById(ctx context.Context, id uint) (*models.RetiredCredentials, error) {
+	record := models.RetiredCredentials{}
+	if err := ds.db.Where("id = ?", id).First(&record).Error; err != nil {
+		return nil, err
+	}
+
+	if err := ds.openRetiredCredentials(&record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// CheckDeletedRetiredCredentialsById checks to see if an instance of RetiredCredentials was soft deleted by its key (id).
+func CheckDeletedRetiredCredentialsById(ctx context.Context, id uint) (bool, error) { return defaultDatastore().CheckDeletedRetiredCredentialsById(ctx, id) }
+func (ds *SqlDatastore) CheckDeletedRetiredCredentialsById(ctx context.Context, id uint) (bool, error) {
+	record := models.RetiredCredentials{}
+	if err := ds.db.Unscoped().Where("id = ?", id).First(&record).Error; err != nil {
+		return false, err
+	}
+
+	return record.DeletedAt != nil, nil
+}
+
+
@@ -33,6 +33,7 @@ func main() {
 			Type:            "ServiceInstanceDetails",
 			PrimaryKeyType:  "string",
 			PrimaryKeyField: "id",
+			Versioned:       true,
 			ExampleFields: map[string]interface{}{
 				"Name":             "Hello",
 				"Location":         "loc",
@@ -57,6 +58,7 @@ func main() {
 					{Type: "string", Column: "binding_id"},
 				},
 			},
+			SealedFields: []string{"OtherDetails"},
 			ExampleFields: map[string]interface{}{
 				"ServiceId":         "1111-1111-1111",
 				"ServiceInstanceId": "2222-2222-2222",
@@ -68,6 +70,11 @@ func main() {
 			Type:            "ProvisionRequestDetails",
 			PrimaryKeyType:  "uint",
 			PrimaryKeyField: "id",
+			Keys: []fieldList{
+				{
+					{Type: "string", Column: "service_instance_id"},
+				},
+			},
 			ExampleFields: map[string]interface{}{
 				"ServiceInstanceId": "2222-2222-2222",
 				"RequestDetails":    `{"some":["json","blob","here"]}`,
@@ -94,6 +101,7 @@ func main() {
 			PrimaryKeyType:  "string",
 			PrimaryKeyField: "id",
 			Keys:            []fieldList{},
+			SealedFields:    []string{"Workspace"},
 			ExampleFields: map[string]interface{}{
 				"Workspace":            "{}",
 				"LastOperationType":    "create",
@@ -101,9 +109,51 @@ func main() {
 				"LastOperationMessage": `Started 2018-01-01`,
 			},
 		},
+		{
+			Type:            "TerraformDeploymentLog",
+			PrimaryKeyType:  "uint",
+			PrimaryKeyField: "id",
+			Keys:            []fieldList{},
+			ExampleFields: map[string]interface{}{
+				"TerraformDeploymentId": "tf:instance:",
+				"OperationId":           "1234",
+				"OperationType":         "provision",
+				"Output":                "Apply complete!",
+			},
+		},
+		{
+			Type:            "OperationHistory",
+			PrimaryKeyType:  "uint",
+			PrimaryKeyField: "id",
+			Keys:            []fieldList{},
+			ExampleFields: map[string]interface{}{
+				"ServiceInstanceId": "instance-1",
+				"Request":           "provision",
+				"OperationType":     "provision",
+				"Result":            "succeeded",
+				"DurationMillis":    1234,
+			},
+		},
+		{
+			Type:            "RetiredCredentials",
+			PrimaryKeyType:  "uint",
+			PrimaryKeyField: "id",
+			Keys:            []fieldList{},
+			SealedFields:    []string{"Details"},
+			ExampleFields: map[string]interface{}{
+				"ServiceId":         "1111-1111-1111",
+				"ServiceInstanceId": "2222-2222-2222",
+				"BindingId":         "0000-0000-0000",
+				"Details":           `{"some":["json","blob","here"]}`,
+			},
+		},
 	}
 
 	for i, model := range models {
+		if model.Versioned && len(model.SealedFields) > 0 {
+			log.Fatalf("%s: versioned models can't have sealed fields", model.Type)
+		}
+
 		pk := fieldList{{Type: model.PrimaryKeyType, Column: model.PrimaryKeyField}}
 		models[i].Keys = append(model.Keys, pk)
 	}
@@ -152,6 +202,12 @@ type crudModel struct {
 	PrimaryKeyField string
 	ExampleFields   map[string]interface{}
 	Keys            []fieldList
+	// SealedFields are the string fields holding secrets that get encrypted
+	// with the datastore's keyring before they're written.
+	SealedFields []string
+	// Versioned models have a Version field that's incremented on every save.
+	// Saves and deletes of stale copies fail with ErrConcurrentModification.
+	Versioned bool
 }
 
 type fieldList []crudField
@@ -310,15 +366,88 @@ func (ds *SqlDatastore) {{$fn}}(ctx context.Context, {{ $key.Args }}) (int, erro
 // {{funcName "Create" .Type}} creates a new record in the database and assigns it a primary key.
 func {{funcName "Create" .Type}}(ctx context.Context, object *models.{{.Type}}) error { return defaultDatastore().{{funcName "Create" .Type}}(ctx, object) }
 func (ds *SqlDatastore) Create{{.Type}}(ctx context.Context, object *models.{{.Type}}) error {
+{{- if .SealedFields }}
+	sealed, err := ds.seal{{.Type}}(object)
+	if err != nil {
+		return err
+	}
+
+	if err := ds.db.Create(sealed).Error; err != nil {
+		return err
+	}
+
+	// keep the fields the database assigned, but not the sealed secrets
+{{- range .SealedFields }}
+	sealed.{{.}} = object.{{.}}
+{{- end }}
+	*object = *sealed
+	return nil
+{{- else }}
 	return ds.db.Create(object).Error
+{{- end }}
 }
 
 // {{funcName "Save" .Type}} updates an existing record in the database.
 func {{funcName "Save" .Type}}(ctx context.Context, object *models.{{.Type}}) error { return defaultDatastore().{{funcName "Save" .Type}}(ctx, object) }
 func (ds *SqlDatastore) {{funcName "Save" .Type}}(ctx context.Context, object *models.{{.Type}}) error {
+{{- if .SealedFields }}
+	sealed, err := ds.seal{{.Type}}(object)
+	if err != nil {
+		return err
+	}
+
+	if err := ds.db.Save(sealed).Error; err != nil {
+		return err
+	}
+
+	// keep the fields the database assigned, but not the sealed secrets
+{{- range .SealedFields }}
+	sealed.{{.}} = object.{{.}}
+{{- end }}
+	*object = *sealed
+	return nil
+{{- else if .Versioned }}
+	// the record is only updated if nobody else saved it since it was read
+	version := object.Version
+	object.Version++
+	if err := ds.updateIfVersion(object, version); err != nil {
+		object.Version = version
+		return err
+	}
+
+	return nil
+{{- else }}
 	return ds.db.Save(object).Error
+{{- end }}
+}
+{{- if .SealedFields }}
+
+// seal{{.Type}} creates a copy of the object with its secrets sealed.
+func (ds *SqlDatastore) seal{{.Type}}(object *models.{{.Type}}) (*models.{{.Type}}, error) {
+	sealed := *object
+	var err error
+{{- range .SealedFields }}
+	if sealed.{{.}}, err = ds.keyring.Seal(object.{{.}}); err != nil {
+		return nil, err
+	}
+{{- end }}
+
+	return &sealed, nil
 }
 
+// open{{.Type}} opens the sealed secrets of the record in place.
+func (ds *SqlDatastore) open{{.Type}}(record *models.{{.Type}}) error {
+	var err error
+{{- range .SealedFields }}
+	if record.{{.}}, err = ds.keyring.Open(record.{{.}}); err != nil {
+		return err
+	}
+{{- end }}
+
+	return nil
+}
+{{- end }}
+
 {{- $type := .Type}}
 {{ range $idx, $key := .Keys -}}
 {{ $fn := (print "Delete" $type $key.FuncName) -}}
@@ -333,10 +462,20 @@ func (ds *SqlDatastore) {{$fn}}(ctx context.Context, {{ $key.Args }}) error {
 // Delete{{.Type}} soft-deletes the record.
 func {{funcName "Delete" .Type}}(ctx context.Context, record *models.{{.Type}}) error { return defaultDatastore().{{funcName "Delete" .Type}}(ctx, record) }
 func (ds *SqlDatastore) {{funcName "Delete" .Type}}(ctx context.Context, record *models.{{.Type}}) error {
+{{- if .Versioned }}
+	// the record is only deleted if nobody else saved it since it was read
+	result := ds.db.Where("version = ?", record.Version).Delete(record)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrConcurrentModification
+	}
+
+	return result.Error
+{{- else }}
 	return ds.db.Delete(record).Error
+{{- end }}
 }
 
-{{- $type := .Type}}
+{{- $type := .Type}}{{ $sealed := .SealedFields }}
 {{ range $idx, $key := .Keys -}}
 
 {{ $fn := (print "Get" $type $key.FuncName) -}}
@@ -347,6 +486,12 @@ func (ds *SqlDatastore) {{$fn}}(ctx context.Context, {{ $key.Args }}) (*models.{
 	if err := ds.db.{{ $key.WhereClause }}.First(&record).Error; err != nil {
 		return nil, err
 	}
+{{- if $sealed }}
+
+	if err := ds.open{{$type}}(&record); err != nil {
+		return nil, err
+	}
+{{- end }}
 
 	return &record, nil
 }
@@ -399,15 +544,9 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
-func newInMemoryDatastore(t *testing.T) *SqlDatastore {
-	testDb, err := gorm.Open("sqlite3", ":memory:")
-	if err != nil {
-		t.Fatalf("Error opening test database %s", err)
-	}
-
-	{{range .Models}}testDb.CreateTable(models.{{.Type}}{})
-	{{end}}
-	return &SqlDatastore{db: testDb}
+func newTestDatastore(t *testing.T) *SqlDatastore {
+	testDb := newTestDb(t{{range .Models}}, &models.{{.Type}}{}{{end}})
+	return &SqlDatastore{db: testDb, keyring: newTestKeyring(t)}
 }
 
 
@@ -433,7 +572,7 @@ func ensure{{.Type}}FieldsMatch(t *testing.T, expected, actual *models.{{.Type}}
 }
 
 func TestSqlDatastore_{{.Type}}DAO(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	testPk, instance := create{{.Type}}Instance()
 	testCtx := context.Background()
 
@@ -451,7 +590,8 @@ func TestSqlDatastore_{{.Type}}DAO(t *testing.T) {
 	}
 
 	// Should be able to create the item
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.{{funcName "Create" .Type}}(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -473,6 +613,19 @@ func TestSqlDatastore_{{.Type}}DAO(t *testing.T) {
 
 	// Ensure non-gorm fields were deserialized correctly
 	ensure{{.Type}}FieldsMatch(t, &instance, ret)
+{{- if .SealedFields }}
+
+	// secrets must only be stored sealed
+	stored := models.{{.Type}}{}
+	if err := ds.db.Where("id = ?", testPk).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+{{range .SealedFields }}
+	if !IsSealed(stored.{{.}}) {
+		t.Errorf("Expected field {{.}} to be stored sealed, got %#v", stored.{{.}})
+	}
+{{- end }}
+{{- end }}
 
 	// we should be able to update the item and it will have a new updated time
 	if err := ds.{{funcName "Save" .Type}}(testCtx, ret); err != nil {
@@ -510,12 +663,59 @@ func TestSqlDatastore_{{.Type}}DAO(t *testing.T) {
 		t.Errorf("Expected ErrRecordNotFound after delete but got %v", err)
 	}
 }
+{{- if .Versioned }}
+
+func TestSqlDatastore_{{.Type}}Versions(t *testing.T) {
+	ds := newTestDatastore(t)
+	testPk, instance := create{{.Type}}Instance()
+	testCtx := context.Background()
+
+	if err := ds.{{funcName "Create" .Type}}(testCtx, &instance); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := ds.{{funcName "Get" .Type .PrimaryKeyField}}(testCtx, testPk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := ds.{{funcName "Get" .Type .PrimaryKeyField}}(testCtx, testPk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ds.{{funcName "Save" .Type}}(testCtx, first); err != nil {
+		t.Fatalf("Expected the first save to succeed, got %v", err)
+	}
+
+	if first.Version != 1 {
+		t.Errorf("Expected the version to be incremented to 1, got %d", first.Version)
+	}
+
+	// the second copy was read before the first save
+	if err := ds.{{funcName "Save" .Type}}(testCtx, second); err != ErrConcurrentModification {
+		t.Errorf("Expected saving a stale copy to fail with ErrConcurrentModification, got %v", err)
+	}
+
+	if second.Version != 0 {
+		t.Errorf("Expected a failed save to leave the version alone, got %d", second.Version)
+	}
+
+	if err := ds.{{funcName "Delete" .Type}}(testCtx, second); err != ErrConcurrentModification {
+		t.Errorf("Expected deleting a stale copy to fail with ErrConcurrentModification, got %v", err)
+	}
+
+	if err := ds.{{funcName "Delete" .Type}}(testCtx, first); err != nil {
+		t.Errorf("Expected deleting the current copy to succeed, got %v", err)
+	}
+}
+{{- end }}
 
 {{- $type := .Type}}{{ $pk := .PrimaryKeyField }}
 {{ range $idx, $key := .Keys -}}
 {{ $fn := (print "Get" $type $key.FuncName) -}}
 func TestSqlDatastore_{{$fn}}(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := create{{$type}}Instance()
 	testCtx := context.Background()
 
@@ -523,7 +723,8 @@ func TestSqlDatastore_{{$fn}}(t *testing.T) {
 		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
 	}
 
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.{{funcName "Create" $type}}(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -549,7 +750,7 @@ func TestSqlDatastore_{{$fn}}(t *testing.T) {
 
 {{ $fn := (print "CheckDeleted" $type $key.FuncName) -}}
 func TestSqlDatastore_{{$fn}}(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := create{{$type}}Instance()
 	testCtx := context.Background()
 
@@ -585,7 +786,7 @@ func TestSqlDatastore_{{$fn}}(t *testing.T) {
 
 {{ $fn := (print "Count" $type $key.FuncName) -}}
 func TestSqlDatastore_{{$fn}}(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := create{{$type}}Instance()
 	testCtx := context.Background()
 
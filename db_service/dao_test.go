@@ -25,19 +25,9 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
-func newInMemoryDatastore(t *testing.T) *SqlDatastore {
-	testDb, err := gorm.Open("sqlite3", ":memory:")
-	if err != nil {
-		t.Fatalf("Error opening test database %s", err)
-	}
-
-	testDb.CreateTable(models.ServiceInstanceDetails{})
-	testDb.CreateTable(models.ServiceBindingCredentials{})
-	testDb.CreateTable(models.ProvisionRequestDetails{})
-	testDb.CreateTable(models.PlanDetailsV1{})
-	testDb.CreateTable(models.TerraformDeployment{})
-	
-	return &SqlDatastore{db: testDb}
+func newTestDatastore(t *testing.T) *SqlDatastore {
+	testDb := newTestDb(t, &models.ServiceInstanceDetails{}, &models.ServiceBindingCredentials{}, &models.ProvisionRequestDetails{}, &models.PlanDetailsV1{}, &models.TerraformDeployment{}, &models.TerraformDeploymentLog{}, &models.OperationHistory{}, &models.RetiredCredentials{})
+	return &SqlDatastore{db: testDb, keyring: newTestKeyring(t)}
 }
 
 func createServiceInstanceDetailsInstance() (string, models.ServiceInstanceDetails) {
@@ -95,7 +85,7 @@ func ensureServiceInstanceDetailsFieldsMatch(t *testing.T, expected, actual *mod
 }
 
 func TestSqlDatastore_ServiceInstanceDetailsDAO(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	testPk, instance := createServiceInstanceDetailsInstance()
 	testCtx := context.Background()
 
@@ -113,7 +103,8 @@ func TestSqlDatastore_ServiceInstanceDetailsDAO(t *testing.T) {
 	}
 
 	// Should be able to create the item
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.CreateServiceInstanceDetails(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -172,8 +163,53 @@ func TestSqlDatastore_ServiceInstanceDetailsDAO(t *testing.T) {
 		t.Errorf("Expected ErrRecordNotFound after delete but got %v", err)
 	}
 }
+
+func TestSqlDatastore_ServiceInstanceDetailsVersions(t *testing.T) {
+	ds := newTestDatastore(t)
+	testPk, instance := createServiceInstanceDetailsInstance()
+	testCtx := context.Background()
+
+	if err := ds.CreateServiceInstanceDetails(testCtx, &instance); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := ds.GetServiceInstanceDetailsById(testCtx, testPk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := ds.GetServiceInstanceDetailsById(testCtx, testPk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ds.SaveServiceInstanceDetails(testCtx, first); err != nil {
+		t.Fatalf("Expected the first save to succeed, got %v", err)
+	}
+
+	if first.Version != 1 {
+		t.Errorf("Expected the version to be incremented to 1, got %d", first.Version)
+	}
+
+	// the second copy was read before the first save
+	if err := ds.SaveServiceInstanceDetails(testCtx, second); err != ErrConcurrentModification {
+		t.Errorf("Expected saving a stale copy to fail with ErrConcurrentModification, got %v", err)
+	}
+
+	if second.Version != 0 {
+		t.Errorf("Expected a failed save to leave the version alone, got %d", second.Version)
+	}
+
+	if err := ds.DeleteServiceInstanceDetails(testCtx, second); err != ErrConcurrentModification {
+		t.Errorf("Expected deleting a stale copy to fail with ErrConcurrentModification, got %v", err)
+	}
+
+	if err := ds.DeleteServiceInstanceDetails(testCtx, first); err != nil {
+		t.Errorf("Expected deleting the current copy to succeed, got %v", err)
+	}
+}
 func TestSqlDatastore_GetServiceInstanceDetailsById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createServiceInstanceDetailsInstance()
 	testCtx := context.Background()
 
@@ -181,7 +217,8 @@ func TestSqlDatastore_GetServiceInstanceDetailsById(t *testing.T) {
 		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
 	}
 
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.CreateServiceInstanceDetails(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -206,7 +243,7 @@ func TestSqlDatastore_GetServiceInstanceDetailsById(t *testing.T) {
 }
 
 func TestSqlDatastore_CheckDeletedServiceInstanceDetailsById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createServiceInstanceDetailsInstance()
 	testCtx := context.Background()
 
@@ -241,7 +278,7 @@ func TestSqlDatastore_CheckDeletedServiceInstanceDetailsById(t *testing.T) {
 }
 
 func TestSqlDatastore_CountServiceInstanceDetailsById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createServiceInstanceDetailsInstance()
 	testCtx := context.Background()
 
@@ -296,7 +333,7 @@ func ensureServiceBindingCredentialsFieldsMatch(t *testing.T, expected, actual *
 }
 
 func TestSqlDatastore_ServiceBindingCredentialsDAO(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	testPk, instance := createServiceBindingCredentialsInstance()
 	testCtx := context.Background()
 
@@ -314,7 +351,8 @@ func TestSqlDatastore_ServiceBindingCredentialsDAO(t *testing.T) {
 	}
 
 	// Should be able to create the item
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.CreateServiceBindingCredentials(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -337,6 +375,16 @@ func TestSqlDatastore_ServiceBindingCredentialsDAO(t *testing.T) {
 	// Ensure non-gorm fields were deserialized correctly
 	ensureServiceBindingCredentialsFieldsMatch(t, &instance, ret)
 
+	// secrets must only be stored sealed
+	stored := models.ServiceBindingCredentials{}
+	if err := ds.db.Where("id = ?", testPk).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsSealed(stored.OtherDetails) {
+		t.Errorf("Expected field OtherDetails to be stored sealed, got %#v", stored.OtherDetails)
+	}
+
 	// we should be able to update the item and it will have a new updated time
 	if err := ds.SaveServiceBindingCredentials(testCtx, ret); err != nil {
 		t.Errorf("Expected no error trying to get update %#v , got: %v", ret, err)
@@ -374,7 +422,7 @@ func TestSqlDatastore_ServiceBindingCredentialsDAO(t *testing.T) {
 	}
 }
 func TestSqlDatastore_GetServiceBindingCredentialsByServiceInstanceIdAndBindingId(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createServiceBindingCredentialsInstance()
 	testCtx := context.Background()
 
@@ -382,7 +430,8 @@ func TestSqlDatastore_GetServiceBindingCredentialsByServiceInstanceIdAndBindingI
 		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
 	}
 
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.CreateServiceBindingCredentials(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -407,7 +456,7 @@ func TestSqlDatastore_GetServiceBindingCredentialsByServiceInstanceIdAndBindingI
 }
 
 func TestSqlDatastore_CheckDeletedServiceBindingCredentialsByServiceInstanceIdAndBindingId(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createServiceBindingCredentialsInstance()
 	testCtx := context.Background()
 
@@ -442,7 +491,7 @@ func TestSqlDatastore_CheckDeletedServiceBindingCredentialsByServiceInstanceIdAn
 }
 
 func TestSqlDatastore_CountServiceBindingCredentialsByServiceInstanceIdAndBindingId(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createServiceBindingCredentialsInstance()
 	testCtx := context.Background()
 
@@ -461,7 +510,7 @@ func TestSqlDatastore_CountServiceBindingCredentialsByServiceInstanceIdAndBindin
 	}
 }
 func TestSqlDatastore_GetServiceBindingCredentialsByBindingId(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createServiceBindingCredentialsInstance()
 	testCtx := context.Background()
 
@@ -469,7 +518,8 @@ func TestSqlDatastore_GetServiceBindingCredentialsByBindingId(t *testing.T) {
 		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
 	}
 
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.CreateServiceBindingCredentials(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -494,7 +544,7 @@ func TestSqlDatastore_GetServiceBindingCredentialsByBindingId(t *testing.T) {
 }
 
 func TestSqlDatastore_CheckDeletedServiceBindingCredentialsByBindingId(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createServiceBindingCredentialsInstance()
 	testCtx := context.Background()
 
@@ -529,7 +579,7 @@ func TestSqlDatastore_CheckDeletedServiceBindingCredentialsByBindingId(t *testin
 }
 
 func TestSqlDatastore_CountServiceBindingCredentialsByBindingId(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createServiceBindingCredentialsInstance()
 	testCtx := context.Background()
 
@@ -548,7 +598,7 @@ func TestSqlDatastore_CountServiceBindingCredentialsByBindingId(t *testing.T) {
 	}
 }
 func TestSqlDatastore_GetServiceBindingCredentialsById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createServiceBindingCredentialsInstance()
 	testCtx := context.Background()
 
@@ -556,7 +606,8 @@ func TestSqlDatastore_GetServiceBindingCredentialsById(t *testing.T) {
 		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
 	}
 
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.CreateServiceBindingCredentials(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -581,7 +632,7 @@ func TestSqlDatastore_GetServiceBindingCredentialsById(t *testing.T) {
 }
 
 func TestSqlDatastore_CheckDeletedServiceBindingCredentialsById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createServiceBindingCredentialsInstance()
 	testCtx := context.Background()
 
@@ -616,7 +667,7 @@ func TestSqlDatastore_CheckDeletedServiceBindingCredentialsById(t *testing.T) {
 }
 
 func TestSqlDatastore_CountServiceBindingCredentialsById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createServiceBindingCredentialsInstance()
 	testCtx := context.Background()
 
@@ -661,7 +712,7 @@ func ensureProvisionRequestDetailsFieldsMatch(t *testing.T, expected, actual *mo
 }
 
 func TestSqlDatastore_ProvisionRequestDetailsDAO(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	testPk, instance := createProvisionRequestDetailsInstance()
 	testCtx := context.Background()
 
@@ -679,7 +730,8 @@ func TestSqlDatastore_ProvisionRequestDetailsDAO(t *testing.T) {
 	}
 
 	// Should be able to create the item
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.CreateProvisionRequestDetails(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -738,8 +790,96 @@ func TestSqlDatastore_ProvisionRequestDetailsDAO(t *testing.T) {
 		t.Errorf("Expected ErrRecordNotFound after delete but got %v", err)
 	}
 }
+func TestSqlDatastore_GetProvisionRequestDetailsByServiceInstanceId(t *testing.T) {
+	ds := newTestDatastore(t)
+	_, instance := createProvisionRequestDetailsInstance()
+	testCtx := context.Background()
+
+	if _, err := ds.GetProvisionRequestDetailsByServiceInstanceId(testCtx, instance.ServiceInstanceId); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
+	}
+
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
+	if err := ds.CreateProvisionRequestDetails(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+	afterCreation := time.Now()
+
+	// after creation we should be able to get the item
+	ret, err := ds.GetProvisionRequestDetailsByServiceInstanceId(testCtx, instance.ServiceInstanceId)
+	if err != nil {
+		t.Errorf("Expected no error trying to get saved item, got: %v", err)
+	}
+
+	if ret.CreatedAt.Before(beforeCreation) || ret.CreatedAt.After(afterCreation) {
+		t.Errorf("Expected creation time to be between  %v and %v got %v", beforeCreation, afterCreation, ret.CreatedAt)
+	}
+
+	if !ret.UpdatedAt.Equal(ret.CreatedAt) {
+		t.Errorf("Expected initial update time to equal creation time, but got update: %v, create: %v", ret.UpdatedAt, ret.CreatedAt)
+	}
+
+	// Ensure non-gorm fields were deserialized correctly
+	ensureProvisionRequestDetailsFieldsMatch(t, &instance, ret)
+}
+
+func TestSqlDatastore_CheckDeletedProvisionRequestDetailsByServiceInstanceId(t *testing.T) {
+	ds := newTestDatastore(t)
+	_, instance := createProvisionRequestDetailsInstance()
+	testCtx := context.Background()
+
+	if _, err := ds.CheckDeletedProvisionRequestDetailsByServiceInstanceId(testCtx, instance.ServiceInstanceId); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
+	}
+
+	if err := ds.CreateProvisionRequestDetails(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+
+	deleted, err := ds.CheckDeletedProvisionRequestDetailsByServiceInstanceId(testCtx, instance.ServiceInstanceId)
+	if err != nil {
+		t.Errorf("Expected no error when checking if a non-deleted thing was deleted")
+	}
+	if deleted {
+		t.Errorf("Expected a non-deleted instance to not be marked as deleted but it was.")
+	}
+
+	if err := ds.DeleteProvisionRequestDetails(testCtx, &instance); err != nil {
+		t.Errorf("Expected no error when deleting by pk got: %v", err)
+	}
+
+	// we should be able to see that it was soft-deleted
+	deleted, err = ds.CheckDeletedProvisionRequestDetailsByServiceInstanceId(testCtx, instance.ServiceInstanceId)
+	if err != nil {
+		t.Errorf("Expected no error when checking if a non-deleted thing was deleted")
+	}
+	if !deleted {
+		t.Errorf("Expected a deleted instance to marked as deleted but it was not.")
+	}
+}
+
+func TestSqlDatastore_CountProvisionRequestDetailsByServiceInstanceId(t *testing.T) {
+	ds := newTestDatastore(t)
+	_, instance := createProvisionRequestDetailsInstance()
+	testCtx := context.Background()
+
+	// on startup, there should be no objects to find or delete
+	if count, err := ds.CountProvisionRequestDetailsByServiceInstanceId(testCtx, instance.ServiceInstanceId); count != 0 || err != nil {
+		t.Fatalf("Expected count to be 0 and error to be nil got count: %d, err: %v", count, err)
+	}
+
+	if err := ds.CreateProvisionRequestDetails(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+
+	// on startup, there should be no objects to find or delete
+	if count, err := ds.CountProvisionRequestDetailsByServiceInstanceId(testCtx, instance.ServiceInstanceId); count != 1 || err != nil {
+		t.Fatalf("Expected count to be 1 and error to be nil got count: %d, err: %v", count, err)
+	}
+}
 func TestSqlDatastore_GetProvisionRequestDetailsById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createProvisionRequestDetailsInstance()
 	testCtx := context.Background()
 
@@ -747,7 +887,8 @@ func TestSqlDatastore_GetProvisionRequestDetailsById(t *testing.T) {
 		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
 	}
 
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.CreateProvisionRequestDetails(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -772,7 +913,7 @@ func TestSqlDatastore_GetProvisionRequestDetailsById(t *testing.T) {
 }
 
 func TestSqlDatastore_CheckDeletedProvisionRequestDetailsById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createProvisionRequestDetailsInstance()
 	testCtx := context.Background()
 
@@ -807,7 +948,7 @@ func TestSqlDatastore_CheckDeletedProvisionRequestDetailsById(t *testing.T) {
 }
 
 func TestSqlDatastore_CountProvisionRequestDetailsById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createProvisionRequestDetailsInstance()
 	testCtx := context.Background()
 
@@ -857,7 +998,7 @@ func ensurePlanDetailsV1FieldsMatch(t *testing.T, expected, actual *models.PlanD
 }
 
 func TestSqlDatastore_PlanDetailsV1DAO(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	testPk, instance := createPlanDetailsV1Instance()
 	testCtx := context.Background()
 
@@ -875,7 +1016,8 @@ func TestSqlDatastore_PlanDetailsV1DAO(t *testing.T) {
 	}
 
 	// Should be able to create the item
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.CreatePlanDetailsV1(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -935,7 +1077,7 @@ func TestSqlDatastore_PlanDetailsV1DAO(t *testing.T) {
 	}
 }
 func TestSqlDatastore_GetPlanDetailsV1ByServiceIdAndName(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createPlanDetailsV1Instance()
 	testCtx := context.Background()
 
@@ -943,7 +1085,8 @@ func TestSqlDatastore_GetPlanDetailsV1ByServiceIdAndName(t *testing.T) {
 		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
 	}
 
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.CreatePlanDetailsV1(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -968,7 +1111,7 @@ func TestSqlDatastore_GetPlanDetailsV1ByServiceIdAndName(t *testing.T) {
 }
 
 func TestSqlDatastore_CheckDeletedPlanDetailsV1ByServiceIdAndName(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createPlanDetailsV1Instance()
 	testCtx := context.Background()
 
@@ -1003,7 +1146,7 @@ func TestSqlDatastore_CheckDeletedPlanDetailsV1ByServiceIdAndName(t *testing.T)
 }
 
 func TestSqlDatastore_CountPlanDetailsV1ByServiceIdAndName(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createPlanDetailsV1Instance()
 	testCtx := context.Background()
 
@@ -1022,7 +1165,7 @@ func TestSqlDatastore_CountPlanDetailsV1ByServiceIdAndName(t *testing.T) {
 	}
 }
 func TestSqlDatastore_GetPlanDetailsV1ById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createPlanDetailsV1Instance()
 	testCtx := context.Background()
 
@@ -1030,7 +1173,8 @@ func TestSqlDatastore_GetPlanDetailsV1ById(t *testing.T) {
 		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
 	}
 
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.CreatePlanDetailsV1(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -1055,7 +1199,7 @@ func TestSqlDatastore_GetPlanDetailsV1ById(t *testing.T) {
 }
 
 func TestSqlDatastore_CheckDeletedPlanDetailsV1ById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createPlanDetailsV1Instance()
 	testCtx := context.Background()
 
@@ -1090,7 +1234,7 @@ func TestSqlDatastore_CheckDeletedPlanDetailsV1ById(t *testing.T) {
 }
 
 func TestSqlDatastore_CountPlanDetailsV1ById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createPlanDetailsV1Instance()
 	testCtx := context.Background()
 
@@ -1145,7 +1289,7 @@ func ensureTerraformDeploymentFieldsMatch(t *testing.T, expected, actual *models
 }
 
 func TestSqlDatastore_TerraformDeploymentDAO(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	testPk, instance := createTerraformDeploymentInstance()
 	testCtx := context.Background()
 
@@ -1163,7 +1307,8 @@ func TestSqlDatastore_TerraformDeploymentDAO(t *testing.T) {
 	}
 
 	// Should be able to create the item
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.CreateTerraformDeployment(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -1186,6 +1331,16 @@ func TestSqlDatastore_TerraformDeploymentDAO(t *testing.T) {
 	// Ensure non-gorm fields were deserialized correctly
 	ensureTerraformDeploymentFieldsMatch(t, &instance, ret)
 
+	// secrets must only be stored sealed
+	stored := models.TerraformDeployment{}
+	if err := ds.db.Where("id = ?", testPk).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsSealed(stored.Workspace) {
+		t.Errorf("Expected field Workspace to be stored sealed, got %#v", stored.Workspace)
+	}
+
 	// we should be able to update the item and it will have a new updated time
 	if err := ds.SaveTerraformDeployment(testCtx, ret); err != nil {
 		t.Errorf("Expected no error trying to get update %#v , got: %v", ret, err)
@@ -1223,7 +1378,7 @@ func TestSqlDatastore_TerraformDeploymentDAO(t *testing.T) {
 	}
 }
 func TestSqlDatastore_GetTerraformDeploymentById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createTerraformDeploymentInstance()
 	testCtx := context.Background()
 
@@ -1231,7 +1386,8 @@ func TestSqlDatastore_GetTerraformDeploymentById(t *testing.T) {
 		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
 	}
 
-	beforeCreation := time.Now()
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
 	if err := ds.CreateTerraformDeployment(testCtx, &instance); err != nil {
 		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
 	}
@@ -1256,7 +1412,7 @@ func TestSqlDatastore_GetTerraformDeploymentById(t *testing.T) {
 }
 
 func TestSqlDatastore_CheckDeletedTerraformDeploymentById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createTerraformDeploymentInstance()
 	testCtx := context.Background()
 
@@ -1291,7 +1447,7 @@ func TestSqlDatastore_CheckDeletedTerraformDeploymentById(t *testing.T) {
 }
 
 func TestSqlDatastore_CountTerraformDeploymentById(t *testing.T) {
-	ds := newInMemoryDatastore(t)
+	ds := newTestDatastore(t)
 	_, instance := createTerraformDeploymentInstance()
 	testCtx := context.Background()
 
@@ -1310,3 +1466,627 @@ func TestSqlDatastore_CountTerraformDeploymentById(t *testing.T) {
 	}
 }
 
+
+func createTerraformDeploymentLogInstance() (uint, models.TerraformDeploymentLog) {
+	testPk := uint(42)
+
+	instance := models.TerraformDeploymentLog{}
+	instance.ID = testPk
+	instance.OperationId = "1234"
+	instance.OperationType = "provision"
+	instance.Output = "Apply complete!"
+	instance.TerraformDeploymentId = "tf:instance:"
+
+
+	return testPk, instance
+}
+
+func ensureTerraformDeploymentLogFieldsMatch(t *testing.T, expected, actual *models.TerraformDeploymentLog) {
+
+	if expected.OperationId != actual.OperationId {
+		t.Errorf("Expected field OperationId to be %#v, got %#v", expected.OperationId, actual.OperationId)
+	}
+
+	if expected.OperationType != actual.OperationType {
+		t.Errorf("Expected field OperationType to be %#v, got %#v", expected.OperationType, actual.OperationType)
+	}
+
+	if expected.Output != actual.Output {
+		t.Errorf("Expected field Output to be %#v, got %#v", expected.Output, actual.Output)
+	}
+
+	if expected.TerraformDeploymentId != actual.TerraformDeploymentId {
+		t.Errorf("Expected field TerraformDeploymentId to be %#v, got %#v", expected.TerraformDeploymentId, actual.TerraformDeploymentId)
+	}
+
+}
+
+func TestSqlDatastore_TerraformDeploymentLogDAO(t *testing.T) {
+	ds := newTestDatastore(t)
+	testPk, instance := createTerraformDeploymentLogInstance()
+	testCtx := context.Background()
+
+	// on startup, there should be no objects to find or delete
+	if count, err := ds.CountTerraformDeploymentLogById(testCtx, testPk); count != 0 || err != nil {
+		t.Fatalf("Expected count to be 0 and error to be nil got count: %d, err: %v", count, err)
+	}
+
+	if _, err := ds.GetTerraformDeploymentLogById(testCtx, testPk); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing PK got %v", err)
+	}
+
+	if _, err := ds.CheckDeletedTerraformDeploymentLogById(testCtx, testPk); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an ErrRecordNotFound trying to check deletion status of a non-existing PK got %v", err)
+	}
+
+	// Should be able to create the item
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
+	if err := ds.CreateTerraformDeploymentLog(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+	afterCreation := time.Now()
+
+	// after creation we should be able to get the item
+	ret, err := ds.GetTerraformDeploymentLogById(testCtx, testPk)
+	if err != nil {
+		t.Errorf("Expected no error trying to get saved item, got: %v", err)
+	}
+
+	if ret.CreatedAt.Before(beforeCreation) || ret.CreatedAt.After(afterCreation) {
+		t.Errorf("Expected creation time to be between  %v and %v got %v", beforeCreation, afterCreation, ret.CreatedAt)
+	}
+
+	if !ret.UpdatedAt.Equal(ret.CreatedAt) {
+		t.Errorf("Expected initial update time to equal creation time, but got update: %v, create: %v", ret.UpdatedAt, ret.CreatedAt)
+	}
+
+	// Ensure non-gorm fields were deserialized correctly
+	ensureTerraformDeploymentLogFieldsMatch(t, &instance, ret)
+
+	// we should be able to update the item and it will have a new updated time
+	if err := ds.SaveTerraformDeploymentLog(testCtx, ret); err != nil {
+		t.Errorf("Expected no error trying to get update %#v , got: %v", ret, err)
+	}
+
+	if !ret.UpdatedAt.After(ret.CreatedAt) {
+		t.Errorf("Expected update time to be after create time after update, got update: %#v create: %#v", ret.UpdatedAt, ret.CreatedAt)
+	}
+
+	// after deleting the item we should not be able to get it
+	deleted, err := ds.CheckDeletedTerraformDeploymentLogById(testCtx, testPk)
+	if err != nil {
+		t.Errorf("Expected no error when checking if a non-deleted thing was deleted")
+	}
+	if deleted {
+		t.Errorf("Expected a non-deleted instance to not be marked as deleted but it was.")
+	}
+
+	if err := ds.DeleteTerraformDeploymentLogById(testCtx, testPk); err != nil {
+		t.Errorf("Expected no error when deleting by pk got: %v", err)
+	}
+
+	// we should be able to see that it was soft-deleted
+	deleted, err = ds.CheckDeletedTerraformDeploymentLogById(testCtx, testPk)
+	if err != nil {
+		t.Errorf("Expected no error when checking if a non-deleted thing was deleted")
+	}
+	if !deleted {
+		t.Errorf("Expected a deleted instance to marked as deleted but it was not.")
+	}
+
+	// after deleting the item we should not be able to get it
+	if _, err := ds.GetTerraformDeploymentLogById(testCtx, testPk); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected ErrRecordNotFound after delete but got %v", err)
+	}
+}
+func TestSqlDatastore_GetTerraformDeploymentLogById(t *testing.T) {
+	ds := newTestDatastore(t)
+	_, instance := createTerraformDeploymentLogInstance()
+	testCtx := context.Background()
+
+	if _, err := ds.GetTerraformDeploymentLogById(testCtx, instance.ID); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
+	}
+
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
+	if err := ds.CreateTerraformDeploymentLog(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+	afterCreation := time.Now()
+
+	// after creation we should be able to get the item
+	ret, err := ds.GetTerraformDeploymentLogById(testCtx, instance.ID)
+	if err != nil {
+		t.Errorf("Expected no error trying to get saved item, got: %v", err)
+	}
+
+	if ret.CreatedAt.Before(beforeCreation) || ret.CreatedAt.After(afterCreation) {
+		t.Errorf("Expected creation time to be between  %v and %v got %v", beforeCreation, afterCreation, ret.CreatedAt)
+	}
+
+	if !ret.UpdatedAt.Equal(ret.CreatedAt) {
+		t.Errorf("Expected initial update time to equal creation time, but got update: %v, create: %v", ret.UpdatedAt, ret.CreatedAt)
+	}
+
+	// Ensure non-gorm fields were deserialized correctly
+	ensureTerraformDeploymentLogFieldsMatch(t, &instance, ret)
+}
+
+func TestSqlDatastore_CheckDeletedTerraformDeploymentLogById(t *testing.T) {
+	ds := newTestDatastore(t)
+	_, instance := createTerraformDeploymentLogInstance()
+	testCtx := context.Background()
+
+	if _, err := ds.CheckDeletedTerraformDeploymentLogById(testCtx, instance.ID); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
+	}
+
+	if err := ds.CreateTerraformDeploymentLog(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+
+	deleted, err := ds.CheckDeletedTerraformDeploymentLogById(testCtx, instance.ID)
+	if err != nil {
+		t.Errorf("Expected no error when checking if a non-deleted thing was deleted")
+	}
+	if deleted {
+		t.Errorf("Expected a non-deleted instance to not be marked as deleted but it was.")
+	}
+
+	if err := ds.DeleteTerraformDeploymentLog(testCtx, &instance); err != nil {
+		t.Errorf("Expected no error when deleting by pk got: %v", err)
+	}
+
+	// we should be able to see that it was soft-deleted
+	deleted, err = ds.CheckDeletedTerraformDeploymentLogById(testCtx, instance.ID)
+	if err != nil {
+		t.Errorf("Expected no error when checking if a non-deleted thing was deleted")
+	}
+	if !deleted {
+		t.Errorf("Expected a deleted instance to marked as deleted but it was not.")
+	}
+}
+
+func TestSqlDatastore_CountTerraformDeploymentLogById(t *testing.T) {
+	ds := newTestDatastore(t)
+	_, instance := createTerraformDeploymentLogInstance()
+	testCtx := context.Background()
+
+	// on startup, there should be no objects to find or delete
+	if count, err := ds.CountTerraformDeploymentLogById(testCtx, instance.ID); count != 0 || err != nil {
+		t.Fatalf("Expected count to be 0 and error to be nil got count: %d, err: %v", count, err)
+	}
+
+	if err := ds.CreateTerraformDeploymentLog(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+
+	// on startup, there should be no objects to find or delete
+	if count, err := ds.CountTerraformDeploymentLogById(testCtx, instance.ID); count != 1 || err != nil {
+		t.Fatalf("Expected count to be 1 and error to be nil got count: %d, err: %v", count, err)
+	}
+}
+
+
+func createOperationHistoryInstance() (uint, models.OperationHistory) {
+	testPk := uint(42)
+
+	instance := models.OperationHistory{}
+	instance.ID = testPk
+	instance.DurationMillis = 1234
+	instance.OperationType = "provision"
+	instance.Request = "provision"
+	instance.Result = "succeeded"
+	instance.ServiceInstanceId = "instance-1"
+
+
+	return testPk, instance
+}
+
+func ensureOperationHistoryFieldsMatch(t *testing.T, expected, actual *models.OperationHistory) {
+
+	if expected.DurationMillis != actual.DurationMillis {
+		t.Errorf("Expected field DurationMillis to be %#v, got %#v", expected.DurationMillis, actual.DurationMillis)
+	}
+
+	if expected.OperationType != actual.OperationType {
+		t.Errorf("Expected field OperationType to be %#v, got %#v", expected.OperationType, actual.OperationType)
+	}
+
+	if expected.Request != actual.Request {
+		t.Errorf("Expected field Request to be %#v, got %#v", expected.Request, actual.Request)
+	}
+
+	if expected.Result != actual.Result {
+		t.Errorf("Expected field Result to be %#v, got %#v", expected.Result, actual.Result)
+	}
+
+	if expected.ServiceInstanceId != actual.ServiceInstanceId {
+		t.Errorf("Expected field ServiceInstanceId to be %#v, got %#v", expected.ServiceInstanceId, actual.ServiceInstanceId)
+	}
+
+}
+
+func TestSqlDatastore_OperationHistoryDAO(t *testing.T) {
+	ds := newTestDatastore(t)
+	testPk, instance := createOperationHistoryInstance()
+	testCtx := context.Background()
+
+	// on startup, there should be no objects to find or delete
+	if count, err := ds.CountOperationHistoryById(testCtx, testPk); count != 0 || err != nil {
+		t.Fatalf("Expected count to be 0 and error to be nil got count: %d, err: %v", count, err)
+	}
+
+	if _, err := ds.GetOperationHistoryById(testCtx, testPk); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing PK got %v", err)
+	}
+
+	if _, err := ds.CheckDeletedOperationHistoryById(testCtx, testPk); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an ErrRecordNotFound trying to check deletion status of a non-existing PK got %v", err)
+	}
+
+	// Should be able to create the item
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
+	if err := ds.CreateOperationHistory(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+	afterCreation := time.Now()
+
+	// after creation we should be able to get the item
+	ret, err := ds.GetOperationHistoryById(testCtx, testPk)
+	if err != nil {
+		t.Errorf("Expected no error trying to get saved item, got: %v", err)
+	}
+
+	if ret.CreatedAt.Before(beforeCreation) || ret.CreatedAt.After(afterCreation) {
+		t.Errorf("Expected creation time to be between  %v and %v got %v", beforeCreation, afterCreation, ret.CreatedAt)
+	}
+
+	if !ret.UpdatedAt.Equal(ret.CreatedAt) {
+		t.Errorf("Expected initial update time to equal creation time, but got update: %v, create: %v", ret.UpdatedAt, ret.CreatedAt)
+	}
+
+	// Ensure non-gorm fields were deserialized correctly
+	ensureOperationHistoryFieldsMatch(t, &instance, ret)
+
+	// we should be able to update the item and it will have a new updated time
+	if err := ds.SaveOperationHistory(testCtx, ret); err != nil {
+		t.Errorf("Expected no error trying to get update %#v , got: %v", ret, err)
+	}
+
+	if !ret.UpdatedAt.After(ret.CreatedAt) {
+		t.Errorf("Expected update time to be after create time after update, got update: %#v create: %#v", ret.UpdatedAt, ret.CreatedAt)
+	}
+
+	// after deleting the item we should not be able to get it
+	deleted, err := ds.CheckDeletedOperationHistoryById(testCtx, testPk)
+	if err != nil {
+		t.Errorf("Expected no error when checking if a non-deleted thing was deleted")
+	}
+	if deleted {
+		t.Errorf("Expected a non-deleted instance to not be marked as deleted but it was.")
+	}
+
+	if err := ds.DeleteOperationHistoryById(testCtx, testPk); err != nil {
+		t.Errorf("Expected no error when deleting by pk got: %v", err)
+	}
+
+	// we should be able to see that it was soft-deleted
+	deleted, err = ds.CheckDeletedOperationHistoryById(testCtx, testPk)
+	if err != nil {
+		t.Errorf("Expected no error when checking if a non-deleted thing was deleted")
+	}
+	if !deleted {
+		t.Errorf("Expected a deleted instance to marked as deleted but it was not.")
+	}
+
+	// after deleting the item we should not be able to get it
+	if _, err := ds.GetOperationHistoryById(testCtx, testPk); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected ErrRecordNotFound after delete but got %v", err)
+	}
+}
+func TestSqlDatastore_GetOperationHistoryById(t *testing.T) {
+	ds := newTestDatastore(t)
+	_, instance := createOperationHistoryInstance()
+	testCtx := context.Background()
+
+	if _, err := ds.GetOperationHistoryById(testCtx, instance.ID); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
+	}
+
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
+	if err := ds.CreateOperationHistory(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+	afterCreation := time.Now()
+
+	// after creation we should be able to get the item
+	ret, err := ds.GetOperationHistoryById(testCtx, instance.ID)
+	if err != nil {
+		t.Errorf("Expected no error trying to get saved item, got: %v", err)
+	}
+
+	if ret.CreatedAt.Before(beforeCreation) || ret.CreatedAt.After(afterCreation) {
+		t.Errorf("Expected creation time to be between  %v and %v got %v", beforeCreation, afterCreation, ret.CreatedAt)
+	}
+
+	if !ret.UpdatedAt.Equal(ret.CreatedAt) {
+		t.Errorf("Expected initial update time to equal creation time, but got update: %v, create: %v", ret.UpdatedAt, ret.CreatedAt)
+	}
+
+	// Ensure non-gorm fields were deserialized correctly
+	ensureOperationHistoryFieldsMatch(t, &instance, ret)
+}
+
+func TestSqlDatastore_CheckDeletedOperationHistoryById(t *testing.T) {
+	ds := newTestDatastore(t)
+	_, instance := createOperationHistoryInstance()
+	testCtx := context.Background()
+
+	if _, err := ds.CheckDeletedOperationHistoryById(testCtx, instance.ID); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
+	}
+
+	if err := ds.CreateOperationHistory(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+
+	deleted, err := ds.CheckDeletedOperationHistoryById(testCtx, instance.ID)
+	if err != nil {
+		t.Errorf("Expected no error when checking if a non-deleted thing was deleted")
+	}
+	if deleted {
+		t.Errorf("Expected a non-deleted instance to not be marked as deleted but it was.")
+	}
+
+	if err := ds.DeleteOperationHistory(testCtx, &instance); err != nil {
+		t.Errorf("Expected no error when deleting by pk got: %v", err)
+	}
+
+	// we should be able to see that it was soft-deleted
+	deleted, err = ds.CheckDeletedOperationHistoryById(testCtx, instance.ID)
+	if err != nil {
+		t.Errorf("Expected no error when checking if a non-deleted thing was deleted")
+	}
+	if !deleted {
+		t.Errorf("Expected a deleted instance to marked as deleted but it was not.")
+	}
+}
+
+func TestSqlDatastore_CountOperationHistoryById(t *testing.T) {
+	ds := newTestDatastore(t)
+	_, instance := createOperationHistoryInstance()
+	testCtx := context.Background()
+
+	// on startup, there should be no objects to find or delete
+	if count, err := ds.CountOperationHistoryById(testCtx, instance.ID); count != 0 || err != nil {
+		t.Fatalf("Expected count to be 0 and error to be nil got count: %d, err: %v", count, err)
+	}
+
+	if err := ds.CreateOperationHistory(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+
+	// on startup, there should be no objects to find or delete
+	if count, err := ds.CountOperationHistoryById(testCtx, instance.ID); count != 1 || err != nil {
+		t.Fatalf("Expected count to be 1 and error to be nil got count: %d, err: %v", count, err)
+	}
+}
+
+
+func createRetiredCredentialsInstance() (uint, models.RetiredCredentials) {
+	testPk := uint(42)
+
+	instance := models.RetiredCredentials{}
+	instance.ID = testPk
+	instance.BindingId = "0000-0000-0000"
+	instance.Details = "{\"some\":[\"json\",\"blob\",\"here\"]}"
+	instance.ServiceId = "1111-1111-1111"
+	instance.ServiceInstanceId = "2222-2222-2222"
+
+
+	return testPk, instance
+}
+
+func ensureRetiredCredentialsFieldsMatch(t *testing.T, expected, actual *models.RetiredCredentials) {
+
+	if expected.BindingId != actual.BindingId {
+		t.Errorf("Expected field BindingId to be %#v, got %#v", expected.BindingId, actual.BindingId)
+	}
+
+	if expected.Details != actual.Details {
+		t.Errorf("Expected field Details to be %#v, got %#v", expected.Details, actual.Details)
+	}
+
+	if expected.ServiceId != actual.ServiceId {
+		t.Errorf("Expected field ServiceId to be %#v, got %#v", expected.ServiceId, actual.ServiceId)
+	}
+
+	if expected.ServiceInstanceId != actual.ServiceInstanceId {
+		t.Errorf("Expected field ServiceInstanceId to be %#v, got %#v", expected.ServiceInstanceId, actual.ServiceInstanceId)
+	}
+
+}
+
+func TestSqlDatastore_RetiredCredentialsDAO(t *testing.T) {
+	ds := newTestDatastore(t)
+	testPk, instance := createRetiredCredentialsInstance()
+	testCtx := context.Background()
+
+	// on startup, there should be no objects to find or delete
+	if count, err := ds.CountRetiredCredentialsById(testCtx, testPk); count != 0 || err != nil {
+		t.Fatalf("Expected count to be 0 and error to be nil got count: %d, err: %v", count, err)
+	}
+
+	if _, err := ds.GetRetiredCredentialsById(testCtx, testPk); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing PK got %v", err)
+	}
+
+	if _, err := ds.CheckDeletedRetiredCredentialsById(testCtx, testPk); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an ErrRecordNotFound trying to check deletion status of a non-existing PK got %v", err)
+	}
+
+	// Should be able to create the item
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
+	if err := ds.CreateRetiredCredentials(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+	afterCreation := time.Now()
+
+	// after creation we should be able to get the item
+	ret, err := ds.GetRetiredCredentialsById(testCtx, testPk)
+	if err != nil {
+		t.Errorf("Expected no error trying to get saved item, got: %v", err)
+	}
+
+	if ret.CreatedAt.Before(beforeCreation) || ret.CreatedAt.After(afterCreation) {
+		t.Errorf("Expected creation time to be between  %v and %v got %v", beforeCreation, afterCreation, ret.CreatedAt)
+	}
+
+	if !ret.UpdatedAt.Equal(ret.CreatedAt) {
+		t.Errorf("Expected initial update time to equal creation time, but got update: %v, create: %v", ret.UpdatedAt, ret.CreatedAt)
+	}
+
+	// Ensure non-gorm fields were deserialized correctly
+	ensureRetiredCredentialsFieldsMatch(t, &instance, ret)
+
+	// secrets must only be stored sealed
+	stored := models.RetiredCredentials{}
+	if err := ds.db.Where("id = ?", testPk).First(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsSealed(stored.Details) {
+		t.Errorf("Expected field Details to be stored sealed, got %#v", stored.Details)
+	}
+
+	// we should be able to update the item and it will have a new updated time
+	if err := ds.SaveRetiredCredentials(testCtx, ret); err != nil {
+		t.Errorf("Expected no error trying to get update %#v , got: %v", ret, err)
+	}
+
+	if !ret.UpdatedAt.After(ret.CreatedAt) {
+		t.Errorf("Expected update time to be after create time after update, got update: %#v create: %#v", ret.UpdatedAt, ret.CreatedAt)
+	}
+
+	// after deleting the item we should not be able to get it
+	deleted, err := ds.CheckDeletedRetiredCredentialsById(testCtx, testPk)
+	if err != nil {
+		t.Errorf("Expected no error when checking if a non-deleted thing was deleted")
+	}
+	if deleted {
+		t.Errorf("Expected a non-deleted instance to not be marked as deleted but it was.")
+	}
+
+	if err := ds.DeleteRetiredCredentialsById(testCtx, testPk); err != nil {
+		t.Errorf("Expected no error when deleting by pk got: %v", err)
+	}
+
+	// we should be able to see that it was soft-deleted
+	deleted, err = ds.CheckDeletedRetiredCredentialsById(testCtx, testPk)
+	if err != nil {
+		t.Errorf("Expected no error when checking if a non-deleted thing was deleted")
+	}
+	if !deleted {
+		t.Errorf("Expected a deleted instance to marked as deleted but it was not.")
+	}
+
+	// after deleting the item we should not be able to get it
+	if _, err := ds.GetRetiredCredentialsById(testCtx, testPk); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected ErrRecordNotFound after delete but got %v", err)
+	}
+}
+func TestSqlDatastore_GetRetiredCredentialsById(t *testing.T) {
+	ds := newTestDatastore(t)
+	_, instance := createRetiredCredentialsInstance()
+	testCtx := context.Background()
+
+	if _, err := ds.GetRetiredCredentialsById(testCtx, instance.ID); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
+	}
+
+	// some databases only store timestamps to the second
+	beforeCreation := time.Now().Truncate(time.Second)
+	if err := ds.CreateRetiredCredentials(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+	afterCreation := time.Now()
+
+	// after creation we should be able to get the item
+	ret, err := ds.GetRetiredCredentialsById(testCtx, instance.ID)
+	if err != nil {
+		t.Errorf("Expected no error trying to get saved item, got: %v", err)
+	}
+
+	if ret.CreatedAt.Before(beforeCreation) || ret.CreatedAt.After(afterCreation) {
+		t.Errorf("Expected creation time to be between  %v and %v got %v", beforeCreation, afterCreation, ret.CreatedAt)
+	}
+
+	if !ret.UpdatedAt.Equal(ret.CreatedAt) {
+		t.Errorf("Expected initial update time to equal creation time, but got update: %v, create: %v", ret.UpdatedAt, ret.CreatedAt)
+	}
+
+	// Ensure non-gorm fields were deserialized correctly
+	ensureRetiredCredentialsFieldsMatch(t, &instance, ret)
+}
+
+func TestSqlDatastore_CheckDeletedRetiredCredentialsById(t *testing.T) {
+	ds := newTestDatastore(t)
+	_, instance := createRetiredCredentialsInstance()
+	testCtx := context.Background()
+
+	if _, err := ds.CheckDeletedRetiredCredentialsById(testCtx, instance.ID); err != gorm.ErrRecordNotFound {
+		t.Errorf("Expected an ErrRecordNotFound trying to get non-existing record got %v", err)
+	}
+
+	if err := ds.CreateRetiredCredentials(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+
+	deleted, err := ds.CheckDeletedRetiredCredentialsById(testCtx, instance.ID)
+	if err != nil {
+		t.Errorf("Expected no error when checking if a non-deleted thing was deleted")
+	}
+	if deleted {
+		t.Errorf("Expected a non-deleted instance to not be marked as deleted but it was.")
+	}
+
+	if err := ds.DeleteRetiredCredentials(testCtx, &instance); err != nil {
+		t.Errorf("Expected no error when deleting by pk got: %v", err)
+	}
+
+	// we should be able to see that it was soft-deleted
+	deleted, err = ds.CheckDeletedRetiredCredentialsById(testCtx, instance.ID)
+	if err != nil {
+		t.Errorf("Expected no error when checking if a non-deleted thing was deleted")
+	}
+	if !deleted {
+		t.Errorf("Expected a deleted instance to marked as deleted but it was not.")
+	}
+}
+
+func TestSqlDatastore_CountRetiredCredentialsById(t *testing.T) {
+	ds := newTestDatastore(t)
+	_, instance := createRetiredCredentialsInstance()
+	testCtx := context.Background()
+
+	// on startup, there should be no objects to find or delete
+	if count, err := ds.CountRetiredCredentialsById(testCtx, instance.ID); count != 0 || err != nil {
+		t.Fatalf("Expected count to be 0 and error to be nil got count: %d, err: %v", count, err)
+	}
+
+	if err := ds.CreateRetiredCredentials(testCtx, &instance); err != nil {
+		t.Errorf("Expected to be able to create the item %#v, got error: %s", instance, err)
+	}
+
+	// on startup, there should be no objects to find or delete
+	if count, err := ds.CountRetiredCredentialsById(testCtx, instance.ID); count != 1 || err != nil {
+		t.Fatalf("Expected count to be 1 and error to be nil got count: %d, err: %v", count, err)
+	}
+}
+
@@ -17,6 +17,7 @@
 package db_service
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 
@@ -26,12 +27,23 @@ import (
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
 )
 
+// ErrConcurrentModification is returned when saving or deleting a copy of a
+// versioned record that was saved by someone else since it was read.
+var ErrConcurrentModification = errors.New("the record was modified by another operation")
+
 var DbConnection *gorm.DB
+var DbKeyring *Keyring
 var once sync.Once
 
-// Instantiates the db connection and runs migrations
+// Instantiates the db connection, loads the encryption keys and runs migrations
 func New(logger lager.Logger) *gorm.DB {
 	once.Do(func() {
+		keyring, err := NewKeyringFromConfig()
+		if err != nil {
+			panic(fmt.Sprintf("Error loading database encryption keys: %s", err.Error()))
+		}
+		DbKeyring = keyring
+
 		DbConnection = SetupDb(logger)
 		if err := RunMigrations(DbConnection); err != nil {
 			panic(fmt.Sprintf("Error migrating database: %s", err.Error()))
@@ -44,9 +56,37 @@ func New(logger lager.Logger) *gorm.DB {
 // instantiated in New(). In the future, all accesses of DbConnection will be
 // done through SqlDatastore and it will become the globally shared instance.
 func defaultDatastore() *SqlDatastore {
-	return &SqlDatastore{db: DbConnection}
+	return &SqlDatastore{db: DbConnection, keyring: DbKeyring}
 }
 
+// SqlDatastore reads and writes records in the database. Columns holding
+// secrets are sealed with the keyring, a nil keyring stores them in plaintext.
 type SqlDatastore struct {
-	db *gorm.DB
+	db      *gorm.DB
+	keyring *Keyring
+}
+
+// updateIfVersion updates every column of the record like Save does, but only
+// if the stored record is still at the given version.
+func (ds *SqlDatastore) updateIfVersion(record interface{}, version int) error {
+	return ds.updateIf(record, "version = ?", version)
+}
+
+// updateIf updates every column of the record like Save does, but only if the
+// stored record matches the condition. It returns ErrConcurrentModification
+// if it doesn't, or if the record was deleted.
+func (ds *SqlDatastore) updateIf(record interface{}, query string, args ...interface{}) error {
+	columns := make(map[string]interface{})
+	for _, field := range ds.db.NewScope(record).Fields() {
+		if field.IsNormal && !field.IsPrimaryKey && !field.IsIgnored {
+			columns[field.DBName] = field.Field.Interface()
+		}
+	}
+
+	result := ds.db.Model(record).Where(query, args...).Updates(columns)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrConcurrentModification
+	}
+
+	return result.Error
 }
@@ -0,0 +1,310 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db_service
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/spf13/viper"
+)
+
+const (
+	encryptionKeysProp         = "db.encryption.keys"
+	encryptionPrimaryKeyIdProp = "db.encryption.primary_key_id"
+
+	// sealedPrefix marks values sealed by a Keyring. Sealed values have the
+	// form sealed:<key-id>:<base64 of the nonce followed by the ciphertext>.
+	sealedPrefix = "sealed:"
+
+	// maxResealAttempts is how many times a record that's being written to by
+	// the broker is re-read and re-sealed before giving up.
+	maxResealAttempts = 5
+)
+
+func init() {
+	viper.BindEnv(encryptionKeysProp, "DB_ENCRYPTION_KEYS")
+	viper.BindEnv(encryptionPrimaryKeyIdProp, "DB_ENCRYPTION_PRIMARY_KEY_ID")
+}
+
+// Keyring seals and opens database columns holding secrets with AES-GCM.
+// Values are sealed with the primary key and opened with the key named in
+// their prefix, so keys can be rotated by adding a new primary key and keeping
+// the old ones until every record is re-sealed.
+//
+// A nil Keyring stores values in plaintext. Values stored in plaintext can
+// always be opened so existing records keep working once keys are configured.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring creates a Keyring from AES keys by their ID. The keys must be
+// 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+func NewKeyring(keys map[string][]byte, primaryKeyId string) (*Keyring, error) {
+	if _, ok := keys[primaryKeyId]; !ok {
+		return nil, fmt.Errorf("the primary encryption key %q isn't one of the keys", primaryKeyId)
+	}
+
+	keyring := &Keyring{primary: primaryKeyId, keys: make(map[string]cipher.AEAD)}
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid encryption key ID %q, IDs must be non-empty and can't contain ':'", id)
+		}
+
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encryption key %q: %v", id, err)
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+
+		keyring.keys[id] = aead
+	}
+
+	return keyring, nil
+}
+
+// NewKeyringFromConfig creates a Keyring from the keys operators configured
+// in db.encryption.keys as a comma separated list of <key-id>:<base64 key>
+// pairs. The key named by db.encryption.primary_key_id seals new values,
+// it defaults to the first key in the list.
+// It returns nil if no keys are configured.
+func NewKeyringFromConfig() (*Keyring, error) {
+	configured := strings.TrimSpace(viper.GetString(encryptionKeysProp))
+	if configured == "" {
+		return nil, nil
+	}
+
+	keys := make(map[string][]byte)
+	primary := viper.GetString(encryptionPrimaryKeyIdProp)
+	for _, pair := range strings.Split(configured, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s must be a comma separated list of <key-id>:<base64 key>", encryptionKeysProp)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("couldn't decode encryption key %q: %v", parts[0], err)
+		}
+
+		keys[parts[0]] = key
+		if primary == "" {
+			primary = parts[0]
+		}
+	}
+
+	return NewKeyring(keys, primary)
+}
+
+// Seal encrypts the value with the primary key. Empty values are left empty.
+func (keyring *Keyring) Seal(plaintext string) (string, error) {
+	if keyring == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	aead := keyring.keys[keyring.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return sealedPrefix + keyring.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value sealed by Seal. Values that aren't sealed are
+// returned as-is.
+func (keyring *Keyring) Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, sealedPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", errors.New("malformed sealed value")
+	}
+
+	if keyring == nil {
+		return "", fmt.Errorf("the value is sealed with the key %q but no encryption keys are configured", parts[0])
+	}
+
+	aead, ok := keyring.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("the value is sealed with the unknown key %q", parts[0])
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed sealed value")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("couldn't open the value sealed with the key %q: %v", parts[0], err)
+	}
+
+	return string(plaintext), nil
+}
+
+// IsCurrent returns true if the value is stored the way Seal would store it
+// now: sealed with the primary key, or in plaintext if the keyring is nil.
+func (keyring *Keyring) IsCurrent(value string) bool {
+	if keyring == nil || value == "" {
+		return !IsSealed(value)
+	}
+
+	return strings.HasPrefix(value, sealedPrefix+keyring.primary+":")
+}
+
+// IsSealed returns true if the value was sealed by a Keyring.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// ResealSecrets seals the secrets of every binding and Terraform deployment,
+// including soft-deleted ones, with the primary key. If plaintextOnly is set,
+// only secrets that are still stored in plaintext are sealed, otherwise
+// secrets sealed with an old key are re-sealed too so the old key can be
+// removed.
+// It returns the number of records that were updated.
+func ResealSecrets(ctx context.Context, plaintextOnly bool) (int, error) {
+	return defaultDatastore().ResealSecrets(ctx, plaintextOnly)
+}
+func (ds *SqlDatastore) ResealSecrets(ctx context.Context, plaintextOnly bool) (int, error) {
+	bindings, err := ds.resealColumn(&models.ServiceBindingCredentials{}, "other_details", plaintextOnly)
+	if err != nil {
+		return bindings, fmt.Errorf("couldn't seal the binding credentials: %v", err)
+	}
+
+	deployments, err := ds.resealColumn(&models.TerraformDeployment{}, "workspace", plaintextOnly)
+	if err != nil {
+		return bindings + deployments, fmt.Errorf("couldn't seal the Terraform workspaces: %v", err)
+	}
+
+	retired, err := ds.resealColumn(&models.RetiredCredentials{}, "details", plaintextOnly)
+	if err != nil {
+		return bindings + deployments + retired, fmt.Errorf("couldn't seal the retired credentials: %v", err)
+	}
+
+	return bindings + deployments + retired, nil
+}
+
+// resealColumn seals the values of the column in the model's table that
+// aren't sealed with the primary key. Values are written with UpdateColumn so
+// the records' update times are left alone.
+func (ds *SqlDatastore) resealColumn(model interface{}, column string, plaintextOnly bool) (int, error) {
+	rows, err := ds.db.Unscoped().Model(model).Select("id, " + column).Rows()
+	if err != nil {
+		return 0, err
+	}
+
+	stale := make(map[string]string)
+	for rows.Next() {
+		var id string
+		var value sql.NullString
+		if err := rows.Scan(&id, &value); err != nil {
+			rows.Close()
+			return 0, err
+		}
+
+		if ds.needsReseal(value.String, plaintextOnly) {
+			stale[id] = value.String
+		}
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for id, value := range stale {
+		resealed, err := ds.resealRecord(model, column, id, value, plaintextOnly)
+		if err != nil {
+			return updated, fmt.Errorf("record %q: %v", id, err)
+		}
+
+		if resealed {
+			updated++
+		}
+	}
+
+	return updated, nil
+}
+
+// needsReseal returns true if the value isn't stored the way Seal would store
+// it now and should be re-sealed.
+func (ds *SqlDatastore) needsReseal(value string, plaintextOnly bool) bool {
+	return !ds.keyring.IsCurrent(value) && !(plaintextOnly && IsSealed(value))
+}
+
+// resealRecord replaces the value of the column of a record with the value
+// sealed with the primary key. The broker may write the record while it's
+// being re-sealed, so the update only applies if the column still holds value.
+// Otherwise the new value is read and re-sealed instead if it needs to be.
+// It returns true if the record was updated.
+func (ds *SqlDatastore) resealRecord(model interface{}, column, id, value string, plaintextOnly bool) (bool, error) {
+	for attempt := 0; attempt < maxResealAttempts; attempt++ {
+		plaintext, err := ds.keyring.Open(value)
+		if err != nil {
+			return false, err
+		}
+
+		sealed, err := ds.keyring.Seal(plaintext)
+		if err != nil {
+			return false, err
+		}
+
+		result := ds.db.Unscoped().Model(model).Where("id = ? AND "+column+" = ?", id, value).UpdateColumn(column, sealed)
+		if result.Error != nil {
+			return false, result.Error
+		}
+
+		if result.RowsAffected == 1 {
+			return true, nil
+		}
+
+		var current sql.NullString
+		err = ds.db.Unscoped().Model(model).Where("id = ?", id).Select(column).Row().Scan(&current)
+		if err == sql.ErrNoRows {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		if !ds.needsReseal(current.String, plaintextOnly) {
+			return false, nil
+		}
+
+		value = current.String
+	}
+
+	return false, fmt.Errorf("the record kept changing while it was re-sealed, try again")
+}
@@ -0,0 +1,344 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db_service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/spf13/viper"
+)
+
+var (
+	testKeyOld = bytes.Repeat([]byte{1}, 32)
+	testKeyNew = bytes.Repeat([]byte{2}, 32)
+)
+
+// newTestKeyring creates a keyring with the keys "old" and "new" that seals
+// with "new".
+func newTestKeyring(t *testing.T) *Keyring {
+	keyring, err := NewKeyring(map[string][]byte{"old": testKeyOld, "new": testKeyNew}, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keyring
+}
+
+func TestKeyring_SealOpen(t *testing.T) {
+	keyring := newTestKeyring(t)
+
+	sealed, err := keyring.Seal(`{"password":"hunter2"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(sealed, "sealed:new:") || strings.Contains(sealed, "hunter2") {
+		t.Errorf("Expected the value to be sealed with the key new, got %q", sealed)
+	}
+
+	if !keyring.IsCurrent(sealed) {
+		t.Errorf("Expected a freshly sealed value to be current")
+	}
+
+	opened, err := keyring.Open(sealed)
+	if err != nil || opened != `{"password":"hunter2"}` {
+		t.Errorf("Expected the value to open, got %q, %v", opened, err)
+	}
+
+	other, _ := keyring.Seal(`{"password":"hunter2"}`)
+	if other == sealed {
+		t.Errorf("Expected sealing to use a new nonce each time")
+	}
+}
+
+func TestKeyring_Open(t *testing.T) {
+	oldKeyring, err := NewKeyring(map[string][]byte{"old": testKeyOld}, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealedWithOld, err := oldKeyring.Seal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknownKeyring, err := NewKeyring(map[string][]byte{"unknown": testKeyOld}, "unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealedWithUnknown, err := unknownKeyring.Seal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]struct {
+		Keyring     *Keyring
+		Value       string
+		Expected    string
+		ErrContains string
+	}{
+		"plaintext":            {Keyring: newTestKeyring(t), Value: `{"a":"b"}`, Expected: `{"a":"b"}`},
+		"plaintext no keyring": {Keyring: nil, Value: `{"a":"b"}`, Expected: `{"a":"b"}`},
+		"empty":                {Keyring: newTestKeyring(t), Value: "", Expected: ""},
+		"rotated key":          {Keyring: newTestKeyring(t), Value: sealedWithOld, Expected: "secret"},
+		"unknown key":          {Keyring: newTestKeyring(t), Value: sealedWithUnknown, ErrContains: `unknown key "unknown"`},
+		"no keyring":           {Keyring: nil, Value: sealedWithOld, ErrContains: "no encryption keys are configured"},
+		"malformed":            {Keyring: newTestKeyring(t), Value: "sealed:new", ErrContains: "malformed"},
+		"bad encoding":         {Keyring: newTestKeyring(t), Value: "sealed:new:!!!", ErrContains: "malformed"},
+		"tampered":             {Keyring: newTestKeyring(t), Value: "sealed:old:" + strings.Repeat("A", 40), ErrContains: `couldn't open the value sealed with the key "old"`},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			actual, err := tc.Keyring.Open(tc.Value)
+			if tc.ErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ErrContains) {
+					t.Fatalf("Expected error containing %q, got %v", tc.ErrContains, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual != tc.Expected {
+				t.Errorf("Expected %q, got %q", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestNewKeyringFromConfig(t *testing.T) {
+	cases := map[string]struct {
+		Keys        string
+		PrimaryKey  string
+		Primary     string
+		ErrContains string
+	}{
+		"not configured":   {Keys: "", Primary: ""},
+		"default primary":  {Keys: "a:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=, b:AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=", Primary: "a"},
+		"explicit primary": {Keys: "a:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=,b:AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=", PrimaryKey: "b", Primary: "b"},
+		"missing primary":  {Keys: "a:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=", PrimaryKey: "b", ErrContains: `primary encryption key "b"`},
+		"missing id":       {Keys: "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=", ErrContains: "<key-id>:<base64 key>"},
+		"bad encoding":     {Keys: "a:not base64", ErrContains: `couldn't decode encryption key "a"`},
+		"bad length":       {Keys: "a:AQEB", ErrContains: `invalid encryption key "a"`},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			viper.Set(encryptionKeysProp, tc.Keys)
+			defer viper.Set(encryptionKeysProp, nil)
+			viper.Set(encryptionPrimaryKeyIdProp, tc.PrimaryKey)
+			defer viper.Set(encryptionPrimaryKeyIdProp, nil)
+
+			keyring, err := NewKeyringFromConfig()
+			if tc.ErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ErrContains) {
+					t.Fatalf("Expected error containing %q, got %v", tc.ErrContains, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			switch {
+			case tc.Primary == "" && keyring != nil:
+				t.Errorf("Expected no keyring, got %v", keyring)
+			case tc.Primary != "" && (keyring == nil || keyring.primary != tc.Primary):
+				t.Errorf("Expected the primary key to be %q, got %v", tc.Primary, keyring)
+			}
+		})
+	}
+}
+
+func TestSqlDatastore_ResealSecrets(t *testing.T) {
+	ds := newTestDatastore(t)
+	testCtx := context.Background()
+
+	// records written before encryption was configured, or with the old key
+	oldKeyring, err := NewKeyring(map[string][]byte{"old": testKeyOld}, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds.keyring = nil
+	plaintext := models.ServiceBindingCredentials{BindingId: "plaintext", OtherDetails: `{"key":"plaintext"}`}
+	if err := ds.CreateServiceBindingCredentials(testCtx, &plaintext); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted := models.ServiceBindingCredentials{BindingId: "deleted", OtherDetails: `{"key":"deleted"}`}
+	if err := ds.CreateServiceBindingCredentials(testCtx, &deleted); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.DeleteServiceBindingCredentials(testCtx, &deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	ds.keyring = oldKeyring
+	deployment := models.TerraformDeployment{ID: "tf:instance:", Workspace: `{"state":"old"}`}
+	if err := ds.CreateTerraformDeployment(testCtx, &deployment); err != nil {
+		t.Fatal(err)
+	}
+
+	ds.keyring = newTestKeyring(t)
+	current := models.ServiceBindingCredentials{BindingId: "current", OtherDetails: `{"key":"current"}`}
+	if err := ds.CreateServiceBindingCredentials(testCtx, &current); err != nil {
+		t.Fatal(err)
+	}
+
+	// encrypting only touches the plaintext records
+	encrypted, err := ds.ResealSecrets(testCtx, true)
+	if err != nil || encrypted != 2 {
+		t.Fatalf("Expected 2 records to be encrypted, got %d, %v", encrypted, err)
+	}
+
+	// rotating re-seals the records sealed with the old key
+	rotated, err := ds.ResealSecrets(testCtx, false)
+	if err != nil || rotated != 1 {
+		t.Fatalf("Expected 1 record to be rotated, got %d, %v", rotated, err)
+	}
+
+	var bindings []models.ServiceBindingCredentials
+	if err := ds.db.Unscoped().Find(&bindings).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, binding := range bindings {
+		if !ds.keyring.IsCurrent(binding.OtherDetails) {
+			t.Errorf("Expected binding %q to be sealed with the new key, got %q", binding.BindingId, binding.OtherDetails)
+		}
+	}
+
+	// the records can be read without the old key
+	ds.keyring, err = NewKeyring(map[string][]byte{"new": testKeyNew}, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := ds.GetTerraformDeploymentById(testCtx, deployment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.Workspace != `{"state":"old"}` {
+		t.Errorf("Expected the workspace to open with the new key, got %q", stored.Workspace)
+	}
+
+	binding, err := ds.GetServiceBindingCredentialsByBindingId(testCtx, "plaintext")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if binding.OtherDetails != `{"key":"plaintext"}` {
+		t.Errorf("Expected the binding to open with the new key, got %q", binding.OtherDetails)
+	}
+}
+
+func TestSqlDatastore_ResealRecord(t *testing.T) {
+	cases := map[string]struct {
+		Stored        string
+		Read          string
+		PlaintextOnly bool
+		Resealed      bool
+		Expected      string
+	}{
+		"unchanged": {
+			Stored:   `{"key":"value"}`,
+			Read:     `{"key":"value"}`,
+			Resealed: true,
+			Expected: `{"key":"value"}`,
+		},
+		"changed while resealing": {
+			Stored:   `{"key":"new"}`,
+			Read:     `{"key":"old"}`,
+			Resealed: true,
+			Expected: `{"key":"new"}`,
+		},
+		"sealed while resealing": {
+			Stored:        "sealed",
+			Read:          `{"key":"old"}`,
+			PlaintextOnly: true,
+			Resealed:      false,
+			Expected:      `{"key":"new"}`,
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			ds := newTestDatastore(t)
+			ds.keyring = newTestKeyring(t)
+			testCtx := context.Background()
+
+			stored := tc.Stored
+			if stored == "sealed" {
+				// the broker wrote a new value sealed with the old key
+				oldKeyring, err := NewKeyring(map[string][]byte{"old": testKeyOld}, "old")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if stored, err = oldKeyring.Seal(tc.Expected); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			binding := models.ServiceBindingCredentials{BindingId: "binding"}
+			if err := ds.db.Create(&binding).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if err := ds.db.Model(&binding).UpdateColumn("other_details", stored).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			resealed, err := ds.resealRecord(&models.ServiceBindingCredentials{}, "other_details", fmt.Sprint(binding.ID), tc.Read, tc.PlaintextOnly)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resealed != tc.Resealed {
+				t.Errorf("Expected resealed to be %v, got %v", tc.Resealed, resealed)
+			}
+
+			var raw models.ServiceBindingCredentials
+			if err := ds.db.First(&raw, binding.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Resealed && !ds.keyring.IsCurrent(raw.OtherDetails) {
+				t.Errorf("Expected the record to be sealed with the primary key, got %q", raw.OtherDetails)
+			}
+
+			actual, err := ds.GetServiceBindingCredentialsByBindingId(testCtx, "binding")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual.OtherDetails != tc.Expected {
+				t.Errorf("Expected the stored value %q to be kept, got %q", tc.Expected, actual.OtherDetails)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db_service
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const queryStartKey = "metrics:query_start"
+
+var dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "gsb_db_query_duration_seconds",
+	Help: "Time taken by the database to run the queries of the broker.",
+}, []string{"operation", "table"})
+
+func init() {
+	prometheus.MustRegister(dbQueryDuration)
+}
+
+// InstrumentDb times the statements every SqlDatastore using the connection
+// runs by hooking into the gorm callbacks around them. It's only called when
+// metrics are enabled so the callbacks don't slow down every query otherwise.
+func InstrumentDb(db *gorm.DB) {
+	callbacks := db.Callback()
+
+	instrumentCallback(callbacks.Create().Before("gorm:create"), callbacks.Create().After("gorm:create"), "create")
+	instrumentCallback(callbacks.Query().Before("gorm:query"), callbacks.Query().After("gorm:query"), "query")
+	instrumentCallback(callbacks.RowQuery().Before("gorm:row_query"), callbacks.RowQuery().After("gorm:row_query"), "row_query")
+	instrumentCallback(callbacks.Update().Before("gorm:update"), callbacks.Update().After("gorm:update"), "update")
+	instrumentCallback(callbacks.Delete().Before("gorm:delete"), callbacks.Delete().After("gorm:delete"), "delete")
+}
+
+func instrumentCallback(before, after *gorm.CallbackProcessor, operation string) {
+	before.Register("metrics:before_"+operation, func(scope *gorm.Scope) {
+		scope.InstanceSet(queryStartKey, time.Now())
+	})
+
+	after.Register("metrics:after_"+operation, func(scope *gorm.Scope) {
+		start, ok := scope.InstanceGet(queryStartKey)
+		if !ok {
+			return
+		}
+
+		dbQueryDuration.WithLabelValues(operation, scope.TableName()).Observe(time.Since(start.(time.Time)).Seconds())
+	})
+}
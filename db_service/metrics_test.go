@@ -0,0 +1,61 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db_service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestInstrumentDb(t *testing.T) {
+	db := newTestDb(t, &models.OperationHistory{})
+	InstrumentDb(db)
+	ds := &SqlDatastore{db: db}
+
+	table := models.OperationHistoryV1{}.TableName()
+	createsBefore := queriesTimed(t, "create", table)
+	queriesBefore := queriesTimed(t, "query", table)
+
+	if err := ds.CreateOperationHistory(context.Background(), &models.OperationHistory{ServiceInstanceId: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ds.ListOperationHistoryByServiceInstanceId(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := queriesTimed(t, "create", table) - createsBefore; actual != 1 {
+		t.Errorf("Expected 1 create to be timed, got %d", actual)
+	}
+
+	if actual := queriesTimed(t, "query", table) - queriesBefore; actual != 1 {
+		t.Errorf("Expected 1 query to be timed, got %d", actual)
+	}
+}
+
+// queriesTimed gets the number of queries dbQueryDuration observed with the
+// given labels.
+func queriesTimed(t *testing.T, operation, table string) uint64 {
+	metric := &dto.Metric{}
+	if err := dbQueryDuration.WithLabelValues(operation, table).(prometheus.Metric).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+
+	return metric.GetHistogram().GetSampleCount()
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
@@ -26,7 +27,7 @@ import (
 	googlecloudsql "google.golang.org/api/sqladmin/v1beta4"
 )
 
-const numMigrations = 5
+const numMigrations = 15
 
 // runs schema migrations on the provided service broker database to get it up to date
 func RunMigrations(db *gorm.DB) error {
@@ -151,6 +152,61 @@ func RunMigrations(db *gorm.DB) error {
 		return autoMigrateTables(db, &models.TerraformDeploymentV1{})
 	}
 
+	migrations[5] = func() error {
+		return autoMigrateTables(db, &models.TerraformDeploymentV2{})
+	}
+
+	migrations[6] = func() error {
+		return autoMigrateTables(db, &models.TerraformDeploymentLogV1{})
+	}
+
+	// MySQL text columns hold 64KiB, which Terraform workspaces outgrow.
+	// PostgreSQL and SQLite3 text columns are unbounded.
+	migrations[7] = func() error {
+		if db.Dialect().GetName() != DbTypeMysql {
+			return nil
+		}
+
+		return db.Model(&models.TerraformDeploymentV2{}).ModifyColumn("workspace", "mediumtext").Error
+	}
+
+	migrations[8] = func() error {
+		if err := autoMigrateTables(db, &models.ServiceInstanceDetailsV3{}); err != nil {
+			return err
+		}
+
+		// Instances are locked while an operation is pending from now on, but
+		// operations that failed before weren't cleared. Clear the ones that
+		// haven't been touched for a while so the instances can be changed.
+		return db.Model(&models.ServiceInstanceDetailsV3{}).
+			Where("operation_type <> ? AND updated_at < ?", models.ClearOperationType, time.Now().Add(-models.StaleOperationAge)).
+			UpdateColumns(map[string]interface{}{"operation_type": models.ClearOperationType, "operation_id": ""}).Error
+	}
+
+	migrations[9] = func() error {
+		return autoMigrateTables(db, &models.ServiceBindingCredentialsV2{})
+	}
+
+	migrations[10] = func() error {
+		return autoMigrateTables(db, &models.OperationHistoryV1{})
+	}
+
+	migrations[11] = func() error {
+		return autoMigrateTables(db, &models.ProvisionRequestDetailsV2{})
+	}
+
+	migrations[12] = func() error {
+		return autoMigrateTables(db, &models.ServiceBindingCredentialsV3{})
+	}
+
+	migrations[13] = func() error {
+		return autoMigrateTables(db, &models.RetiredCredentialsV1{})
+	}
+
+	migrations[14] = func() error {
+		return autoMigrateTables(db, &models.TerraformDeploymentV3{})
+	}
+
 	var lastMigrationNumber = -1
 
 	// if we've run any migrations before, we should have a migrations table, so find the last one we ran
@@ -0,0 +1,36 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db_service
+
+import (
+	"context"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+)
+
+// ListOperationHistoryByServiceInstanceId gets the requests made for a service
+// instance and its bindings, oldest first.
+func ListOperationHistoryByServiceInstanceId(ctx context.Context, serviceInstanceId string) ([]models.OperationHistory, error) {
+	return defaultDatastore().ListOperationHistoryByServiceInstanceId(ctx, serviceInstanceId)
+}
+func (ds *SqlDatastore) ListOperationHistoryByServiceInstanceId(ctx context.Context, serviceInstanceId string) ([]models.OperationHistory, error) {
+	var history []models.OperationHistory
+	err := ds.db.
+		Where("service_instance_id = ?", serviceInstanceId).
+		Order("id asc").
+		Find(&history).Error
+
+	return history, err
+}
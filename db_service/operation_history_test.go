@@ -0,0 +1,56 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db_service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+)
+
+func TestSqlDatastore_ListOperationHistoryByServiceInstanceId(t *testing.T) {
+	ds := newTestDatastore(t)
+	testCtx := context.Background()
+
+	history := []models.OperationHistory{
+		{ServiceInstanceId: "a", Request: "provision"},
+		{ServiceInstanceId: "b", Request: "provision"},
+		{ServiceInstanceId: "a", BindingId: "binding", Request: "bind"},
+		{ServiceInstanceId: "a", Request: "deprovision"},
+	}
+
+	for i := range history {
+		if err := ds.CreateOperationHistory(testCtx, &history[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listed, err := ds.ListOperationHistoryByServiceInstanceId(testCtx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests []string
+	for _, entry := range listed {
+		requests = append(requests, entry.Request)
+	}
+
+	expected := []string{"provision", "bind", "deprovision"}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("Expected requests %v, got %v", expected, requests)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db_service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+)
+
+// ListRetiredCredentialsDue gets the retired credentials whose grace period
+// ended by the given time, oldest first.
+func ListRetiredCredentialsDue(ctx context.Context, now time.Time) ([]models.RetiredCredentials, error) {
+	return defaultDatastore().ListRetiredCredentialsDue(ctx, now)
+}
+func (ds *SqlDatastore) ListRetiredCredentialsDue(ctx context.Context, now time.Time) ([]models.RetiredCredentials, error) {
+	var retired []models.RetiredCredentials
+	if err := ds.db.Where("retire_at <= ?", now).Order("id asc").Find(&retired).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range retired {
+		if err := ds.openRetiredCredentials(&retired[i]); err != nil {
+			return nil, fmt.Errorf("couldn't open the retired credentials of %q: %v", retired[i].BindingId, err)
+		}
+	}
+
+	return retired, nil
+}
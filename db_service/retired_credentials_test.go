@@ -0,0 +1,58 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db_service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+)
+
+func TestSqlDatastore_ListRetiredCredentialsDue(t *testing.T) {
+	ds := newTestDatastore(t)
+	ds.keyring = newTestKeyring(t)
+	testCtx := context.Background()
+	now := time.Now()
+
+	retired := []models.RetiredCredentials{
+		{BindingId: "due", Details: `{"key":"due"}`, RetireAt: now.Add(-time.Hour)},
+		{BindingId: "pending", Details: `{"key":"pending"}`, RetireAt: now.Add(time.Hour)},
+		{BindingId: "now", Details: `{"key":"now"}`, RetireAt: now},
+	}
+
+	for i := range retired {
+		if err := ds.CreateRetiredCredentials(testCtx, &retired[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listed, err := ds.ListRetiredCredentialsDue(testCtx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var details []string
+	for _, entry := range listed {
+		details = append(details, entry.Details)
+	}
+
+	expected := []string{`{"key":"due"}`, `{"key":"now"}`}
+	if !reflect.DeepEqual(details, expected) {
+		t.Errorf("Expected details %v, got %v", expected, details)
+	}
+}
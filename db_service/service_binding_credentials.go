@@ -0,0 +1,67 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db_service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+)
+
+// ListServiceBindingCredentials gets every binding that hasn't been deleted
+// ordered by instance and binding ID.
+func ListServiceBindingCredentials(ctx context.Context) ([]models.ServiceBindingCredentials, error) {
+	return defaultDatastore().ListServiceBindingCredentials(ctx)
+}
+func (ds *SqlDatastore) ListServiceBindingCredentials(ctx context.Context) ([]models.ServiceBindingCredentials, error) {
+	var bindings []models.ServiceBindingCredentials
+	if err := ds.db.Order("service_instance_id asc, binding_id asc").Find(&bindings).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range bindings {
+		if err := ds.openServiceBindingCredentials(&bindings[i]); err != nil {
+			return nil, fmt.Errorf("couldn't open the credentials of %q: %v", bindings[i].BindingId, err)
+		}
+	}
+
+	return bindings, nil
+}
+
+// SaveServiceBindingCredentialsIfOperation updates the binding like
+// SaveServiceBindingCredentials does, but only if the pending operation stored
+// in the database is still operationType. It returns
+// ErrConcurrentModification if another operation claimed the binding or it
+// was deleted, so a binding can be locked without overwriting someone else's
+// lock or bringing back a deleted binding.
+func SaveServiceBindingCredentialsIfOperation(ctx context.Context, object *models.ServiceBindingCredentials, operationType string) error {
+	return defaultDatastore().SaveServiceBindingCredentialsIfOperation(ctx, object, operationType)
+}
+func (ds *SqlDatastore) SaveServiceBindingCredentialsIfOperation(ctx context.Context, object *models.ServiceBindingCredentials, operationType string) error {
+	sealed, err := ds.sealServiceBindingCredentials(object)
+	if err != nil {
+		return err
+	}
+
+	if err := ds.updateIf(sealed, "operation_type = ?", operationType); err != nil {
+		return err
+	}
+
+	// keep the fields the database assigned, but not the sealed secrets
+	sealed.OtherDetails = object.OtherDetails
+	*object = *sealed
+	return nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db_service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+)
+
+func TestSqlDatastore_ListServiceBindingCredentials(t *testing.T) {
+	ds := newTestDatastore(t)
+	testCtx := context.Background()
+
+	bindings := []models.ServiceBindingCredentials{
+		{ServiceInstanceId: "instance-b", BindingId: "binding-1", OtherDetails: `{"key":"b1"}`},
+		{ServiceInstanceId: "instance-a", BindingId: "binding-2", OtherDetails: `{"key":"a2"}`},
+		{ServiceInstanceId: "instance-a", BindingId: "deleted", OtherDetails: `{"key":"deleted"}`},
+	}
+	for i := range bindings {
+		if err := ds.CreateServiceBindingCredentials(testCtx, &bindings[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ds.DeleteServiceBindingCredentials(testCtx, &bindings[2]); err != nil {
+		t.Fatal(err)
+	}
+
+	listed, err := ds.ListServiceBindingCredentials(testCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(listed) != 2 || listed[0].BindingId != "binding-2" || listed[1].BindingId != "binding-1" {
+		t.Fatalf("Expected the bindings binding-2 and binding-1, got %v", listed)
+	}
+
+	if listed[0].OtherDetails != `{"key":"a2"}` {
+		t.Errorf("Expected the credentials to be opened, got %q", listed[0].OtherDetails)
+	}
+}
+
+func TestSqlDatastore_SaveServiceBindingCredentialsIfOperation(t *testing.T) {
+	cases := map[string]struct {
+		StoredOperation string
+		Deleted         bool
+		ExpectedErr     error
+	}{
+		"matching operation": {
+			StoredOperation: models.ClearOperationType,
+		},
+		"claimed by another operation": {
+			StoredOperation: models.RotateOperationType,
+			ExpectedErr:     ErrConcurrentModification,
+		},
+		"deleted": {
+			StoredOperation: models.ClearOperationType,
+			Deleted:         true,
+			ExpectedErr:     ErrConcurrentModification,
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			ds := newTestDatastore(t)
+			testCtx := context.Background()
+
+			stored := models.ServiceBindingCredentials{ServiceInstanceId: "instance", BindingId: "binding", OtherDetails: `{"key":"old"}`, OperationType: tc.StoredOperation}
+			if err := ds.CreateServiceBindingCredentials(testCtx, &stored); err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Deleted {
+				if err := ds.DeleteServiceBindingCredentials(testCtx, &stored); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			binding := models.ServiceBindingCredentials{ServiceInstanceId: "instance", BindingId: "binding", OtherDetails: `{"key":"new"}`, OperationType: models.RotateOperationType}
+			binding.ID = stored.ID
+			if err := ds.SaveServiceBindingCredentialsIfOperation(testCtx, &binding, models.ClearOperationType); err != tc.ExpectedErr {
+				t.Fatalf("Expected error %v, got %v", tc.ExpectedErr, err)
+			}
+
+			if binding.OtherDetails != `{"key":"new"}` {
+				t.Errorf("Expected the credentials to stay opened, got %q", binding.OtherDetails)
+			}
+
+			if tc.Deleted {
+				if deleted, err := ds.CheckDeletedServiceBindingCredentialsById(testCtx, stored.ID); err != nil || !deleted {
+					t.Errorf("Expected the binding to stay deleted, got %v, %v", deleted, err)
+				}
+				return
+			}
+
+			actual, err := ds.GetServiceBindingCredentialsById(testCtx, stored.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := stored
+			if tc.ExpectedErr == nil {
+				expected = binding
+			}
+
+			if actual.OtherDetails != expected.OtherDetails || actual.OperationType != expected.OperationType {
+				t.Errorf("Expected credentials %q with operation %q, got %q with %q", expected.OtherDetails, expected.OperationType, actual.OtherDetails, actual.OperationType)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db_service
+
+import (
+	"context"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+)
+
+// ListServiceInstanceDetails gets every instance that hasn't been deleted
+// ordered by ID.
+func ListServiceInstanceDetails(ctx context.Context) ([]models.ServiceInstanceDetails, error) {
+	return defaultDatastore().ListServiceInstanceDetails(ctx)
+}
+func (ds *SqlDatastore) ListServiceInstanceDetails(ctx context.Context) ([]models.ServiceInstanceDetails, error) {
+	var instances []models.ServiceInstanceDetails
+	err := ds.db.Order("id asc").Find(&instances).Error
+
+	return instances, err
+}
+
+// ListServiceInstanceDetailsWithDeleted gets every instance, including the
+// soft-deleted ones, ordered by ID.
+func ListServiceInstanceDetailsWithDeleted(ctx context.Context) ([]models.ServiceInstanceDetails, error) {
+	return defaultDatastore().ListServiceInstanceDetailsWithDeleted(ctx)
+}
+func (ds *SqlDatastore) ListServiceInstanceDetailsWithDeleted(ctx context.Context) ([]models.ServiceInstanceDetails, error) {
+	var instances []models.ServiceInstanceDetails
+	err := ds.db.Unscoped().Order("id asc").Find(&instances).Error
+
+	return instances, err
+}
+
+// PurgeServiceInstanceDetails permanently deletes the record if nobody else
+// saved it since it was read. Unlike a soft-delete this frees up the ID of the
+// instance, so it's used to release the records of failed provisions.
+func PurgeServiceInstanceDetails(ctx context.Context, record *models.ServiceInstanceDetails) error {
+	return defaultDatastore().PurgeServiceInstanceDetails(ctx, record)
+}
+func (ds *SqlDatastore) PurgeServiceInstanceDetails(ctx context.Context, record *models.ServiceInstanceDetails) error {
+	result := ds.db.Unscoped().Where("version = ?", record.Version).Delete(record)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrConcurrentModification
+	}
+
+	return result.Error
+}
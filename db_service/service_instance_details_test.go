@@ -0,0 +1,83 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db_service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+)
+
+func TestSqlDatastore_ListServiceInstanceDetails(t *testing.T) {
+	ds := newTestDatastore(t)
+	testCtx := context.Background()
+
+	for _, id := range []string{"b", "a", "deleted"} {
+		if err := ds.CreateServiceInstanceDetails(testCtx, &models.ServiceInstanceDetails{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ds.DeleteServiceInstanceDetailsById(testCtx, "deleted"); err != nil {
+		t.Fatal(err)
+	}
+
+	instances, err := ds.ListServiceInstanceDetails(testCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(instances) != 2 || instances[0].ID != "a" || instances[1].ID != "b" {
+		t.Errorf("Expected the instances a and b, got %v", instances)
+	}
+
+	withDeleted, err := ds.ListServiceInstanceDetailsWithDeleted(testCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(withDeleted) != 3 || withDeleted[2].ID != "deleted" {
+		t.Errorf("Expected the instances a, b and deleted, got %v", withDeleted)
+	}
+}
+
+func TestSqlDatastore_PurgeServiceInstanceDetails(t *testing.T) {
+	ds := newTestDatastore(t)
+	testCtx := context.Background()
+
+	instance := models.ServiceInstanceDetails{ID: "reserved"}
+	if err := ds.CreateServiceInstanceDetails(testCtx, &instance); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := instance
+	if err := ds.SaveServiceInstanceDetails(testCtx, &instance); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ds.PurgeServiceInstanceDetails(testCtx, &stale); err != ErrConcurrentModification {
+		t.Errorf("Expected purging a stale copy to fail with ErrConcurrentModification, got %v", err)
+	}
+
+	if err := ds.PurgeServiceInstanceDetails(testCtx, &instance); err != nil {
+		t.Fatal(err)
+	}
+
+	// purged IDs can be used again, unlike soft-deleted ones
+	if err := ds.CreateServiceInstanceDetails(testCtx, &models.ServiceInstanceDetails{ID: "reserved"}); err != nil {
+		t.Errorf("Expected the purged ID to be free, got %v", err)
+	}
+}
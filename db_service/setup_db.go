@@ -19,11 +19,16 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
 
 	"code.cloudfoundry.org/lager"
 	"github.com/go-sql-driver/mysql"
 	"github.com/jinzhu/gorm"
+	_ "github.com/lib/pq"
 	"github.com/spf13/viper"
 )
 
@@ -39,10 +44,18 @@ const (
 	dbTypeProp     = "db.type"
 	dbPathProp     = "db.path"
 
-	DbTypeMysql   = "mysql"
-	DbTypeSqlite3 = "sqlite3"
+	DbTypeMysql    = "mysql"
+	DbTypeSqlite3  = "sqlite3"
+	DbTypePostgres = "postgres"
 )
 
+// defaultPorts holds the port to connect to for each type of database if
+// db.port isn't set.
+var defaultPorts = map[string]string{
+	DbTypeMysql:    "3306",
+	DbTypePostgres: "5432",
+}
+
 func init() {
 	viper.BindEnv(caCertProp, "CA_CERT")
 	viper.BindEnv(clientCertProp, "CLIENT_CERT")
@@ -53,7 +66,6 @@ func init() {
 	viper.BindEnv(dbPassProp, "DB_PASSWORD")
 
 	viper.BindEnv(dbPortProp, "DB_PORT")
-	viper.SetDefault(dbPortProp, "3306")
 	viper.BindEnv(dbNameProp, "DB_NAME")
 	viper.SetDefault(dbNameProp, "servicebroker")
 
@@ -70,12 +82,14 @@ func SetupDb(logger lager.Logger) *gorm.DB {
 	var err error
 	switch dbType {
 	default:
-		logger.Error("Database Setup", fmt.Errorf("Invalid database type %q, valid types are: sqlite3 and mysql", dbType))
+		logger.Error("Database Setup", fmt.Errorf("Invalid database type %q, valid types are: sqlite3, mysql and postgres", dbType))
 		os.Exit(1)
 	case DbTypeMysql:
 		db, err = setupMysqlDb(logger)
 	case DbTypeSqlite3:
 		db, err = setupSqlite3Db(logger)
+	case DbTypePostgres:
+		db, err = setupPostgresDb(logger)
 	}
 
 	if err != nil {
@@ -107,7 +121,7 @@ func setupMysqlDb(logger lager.Logger) (*gorm.DB, error) {
 		return nil, errors.New("DB_HOST, DB_USERNAME and DB_PASSWORD are required environment variables.")
 	}
 
-	dbPort := viper.GetString(dbPortProp)
+	dbPort := dbPortOrDefault(DbTypeMysql)
 	dbName := viper.GetString(dbNameProp)
 
 	tlsStr, err := generateTlsStringFromEnv()
@@ -125,6 +139,94 @@ func setupMysqlDb(logger lager.Logger) (*gorm.DB, error) {
 	return gorm.Open(DbTypeMysql, connStr)
 }
 
+func setupPostgresDb(logger lager.Logger) (*gorm.DB, error) {
+	dbHost := viper.GetString(dbHostProp)
+	dbUsername := viper.GetString(dbUserProp)
+	dbPassword := viper.GetString(dbPassProp)
+
+	if dbPassword == "" || dbHost == "" || dbUsername == "" {
+		return nil, errors.New("DB_HOST, DB_USERNAME and DB_PASSWORD are required environment variables.")
+	}
+
+	dbPort := dbPortOrDefault(DbTypePostgres)
+	dbName := viper.GetString(dbNameProp)
+
+	tlsParams, err := generatePostgresTlsParamsFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("Error generating TLS parameters from env: %s", err)
+	}
+
+	logger.Info("Connecting to PostgreSQL Database", lager.Data{
+		"host": dbHost,
+		"port": dbPort,
+		"name": dbName,
+	})
+
+	connUrl := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(dbUsername, dbPassword),
+		Host:     net.JoinHostPort(dbHost, dbPort),
+		Path:     "/" + dbName,
+		RawQuery: tlsParams.Encode(),
+	}
+
+	return gorm.Open(DbTypePostgres, connUrl.String())
+}
+
+// dbPortOrDefault gets the port to connect to, falling back to the default
+// port of the database type if none is configured.
+func dbPortOrDefault(dbType string) string {
+	if port := viper.GetString(dbPortProp); port != "" {
+		return port
+	}
+
+	return defaultPorts[dbType]
+}
+
+// generatePostgresTlsParamsFromEnv is the PostgreSQL equivalent of
+// generateTlsStringFromEnv. The driver only reads certificates from files so
+// they're written to a private temporary directory.
+func generatePostgresTlsParamsFromEnv() (url.Values, error) {
+	caCert := viper.GetString(caCertProp)
+	clientCertStr := viper.GetString(clientCertProp)
+	clientKeyStr := viper.GetString(clientKeyProp)
+
+	if caCert == "" || clientCertStr == "" || clientKeyStr == "" {
+		return url.Values{"sslmode": {"disable"}}, nil
+	}
+
+	if _, err := tls.X509KeyPair([]byte(clientCertStr), []byte(clientKeyStr)); err != nil {
+		return nil, fmt.Errorf("Error parsing cert pair: %s", err)
+	}
+
+	dir, err := ioutil.TempDir("", "gsb-db-certs")
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{"sslmode": {"verify-ca"}}
+	files := []struct {
+		Param    string
+		Name     string
+		Contents string
+	}{
+		{Param: "sslrootcert", Name: "ca.pem", Contents: caCert},
+		{Param: "sslcert", Name: "client-cert.pem", Contents: clientCertStr},
+		{Param: "sslkey", Name: "client-key.pem", Contents: clientKeyStr},
+	}
+
+	for _, file := range files {
+		path := filepath.Join(dir, file.Name)
+		if err := ioutil.WriteFile(path, []byte(file.Contents), 0600); err != nil {
+			return nil, fmt.Errorf("Error writing %s: %s", file.Name, err)
+		}
+
+		params.Set(file.Param, path)
+	}
+
+	return params, nil
+}
+
 func generateTlsStringFromEnv() (string, error) {
 	caCert := viper.GetString(caCertProp)
 	clientCertStr := viper.GetString(clientCertProp)
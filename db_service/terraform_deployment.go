@@ -0,0 +1,189 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db_service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+)
+
+// AcquireTerraformDeploymentLease takes the lease on the deployment with the
+// given ID for owner until expiration. The lease is only taken if it's free,
+// expired, or already held by owner unless force is set.
+// It returns true if the lease was acquired.
+func AcquireTerraformDeploymentLease(ctx context.Context, id, owner string, expiration time.Time, force bool) (bool, error) {
+	return defaultDatastore().AcquireTerraformDeploymentLease(ctx, id, owner, expiration, force)
+}
+func (ds *SqlDatastore) AcquireTerraformDeploymentLease(ctx context.Context, id, owner string, expiration time.Time, force bool) (bool, error) {
+	query := ds.db.Model(&models.TerraformDeployment{}).Where("id = ?", id)
+	if !force {
+		query = query.Where("lease_owner = ? OR lease_owner = '' OR lease_owner IS NULL OR lease_expiration IS NULL OR lease_expiration < ?", owner, time.Now())
+	}
+
+	result := query.Updates(map[string]interface{}{
+		"lease_owner":      owner,
+		"lease_expiration": expiration,
+	})
+
+	return result.RowsAffected == 1, result.Error
+}
+
+// RenewTerraformDeploymentLease extends the lease owner holds on the
+// deployment and stores a snapshot of its workspace.
+// It returns false if owner no longer holds the lease.
+func RenewTerraformDeploymentLease(ctx context.Context, id, owner string, expiration time.Time, workspace string) (bool, error) {
+	return defaultDatastore().RenewTerraformDeploymentLease(ctx, id, owner, expiration, workspace)
+}
+func (ds *SqlDatastore) RenewTerraformDeploymentLease(ctx context.Context, id, owner string, expiration time.Time, workspace string) (bool, error) {
+	sealed, err := ds.keyring.Seal(workspace)
+	if err != nil {
+		return false, err
+	}
+
+	result := ds.db.Model(&models.TerraformDeployment{}).
+		Where("id = ? AND lease_owner = ?", id, owner).
+		Updates(map[string]interface{}{
+			"lease_expiration": expiration,
+			"workspace":        sealed,
+		})
+
+	return result.RowsAffected == 1, result.Error
+}
+
+// SaveTerraformDeploymentIfLeaseOwner updates the deployment like
+// SaveTerraformDeployment does, but only if owner still holds its lease in the
+// database. It returns ErrConcurrentModification if another process took the
+// lease over or the deployment was deleted.
+func SaveTerraformDeploymentIfLeaseOwner(ctx context.Context, object *models.TerraformDeployment, owner string) error {
+	return defaultDatastore().SaveTerraformDeploymentIfLeaseOwner(ctx, object, owner)
+}
+func (ds *SqlDatastore) SaveTerraformDeploymentIfLeaseOwner(ctx context.Context, object *models.TerraformDeployment, owner string) error {
+	sealed, err := ds.sealTerraformDeployment(object)
+	if err != nil {
+		return err
+	}
+
+	if err := ds.updateIf(sealed, "lease_owner = ?", owner); err != nil {
+		return err
+	}
+
+	// keep the fields the database assigned, but not the sealed secrets
+	sealed.Workspace = object.Workspace
+	*object = *sealed
+	return nil
+}
+
+// ListTerraformDeployments gets every deployment ordered by ID.
+func ListTerraformDeployments(ctx context.Context) ([]models.TerraformDeployment, error) {
+	return defaultDatastore().ListTerraformDeployments(ctx)
+}
+func (ds *SqlDatastore) ListTerraformDeployments(ctx context.Context) ([]models.TerraformDeployment, error) {
+	var deployments []models.TerraformDeployment
+	if err := ds.db.Order("id asc").Find(&deployments).Error; err != nil {
+		return nil, err
+	}
+
+	return deployments, ds.openTerraformDeployments(deployments)
+}
+
+// ListOrphanedTerraformDeployments gets the deployments in one of the pending
+// states whose lease expired before the given time, in the order their
+// operations were queued.
+func ListOrphanedTerraformDeployments(ctx context.Context, pendingStates []string, before time.Time) ([]models.TerraformDeployment, error) {
+	return defaultDatastore().ListOrphanedTerraformDeployments(ctx, pendingStates, before)
+}
+func (ds *SqlDatastore) ListOrphanedTerraformDeployments(ctx context.Context, pendingStates []string, before time.Time) ([]models.TerraformDeployment, error) {
+	var deployments []models.TerraformDeployment
+	err := ds.db.
+		Where("last_operation_state IN (?)", pendingStates).
+		Where("lease_expiration IS NULL OR lease_expiration < ?", before).
+		Order("queued_at asc, id asc").
+		Find(&deployments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return deployments, ds.openTerraformDeployments(deployments)
+}
+
+// openTerraformDeployments opens the sealed workspaces of the deployments.
+func (ds *SqlDatastore) openTerraformDeployments(deployments []models.TerraformDeployment) error {
+	for i := range deployments {
+		if err := ds.openTerraformDeployment(&deployments[i]); err != nil {
+			return fmt.Errorf("couldn't open the workspace of %q: %v", deployments[i].ID, err)
+		}
+	}
+
+	return nil
+}
+
+// ListTerraformDeploymentLogs gets the log chunks of every operation run on the
+// deployment in the order they were written.
+func ListTerraformDeploymentLogs(ctx context.Context, deploymentId string) ([]models.TerraformDeploymentLog, error) {
+	return defaultDatastore().ListTerraformDeploymentLogs(ctx, deploymentId)
+}
+func (ds *SqlDatastore) ListTerraformDeploymentLogs(ctx context.Context, deploymentId string) ([]models.TerraformDeploymentLog, error) {
+	var logs []models.TerraformDeploymentLog
+	err := ds.db.
+		Where("terraform_deployment_id = ?", deploymentId).
+		Order("id asc").
+		Find(&logs).Error
+
+	return logs, err
+}
+
+// PurgeTerraformDeploymentLogs permanently deletes the log chunks with the
+// given IDs. Unlike the soft-delete of the other records, this frees up the
+// space the logs took.
+func PurgeTerraformDeploymentLogs(ctx context.Context, ids []uint) error {
+	return defaultDatastore().PurgeTerraformDeploymentLogs(ctx, ids)
+}
+func (ds *SqlDatastore) PurgeTerraformDeploymentLogs(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return ds.db.Unscoped().Where("id IN (?)", ids).Delete(&models.TerraformDeploymentLog{}).Error
+}
+
+// PurgeOldTerraformDeploymentLogs permanently deletes the log chunks of all but
+// the newest keep operations run on the deployment. If keep is 0, the logs of
+// every operation are deleted.
+func PurgeOldTerraformDeploymentLogs(ctx context.Context, deploymentId string, keep int) error {
+	return defaultDatastore().PurgeOldTerraformDeploymentLogs(ctx, deploymentId, keep)
+}
+func (ds *SqlDatastore) PurgeOldTerraformDeploymentLogs(ctx context.Context, deploymentId string, keep int) error {
+	// operation IDs are timestamps so they sort in the order the operations ran
+	var operationIds []string
+	err := ds.db.Unscoped().
+		Model(&models.TerraformDeploymentLog{}).
+		Where("terraform_deployment_id = ?", deploymentId).
+		Order("operation_id desc").
+		Pluck("DISTINCT operation_id", &operationIds).Error
+	if err != nil {
+		return err
+	}
+
+	if len(operationIds) <= keep {
+		return nil
+	}
+
+	return ds.db.Unscoped().
+		Where("terraform_deployment_id = ? AND operation_id IN (?)", deploymentId, operationIds[keep:]).
+		Delete(&models.TerraformDeploymentLog{}).Error
+}
@@ -0,0 +1,276 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package db_service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+)
+
+func TestSqlDatastore_TerraformDeploymentLeases(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	cases := map[string]struct {
+		Owner      string
+		Expiration *time.Time
+		Force      bool
+		Acquired   bool
+	}{
+		"free":            {Owner: "", Expiration: nil, Acquired: true},
+		"expired":         {Owner: "other", Expiration: &past, Acquired: true},
+		"held":            {Owner: "other", Expiration: &future, Acquired: false},
+		"held and forced": {Owner: "other", Expiration: &future, Force: true, Acquired: true},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			ds := newTestDatastore(t)
+			testCtx := context.Background()
+
+			deployment := models.TerraformDeployment{
+				ID:                 "tf:instance:",
+				LastOperationState: "in progress",
+				LeaseOwner:         tc.Owner,
+				LeaseExpiration:    tc.Expiration,
+			}
+			if err := ds.CreateTerraformDeployment(testCtx, &deployment); err != nil {
+				t.Fatal(err)
+			}
+
+			acquired, err := ds.AcquireTerraformDeploymentLease(testCtx, deployment.ID, "me", future, tc.Force)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if acquired != tc.Acquired {
+				t.Fatalf("Expected acquired to be %v, got %v", tc.Acquired, acquired)
+			}
+
+			renewed, err := ds.RenewTerraformDeploymentLease(testCtx, deployment.ID, "me", future, "{}")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if renewed != tc.Acquired {
+				t.Errorf("Expected renewed to be %v, got %v", tc.Acquired, renewed)
+			}
+		})
+	}
+}
+
+func TestSqlDatastore_SaveTerraformDeploymentIfLeaseOwner(t *testing.T) {
+	cases := map[string]struct {
+		StoredOwner string
+		Deleted     bool
+		ExpectedErr error
+	}{
+		"lease held":       {StoredOwner: "me"},
+		"lease taken over": {StoredOwner: "other", ExpectedErr: ErrConcurrentModification},
+		"deleted":          {StoredOwner: "me", Deleted: true, ExpectedErr: ErrConcurrentModification},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			ds := newTestDatastore(t)
+			testCtx := context.Background()
+
+			stored := models.TerraformDeployment{ID: "tf:instance:", Workspace: "old", LastOperationState: "in progress", LeaseOwner: tc.StoredOwner}
+			if err := ds.CreateTerraformDeployment(testCtx, &stored); err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Deleted {
+				if err := ds.DeleteTerraformDeployment(testCtx, &stored); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			deployment := models.TerraformDeployment{ID: "tf:instance:", Workspace: "new", LastOperationState: "succeeded"}
+			if err := ds.SaveTerraformDeploymentIfLeaseOwner(testCtx, &deployment, "me"); err != tc.ExpectedErr {
+				t.Fatalf("Expected error %v, got %v", tc.ExpectedErr, err)
+			}
+
+			if deployment.Workspace != "new" {
+				t.Errorf("Expected the workspace to stay opened, got %q", deployment.Workspace)
+			}
+
+			if tc.Deleted {
+				if count, err := ds.CountTerraformDeploymentById(testCtx, stored.ID); err != nil || count != 0 {
+					t.Errorf("Expected the deployment to stay deleted, got %v, %v", count, err)
+				}
+				return
+			}
+
+			actual, err := ds.GetTerraformDeploymentById(testCtx, stored.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := stored
+			if tc.ExpectedErr == nil {
+				expected = deployment
+			}
+
+			if actual.Workspace != expected.Workspace || actual.LastOperationState != expected.LastOperationState || actual.LeaseOwner != expected.LeaseOwner {
+				t.Errorf("Expected deployment %v, got %v", expected, actual)
+			}
+		})
+	}
+}
+
+func TestSqlDatastore_ListOrphanedTerraformDeployments(t *testing.T) {
+	ds := newTestDatastore(t)
+	testCtx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	longAgo := time.Now().Add(-2 * time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	deployments := []models.TerraformDeployment{
+		{ID: "expired", LastOperationState: "in progress", LeaseOwner: "other", LeaseExpiration: &past, QueuedAt: &past},
+		{ID: "no-lease", LastOperationState: "in progress"},
+		{ID: "queued", LastOperationState: "queued", LeaseOwner: "other", LeaseExpiration: &past, QueuedAt: &longAgo},
+		{ID: "running", LastOperationState: "in progress", LeaseOwner: "other", LeaseExpiration: &future},
+		{ID: "finished", LastOperationState: "succeeded"},
+	}
+
+	for i := range deployments {
+		if err := ds.CreateTerraformDeployment(testCtx, &deployments[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	orphans, err := ds.ListOrphanedTerraformDeployments(testCtx, []string{"queued", "in progress"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, orphan := range orphans {
+		ids = append(ids, orphan.ID)
+	}
+
+	if len(ids) != 3 || ids[0] != "no-lease" || ids[1] != "queued" || ids[2] != "expired" {
+		t.Errorf("Expected orphans [no-lease queued expired], got %v", ids)
+	}
+}
+
+func TestSqlDatastore_TerraformDeploymentLogs(t *testing.T) {
+	ds := newTestDatastore(t)
+	testCtx := context.Background()
+
+	logs := []models.TerraformDeploymentLog{
+		{TerraformDeploymentId: "tf:a:", OperationId: "1", Output: "first"},
+		{TerraformDeploymentId: "tf:b:", OperationId: "2", Output: "other"},
+		{TerraformDeploymentId: "tf:a:", OperationId: "1", Output: "second"},
+		{TerraformDeploymentId: "tf:a:", OperationId: "3", Output: "third"},
+	}
+
+	for i := range logs {
+		if err := ds.CreateTerraformDeploymentLog(testCtx, &logs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ds.PurgeTerraformDeploymentLogs(testCtx, []uint{logs[0].ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	listed, err := ds.ListTerraformDeploymentLogs(testCtx, "tf:a:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var outputs []string
+	for _, log := range listed {
+		outputs = append(outputs, log.Output)
+	}
+
+	if len(outputs) != 2 || outputs[0] != "second" || outputs[1] != "third" {
+		t.Errorf("Expected logs [second third], got %v", outputs)
+	}
+
+	var total int
+	if err := ds.db.Unscoped().Model(&models.TerraformDeploymentLog{}).Count(&total).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if total != 3 {
+		t.Errorf("Expected purged logs to be deleted permanently, got %d rows", total)
+	}
+}
+
+func TestSqlDatastore_PurgeOldTerraformDeploymentLogs(t *testing.T) {
+	cases := map[string]struct {
+		Keep     int
+		Expected []string
+	}{
+		"keeps newest operations": {Keep: 2, Expected: []string{"2", "3"}},
+		"keeps everything":        {Keep: 5, Expected: []string{"1", "1", "2", "3"}},
+		"purges everything":       {Keep: 0, Expected: nil},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			ds := newTestDatastore(t)
+			testCtx := context.Background()
+
+			logs := []models.TerraformDeploymentLog{
+				{TerraformDeploymentId: "tf:a:", OperationId: "1"},
+				{TerraformDeploymentId: "tf:a:", OperationId: "1"},
+				{TerraformDeploymentId: "tf:b:", OperationId: "1"},
+				{TerraformDeploymentId: "tf:a:", OperationId: "2"},
+				{TerraformDeploymentId: "tf:a:", OperationId: "3"},
+			}
+
+			for i := range logs {
+				if err := ds.CreateTerraformDeploymentLog(testCtx, &logs[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := ds.PurgeOldTerraformDeploymentLogs(testCtx, "tf:a:", tc.Keep); err != nil {
+				t.Fatal(err)
+			}
+
+			listed, err := ds.ListTerraformDeploymentLogs(testCtx, "tf:a:")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var operations []string
+			for _, log := range listed {
+				operations = append(operations, log.OperationId)
+			}
+
+			if !reflect.DeepEqual(operations, tc.Expected) {
+				t.Errorf("Expected operations %v, got %v", tc.Expected, operations)
+			}
+
+			other, err := ds.ListTerraformDeploymentLogs(testCtx, "tf:b:")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(other) != 1 {
+				t.Errorf("Expected the logs of other deployments to be kept, got %d", len(other))
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@
 package broker
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"reflect"
@@ -422,7 +423,7 @@ func TestServiceDefinition_ProvisionVariables(t *testing.T) {
 			viper.Set(service.ProvisionDefaultOverrideProperty(), tc.DefaultOverride)
 			details := brokerapi.ProvisionDetails{RawParameters: json.RawMessage(tc.UserParams)}
 			plan := ServicePlan{ServiceProperties: tc.ServiceProperties}
-			vars, err := service.ProvisionVariables("instance-id-here", details, plan)
+			vars, err := service.ProvisionVariables(context.Background(), "instance-id-here", details, plan)
 
 			if err != nil {
 				t.Errorf("got error while creating provision variables: %v", err)
@@ -435,6 +436,111 @@ func TestServiceDefinition_ProvisionVariables(t *testing.T) {
 	}
 }
 
+func TestServiceDefinition_UpdateVariables(t *testing.T) {
+	service := ServiceDefinition{
+		Name: "left-handed-smoke-sifter",
+		DefaultServiceDefinition: `{"id":"abcd-efgh-ijkl", "plans": [{"id": "builtin-plan", "name": "Builtin!"}]}`,
+		ProvisionInputVariables: []BrokerVariable{
+			{
+				FieldName: "location",
+				Type:      JsonTypeString,
+				Default:   "us",
+			},
+			{
+				FieldName: "name",
+				Type:      JsonTypeString,
+				Default:   "name-${location}",
+			},
+		},
+		ProvisionComputedVariables: []varcontext.DefaultVariable{
+			{
+				Name:      "instance-name",
+				Default:   "${instance.name}",
+				Overwrite: true,
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		OriginalParams    string
+		UserParams        string
+		ServiceProperties map[string]string
+		DefaultOverride   string
+		ExpectedContext   map[string]interface{}
+	}{
+		"empty": {
+			OriginalParams:    "",
+			UserParams:        "",
+			ServiceProperties: map[string]string{},
+			ExpectedContext: map[string]interface{}{
+				"location":      "us",
+				"name":          "name-us",
+				"instance-name": "existing-instance",
+			},
+		},
+		"original params are kept": {
+			OriginalParams:    `{"location":"eu"}`,
+			UserParams:        "",
+			ServiceProperties: map[string]string{},
+			ExpectedContext: map[string]interface{}{
+				"location":      "eu",
+				"name":          "name-eu",
+				"instance-name": "existing-instance",
+			},
+		},
+		"update params override original params": {
+			OriginalParams:    `{"location":"eu", "name":"foo"}`,
+			UserParams:        `{"location":"nz"}`,
+			ServiceProperties: map[string]string{},
+			ExpectedContext: map[string]interface{}{
+				"location":      "nz",
+				"name":          "foo",
+				"instance-name": "existing-instance",
+			},
+		},
+		"user values override operator defaults": {
+			OriginalParams:    `{"location":"nz"}`,
+			DefaultOverride:   `{"location":"eu"}`,
+			ServiceProperties: map[string]string{},
+			ExpectedContext: map[string]interface{}{
+				"location":      "nz",
+				"name":          "name-nz",
+				"instance-name": "existing-instance",
+			},
+		},
+		"new plan properties are used": {
+			OriginalParams:    "",
+			UserParams:        `{"tier":"user-tier"}`,
+			ServiceProperties: map[string]string{"tier": "plan-tier"},
+			ExpectedContext: map[string]interface{}{
+				"location":      "us",
+				"name":          "name-us",
+				"tier":          "plan-tier",
+				"instance-name": "existing-instance",
+			},
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			viper.Set(service.ProvisionDefaultOverrideProperty(), tc.DefaultOverride)
+			instance := models.ServiceInstanceDetails{ID: "instance-id-here", Name: "existing-instance"}
+			details := brokerapi.UpdateDetails{RawParameters: json.RawMessage(tc.UserParams)}
+			provisionRequest := models.ProvisionRequestDetails{RequestDetails: tc.OriginalParams}
+			plan := ServicePlan{ServiceProperties: tc.ServiceProperties}
+			vars, err := service.UpdateVariables(context.Background(), instance, details, provisionRequest, plan)
+
+			if err != nil {
+				t.Errorf("got error while creating update variables: %v", err)
+			}
+
+			if !reflect.DeepEqual(vars.ToMap(), tc.ExpectedContext) {
+				t.Errorf("Expected context: %v got %v", tc.ExpectedContext, vars.ToMap())
+			}
+		})
+	}
+}
+
 func TestServiceDefinition_BindVariables(t *testing.T) {
 	service := ServiceDefinition{
 		Name: "left-handed-smoke-sifter",
@@ -544,7 +650,7 @@ func TestServiceDefinition_BindVariables(t *testing.T) {
 			viper.Set(service.BindDefaultOverrideProperty(), tc.DefaultOverride)
 			details := brokerapi.BindDetails{RawParameters: json.RawMessage(tc.UserParams)}
 			instance := models.ServiceInstanceDetails{OtherDetails: tc.InstanceVars}
-			vars, err := service.BindVariables(instance, "binding-id-here", details)
+			vars, err := service.BindVariables(context.Background(), instance, "binding-id-here", details)
 
 			if err != nil {
 				t.Fatalf("got error while creating provision variables: %v", err)
@@ -556,3 +662,144 @@ func TestServiceDefinition_BindVariables(t *testing.T) {
 		})
 	}
 }
+
+func TestServiceDefinition_OrganizationAndSpace(t *testing.T) {
+	service := ServiceDefinition{
+		Name: "org-space-service",
+		DefaultServiceDefinition: `{"id":"abcd-efgh-ijkl", "plans": [{"id": "builtin-plan", "name": "Builtin!"}]}`,
+		ProvisionComputedVariables: []varcontext.DefaultVariable{
+			{Name: "org", Default: "${request.organization_guid}", Overwrite: true},
+			{Name: "space", Default: "${request.space_guid}", Overwrite: true},
+		},
+	}
+
+	provisionVars, err := service.ProvisionVariables(context.Background(), "instance-id-here", brokerapi.ProvisionDetails{OrganizationGUID: "org-1", SpaceGUID: "space-1"}, ServicePlan{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := provisionVars.GetString("org"); actual != "org-1" {
+		t.Errorf("Expected provision org %q, got %q", "org-1", actual)
+	}
+
+	if actual := provisionVars.GetString("space"); actual != "space-1" {
+		t.Errorf("Expected provision space %q, got %q", "space-1", actual)
+	}
+
+	instance := models.ServiceInstanceDetails{ID: "instance-id-here", OrganizationGuid: "org-2", SpaceGuid: "space-2"}
+	updateVars, err := service.UpdateVariables(context.Background(), instance, brokerapi.UpdateDetails{}, models.ProvisionRequestDetails{}, ServicePlan{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := updateVars.GetString("org"); actual != "org-2" {
+		t.Errorf("Expected update org %q, got %q", "org-2", actual)
+	}
+
+	if actual := updateVars.GetString("space"); actual != "space-2" {
+		t.Errorf("Expected update space %q, got %q", "space-2", actual)
+	}
+}
+
+func TestServiceDefinition_OriginatingUser(t *testing.T) {
+	computed := []varcontext.DefaultVariable{{Name: "owner", Default: "${request.originating_user}", Overwrite: true}}
+	service := ServiceDefinition{
+		Name: "originating-user-service",
+		DefaultServiceDefinition:   `{"id":"abcd-efgh-ijkl", "plans": [{"id": "builtin-plan", "name": "Builtin!"}]}`,
+		ProvisionComputedVariables: computed,
+		BindComputedVariables:      computed,
+	}
+
+	cases := map[string]struct {
+		Identity *OriginatingIdentity
+		Expected string
+	}{
+		"no identity": {Identity: nil, Expected: ""},
+		"cloud foundry": {
+			Identity: &OriginatingIdentity{Platform: CloudFoundryPlatform, Value: map[string]interface{}{"user_id": "cf-user"}},
+			Expected: "cf-user",
+		},
+		"kubernetes": {
+			Identity: &OriginatingIdentity{Platform: KubernetesPlatform, Value: map[string]interface{}{"username": "k8s-user", "uid": "1234"}},
+			Expected: "k8s-user",
+		},
+		"unknown platform": {
+			Identity: &OriginatingIdentity{Platform: "other", Value: map[string]interface{}{"user_id": "someone"}},
+			Expected: "",
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			ctx := context.Background()
+			if tc.Identity != nil {
+				ctx = WithOriginatingIdentity(ctx, tc.Identity)
+			}
+
+			provisionVars, err := service.ProvisionVariables(ctx, "instance-id-here", brokerapi.ProvisionDetails{}, ServicePlan{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := provisionVars.GetString("owner"); actual != tc.Expected {
+				t.Errorf("Expected provision owner %q, got %q", tc.Expected, actual)
+			}
+
+			bindVars, err := service.BindVariables(ctx, models.ServiceInstanceDetails{}, "binding-id-here", brokerapi.BindDetails{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := bindVars.GetString("owner"); actual != tc.Expected {
+				t.Errorf("Expected bind owner %q, got %q", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestServiceDefinition_RequestContext(t *testing.T) {
+	computed := []varcontext.DefaultVariable{
+		{Name: "namespace", Default: `${request.context["namespace"]}`, Overwrite: true},
+	}
+	service := ServiceDefinition{
+		Name: "request-context-service",
+		DefaultServiceDefinition:   `{"id":"abcd-efgh-ijkl", "plans": [{"id": "builtin-plan", "name": "Builtin!"}]}`,
+		ProvisionComputedVariables: append(computed, varcontext.DefaultVariable{Name: "labels", Default: "${request.default_labels}", Overwrite: true}),
+		BindComputedVariables:      computed,
+	}
+
+	rawContext := json.RawMessage(`{"platform":"kubernetes","namespace":"team-a","clusterid":"cluster-1"}`)
+	instance := models.ServiceInstanceDetails{ID: "instance-id-here", OrganizationGuid: "org-guid", SpaceGuid: "space-guid"}
+
+	provisionVars, err := service.ProvisionVariables(context.Background(), "instance-id-here", brokerapi.ProvisionDetails{RawContext: rawContext}, ServicePlan{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updateVars, err := service.UpdateVariables(context.Background(), instance, brokerapi.UpdateDetails{RawContext: rawContext}, models.ProvisionRequestDetails{}, ServicePlan{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bindVars, err := service.BindVariables(context.Background(), instance, "binding-id-here", brokerapi.BindDetails{RawContext: rawContext})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for tn, vars := range map[string]*varcontext.VarContext{"provision": provisionVars, "update": updateVars, "bind": bindVars} {
+		if actual := vars.GetString("namespace"); actual != "team-a" {
+			t.Errorf("%s: expected namespace %q, got %q", tn, "team-a", actual)
+		}
+	}
+
+	expectedUpdateLabels := map[string]interface{}{
+		"pcf-organization-guid": "org-guid",
+		"pcf-space-guid":        "space-guid",
+		"pcf-instance-id":       "instance-id-here",
+		"k8s-namespace":         "team-a",
+		"k8s-clusterid":         "cluster-1",
+	}
+	if actual := updateVars.ToMap()["labels"]; !reflect.DeepEqual(actual, expectedUpdateLabels) {
+		t.Errorf("Expected update labels %v, got %v", expectedUpdateLabels, actual)
+	}
+}
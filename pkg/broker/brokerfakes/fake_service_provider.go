@@ -26,6 +26,31 @@ type FakeServiceProvider struct {
 		result1 map[string]interface{}
 		result2 error
 	}
+	BindingExistsStub        func(context.Context, models.ServiceInstanceDetails, models.ServiceBindingCredentials) (bool, error)
+	bindingExistsMutex       sync.RWMutex
+	bindingExistsArgsForCall []struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+		arg3 models.ServiceBindingCredentials
+	}
+	bindingExistsReturns struct {
+		result1 bool
+		result2 error
+	}
+	bindingExistsReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
+	BindsAsyncStub        func() bool
+	bindsAsyncMutex       sync.RWMutex
+	bindsAsyncArgsForCall []struct {
+	}
+	bindsAsyncReturns struct {
+		result1 bool
+	}
+	bindsAsyncReturnsOnCall map[int]struct {
+		result1 bool
+	}
 	BuildInstanceCredentialsStub        func(context.Context, models.ServiceBindingCredentials, models.ServiceInstanceDetails) (map[string]interface{}, error)
 	buildInstanceCredentialsMutex       sync.RWMutex
 	buildInstanceCredentialsArgsForCall []struct {
@@ -66,6 +91,62 @@ type FakeServiceProvider struct {
 	deprovisionsAsyncReturnsOnCall map[int]struct {
 		result1 bool
 	}
+	DescribeOperationStub        func(context.Context, models.ServiceInstanceDetails) (string, error)
+	describeOperationMutex       sync.RWMutex
+	describeOperationArgsForCall []struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+	}
+	describeOperationReturns struct {
+		result1 string
+		result2 error
+	}
+	describeOperationReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	DescribeResourcesStub        func(context.Context) ([]models.ServiceInstanceDetails, error)
+	describeResourcesMutex       sync.RWMutex
+	describeResourcesArgsForCall []struct {
+		arg1 context.Context
+	}
+	describeResourcesReturns struct {
+		result1 []models.ServiceInstanceDetails
+		result2 error
+	}
+	describeResourcesReturnsOnCall map[int]struct {
+		result1 []models.ServiceInstanceDetails
+		result2 error
+	}
+	ExistsStub        func(context.Context, models.ServiceInstanceDetails) (bool, error)
+	existsMutex       sync.RWMutex
+	existsArgsForCall []struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+	}
+	existsReturns struct {
+		result1 bool
+		result2 error
+	}
+	existsReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
+	PollBindingStub        func(context.Context, models.ServiceInstanceDetails, models.ServiceBindingCredentials) (bool, error)
+	pollBindingMutex       sync.RWMutex
+	pollBindingArgsForCall []struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+		arg3 models.ServiceBindingCredentials
+	}
+	pollBindingReturns struct {
+		result1 bool
+		result2 error
+	}
+	pollBindingReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
 	PollInstanceStub        func(context.Context, models.ServiceInstanceDetails) (bool, error)
 	pollInstanceMutex       sync.RWMutex
 	pollInstanceArgsForCall []struct {
@@ -104,6 +185,34 @@ type FakeServiceProvider struct {
 	provisionsAsyncReturnsOnCall map[int]struct {
 		result1 bool
 	}
+	RetireCredentialsStub        func(context.Context, models.ServiceInstanceDetails, map[string]interface{}) error
+	retireCredentialsMutex       sync.RWMutex
+	retireCredentialsArgsForCall []struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+		arg3 map[string]interface{}
+	}
+	retireCredentialsReturns struct {
+		result1 error
+	}
+	retireCredentialsReturnsOnCall map[int]struct {
+		result1 error
+	}
+	RotateCredentialsStub        func(context.Context, models.ServiceInstanceDetails, *models.ServiceBindingCredentials) (map[string]interface{}, error)
+	rotateCredentialsMutex       sync.RWMutex
+	rotateCredentialsArgsForCall []struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+		arg3 *models.ServiceBindingCredentials
+	}
+	rotateCredentialsReturns struct {
+		result1 map[string]interface{}
+		result2 error
+	}
+	rotateCredentialsReturnsOnCall map[int]struct {
+		result1 map[string]interface{}
+		result2 error
+	}
 	UnbindStub        func(context.Context, models.ServiceInstanceDetails, models.ServiceBindingCredentials) error
 	unbindMutex       sync.RWMutex
 	unbindArgsForCall []struct {
@@ -117,6 +226,34 @@ type FakeServiceProvider struct {
 	unbindReturnsOnCall map[int]struct {
 		result1 error
 	}
+	UpdateStub        func(context.Context, models.ServiceInstanceDetails, *varcontext.VarContext) (models.ServiceInstanceDetails, error)
+	updateMutex       sync.RWMutex
+	updateArgsForCall []struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+		arg3 *varcontext.VarContext
+	}
+	updateReturns struct {
+		result1 models.ServiceInstanceDetails
+		result2 error
+	}
+	updateReturnsOnCall map[int]struct {
+		result1 models.ServiceInstanceDetails
+		result2 error
+	}
+	UpdateBindingDetailsStub        func(context.Context, models.ServiceInstanceDetails, *models.ServiceBindingCredentials) error
+	updateBindingDetailsMutex       sync.RWMutex
+	updateBindingDetailsArgsForCall []struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+		arg3 *models.ServiceBindingCredentials
+	}
+	updateBindingDetailsReturns struct {
+		result1 error
+	}
+	updateBindingDetailsReturnsOnCall map[int]struct {
+		result1 error
+	}
 	UpdateInstanceDetailsStub        func(context.Context, *models.ServiceInstanceDetails) error
 	updateInstanceDetailsMutex       sync.RWMutex
 	updateInstanceDetailsArgsForCall []struct {
@@ -197,6 +334,123 @@ func (fake *FakeServiceProvider) BindReturnsOnCall(i int, result1 map[string]int
 	}{result1, result2}
 }
 
+func (fake *FakeServiceProvider) BindingExists(arg1 context.Context, arg2 models.ServiceInstanceDetails, arg3 models.ServiceBindingCredentials) (bool, error) {
+	fake.bindingExistsMutex.Lock()
+	ret, specificReturn := fake.bindingExistsReturnsOnCall[len(fake.bindingExistsArgsForCall)]
+	fake.bindingExistsArgsForCall = append(fake.bindingExistsArgsForCall, struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+		arg3 models.ServiceBindingCredentials
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("BindingExists", []interface{}{arg1, arg2, arg3})
+	fake.bindingExistsMutex.Unlock()
+	if fake.BindingExistsStub != nil {
+		return fake.BindingExistsStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.bindingExistsReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeServiceProvider) BindingExistsCallCount() int {
+	fake.bindingExistsMutex.RLock()
+	defer fake.bindingExistsMutex.RUnlock()
+	return len(fake.bindingExistsArgsForCall)
+}
+
+func (fake *FakeServiceProvider) BindingExistsCalls(stub func(context.Context, models.ServiceInstanceDetails, models.ServiceBindingCredentials) (bool, error)) {
+	fake.bindingExistsMutex.Lock()
+	defer fake.bindingExistsMutex.Unlock()
+	fake.BindingExistsStub = stub
+}
+
+func (fake *FakeServiceProvider) BindingExistsArgsForCall(i int) (context.Context, models.ServiceInstanceDetails, models.ServiceBindingCredentials) {
+	fake.bindingExistsMutex.RLock()
+	defer fake.bindingExistsMutex.RUnlock()
+	argsForCall := fake.bindingExistsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeServiceProvider) BindingExistsReturns(result1 bool, result2 error) {
+	fake.bindingExistsMutex.Lock()
+	defer fake.bindingExistsMutex.Unlock()
+	fake.BindingExistsStub = nil
+	fake.bindingExistsReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceProvider) BindingExistsReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.bindingExistsMutex.Lock()
+	defer fake.bindingExistsMutex.Unlock()
+	fake.BindingExistsStub = nil
+	if fake.bindingExistsReturnsOnCall == nil {
+		fake.bindingExistsReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.bindingExistsReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceProvider) BindsAsync() bool {
+	fake.bindsAsyncMutex.Lock()
+	ret, specificReturn := fake.bindsAsyncReturnsOnCall[len(fake.bindsAsyncArgsForCall)]
+	fake.bindsAsyncArgsForCall = append(fake.bindsAsyncArgsForCall, struct {
+	}{})
+	fake.recordInvocation("BindsAsync", []interface{}{})
+	fake.bindsAsyncMutex.Unlock()
+	if fake.BindsAsyncStub != nil {
+		return fake.BindsAsyncStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.bindsAsyncReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeServiceProvider) BindsAsyncCallCount() int {
+	fake.bindsAsyncMutex.RLock()
+	defer fake.bindsAsyncMutex.RUnlock()
+	return len(fake.bindsAsyncArgsForCall)
+}
+
+func (fake *FakeServiceProvider) BindsAsyncCalls(stub func() bool) {
+	fake.bindsAsyncMutex.Lock()
+	defer fake.bindsAsyncMutex.Unlock()
+	fake.BindsAsyncStub = stub
+}
+
+func (fake *FakeServiceProvider) BindsAsyncReturns(result1 bool) {
+	fake.bindsAsyncMutex.Lock()
+	defer fake.bindsAsyncMutex.Unlock()
+	fake.BindsAsyncStub = nil
+	fake.bindsAsyncReturns = struct {
+		result1 bool
+	}{result1}
+}
+
+func (fake *FakeServiceProvider) BindsAsyncReturnsOnCall(i int, result1 bool) {
+	fake.bindsAsyncMutex.Lock()
+	defer fake.bindsAsyncMutex.Unlock()
+	fake.BindsAsyncStub = nil
+	if fake.bindsAsyncReturnsOnCall == nil {
+		fake.bindsAsyncReturnsOnCall = make(map[int]struct {
+			result1 bool
+		})
+	}
+	fake.bindsAsyncReturnsOnCall[i] = struct {
+		result1 bool
+	}{result1}
+}
+
 func (fake *FakeServiceProvider) BuildInstanceCredentials(arg1 context.Context, arg2 models.ServiceBindingCredentials, arg3 models.ServiceInstanceDetails) (map[string]interface{}, error) {
 	fake.buildInstanceCredentialsMutex.Lock()
 	ret, specificReturn := fake.buildInstanceCredentialsReturnsOnCall[len(fake.buildInstanceCredentialsArgsForCall)]
@@ -379,6 +633,262 @@ func (fake *FakeServiceProvider) DeprovisionsAsyncReturnsOnCall(i int, result1 b
 	}{result1}
 }
 
+func (fake *FakeServiceProvider) DescribeOperation(arg1 context.Context, arg2 models.ServiceInstanceDetails) (string, error) {
+	fake.describeOperationMutex.Lock()
+	ret, specificReturn := fake.describeOperationReturnsOnCall[len(fake.describeOperationArgsForCall)]
+	fake.describeOperationArgsForCall = append(fake.describeOperationArgsForCall, struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+	}{arg1, arg2})
+	fake.recordInvocation("DescribeOperation", []interface{}{arg1, arg2})
+	fake.describeOperationMutex.Unlock()
+	if fake.DescribeOperationStub != nil {
+		return fake.DescribeOperationStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.describeOperationReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeServiceProvider) DescribeOperationCallCount() int {
+	fake.describeOperationMutex.RLock()
+	defer fake.describeOperationMutex.RUnlock()
+	return len(fake.describeOperationArgsForCall)
+}
+
+func (fake *FakeServiceProvider) DescribeOperationCalls(stub func(context.Context, models.ServiceInstanceDetails) (string, error)) {
+	fake.describeOperationMutex.Lock()
+	defer fake.describeOperationMutex.Unlock()
+	fake.DescribeOperationStub = stub
+}
+
+func (fake *FakeServiceProvider) DescribeOperationArgsForCall(i int) (context.Context, models.ServiceInstanceDetails) {
+	fake.describeOperationMutex.RLock()
+	defer fake.describeOperationMutex.RUnlock()
+	argsForCall := fake.describeOperationArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeServiceProvider) DescribeOperationReturns(result1 string, result2 error) {
+	fake.describeOperationMutex.Lock()
+	defer fake.describeOperationMutex.Unlock()
+	fake.DescribeOperationStub = nil
+	fake.describeOperationReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceProvider) DescribeOperationReturnsOnCall(i int, result1 string, result2 error) {
+	fake.describeOperationMutex.Lock()
+	defer fake.describeOperationMutex.Unlock()
+	fake.DescribeOperationStub = nil
+	if fake.describeOperationReturnsOnCall == nil {
+		fake.describeOperationReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.describeOperationReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceProvider) DescribeResources(arg1 context.Context) ([]models.ServiceInstanceDetails, error) {
+	fake.describeResourcesMutex.Lock()
+	ret, specificReturn := fake.describeResourcesReturnsOnCall[len(fake.describeResourcesArgsForCall)]
+	fake.describeResourcesArgsForCall = append(fake.describeResourcesArgsForCall, struct {
+		arg1 context.Context
+	}{arg1})
+	fake.recordInvocation("DescribeResources", []interface{}{arg1})
+	fake.describeResourcesMutex.Unlock()
+	if fake.DescribeResourcesStub != nil {
+		return fake.DescribeResourcesStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.describeResourcesReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeServiceProvider) DescribeResourcesCallCount() int {
+	fake.describeResourcesMutex.RLock()
+	defer fake.describeResourcesMutex.RUnlock()
+	return len(fake.describeResourcesArgsForCall)
+}
+
+func (fake *FakeServiceProvider) DescribeResourcesCalls(stub func(context.Context) ([]models.ServiceInstanceDetails, error)) {
+	fake.describeResourcesMutex.Lock()
+	defer fake.describeResourcesMutex.Unlock()
+	fake.DescribeResourcesStub = stub
+}
+
+func (fake *FakeServiceProvider) DescribeResourcesArgsForCall(i int) context.Context {
+	fake.describeResourcesMutex.RLock()
+	defer fake.describeResourcesMutex.RUnlock()
+	argsForCall := fake.describeResourcesArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeServiceProvider) DescribeResourcesReturns(result1 []models.ServiceInstanceDetails, result2 error) {
+	fake.describeResourcesMutex.Lock()
+	defer fake.describeResourcesMutex.Unlock()
+	fake.DescribeResourcesStub = nil
+	fake.describeResourcesReturns = struct {
+		result1 []models.ServiceInstanceDetails
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceProvider) DescribeResourcesReturnsOnCall(i int, result1 []models.ServiceInstanceDetails, result2 error) {
+	fake.describeResourcesMutex.Lock()
+	defer fake.describeResourcesMutex.Unlock()
+	fake.DescribeResourcesStub = nil
+	if fake.describeResourcesReturnsOnCall == nil {
+		fake.describeResourcesReturnsOnCall = make(map[int]struct {
+			result1 []models.ServiceInstanceDetails
+			result2 error
+		})
+	}
+	fake.describeResourcesReturnsOnCall[i] = struct {
+		result1 []models.ServiceInstanceDetails
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceProvider) Exists(arg1 context.Context, arg2 models.ServiceInstanceDetails) (bool, error) {
+	fake.existsMutex.Lock()
+	ret, specificReturn := fake.existsReturnsOnCall[len(fake.existsArgsForCall)]
+	fake.existsArgsForCall = append(fake.existsArgsForCall, struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+	}{arg1, arg2})
+	fake.recordInvocation("Exists", []interface{}{arg1, arg2})
+	fake.existsMutex.Unlock()
+	if fake.ExistsStub != nil {
+		return fake.ExistsStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.existsReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeServiceProvider) ExistsCallCount() int {
+	fake.existsMutex.RLock()
+	defer fake.existsMutex.RUnlock()
+	return len(fake.existsArgsForCall)
+}
+
+func (fake *FakeServiceProvider) ExistsCalls(stub func(context.Context, models.ServiceInstanceDetails) (bool, error)) {
+	fake.existsMutex.Lock()
+	defer fake.existsMutex.Unlock()
+	fake.ExistsStub = stub
+}
+
+func (fake *FakeServiceProvider) ExistsArgsForCall(i int) (context.Context, models.ServiceInstanceDetails) {
+	fake.existsMutex.RLock()
+	defer fake.existsMutex.RUnlock()
+	argsForCall := fake.existsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeServiceProvider) ExistsReturns(result1 bool, result2 error) {
+	fake.existsMutex.Lock()
+	defer fake.existsMutex.Unlock()
+	fake.ExistsStub = nil
+	fake.existsReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceProvider) ExistsReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.existsMutex.Lock()
+	defer fake.existsMutex.Unlock()
+	fake.ExistsStub = nil
+	if fake.existsReturnsOnCall == nil {
+		fake.existsReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.existsReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceProvider) PollBinding(arg1 context.Context, arg2 models.ServiceInstanceDetails, arg3 models.ServiceBindingCredentials) (bool, error) {
+	fake.pollBindingMutex.Lock()
+	ret, specificReturn := fake.pollBindingReturnsOnCall[len(fake.pollBindingArgsForCall)]
+	fake.pollBindingArgsForCall = append(fake.pollBindingArgsForCall, struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+		arg3 models.ServiceBindingCredentials
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("PollBinding", []interface{}{arg1, arg2, arg3})
+	fake.pollBindingMutex.Unlock()
+	if fake.PollBindingStub != nil {
+		return fake.PollBindingStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.pollBindingReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeServiceProvider) PollBindingCallCount() int {
+	fake.pollBindingMutex.RLock()
+	defer fake.pollBindingMutex.RUnlock()
+	return len(fake.pollBindingArgsForCall)
+}
+
+func (fake *FakeServiceProvider) PollBindingCalls(stub func(context.Context, models.ServiceInstanceDetails, models.ServiceBindingCredentials) (bool, error)) {
+	fake.pollBindingMutex.Lock()
+	defer fake.pollBindingMutex.Unlock()
+	fake.PollBindingStub = stub
+}
+
+func (fake *FakeServiceProvider) PollBindingArgsForCall(i int) (context.Context, models.ServiceInstanceDetails, models.ServiceBindingCredentials) {
+	fake.pollBindingMutex.RLock()
+	defer fake.pollBindingMutex.RUnlock()
+	argsForCall := fake.pollBindingArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeServiceProvider) PollBindingReturns(result1 bool, result2 error) {
+	fake.pollBindingMutex.Lock()
+	defer fake.pollBindingMutex.Unlock()
+	fake.PollBindingStub = nil
+	fake.pollBindingReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceProvider) PollBindingReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.pollBindingMutex.Lock()
+	defer fake.pollBindingMutex.Unlock()
+	fake.PollBindingStub = nil
+	if fake.pollBindingReturnsOnCall == nil {
+		fake.pollBindingReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.pollBindingReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeServiceProvider) PollInstance(arg1 context.Context, arg2 models.ServiceInstanceDetails) (bool, error) {
 	fake.pollInstanceMutex.Lock()
 	ret, specificReturn := fake.pollInstanceReturnsOnCall[len(fake.pollInstanceArgsForCall)]
@@ -559,6 +1069,133 @@ func (fake *FakeServiceProvider) ProvisionsAsyncReturnsOnCall(i int, result1 boo
 	}{result1}
 }
 
+func (fake *FakeServiceProvider) RetireCredentials(arg1 context.Context, arg2 models.ServiceInstanceDetails, arg3 map[string]interface{}) error {
+	fake.retireCredentialsMutex.Lock()
+	ret, specificReturn := fake.retireCredentialsReturnsOnCall[len(fake.retireCredentialsArgsForCall)]
+	fake.retireCredentialsArgsForCall = append(fake.retireCredentialsArgsForCall, struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+		arg3 map[string]interface{}
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("RetireCredentials", []interface{}{arg1, arg2, arg3})
+	fake.retireCredentialsMutex.Unlock()
+	if fake.RetireCredentialsStub != nil {
+		return fake.RetireCredentialsStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.retireCredentialsReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeServiceProvider) RetireCredentialsCallCount() int {
+	fake.retireCredentialsMutex.RLock()
+	defer fake.retireCredentialsMutex.RUnlock()
+	return len(fake.retireCredentialsArgsForCall)
+}
+
+func (fake *FakeServiceProvider) RetireCredentialsCalls(stub func(context.Context, models.ServiceInstanceDetails, map[string]interface{}) error) {
+	fake.retireCredentialsMutex.Lock()
+	defer fake.retireCredentialsMutex.Unlock()
+	fake.RetireCredentialsStub = stub
+}
+
+func (fake *FakeServiceProvider) RetireCredentialsArgsForCall(i int) (context.Context, models.ServiceInstanceDetails, map[string]interface{}) {
+	fake.retireCredentialsMutex.RLock()
+	defer fake.retireCredentialsMutex.RUnlock()
+	argsForCall := fake.retireCredentialsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeServiceProvider) RetireCredentialsReturns(result1 error) {
+	fake.retireCredentialsMutex.Lock()
+	defer fake.retireCredentialsMutex.Unlock()
+	fake.RetireCredentialsStub = nil
+	fake.retireCredentialsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeServiceProvider) RetireCredentialsReturnsOnCall(i int, result1 error) {
+	fake.retireCredentialsMutex.Lock()
+	defer fake.retireCredentialsMutex.Unlock()
+	fake.RetireCredentialsStub = nil
+	if fake.retireCredentialsReturnsOnCall == nil {
+		fake.retireCredentialsReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.retireCredentialsReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeServiceProvider) RotateCredentials(arg1 context.Context, arg2 models.ServiceInstanceDetails, arg3 *models.ServiceBindingCredentials) (map[string]interface{}, error) {
+	fake.rotateCredentialsMutex.Lock()
+	ret, specificReturn := fake.rotateCredentialsReturnsOnCall[len(fake.rotateCredentialsArgsForCall)]
+	fake.rotateCredentialsArgsForCall = append(fake.rotateCredentialsArgsForCall, struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+		arg3 *models.ServiceBindingCredentials
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("RotateCredentials", []interface{}{arg1, arg2, arg3})
+	fake.rotateCredentialsMutex.Unlock()
+	if fake.RotateCredentialsStub != nil {
+		return fake.RotateCredentialsStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.rotateCredentialsReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeServiceProvider) RotateCredentialsCallCount() int {
+	fake.rotateCredentialsMutex.RLock()
+	defer fake.rotateCredentialsMutex.RUnlock()
+	return len(fake.rotateCredentialsArgsForCall)
+}
+
+func (fake *FakeServiceProvider) RotateCredentialsCalls(stub func(context.Context, models.ServiceInstanceDetails, *models.ServiceBindingCredentials) (map[string]interface{}, error)) {
+	fake.rotateCredentialsMutex.Lock()
+	defer fake.rotateCredentialsMutex.Unlock()
+	fake.RotateCredentialsStub = stub
+}
+
+func (fake *FakeServiceProvider) RotateCredentialsArgsForCall(i int) (context.Context, models.ServiceInstanceDetails, *models.ServiceBindingCredentials) {
+	fake.rotateCredentialsMutex.RLock()
+	defer fake.rotateCredentialsMutex.RUnlock()
+	argsForCall := fake.rotateCredentialsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeServiceProvider) RotateCredentialsReturns(result1 map[string]interface{}, result2 error) {
+	fake.rotateCredentialsMutex.Lock()
+	defer fake.rotateCredentialsMutex.Unlock()
+	fake.RotateCredentialsStub = nil
+	fake.rotateCredentialsReturns = struct {
+		result1 map[string]interface{}
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceProvider) RotateCredentialsReturnsOnCall(i int, result1 map[string]interface{}, result2 error) {
+	fake.rotateCredentialsMutex.Lock()
+	defer fake.rotateCredentialsMutex.Unlock()
+	fake.RotateCredentialsStub = nil
+	if fake.rotateCredentialsReturnsOnCall == nil {
+		fake.rotateCredentialsReturnsOnCall = make(map[int]struct {
+			result1 map[string]interface{}
+			result2 error
+		})
+	}
+	fake.rotateCredentialsReturnsOnCall[i] = struct {
+		result1 map[string]interface{}
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeServiceProvider) Unbind(arg1 context.Context, arg2 models.ServiceInstanceDetails, arg3 models.ServiceBindingCredentials) error {
 	fake.unbindMutex.Lock()
 	ret, specificReturn := fake.unbindReturnsOnCall[len(fake.unbindArgsForCall)]
@@ -621,6 +1258,133 @@ func (fake *FakeServiceProvider) UnbindReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeServiceProvider) Update(arg1 context.Context, arg2 models.ServiceInstanceDetails, arg3 *varcontext.VarContext) (models.ServiceInstanceDetails, error) {
+	fake.updateMutex.Lock()
+	ret, specificReturn := fake.updateReturnsOnCall[len(fake.updateArgsForCall)]
+	fake.updateArgsForCall = append(fake.updateArgsForCall, struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+		arg3 *varcontext.VarContext
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("Update", []interface{}{arg1, arg2, arg3})
+	fake.updateMutex.Unlock()
+	if fake.UpdateStub != nil {
+		return fake.UpdateStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.updateReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeServiceProvider) UpdateCallCount() int {
+	fake.updateMutex.RLock()
+	defer fake.updateMutex.RUnlock()
+	return len(fake.updateArgsForCall)
+}
+
+func (fake *FakeServiceProvider) UpdateCalls(stub func(context.Context, models.ServiceInstanceDetails, *varcontext.VarContext) (models.ServiceInstanceDetails, error)) {
+	fake.updateMutex.Lock()
+	defer fake.updateMutex.Unlock()
+	fake.UpdateStub = stub
+}
+
+func (fake *FakeServiceProvider) UpdateArgsForCall(i int) (context.Context, models.ServiceInstanceDetails, *varcontext.VarContext) {
+	fake.updateMutex.RLock()
+	defer fake.updateMutex.RUnlock()
+	argsForCall := fake.updateArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeServiceProvider) UpdateReturns(result1 models.ServiceInstanceDetails, result2 error) {
+	fake.updateMutex.Lock()
+	defer fake.updateMutex.Unlock()
+	fake.UpdateStub = nil
+	fake.updateReturns = struct {
+		result1 models.ServiceInstanceDetails
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceProvider) UpdateReturnsOnCall(i int, result1 models.ServiceInstanceDetails, result2 error) {
+	fake.updateMutex.Lock()
+	defer fake.updateMutex.Unlock()
+	fake.UpdateStub = nil
+	if fake.updateReturnsOnCall == nil {
+		fake.updateReturnsOnCall = make(map[int]struct {
+			result1 models.ServiceInstanceDetails
+			result2 error
+		})
+	}
+	fake.updateReturnsOnCall[i] = struct {
+		result1 models.ServiceInstanceDetails
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeServiceProvider) UpdateBindingDetails(arg1 context.Context, arg2 models.ServiceInstanceDetails, arg3 *models.ServiceBindingCredentials) error {
+	fake.updateBindingDetailsMutex.Lock()
+	ret, specificReturn := fake.updateBindingDetailsReturnsOnCall[len(fake.updateBindingDetailsArgsForCall)]
+	fake.updateBindingDetailsArgsForCall = append(fake.updateBindingDetailsArgsForCall, struct {
+		arg1 context.Context
+		arg2 models.ServiceInstanceDetails
+		arg3 *models.ServiceBindingCredentials
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("UpdateBindingDetails", []interface{}{arg1, arg2, arg3})
+	fake.updateBindingDetailsMutex.Unlock()
+	if fake.UpdateBindingDetailsStub != nil {
+		return fake.UpdateBindingDetailsStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.updateBindingDetailsReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeServiceProvider) UpdateBindingDetailsCallCount() int {
+	fake.updateBindingDetailsMutex.RLock()
+	defer fake.updateBindingDetailsMutex.RUnlock()
+	return len(fake.updateBindingDetailsArgsForCall)
+}
+
+func (fake *FakeServiceProvider) UpdateBindingDetailsCalls(stub func(context.Context, models.ServiceInstanceDetails, *models.ServiceBindingCredentials) error) {
+	fake.updateBindingDetailsMutex.Lock()
+	defer fake.updateBindingDetailsMutex.Unlock()
+	fake.UpdateBindingDetailsStub = stub
+}
+
+func (fake *FakeServiceProvider) UpdateBindingDetailsArgsForCall(i int) (context.Context, models.ServiceInstanceDetails, *models.ServiceBindingCredentials) {
+	fake.updateBindingDetailsMutex.RLock()
+	defer fake.updateBindingDetailsMutex.RUnlock()
+	argsForCall := fake.updateBindingDetailsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeServiceProvider) UpdateBindingDetailsReturns(result1 error) {
+	fake.updateBindingDetailsMutex.Lock()
+	defer fake.updateBindingDetailsMutex.Unlock()
+	fake.UpdateBindingDetailsStub = nil
+	fake.updateBindingDetailsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeServiceProvider) UpdateBindingDetailsReturnsOnCall(i int, result1 error) {
+	fake.updateBindingDetailsMutex.Lock()
+	defer fake.updateBindingDetailsMutex.Unlock()
+	fake.UpdateBindingDetailsStub = nil
+	if fake.updateBindingDetailsReturnsOnCall == nil {
+		fake.updateBindingDetailsReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.updateBindingDetailsReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeServiceProvider) UpdateInstanceDetails(arg1 context.Context, arg2 *models.ServiceInstanceDetails) error {
 	fake.updateInstanceDetailsMutex.Lock()
 	ret, specificReturn := fake.updateInstanceDetailsReturnsOnCall[len(fake.updateInstanceDetailsArgsForCall)]
@@ -687,20 +1451,40 @@ func (fake *FakeServiceProvider) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.bindMutex.RLock()
 	defer fake.bindMutex.RUnlock()
+	fake.bindingExistsMutex.RLock()
+	defer fake.bindingExistsMutex.RUnlock()
+	fake.bindsAsyncMutex.RLock()
+	defer fake.bindsAsyncMutex.RUnlock()
 	fake.buildInstanceCredentialsMutex.RLock()
 	defer fake.buildInstanceCredentialsMutex.RUnlock()
 	fake.deprovisionMutex.RLock()
 	defer fake.deprovisionMutex.RUnlock()
 	fake.deprovisionsAsyncMutex.RLock()
 	defer fake.deprovisionsAsyncMutex.RUnlock()
+	fake.describeOperationMutex.RLock()
+	defer fake.describeOperationMutex.RUnlock()
+	fake.describeResourcesMutex.RLock()
+	defer fake.describeResourcesMutex.RUnlock()
+	fake.existsMutex.RLock()
+	defer fake.existsMutex.RUnlock()
+	fake.pollBindingMutex.RLock()
+	defer fake.pollBindingMutex.RUnlock()
 	fake.pollInstanceMutex.RLock()
 	defer fake.pollInstanceMutex.RUnlock()
 	fake.provisionMutex.RLock()
 	defer fake.provisionMutex.RUnlock()
 	fake.provisionsAsyncMutex.RLock()
 	defer fake.provisionsAsyncMutex.RUnlock()
+	fake.retireCredentialsMutex.RLock()
+	defer fake.retireCredentialsMutex.RUnlock()
+	fake.rotateCredentialsMutex.RLock()
+	defer fake.rotateCredentialsMutex.RUnlock()
 	fake.unbindMutex.RLock()
 	defer fake.unbindMutex.RUnlock()
+	fake.updateMutex.RLock()
+	defer fake.updateMutex.RUnlock()
+	fake.updateBindingDetailsMutex.RLock()
+	defer fake.updateBindingDetailsMutex.RUnlock()
 	fake.updateInstanceDetailsMutex.RLock()
 	defer fake.updateInstanceDetailsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
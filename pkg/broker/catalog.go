@@ -14,7 +14,12 @@
 
 package broker
 
-import "github.com/pivotal-cf/brokerapi"
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pivotal-cf/brokerapi"
+)
 
 // Service overrides the canonical Service Broker service type using a custom
 // type for Plans, everything else is the same.
@@ -46,6 +51,65 @@ type ServicePlan struct {
 	ServiceProperties map[string]string `json:"service_properties"`
 }
 
+// UnmarshalYAML decodes the plan using the same field names as its JSON form so
+// plans are written the same way in YAML service definitions as they are in
+// the catalog and user-defined plans.
+func (sp *ServicePlan) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw interface{}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+
+	converted, err := yamlToJsonCompatible(raw)
+	if err != nil {
+		return err
+	}
+
+	asJson, err := json.Marshal(converted)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(asJson, sp)
+}
+
+// yamlToJsonCompatible converts the map[interface{}]interface{} objects the
+// YAML decoder produces into map[string]interface{} objects that can be
+// marshaled as JSON.
+func yamlToJsonCompatible(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{})
+		for key, val := range v {
+			strKey, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("object keys must be strings, got %v", key)
+			}
+
+			converted, err := yamlToJsonCompatible(val)
+			if err != nil {
+				return nil, err
+			}
+			out[strKey] = converted
+		}
+		return out, nil
+
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			converted, err := yamlToJsonCompatible(val)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = converted
+		}
+		return out, nil
+
+	default:
+		return value, nil
+	}
+}
+
 // GetServiceProperties gets the plan settings variables as a string->interface map.
 func (sp *ServicePlan) GetServiceProperties() map[string]interface{} {
 	props := make(map[string]interface{})
@@ -0,0 +1,70 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pivotal-cf/brokerapi"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestServicePlan_UnmarshalYAML(t *testing.T) {
+	cases := map[string]struct {
+		Yaml        string
+		Expected    ServicePlan
+		ErrExpected bool
+	}{
+		"json field names": {
+			Yaml: `
+id: 8b5ab1a5-5a5e-4b33-bd0c-8ca1a8d4b9a9
+name: standard
+description: Standard storage class.
+metadata:
+  displayName: Standard
+service_properties:
+  storage_class: STANDARD
+`,
+			Expected: ServicePlan{
+				ServicePlan: brokerapi.ServicePlan{
+					ID:          "8b5ab1a5-5a5e-4b33-bd0c-8ca1a8d4b9a9",
+					Name:        "standard",
+					Description: "Standard storage class.",
+					Metadata:    &brokerapi.ServicePlanMetadata{DisplayName: "Standard"},
+				},
+				ServiceProperties: map[string]string{"storage_class": "STANDARD"},
+			},
+		},
+		"non-string keys": {
+			Yaml:        `{1: one}`,
+			ErrExpected: true,
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			actual := ServicePlan{}
+			err := yaml.Unmarshal([]byte(tc.Yaml), &actual)
+			if (err != nil) != tc.ErrExpected {
+				t.Fatalf("Expected error: %v, got %v", tc.ErrExpected, err)
+			}
+
+			if !tc.ErrExpected && !reflect.DeepEqual(actual, tc.Expected) {
+				t.Errorf("Expected %#v, got %#v", tc.Expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// CloudFoundryPlatform identifies originating identities sent by Cloud
+	// Foundry, their value holds the `user_id` of the user.
+	CloudFoundryPlatform = "cloudfoundry"
+
+	// KubernetesPlatform identifies originating identities sent by Kubernetes,
+	// their value holds the `username`, `uid`, `groups` and `extra` fields of
+	// the user.
+	KubernetesPlatform = "kubernetes"
+)
+
+type contextKey string
+
+const originatingIdentityKey contextKey = "originating-identity"
+
+// OriginatingIdentity is the decoded X-Broker-API-Originating-Identity header
+// which identifies the platform user that made a request.
+type OriginatingIdentity struct {
+	Platform string                 `json:"platform"`
+	Value    map[string]interface{} `json:"value"`
+}
+
+// ParseOriginatingIdentity decodes an X-Broker-API-Originating-Identity
+// header of the form `<platform> <base64 encoded JSON object>`.
+func ParseOriginatingIdentity(header string) (*OriginatingIdentity, error) {
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, fmt.Errorf("originating identity must be of the form \"<platform> <value>\"")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode the originating identity value: %v", err)
+	}
+
+	identity := &OriginatingIdentity{Platform: parts[0]}
+	if err := json.Unmarshal(decoded, &identity.Value); err != nil {
+		return nil, fmt.Errorf("couldn't parse the originating identity value: %v", err)
+	}
+
+	return identity, nil
+}
+
+// User gets the platform's ID for the user that made the request or an empty
+// string if the identity is nil or from an unknown platform.
+func (id *OriginatingIdentity) User() string {
+	if id == nil {
+		return ""
+	}
+
+	var user interface{}
+	switch id.Platform {
+	case CloudFoundryPlatform:
+		user = id.Value["user_id"]
+	case KubernetesPlatform:
+		user = id.Value["username"]
+	}
+
+	userString, _ := user.(string)
+	return userString
+}
+
+// Serialize converts the identity to JSON for audit records. Nil identities
+// serialize to an empty string.
+func (id *OriginatingIdentity) Serialize() string {
+	if id == nil {
+		return ""
+	}
+
+	out, err := json.Marshal(id)
+	if err != nil {
+		return ""
+	}
+
+	return string(out)
+}
+
+// WithOriginatingIdentity returns a copy of the context holding the identity
+// of the user that made the request.
+func WithOriginatingIdentity(ctx context.Context, identity *OriginatingIdentity) context.Context {
+	return context.WithValue(ctx, originatingIdentityKey, identity)
+}
+
+// OriginatingIdentityFromContext gets the identity stored in the context or
+// nil if the platform didn't send one.
+func OriginatingIdentityFromContext(ctx context.Context) *OriginatingIdentity {
+	identity, _ := ctx.Value(originatingIdentityKey).(*OriginatingIdentity)
+	return identity
+}
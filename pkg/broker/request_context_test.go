@@ -0,0 +1,86 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOriginatingIdentity(t *testing.T) {
+	cases := map[string]struct {
+		Header      string
+		Expected    *OriginatingIdentity
+		ExpectError bool
+	}{
+		"cloud foundry": {
+			// {"user_id":"683ea748-3092-4ff4-b656-39cacc4d5360"}
+			Header: "cloudfoundry eyJ1c2VyX2lkIjoiNjgzZWE3NDgtMzA5Mi00ZmY0LWI2NTYtMzljYWNjNGQ1MzYwIn0=",
+			Expected: &OriginatingIdentity{
+				Platform: CloudFoundryPlatform,
+				Value:    map[string]interface{}{"user_id": "683ea748-3092-4ff4-b656-39cacc4d5360"},
+			},
+		},
+		"kubernetes": {
+			// {"username":"duke","uid":"c2dde242-5ce4-11e7-988c-000c2946f14f","groups":["admin","dev"]}
+			Header: "kubernetes eyJ1c2VybmFtZSI6ImR1a2UiLCJ1aWQiOiJjMmRkZTI0Mi01Y2U0LTExZTctOTg4Yy0wMDBjMjk0NmYxNGYiLCJncm91cHMiOlsiYWRtaW4iLCJkZXYiXX0=",
+			Expected: &OriginatingIdentity{
+				Platform: KubernetesPlatform,
+				Value: map[string]interface{}{
+					"username": "duke",
+					"uid":      "c2dde242-5ce4-11e7-988c-000c2946f14f",
+					"groups":   []interface{}{"admin", "dev"},
+				},
+			},
+		},
+		"missing value":  {Header: "cloudfoundry", ExpectError: true},
+		"bad base64":     {Header: "cloudfoundry not-base64!", ExpectError: true},
+		"not an object":  {Header: "cloudfoundry WzFd", ExpectError: true},
+		"empty platform": {Header: " e30=", ExpectError: true},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			actual, err := ParseOriginatingIdentity(tc.Header)
+			if (err != nil) != tc.ExpectError {
+				t.Fatalf("Expected error? %t, got %v", tc.ExpectError, err)
+			}
+
+			if !reflect.DeepEqual(actual, tc.Expected) {
+				t.Errorf("Expected identity %v, got %v", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestOriginatingIdentity_User(t *testing.T) {
+	cases := map[string]struct {
+		Identity *OriginatingIdentity
+		Expected string
+	}{
+		"nil":           {Identity: nil, Expected: ""},
+		"cloud foundry": {Identity: &OriginatingIdentity{Platform: CloudFoundryPlatform, Value: map[string]interface{}{"user_id": "abc"}}, Expected: "abc"},
+		"kubernetes":    {Identity: &OriginatingIdentity{Platform: KubernetesPlatform, Value: map[string]interface{}{"username": "duke"}}, Expected: "duke"},
+		"wrong type":    {Identity: &OriginatingIdentity{Platform: CloudFoundryPlatform, Value: map[string]interface{}{"user_id": 42}}, Expected: ""},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			if actual := tc.Identity.User(); actual != tc.Expected {
+				t.Errorf("Expected user %q, got %q", tc.Expected, actual)
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@
 package broker
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -266,15 +267,21 @@ func (svc *ServiceDefinition) bindDefaults() []varcontext.DefaultVariable {
 // For example, to create a default database name based on a user-provided instance name.
 // Therefore, they get executed conditionally if a user-provided variable does not exist.
 // Computed variables get executed either unconditionally or conditionally for greater flexibility.
-func (svc *ServiceDefinition) ProvisionVariables(instanceId string, details brokerapi.ProvisionDetails, plan ServicePlan) (*varcontext.VarContext, error) {
+func (svc *ServiceDefinition) ProvisionVariables(ctx context.Context, instanceId string, details brokerapi.ProvisionDetails, plan ServicePlan) (*varcontext.VarContext, error) {
 	defaults := svc.provisionDefaults()
 
 	// The namespaces of these values roughly align with the OSB spec.
 	constants := map[string]interface{}{
-		"request.plan_id":        details.PlanID,
-		"request.service_id":     details.ServiceID,
-		"request.instance_id":    instanceId,
-		"request.default_labels": utils.ExtractDefaultLabels(instanceId, details),
+		"request.plan_id":           details.PlanID,
+		"request.service_id":        details.ServiceID,
+		"request.instance_id":       instanceId,
+		"request.organization_guid": details.OrganizationGUID,
+		"request.space_guid":        details.SpaceGUID,
+		"request.default_labels":    utils.ExtractDefaultLabels(instanceId, details),
+		"request.context":           utils.ExtractRequestContext(details.GetRawContext()),
+
+		// specified by the X-Broker-API-Originating-Identity header
+		"request.originating_user": OriginatingIdentityFromContext(ctx).User(),
 	}
 
 	return varcontext.Builder().
@@ -287,6 +294,68 @@ func (svc *ServiceDefinition) ProvisionVariables(instanceId string, details brok
 		Build()
 }
 
+// UpdateVariables gets the variable resolution context for an update request.
+// Variables are resolved in the same order as ProvisionVariables, but the user
+// defined variables are the parameters the instance was provisioned with
+// overlaid by the parameters supplied in the update request.
+//
+// The existing instance is exposed through the `instance.name` and
+// `instance.details` constants so services can reference values that were
+// generated at provision time rather than re-computing them.
+func (svc *ServiceDefinition) UpdateVariables(ctx context.Context, instance models.ServiceInstanceDetails, details brokerapi.UpdateDetails, provisionRequest models.ProvisionRequestDetails, plan ServicePlan) (*varcontext.VarContext, error) {
+	defaults := svc.provisionDefaults()
+
+	otherDetails := make(map[string]interface{})
+	if instance.OtherDetails != "" {
+		if err := json.Unmarshal(json.RawMessage(instance.OtherDetails), &otherDetails); err != nil {
+			return nil, err
+		}
+	}
+
+	// The org and space labels are computed from the original provision request
+	// so an update never moves the instance to a different org or space. The
+	// Kubernetes labels come from the context of the update request because
+	// the broker doesn't store them.
+	provisionDetails := brokerapi.ProvisionDetails{
+		ServiceID:        instance.ServiceId,
+		PlanID:           plan.ID,
+		OrganizationGUID: instance.OrganizationGuid,
+		SpaceGUID:        instance.SpaceGuid,
+		RawContext:       details.RawContext,
+	}
+	defaultLabels := utils.ExtractDefaultLabels(instance.ID, provisionDetails)
+	defaultLabels[utils.OrganizationGuidLabel] = utils.SanitizeLabelValue(instance.OrganizationGuid)
+	defaultLabels[utils.SpaceGuidLabel] = utils.SanitizeLabelValue(instance.SpaceGuid)
+
+	// The namespaces of these values roughly align with the OSB spec.
+	constants := map[string]interface{}{
+		"request.plan_id":           plan.ID,
+		"request.service_id":        instance.ServiceId,
+		"request.instance_id":       instance.ID,
+		"request.organization_guid": instance.OrganizationGuid,
+		"request.space_guid":        instance.SpaceGuid,
+		"request.default_labels":    defaultLabels,
+		"request.context":           utils.ExtractRequestContext(details.RawContext),
+
+		// specified by the X-Broker-API-Originating-Identity header
+		"request.originating_user": OriginatingIdentityFromContext(ctx).User(),
+
+		// specified by the existing instance
+		"instance.name":    instance.Name,
+		"instance.details": otherDetails,
+	}
+
+	return varcontext.Builder().
+		SetEvalConstants(constants).
+		MergeMap(svc.ProvisionDefaultOverrides()).
+		MergeJsonObject(json.RawMessage(provisionRequest.RequestDetails)).
+		MergeJsonObject(details.GetRawParameters()).
+		MergeDefaults(defaults).
+		MergeMap(plan.GetServiceProperties()).
+		MergeDefaults(svc.ProvisionComputedVariables).
+		Build()
+}
+
 // BindVariables gets the variable resolution context for a bind request.
 // Variables have a very specific resolution order, and this function populates the context to preserve that.
 // The variable resolution order is the following:
@@ -296,7 +365,7 @@ func (svc *ServiceDefinition) ProvisionVariables(instanceId string, details brok
 // 4. Operator default variables loaded from the environment.
 // 5. Default variables (in `bind_input_variables`).
 //
-func (svc *ServiceDefinition) BindVariables(instance models.ServiceInstanceDetails, bindingID string, details brokerapi.BindDetails) (*varcontext.VarContext, error) {
+func (svc *ServiceDefinition) BindVariables(ctx context.Context, instance models.ServiceInstanceDetails, bindingID string, details brokerapi.BindDetails) (*varcontext.VarContext, error) {
 	defaults := svc.bindDefaults()
 
 	otherDetails := make(map[string]interface{})
@@ -324,6 +393,10 @@ func (svc *ServiceDefinition) BindVariables(instance models.ServiceInstanceDetai
 		"request.plan_id":    instance.PlanId,
 		"request.service_id": instance.ServiceId,
 		"request.app_guid":   appGuid,
+		"request.context":    utils.ExtractRequestContext(details.RawContext),
+
+		// specified by the X-Broker-API-Originating-Identity header
+		"request.originating_user": OriginatingIdentityFromContext(ctx).User(),
 
 		// specified by the existing instance
 		"instance.name":    instance.Name,
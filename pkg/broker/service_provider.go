@@ -16,12 +16,17 @@ package broker
 
 import (
 	"context"
+	"errors"
 
 	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/varcontext"
 	"github.com/pivotal-cf/brokerapi"
 )
 
+// ErrCredentialRotationNotSupported is returned by service providers that
+// can't replace the credentials of a binding.
+var ErrCredentialRotationNotSupported = errors.New("credential rotation is not supported by this service")
+
 //go:generate counterfeiter . ServiceProvider
 
 // ServiceProvider performs the actual provisoning/deprovisioning part of a service broker request.
@@ -40,13 +45,77 @@ type ServiceProvider interface {
 	BuildInstanceCredentials(ctx context.Context, bindRecord models.ServiceBindingCredentials, instance models.ServiceInstanceDetails) (map[string]interface{}, error)
 	// Unbind deprovisions the resources created with Bind.
 	Unbind(ctx context.Context, instance models.ServiceInstanceDetails, details models.ServiceBindingCredentials) error
+	// Update makes changes to an existing instance of the service, for example
+	// resizing it or moving it to a different plan.
+	// The provider receives the current record of the instance and SHOULD return
+	// a modified copy of it. If the update is asynchronous, the returned
+	// details must contain an OperationId that can be passed to PollInstance.
+	// Providers that provision asynchronously are expected to update
+	// asynchronously as well.
+	// This function is optional; return brokerapi.ErrPlanChangeNotSupported
+	// if you choose not to implement it.
+	Update(ctx context.Context, instance models.ServiceInstanceDetails, updateContext *varcontext.VarContext) (models.ServiceInstanceDetails, error)
 	// Deprovision deprovisions the service.
 	// If the deprovision is asynchronous (results in a long-running job), then operationId is returned.
 	// If no error and no operationId are returned, then the deprovision is expected to have been completed successfully.
 	Deprovision(ctx context.Context, instance models.ServiceInstanceDetails, details brokerapi.DeprovisionDetails) (operationId *string, err error)
 	PollInstance(ctx context.Context, instance models.ServiceInstanceDetails) (bool, error)
+	// DescribeOperation gets a human readable description of the pending
+	// operation on the instance that's shown to users while they wait.
+	// This function is optional; return an empty string and a nil error if you
+	// choose not to implement it.
+	DescribeOperation(ctx context.Context, instance models.ServiceInstanceDetails) (string, error)
 	ProvisionsAsync() bool
 	DeprovisionsAsync() bool
+	// BindsAsync indicates if Bind and Unbind start long-running operations
+	// that are checked with PollBinding. Bind then returns the details needed
+	// to track and delete the binding, and the credentials are filled in by
+	// UpdateBindingDetails once the operation is done.
+	// This function is optional; return false if you choose not to implement it.
+	BindsAsync() bool
+	// PollBinding checks if the pending bind or unbind operation, given by the
+	// OperationType of the binding, is done.
+	// This function is optional; return true and brokerapi.ErrAsyncRequired if
+	// you choose not to implement it.
+	PollBinding(ctx context.Context, instance models.ServiceInstanceDetails, binding models.ServiceBindingCredentials) (bool, error)
+	// UpdateBindingDetails updates the OtherDetails of the binding with its
+	// credentials after an asynchronous bind completes.
+	// This function is optional; return a nil error if you choose not to
+	// implement it.
+	UpdateBindingDetails(ctx context.Context, instance models.ServiceInstanceDetails, binding *models.ServiceBindingCredentials) error
+
+	// Exists checks if the GCP resources of the instance still exist. It's used
+	// to find instance records whose resources were deleted outside of the broker.
+	// This function is optional; return true and a nil error if you choose not
+	// to implement it.
+	Exists(ctx context.Context, instance models.ServiceInstanceDetails) (bool, error)
+	// BindingExists checks if the GCP resources of the binding still exist.
+	// This function is optional; return true and a nil error if you choose not
+	// to implement it.
+	BindingExists(ctx context.Context, instance models.ServiceInstanceDetails, binding models.ServiceBindingCredentials) (bool, error)
+	// DescribeResources lists the resources of the service in the project that
+	// have the default instance ID label, so resources without an instance
+	// record can be found. Each resource is described by the instance details
+	// Provision would have returned for it, with the ID set to the value of the
+	// label, so it can be passed to Deprovision. CreatedAt is set to when the
+	// resource was created; resources of unknown age are never deprovisioned.
+	// This function is optional; return a nil list and a nil error if you
+	// choose not to implement it.
+	DescribeResources(ctx context.Context) ([]models.ServiceInstanceDetails, error)
+
+	// RotateCredentials creates new credentials for the binding and replaces
+	// the ones in its OtherDetails. The old credentials must keep working so
+	// applications have a grace period to pick up the new ones; the returned
+	// details identify them so they can be passed to RetireCredentials later.
+	// This function is optional; return a nil map and
+	// ErrCredentialRotationNotSupported if you choose not to implement it.
+	RotateCredentials(ctx context.Context, instance models.ServiceInstanceDetails, binding *models.ServiceBindingCredentials) (map[string]interface{}, error)
+	// RetireCredentials deletes credentials replaced by RotateCredentials.
+	// Credentials that no longer exist, for example because the binding was
+	// deleted, should be ignored.
+	// This function is optional; return a nil error if you choose not to
+	// implement it.
+	RetireCredentials(ctx context.Context, instance models.ServiceInstanceDetails, retired map[string]interface{}) error
 
 	// UpdateInstanceDetails updates the ServiceInstanceDetails with the most recent state from GCP.
 	// This function is optional, but will be called after async provisions, updates, and possibly
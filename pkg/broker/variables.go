@@ -31,6 +31,7 @@ const (
 	JsonTypeNumeric JsonType = "number"
 	JsonTypeInteger JsonType = "integer"
 	JsonTypeBoolean JsonType = "boolean"
+	JsonTypeArray   JsonType = "array"
 )
 
 type JsonType string
@@ -54,6 +55,10 @@ type BrokerVariable struct {
 	// associated values.
 	// http://json-schema.org/latest/json-schema-validation.html
 	Constraints map[string]interface{} `yaml:"constraints,omitempty"`
+	// ForcesReplacement is true if changing the value of this field after the
+	// resource is created would require destroying and re-creating it.
+	// Fields that force replacement can't be changed in update requests.
+	ForcesReplacement bool `yaml:"forces_replacement,omitempty"`
 }
 
 // ToSchema converts the BrokerVariable into the value part of a JSON Schema.
@@ -129,6 +134,39 @@ func ValidateVariables(parameters map[string]interface{}, variables []BrokerVari
 	return allErrors
 }
 
+// ValidateUpdatableVariables checks that updated parameters don't change the
+// value of any variable that forces replacement of the resource.
+// Variables missing from the original parameters are compared against their
+// default.
+func ValidateUpdatableVariables(original, updated map[string]interface{}, variables []BrokerVariable) error {
+	var changed []string
+	for _, variable := range variables {
+		if !variable.ForcesReplacement {
+			continue
+		}
+
+		newValue, ok := updated[variable.FieldName]
+		if !ok {
+			continue
+		}
+
+		oldValue, ok := original[variable.FieldName]
+		if !ok {
+			oldValue = variable.Default
+		}
+
+		if fmt.Sprintf("%v", oldValue) != fmt.Sprintf("%v", newValue) {
+			changed = append(changed, variable.FieldName)
+		}
+	}
+
+	if len(changed) > 0 {
+		return fmt.Errorf("the fields %v can't be changed because they would force the replacement of the instance", changed)
+	}
+
+	return nil
+}
+
 func createJsonSchema(schemaVariables []BrokerVariable) map[string]interface{} {
 	var required []string
 	properties := make(map[string]interface{})
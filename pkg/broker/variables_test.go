@@ -246,3 +246,66 @@ func TestBrokerVariable_ApplyDefaults(t *testing.T) {
 		})
 	}
 }
+
+func TestBrokerVariable_ValidateUpdatableVariables(t *testing.T) {
+	variables := []BrokerVariable{
+		{
+			FieldName:         "region",
+			Type:              JsonTypeString,
+			Default:           "us",
+			ForcesReplacement: true,
+		},
+		{
+			FieldName: "size",
+			Type:      JsonTypeInteger,
+			Default:   10,
+		},
+	}
+
+	cases := map[string]struct {
+		Original  map[string]interface{}
+		Updated   map[string]interface{}
+		ExpectErr bool
+	}{
+		"no changes": {
+			Original:  map[string]interface{}{"region": "eu"},
+			Updated:   map[string]interface{}{},
+			ExpectErr: false,
+		},
+		"updatable field changed": {
+			Original:  map[string]interface{}{"region": "eu", "size": 10},
+			Updated:   map[string]interface{}{"size": 20},
+			ExpectErr: false,
+		},
+		"replacement field set to same value": {
+			Original:  map[string]interface{}{"region": "eu"},
+			Updated:   map[string]interface{}{"region": "eu"},
+			ExpectErr: false,
+		},
+		"replacement field set to default": {
+			Original:  map[string]interface{}{},
+			Updated:   map[string]interface{}{"region": "us"},
+			ExpectErr: false,
+		},
+		"replacement field changed": {
+			Original:  map[string]interface{}{"region": "eu"},
+			Updated:   map[string]interface{}{"region": "us"},
+			ExpectErr: true,
+		},
+		"replacement field changed from default": {
+			Original:  map[string]interface{}{},
+			Updated:   map[string]interface{}{"region": "eu"},
+			ExpectErr: true,
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			err := ValidateUpdatableVariables(tc.Original, tc.Updated, variables)
+			hasErr := err != nil
+			if hasErr != tc.ExpectErr {
+				t.Errorf("Expected error? %v, got: %v", tc.ExpectErr, err)
+			}
+		})
+	}
+}
@@ -87,7 +87,7 @@ func (client *Client) Deprovision(instanceId, serviceId, planId string) *BrokerR
 
 // Bind creates an account identified by bindingId and gives it access to instanceId
 func (client *Client) Bind(instanceId, bindingId, serviceId, planId string, parameters json.RawMessage) *BrokerResponse {
-	url := fmt.Sprintf("service_instances/%s/service_bindings/%s", instanceId, bindingId)
+	url := fmt.Sprintf("service_instances/%s/service_bindings/%s?accepts_incomplete=true", instanceId, bindingId)
 
 	return client.makeRequest(http.MethodPut, url, brokerapi.BindDetails{
 		ServiceID:     serviceId,
@@ -98,7 +98,7 @@ func (client *Client) Bind(instanceId, bindingId, serviceId, planId string, para
 
 // Unbind destroys an account identified by bindingId
 func (client *Client) Unbind(instanceId, bindingId, serviceId, planId string) *BrokerResponse {
-	url := fmt.Sprintf("service_instances/%s/service_bindings/%s?service_id=%s&plan_id=%s", instanceId, bindingId, serviceId, planId)
+	url := fmt.Sprintf("service_instances/%s/service_bindings/%s?accepts_incomplete=true&service_id=%s&plan_id=%s", instanceId, bindingId, serviceId, planId)
 
 	return client.makeRequest(http.MethodDelete, url, nil)
 }
@@ -121,6 +121,27 @@ func (client *Client) LastOperation(instanceId string) *BrokerResponse {
 	return client.makeRequest(http.MethodGet, url, nil)
 }
 
+// LastBindingOperation queries the status of a long-running bind or unbind on the server
+func (client *Client) LastBindingOperation(instanceId, bindingId string) *BrokerResponse {
+	url := fmt.Sprintf("service_instances/%s/service_bindings/%s/last_operation", instanceId, bindingId)
+
+	return client.makeRequest(http.MethodGet, url, nil)
+}
+
+// GetInstance fetches the service instance identified by instanceId
+func (client *Client) GetInstance(instanceId string) *BrokerResponse {
+	url := fmt.Sprintf("service_instances/%s", instanceId)
+
+	return client.makeRequest(http.MethodGet, url, nil)
+}
+
+// GetBinding fetches the binding identified by bindingId, including its credentials
+func (client *Client) GetBinding(instanceId, bindingId string) *BrokerResponse {
+	url := fmt.Sprintf("service_instances/%s/service_bindings/%s", instanceId, bindingId)
+
+	return client.makeRequest(http.MethodGet, url, nil)
+}
+
 func (client *Client) makeRequest(method, path string, body interface{}) *BrokerResponse {
 	br := BrokerResponse{}
 
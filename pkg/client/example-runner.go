@@ -126,10 +126,22 @@ func retry(timeout, period time.Duration, function func() (tryAgain bool, err er
 }
 
 func pollUntilFinished(client *Client, instanceId string) error {
+	return pollOperation(instanceId, func() *BrokerResponse {
+		return client.LastOperation(instanceId)
+	})
+}
+
+func pollBindingUntilFinished(client *Client, instanceId, bindingId string) error {
+	return pollOperation(bindingId, func() *BrokerResponse {
+		return client.LastBindingOperation(instanceId, bindingId)
+	})
+}
+
+func pollOperation(id string, lastOperation func() *BrokerResponse) error {
 	return retry(15*time.Minute, 15*time.Second, func() (bool, error) {
 		log.Println("Polling for async job")
 
-		resp := client.LastOperation(instanceId)
+		resp := lastOperation()
 		if resp.InError() {
 			return false, resp.Error
 		}
@@ -147,7 +159,7 @@ func pollUntilFinished(client *Client, instanceId string) error {
 
 		state := responseBody["state"]
 		eq := state == string(brokerapi.Succeeded)
-		log.Printf("Last operation for %q was %q\n", instanceId, state)
+		log.Printf("Last operation for %q was %q\n", id, state)
 
 		return !eq, nil
 
@@ -270,6 +282,10 @@ func (ee *exampleExecutor) Unbind() error {
 			return false, nil
 		}
 
+		if resp.StatusCode == 202 {
+			return false, pollBindingUntilFinished(ee.client, ee.InstanceId, ee.BindingId)
+		}
+
 		if resp.StatusCode == 500 {
 			return true, nil
 		}
@@ -290,11 +306,34 @@ func (ee *exampleExecutor) Bind() (json.RawMessage, error) {
 		return nil, resp.Error
 	}
 
-	if resp.StatusCode == 201 {
+	switch resp.StatusCode {
+	case 201:
 		return resp.ResponseBody, nil
+	case 202:
+		if err := pollBindingUntilFinished(ee.client, ee.InstanceId, ee.BindingId); err != nil {
+			return nil, err
+		}
+
+		return ee.fetchBinding()
+	default:
+		return nil, fmt.Errorf("Unexpected response code %d", resp.StatusCode)
+	}
+}
+
+// fetchBinding gets the binding created by an asynchronous Bind.
+func (ee *exampleExecutor) fetchBinding() (json.RawMessage, error) {
+	resp := ee.client.GetBinding(ee.InstanceId, ee.BindingId)
+
+	log.Println(resp.String())
+	if resp.InError() {
+		return nil, resp.Error
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("Unexpected response code %d", resp.StatusCode)
 	}
 
-	return nil, fmt.Errorf("Unexpected response code %d", resp.StatusCode)
+	return resp.ResponseBody, nil
 }
 
 // LogTestInfo writes information about the running example and a manual backout
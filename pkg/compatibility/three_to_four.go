@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
 	"github.com/pivotal-cf/brokerapi"
@@ -150,6 +151,56 @@ func (t *ThreeToFour) Unbind(ctx context.Context, instanceID, bindingID string,
 	return t.Wrapped.Unbind(ctx, instanceID, bindingID, details)
 }
 
+// asyncBinder is implemented by brokers that can bind asynchronously.
+type asyncBinder interface {
+	BindAsync(ctx context.Context, instanceID, bindingID string, details brokerapi.BindDetails, asyncAllowed bool) (brokers.BindingSpec, error)
+	UnbindAsync(ctx context.Context, instanceID, bindingID string, details brokerapi.UnbindDetails, asyncAllowed bool) (brokers.UnbindSpec, error)
+	LastBindingOperation(ctx context.Context, instanceID, bindingID, operationData string) (brokerapi.LastOperation, error)
+}
+
+// BindAsync calls the wrapped service broker unless the plan is legacy, in
+// which case the user is told how to upgrade first. Wrapped brokers that
+// can't bind asynchronously bind synchronously.
+func (t *ThreeToFour) BindAsync(ctx context.Context, instanceID, bindingID string, details brokerapi.BindDetails, asyncAllowed bool) (brokers.BindingSpec, error) {
+	binder, ok := t.Wrapped.(asyncBinder)
+	if !ok {
+		binding, err := t.Bind(ctx, instanceID, bindingID, details)
+		return brokers.BindingSpec{Binding: binding}, err
+	}
+
+	if err := t.migrationErrorMessage(ctx, "bind", instanceID); err != nil {
+		return brokers.BindingSpec{}, err
+	}
+
+	return binder.BindAsync(ctx, instanceID, bindingID, details, asyncAllowed)
+}
+
+// UnbindAsync calls the wrapped service broker unless the plan is legacy, in
+// which case the user is told how to upgrade first. Wrapped brokers that
+// can't bind asynchronously unbind synchronously.
+func (t *ThreeToFour) UnbindAsync(ctx context.Context, instanceID, bindingID string, details brokerapi.UnbindDetails, asyncAllowed bool) (brokers.UnbindSpec, error) {
+	binder, ok := t.Wrapped.(asyncBinder)
+	if !ok {
+		return brokers.UnbindSpec{}, t.Unbind(ctx, instanceID, bindingID, details)
+	}
+
+	if err := t.migrationErrorMessage(ctx, "unbind", instanceID); err != nil {
+		return brokers.UnbindSpec{}, err
+	}
+
+	return binder.UnbindAsync(ctx, instanceID, bindingID, details, asyncAllowed)
+}
+
+// LastBindingOperation calls the wrapped service broker.
+func (t *ThreeToFour) LastBindingOperation(ctx context.Context, instanceID, bindingID, operationData string) (brokerapi.LastOperation, error) {
+	binder, ok := t.Wrapped.(asyncBinder)
+	if !ok {
+		return brokerapi.LastOperation{}, brokerapi.ErrAsyncRequired
+	}
+
+	return binder.LastBindingOperation(ctx, instanceID, bindingID, operationData)
+}
+
 // Services returns the list of enabled services, with dummy services injected
 // for legacy compatibility.
 func (t *ThreeToFour) Services(ctx context.Context) ([]brokerapi.Service, error) {
@@ -211,6 +211,16 @@ func TestThreeToFour_migrationErrorMessage(t *testing.T) {
 			err := broker.Unbind(context.Background(), tn, tn, brokerapi.UnbindDetails{PlanID: tc.PlanId, ServiceID: tc.ServiceId})
 			checkErrorMatches(t, err, tc.ErrContains)
 		})
+
+		t.Run(tn+"-bind-async", func(t *testing.T) {
+			_, err := broker.BindAsync(context.Background(), tn, tn, brokerapi.BindDetails{PlanID: tc.PlanId, ServiceID: tc.ServiceId}, true)
+			checkErrorMatches(t, err, tc.ErrContains)
+		})
+
+		t.Run(tn+"-unbind-async", func(t *testing.T) {
+			_, err := broker.UnbindAsync(context.Background(), tn, tn, brokerapi.UnbindDetails{PlanID: tc.PlanId, ServiceID: tc.ServiceId}, true)
+			checkErrorMatches(t, err, tc.ErrContains)
+		})
 	}
 }
 
@@ -68,10 +68,11 @@ func init() {
 			},
 			UserInputs: []broker.BrokerVariable{
 				{
-					FieldName: "name",
-					Type:      broker.JsonTypeString,
-					Details:   "The name of the bucket. There is a single global namespace shared by all buckets so it MUST be unique.",
-					Default:   "pcf_sb_${counter.next()}_${time.nano()}",
+					FieldName:         "name",
+					Type:              broker.JsonTypeString,
+					Details:           "The name of the bucket. There is a single global namespace shared by all buckets so it MUST be unique.",
+					Default:           "pcf_sb_${counter.next()}_${time.nano()}",
+					ForcesReplacement: true,
 					Constraints: validation.NewConstraintBuilder(). // https://cloud.google.com/storage/docs/naming
 											Pattern("^[A-Za-z0-9_\\.]+$").
 											MinLength(3).
@@ -79,10 +80,11 @@ func init() {
 											Build(),
 				},
 				{
-					FieldName: "location",
-					Type:      broker.JsonTypeString,
-					Default:   "US",
-					Details:   `The location of the bucket. Object data for objects in the bucket resides in physical storage within this region. See: https://cloud.google.com/storage/docs/bucket-locations`,
+					FieldName:         "location",
+					Type:              broker.JsonTypeString,
+					Default:           "US",
+					ForcesReplacement: true,
+					Details:           `The location of the bucket. Object data for objects in the bucket resides in physical storage within this region. See: https://cloud.google.com/storage/docs/bucket-locations`,
 					Constraints: validation.NewConstraintBuilder().
 						Pattern("^[A-Za-z][-a-z0-9A-Z]+$").
 						Examples("US", "EU", "southamerica-east1").
@@ -17,6 +17,8 @@ package tf
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 
 	"code.cloudfoundry.org/lager"
 	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
@@ -46,6 +48,10 @@ type TfServiceDefinitionV1 struct {
 
 	// Internal SHOULD be set to true for Google maintained services.
 	Internal bool `yaml:"-"`
+
+	// Executor runs Terraform for the jobs of the service if set, for example
+	// to use the provider plugins bundled with the definition.
+	Executor wrapper.TerraformExecutor `yaml:"-"`
 }
 
 // TfServiceDefinitionV1Action holds information needed to process user inputs
@@ -55,12 +61,36 @@ type TfServiceDefinitionV1Action struct {
 	UserInputs []broker.BrokerVariable      `yaml:"user_inputs" validate:"dive"`
 	Computed   []varcontext.DefaultVariable `yaml:"computed_inputs" validate:"dive"`
 	Template   string                       `yaml:"template" validate:"hcl"`
-	Outputs    []broker.BrokerVariable      `yaml:"outputs" validate:"dive"`
+	// TemplateRef is the path of a file holding the template, relative to the
+	// definition file. It's read into Template when the definition is loaded.
+	TemplateRef string `yaml:"template_ref"`
+	// Modules are additional templates instantiated alongside Template, for
+	// example to set up networking or IAM shared between services.
+	Modules []TfServiceDefinitionV1Module `yaml:"modules" validate:"dive"`
+	// Wiring maps inputs of Template to outputs of Modules in the form
+	// "<module>.<output>".
+	Wiring  map[string]string       `yaml:"wiring"`
+	Outputs []broker.BrokerVariable `yaml:"outputs" validate:"dive"`
+}
+
+// TfServiceDefinitionV1Module is a named template instantiated as part of an
+// action. Its inputs are populated the same way as the action's template.
+type TfServiceDefinitionV1Module struct {
+	Name        string `yaml:"name" validate:"required,terraform_identifier"`
+	Template    string `yaml:"template" validate:"hcl"`
+	TemplateRef string `yaml:"template_ref"`
+	// Wiring maps inputs of the module to outputs of other modules in the form
+	// "<module>.<output>".
+	Wiring map[string]string `yaml:"wiring"`
 }
 
+// mainModuleName is the name of the module holding the action's Template.
+const mainModuleName = "brokertemplate"
+
 // ValidateTemplateIO makes sure that the inputs supplied by the user are a
-// superset of the inputs needed by the Terraform template, and the template
-// outputs match the outputs.
+// superset of the inputs needed by the Terraform templates that aren't wired
+// to other modules, the wiring is sound, and the template outputs match the
+// outputs.
 func (action *TfServiceDefinitionV1Action) ValidateTemplateIO() error {
 	if err := action.validateTemplateInputs(); err != nil {
 		return err
@@ -69,8 +99,9 @@ func (action *TfServiceDefinitionV1Action) ValidateTemplateIO() error {
 	return action.validateTemplateOutputs()
 }
 
-// validateTemplateInputs checks that all the inputs of the Terraform template
-// are defined by the service.
+// validateTemplateInputs checks that all the inputs of the Terraform templates
+// are either defined by the service or wired to the output of another module,
+// and that the wiring between modules doesn't form a cycle.
 func (action *TfServiceDefinitionV1Action) validateTemplateInputs() error {
 	inputs := utils.NewStringSet()
 
@@ -86,22 +117,122 @@ func (action *TfServiceDefinitionV1Action) validateTemplateInputs() error {
 		inputs.Add(in.Name)
 	}
 
-	tfModule := wrapper.ModuleDefinition{Definition: action.Template}
-	tfIn, err := tfModule.Inputs()
-	if err != nil {
-		return err
+	modules, instances := action.moduleGraph()
+	templates := make(map[string]wrapper.ModuleDefinition)
+	for _, module := range modules {
+		if _, ok := templates[module.Name]; ok || module.Name == wrapper.DefaultInstanceName {
+			return fmt.Errorf("The module name %q is reserved or used more than once.", module.Name)
+		}
+
+		templates[module.Name] = module
 	}
 
-	missingFields := utils.NewStringSet(tfIn...).Minus(inputs).ToSlice()
-	if len(missingFields) > 0 {
-		return fmt.Errorf("The Terraform template requires the fields %v which are missing from the declared inputs.", missingFields)
+	for _, instance := range instances {
+		tfModule := templates[instance.ModuleName]
+		tfIn, err := tfModule.Inputs()
+		if err != nil {
+			return err
+		}
+
+		if err := validateWiring(instance, tfIn, templates); err != nil {
+			return err
+		}
+
+		missingFields := utils.NewStringSet(tfIn...).Minus(inputs).Minus(utils.NewStringSet(sortedKeys(instance.Wiring)...)).ToSlice()
+		if len(missingFields) == 0 {
+			continue
+		}
+
+		if instance.ModuleName == mainModuleName {
+			return fmt.Errorf("The Terraform template requires the fields %v which are missing from the declared inputs.", missingFields)
+		}
+
+		return fmt.Errorf("The Terraform module %q requires the fields %v which are missing from the declared inputs.", instance.ModuleName, missingFields)
+	}
+
+	return validateAcyclic(instances)
+}
+
+// validateWiring checks that every wired input exists on the instance and
+// refers to an existing output of another module.
+func validateWiring(instance wrapper.ModuleInstance, tfIn []string, templates map[string]wrapper.ModuleDefinition) error {
+	declaredInputs := utils.NewStringSet(tfIn...)
+
+	for _, input := range sortedKeys(instance.Wiring) {
+		ref := instance.Wiring[input]
+		if !declaredInputs.Contains(input) {
+			return fmt.Errorf("The module %q wires the input %q which it doesn't have.", instance.ModuleName, input)
+		}
+
+		parts := strings.SplitN(ref, ".", 2)
+		source, ok := templates[parts[0]]
+		if len(parts) != 2 || !ok || source.Name == mainModuleName {
+			return fmt.Errorf("The module %q wires %q to %q, expected <module>.<output> naming one of the modules.", instance.ModuleName, input, ref)
+		}
+
+		tfOut, err := source.Outputs()
+		if err != nil {
+			return err
+		}
+
+		if !utils.NewStringSet(tfOut...).Contains(parts[1]) {
+			return fmt.Errorf("The module %q wires %q to %q, but the module %q has no such output.", instance.ModuleName, input, ref, source.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateAcyclic checks that no module depends on its own outputs through
+// the wiring.
+func validateAcyclic(instances []wrapper.ModuleInstance) error {
+	dependencies := make(map[string][]string)
+	for _, instance := range instances {
+		for _, input := range sortedKeys(instance.Wiring) {
+			source := strings.SplitN(instance.Wiring[input], ".", 2)[0]
+			dependencies[instance.InstanceName] = append(dependencies[instance.InstanceName], source)
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("The modules are wired in a cycle: %s.", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		for _, dependency := range dependencies[name] {
+			if err := visit(dependency, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+
+		return nil
+	}
+
+	for _, instance := range instances {
+		if err := visit(instance.InstanceName, nil); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
 // validateTemplateOutputs checks that the Terraform template outputs match
-// the names of the defined outputs.
+// the names of the defined outputs. Only the outputs of Template are returned
+// by the action, outputs of the other modules must be wired into it to be
+// exposed.
 func (action *TfServiceDefinitionV1Action) validateTemplateOutputs() error {
 	definedOutputs := utils.NewStringSet()
 
@@ -122,6 +253,38 @@ func (action *TfServiceDefinitionV1Action) validateTemplateOutputs() error {
 	return nil
 }
 
+// moduleGraph gets the Terraform modules of the action and an instance of
+// each. Template is instantiated as wrapper.DefaultInstanceName, the other
+// modules are instantiated under their own name.
+func (action *TfServiceDefinitionV1Action) moduleGraph() ([]wrapper.ModuleDefinition, []wrapper.ModuleInstance) {
+	modules := []wrapper.ModuleDefinition{{Name: mainModuleName, Definition: action.Template}}
+	instances := []wrapper.ModuleInstance{{ModuleName: mainModuleName, InstanceName: wrapper.DefaultInstanceName, Wiring: action.Wiring}}
+
+	for _, module := range action.Modules {
+		modules = append(modules, wrapper.ModuleDefinition{Name: module.Name, Definition: module.Template})
+		instances = append(instances, wrapper.ModuleInstance{ModuleName: module.Name, InstanceName: module.Name, Wiring: module.Wiring})
+	}
+
+	return modules, instances
+}
+
+// NewWorkspace creates a Terraform workspace holding the action's modules
+// populated with the given variables.
+func (action *TfServiceDefinitionV1Action) NewWorkspace(templateVars map[string]interface{}) (*wrapper.TerraformWorkspace, error) {
+	modules, instances := action.moduleGraph()
+	return wrapper.NewComposedWorkspace(templateVars, modules, instances)
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
 // Validate checks the service definition for semantic errors.
 func (tfb *TfServiceDefinitionV1) Validate() error {
 	if err := validation.ValidateStruct(tfb); err != nil {
@@ -187,11 +350,19 @@ func (tfb *TfServiceDefinitionV1) ToService() (*broker.ServiceDefinition, error)
 		Examples:            tfb.Examples,
 		ProviderBuilder: func(projectId string, auth *jwt.Config, logger lager.Logger) broker.ServiceProvider {
 			jobRunner := NewTfJobRunnerForProject(projectId)
+			jobRunner.ServiceName = tfb.Name
+			jobRunner.Executor = tfb.Executor
 			return NewTerraformProvider(jobRunner, logger, *tfb)
 		},
 	}, nil
 }
 
+// DeploymentId gets the ID of the Terraform deployment of a service instance,
+// or of one of its bindings if bindingId isn't empty.
+func DeploymentId(instanceId, bindingId string) string {
+	return generateTfId(instanceId, bindingId)
+}
+
 // generateTfId creates a unique id for a given provision/bind combination that
 // will be consistent across calls. This ID will be used in LastOperation polls
 // as well as to uniquely identify the workspace.
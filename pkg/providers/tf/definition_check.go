@@ -0,0 +1,208 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/providers/tf/wrapper"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/validation"
+	"github.com/pivotal-cf/brokerapi"
+)
+
+const (
+	// The IDs the requests are simulated with when checking definitions.
+	checkInstanceId = "00000000-0000-0000-0000-000000000000"
+	checkBindingId  = "11111111-1111-1111-1111-111111111111"
+)
+
+// DefinitionReport holds the results of checking a service definition file.
+type DefinitionReport struct {
+	File   string            `json:"file"`
+	Checks []DefinitionCheck `json:"checks"`
+}
+
+// DefinitionCheck is the result of a single check on a service definition.
+type DefinitionCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Passed is true if every check passed.
+func (report *DefinitionReport) Passed() bool {
+	for _, check := range report.Checks {
+		if !check.Passed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// check records the result of a check and returns true if it passed.
+func (report *DefinitionReport) check(name string, err error) bool {
+	result := DefinitionCheck{Name: name, Passed: err == nil}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	report.Checks = append(report.Checks, result)
+	return err == nil
+}
+
+// CheckServiceDefinitionFile checks a service definition file end to end
+// without contacting GCP. It runs the same validation the broker does when
+// loading the definition, then evaluates the provision variables of every
+// plan and the provision and bind variables of every example.
+//
+// If executor is non-nil, `terraform validate` is run through it on the
+// workspace built from each set of variables. Use CustomTerraformExecutor with
+// a local plugin directory to keep Terraform from downloading providers.
+func CheckServiceDefinitionFile(path string, executor wrapper.TerraformExecutor) *DefinitionReport {
+	report := &DefinitionReport{File: path}
+
+	defn, err := parseServiceDefinitionFile(path, path)
+	if !report.check("parse", err) {
+		return report
+	}
+
+	// These are the checks Validate runs, split up to report them separately.
+	report.check("validate definition", validation.ValidateStruct(defn))
+	report.check("validate provision template inputs and outputs", defn.ProvisionSettings.ValidateTemplateIO())
+	report.check("validate bind template inputs and outputs", defn.BindSettings.ValidateTemplateIO())
+	if !report.Passed() {
+		return report
+	}
+
+	svc, err := defn.ToService()
+	if !report.check("build service", err) {
+		return report
+	}
+
+	catalog, err := svc.CatalogEntry()
+	if !report.check("build catalog entry", err) {
+		return report
+	}
+
+	for _, plan := range catalog.Plans {
+		name := fmt.Sprintf("plan %q", plan.Name)
+		details := brokerapi.ProvisionDetails{PlanID: plan.ID, ServiceID: catalog.ID}
+
+		vars, err := svc.ProvisionVariables(context.Background(), checkInstanceId, details, plan)
+		if report.check(name+": evaluate provision variables", err) && executor != nil {
+			report.check(name+": terraform validate provision", terraformValidate(vars.ToMap(), defn.ProvisionSettings, executor))
+		}
+	}
+
+	for _, example := range svc.Examples {
+		report.checkExample(svc, defn, example, executor)
+	}
+
+	return report
+}
+
+func (report *DefinitionReport) checkExample(svc *broker.ServiceDefinition, defn *TfServiceDefinitionV1, example broker.ServiceExample, executor wrapper.TerraformExecutor) {
+	name := fmt.Sprintf("example %q", example.Name)
+
+	plan, err := svc.GetPlanById(example.PlanId)
+	if !report.check(name+": find plan", err) {
+		return
+	}
+
+	provisionParams, err := json.Marshal(example.ProvisionParams)
+	if !report.check(name+": encode provision parameters", err) {
+		return
+	}
+
+	if !report.check(name+": validate provision parameters", broker.ValidateVariables(example.ProvisionParams, svc.ProvisionInputVariables)) {
+		return
+	}
+
+	details := brokerapi.ProvisionDetails{PlanID: plan.ID, ServiceID: defn.Id, RawParameters: provisionParams}
+	vars, err := svc.ProvisionVariables(context.Background(), checkInstanceId, details, *plan)
+	if !report.check(name+": evaluate provision variables", err) {
+		return
+	}
+
+	if executor != nil {
+		report.check(name+": terraform validate provision", terraformValidate(vars.ToMap(), defn.ProvisionSettings, executor))
+	}
+
+	// The instance details are normally the outputs of the provision, so
+	// placeholders are used for them.
+	instance := models.ServiceInstanceDetails{ID: checkInstanceId, PlanId: plan.ID, ServiceId: defn.Id}
+	if err := instance.SetOtherDetails(placeholderOutputs(defn.ProvisionSettings.Outputs)); err != nil {
+		report.check(name+": create placeholder instance outputs", err)
+		return
+	}
+
+	bindParams, err := json.Marshal(example.BindParams)
+	if !report.check(name+": encode bind parameters", err) {
+		return
+	}
+
+	if !report.check(name+": validate bind parameters", broker.ValidateVariables(example.BindParams, svc.BindInputVariables)) {
+		return
+	}
+
+	bindVars, err := svc.BindVariables(context.Background(), instance, checkBindingId, brokerapi.BindDetails{PlanID: plan.ID, ServiceID: defn.Id, RawParameters: bindParams})
+	if report.check(name+": evaluate bind variables", err) && executor != nil {
+		report.check(name+": terraform validate bind", terraformValidate(bindVars.ToMap(), defn.BindSettings, executor))
+	}
+}
+
+// placeholderOutputs creates a value of the right type for each output.
+func placeholderOutputs(outputs []broker.BrokerVariable) map[string]interface{} {
+	out := make(map[string]interface{})
+	for _, output := range outputs {
+		switch output.Type {
+		case broker.JsonTypeString:
+			out[output.FieldName] = "placeholder-" + output.FieldName
+		case broker.JsonTypeInteger, broker.JsonTypeNumeric:
+			out[output.FieldName] = 0
+		case broker.JsonTypeBoolean:
+			out[output.FieldName] = false
+		default:
+			out[output.FieldName] = nil
+		}
+	}
+
+	return out
+}
+
+// terraformValidate runs `terraform validate` on a workspace built from the
+// action and variables. The end of the output is included in errors.
+func terraformValidate(vars map[string]interface{}, action TfServiceDefinitionV1Action, executor wrapper.TerraformExecutor) error {
+	workspace, err := action.NewWorkspace(vars)
+	if err != nil {
+		return err
+	}
+
+	var output bytes.Buffer
+	workspace.Output = &output
+	workspace.Executor = executor
+
+	if err := workspace.Validate(); err != nil {
+		return fmt.Errorf("%v: %s", err, sanitizeLogTail(output.String(), failureTailLines))
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckServiceDefinitionFile(t *testing.T) {
+	cases := map[string]struct {
+		Definition string
+		Executor   func(*exec.Cmd) error
+		Ran        []string
+		Failed     []string
+	}{
+		"valid": {
+			Definition: exampleDefinitionYaml,
+		},
+		"parse error": {
+			Definition: "version: [1",
+			Failed:     []string{"parse"},
+		},
+		"invalid definition": {
+			Definition: strings.Replace(exampleDefinitionYaml, "id: 5d8b4b3c-0ed6-4c06-a3b4-5d5a5e3f3c2b", "id: not-a-uuid", 1),
+			Failed:     []string{"validate definition"},
+		},
+		"undeclared template input": {
+			Definition: strings.Replace(exampleDefinitionYaml, "  - field_name: storage_class\n    type: string\n    details: The storage class of the bucket.\n", "", 1),
+			Failed:     []string{"validate provision template inputs and outputs"},
+		},
+		"bad computed input": {
+			Definition: strings.Replace(exampleDefinitionYaml, "default: example-${request.instance_id}", "default: ${does_not_exist}", 1),
+			Failed:     []string{`plan "standard": evaluate provision variables`, `example "Basic": evaluate provision variables`},
+		},
+		"example with unknown plan": {
+			Definition: strings.Replace(exampleDefinitionYaml, "  plan_id: 9b7ac6a5-7dd3-4b6c-9a64-ac3e0a8b4bf1", "  plan_id: missing", 1),
+			Failed:     []string{`example "Basic": find plan`},
+		},
+		"example with invalid parameters": {
+			Definition: strings.Replace(exampleDefinitionYaml, "provision_params: {}", "provision_params: {name: 42}", 1),
+			Failed:     []string{`example "Basic": validate provision parameters`},
+		},
+		"terraform validate": {
+			Definition: exampleDefinitionYaml,
+			Executor:   func(*exec.Cmd) error { return nil },
+			Ran: []string{
+				`plan "standard": terraform validate provision`,
+				`example "Basic": terraform validate provision`,
+				`example "Basic": terraform validate bind`,
+			},
+		},
+		"terraform validate failure": {
+			Definition: exampleDefinitionYaml,
+			Executor: func(c *exec.Cmd) error {
+				if c.Args[1] != "validate" {
+					return nil
+				}
+
+				fmt.Fprintln(c.Stdout, "Error: unknown resource")
+				return exec.Command("sh", "-c", "exit 1").Run()
+			},
+			Failed: []string{
+				`plan "standard": terraform validate provision`,
+				`example "Basic": terraform validate provision`,
+				`example "Basic": terraform validate bind`,
+			},
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "definitions")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			writeFiles(t, dir, map[string]string{"example.yml": tc.Definition, "bucket.tf": exampleBucketTemplate})
+
+			report := CheckServiceDefinitionFile(filepath.Join(dir, "example.yml"), tc.Executor)
+
+			var ran, failed []string
+			for _, check := range report.Checks {
+				ran = append(ran, check.Name)
+				if !check.Passed {
+					failed = append(failed, check.Name)
+				}
+			}
+
+			if strings.Join(failed, ",") != strings.Join(tc.Failed, ",") {
+				t.Errorf("Expected checks %v to fail, got %v", tc.Failed, failed)
+			}
+
+			if report.Passed() != (tc.Failed == nil) {
+				t.Errorf("Expected Passed() to be %t", tc.Failed == nil)
+			}
+
+			for _, expected := range tc.Ran {
+				if !strings.Contains(strings.Join(ran, ","), expected) {
+					t.Errorf("Expected check %q to run, got %v", expected, ran)
+				}
+			}
+
+			for _, check := range report.Checks {
+				if strings.Contains(check.Name, "terraform validate") && !check.Passed && !strings.Contains(check.Error, "Error: unknown resource") {
+					t.Errorf("Expected the Terraform output in the error, got %q", check.Error)
+				}
+			}
+		})
+	}
+}
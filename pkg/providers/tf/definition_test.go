@@ -80,6 +80,80 @@ func TestTfServiceDefinitionV1Action_ValidateTemplateIO(t *testing.T) {
 			ErrContains: "MUST match the service declared outputs",
 		},
 
+		"wired modules": {
+			Action: TfServiceDefinitionV1Action{
+				UserInputs: []broker.BrokerVariable{{FieldName: "name"}, {FieldName: "region"}},
+				Template: `
+        variable name {type = "string"}
+        variable network {type = "string"}
+        variable service_account {type = "string"}
+        `,
+				Wiring: map[string]string{"network": "networking.network_name", "service_account": "iam.email"},
+				Modules: []TfServiceDefinitionV1Module{
+					{Name: "networking", Template: `
+          variable region {type = "string"}
+          output network_name {value = "${var.region}"}
+          `},
+					{Name: "iam", Template: `
+          variable network {type = "string"}
+          output email {value = "${var.network}"}
+          `, Wiring: map[string]string{"network": "networking.network_name"}},
+				},
+			},
+			ErrContains: "",
+		},
+		"missing module inputs": {
+			Action: TfServiceDefinitionV1Action{
+				Modules: []TfServiceDefinitionV1Module{
+					{Name: "networking", Template: `variable region {type = "string"}`},
+				},
+			},
+			ErrContains: `The Terraform module "networking" requires the fields [region] which are missing from the declared inputs.`,
+		},
+		"wiring unknown module": {
+			Action: TfServiceDefinitionV1Action{
+				Template: `variable network {type = "string"}`,
+				Wiring:   map[string]string{"network": "networking.network_name"},
+			},
+			ErrContains: "naming one of the modules",
+		},
+		"wiring unknown output": {
+			Action: TfServiceDefinitionV1Action{
+				Template: `variable network {type = "string"}`,
+				Wiring:   map[string]string{"network": "networking.missing"},
+				Modules:  []TfServiceDefinitionV1Module{{Name: "networking", Template: `output network_name {value = "x"}`}},
+			},
+			ErrContains: "has no such output",
+		},
+		"wiring unknown input": {
+			Action: TfServiceDefinitionV1Action{
+				Wiring:  map[string]string{"network": "networking.network_name"},
+				Modules: []TfServiceDefinitionV1Module{{Name: "networking", Template: `output network_name {value = "x"}`}},
+			},
+			ErrContains: "which it doesn't have",
+		},
+		"reserved module name": {
+			Action: TfServiceDefinitionV1Action{
+				Modules: []TfServiceDefinitionV1Module{{Name: "instance"}},
+			},
+			ErrContains: "reserved or used more than once",
+		},
+		"wiring cycle": {
+			Action: TfServiceDefinitionV1Action{
+				Modules: []TfServiceDefinitionV1Module{
+					{Name: "a", Template: `
+          variable in {type = "string"}
+          output out {value = "${var.in}"}
+          `, Wiring: map[string]string{"in": "b.out"}},
+					{Name: "b", Template: `
+          variable in {type = "string"}
+          output out {value = "${var.in}"}
+          `, Wiring: map[string]string{"in": "a.out"}},
+				},
+			},
+			ErrContains: "The modules are wired in a cycle: a -> b -> a.",
+		},
+
 		"missing template outputs": {
 			Action: TfServiceDefinitionV1Action{
 				Template: `
@@ -0,0 +1,230 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+	"github.com/spf13/viper"
+)
+
+const (
+	maxLogBytesProp      = "terraform.max_log_bytes"
+	maxLogOperationsProp = "terraform.max_log_operations"
+
+	// logFlushBytes and logFlushInterval control how often buffered output is
+	// written to the database as a new chunk.
+	logFlushBytes    = 4 * 1024
+	logFlushInterval = 5 * time.Second
+
+	// logMaxLineBytes is how much of a line is buffered waiting for its end
+	// before it's flushed anyway. Lines are normally flushed whole so values
+	// can be redacted.
+	logMaxLineBytes = 4 * logFlushBytes
+
+	// logTailBytes is how much of the end of the output is kept in memory to
+	// describe failures.
+	logTailBytes = 4 * 1024
+
+	// failureTailLines is the number of lines of output included in the
+	// description of failed operations.
+	failureTailLines = 10
+)
+
+func init() {
+	viper.SetDefault(maxLogBytesProp, 1024*1024)
+	viper.SetDefault(maxLogOperationsProp, 5)
+}
+
+// sensitiveValuePattern matches assignments of values that look like secrets
+// so they can be redacted before output is stored or shown to users.
+var sensitiveValuePattern = regexp.MustCompile(`(?i)((?:password|secret|private_key|token|credentials)[\w.]*"?\s*[:=]\s*)("[^"]*"|\S+)`)
+
+type logChunk struct {
+	id   uint
+	size int
+}
+
+// deploymentLog is an io.Writer that streams the output of an operation to the
+// terraform_deployment_logs table in chunks of whole lines with anything
+// resembling a secret redacted. Once the stored output of the operation grows
+// past the configured maximum, the oldest chunks are purged so the end of the
+// output, which usually holds the errors, is kept. Once the operation
+// finishes, the logs of all but the newest operations on the deployment are
+// purged.
+//
+// Database errors are ignored rather than returned so a failure to store logs
+// doesn't fail the operation.
+type deploymentLog struct {
+	deploymentId  string
+	operationId   string
+	operationType string
+
+	mu        sync.Mutex
+	pending   bytes.Buffer
+	lastFlush time.Time
+	chunks    []logChunk
+	stored    int
+	tail      []byte
+}
+
+func newDeploymentLog(deployment *models.TerraformDeployment) *deploymentLog {
+	return &deploymentLog{
+		deploymentId:  deployment.ID,
+		operationId:   time.Now().UTC().Format("20060102T150405.000000000Z"),
+		operationType: deployment.LastOperationType,
+		lastFlush:     time.Now(),
+	}
+}
+
+// Write buffers the output and flushes it to the database once enough output
+// or time has accumulated.
+func (dl *deploymentLog) Write(p []byte) (int, error) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	dl.pending.Write(p)
+
+	dl.tail = append(dl.tail, p...)
+	if len(dl.tail) > logTailBytes {
+		dl.tail = dl.tail[len(dl.tail)-logTailBytes:]
+	}
+
+	if dl.pending.Len() >= logFlushBytes || time.Since(dl.lastFlush) >= logFlushInterval {
+		dl.flush(false)
+	}
+
+	return len(p), nil
+}
+
+// Close flushes any buffered output to the database and purges the logs of
+// older operations past the configured number to keep.
+func (dl *deploymentLog) Close() error {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	dl.flush(true)
+
+	if keep := viper.GetInt(maxLogOperationsProp); keep > 0 {
+		db_service.PurgeOldTerraformDeploymentLogs(context.Background(), dl.deploymentId, keep)
+	}
+
+	return nil
+}
+
+// flush writes the buffered lines as a new chunk and enforces the size limit.
+// An incomplete last line is kept buffered unless final is set or the line is
+// too long to wait for.
+// The caller MUST hold the lock.
+func (dl *deploymentLog) flush(final bool) {
+	dl.lastFlush = time.Now()
+
+	end := dl.pending.Len()
+	if !final && end < logMaxLineBytes {
+		end = bytes.LastIndexByte(dl.pending.Bytes(), '\n') + 1
+	}
+
+	if end == 0 {
+		return
+	}
+
+	chunk := models.TerraformDeploymentLog{
+		TerraformDeploymentId: dl.deploymentId,
+		OperationId:           dl.operationId,
+		OperationType:         dl.operationType,
+		Output:                redactSensitiveValues(string(dl.pending.Next(end))),
+	}
+
+	if err := db_service.CreateTerraformDeploymentLog(context.Background(), &chunk); err != nil {
+		return
+	}
+
+	dl.chunks = append(dl.chunks, logChunk{id: chunk.ID, size: len(chunk.Output)})
+	dl.stored += len(chunk.Output)
+
+	// always keep the newest chunk even if it's over the limit by itself
+	maxBytes := viper.GetInt(maxLogBytesProp)
+	var purge []uint
+	for maxBytes > 0 && dl.stored > maxBytes && len(dl.chunks) > 1 {
+		purge = append(purge, dl.chunks[0].id)
+		dl.stored -= dl.chunks[0].size
+		dl.chunks = dl.chunks[1:]
+	}
+
+	db_service.PurgeTerraformDeploymentLogs(context.Background(), purge)
+}
+
+// Tail gets the last lines of output with anything resembling a secret
+// redacted so it's safe to show to users.
+func (dl *deploymentLog) Tail() string {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	tail := string(dl.tail)
+	if len(dl.tail) == logTailBytes {
+		// the first line was probably cut off
+		if i := strings.Index(tail, "\n"); i >= 0 {
+			tail = tail[i+1:]
+		}
+	}
+
+	return sanitizeLogTail(tail, failureTailLines)
+}
+
+// sanitizeLogTail gets the last non-empty lines of the output with control
+// characters stripped and values that look like secrets redacted.
+func sanitizeLogTail(output string, lines int) string {
+	printable := strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' || unicode.IsPrint(r) {
+			return r
+		}
+
+		return -1
+	}, output)
+
+	var kept []string
+	for _, line := range strings.Split(printable, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		kept = append(kept, redactSensitiveValues(line))
+	}
+
+	if len(kept) > lines {
+		kept = kept[len(kept)-lines:]
+	}
+
+	return strings.Join(kept, "\n")
+}
+
+// redactSensitiveValues replaces values that look like secrets in each line of
+// the output.
+func redactSensitiveValues(output string) string {
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		lines[i] = sensitiveValuePattern.ReplaceAllString(line, "${1}[REDACTED]")
+	}
+
+	return strings.Join(lines, "\n")
+}
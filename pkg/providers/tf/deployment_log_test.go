@@ -0,0 +1,180 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+	"github.com/jinzhu/gorm"
+	"github.com/spf13/viper"
+)
+
+func TestSanitizeLogTail(t *testing.T) {
+	cases := map[string]struct {
+		Output   string
+		Lines    int
+		Expected string
+	}{
+		"keeps last lines": {
+			Output:   "one\ntwo\nthree\n",
+			Lines:    2,
+			Expected: "two\nthree",
+		},
+		"skips blank lines": {
+			Output:   "one\n\n  \ntwo\n\n",
+			Lines:    2,
+			Expected: "one\ntwo",
+		},
+		"strips control characters": {
+			Output:   "\x1b[31mError\x1b[0m: bad\r\n",
+			Lines:    1,
+			Expected: "[31mError[0m: bad",
+		},
+		"redacts secrets": {
+			Output:   `password: "hunter2"` + "\n" + `private_key = abc123 more`,
+			Lines:    2,
+			Expected: "password: [REDACTED]\nprivate_key = [REDACTED] more",
+		},
+		"redacts quoted json keys": {
+			Output:   `{"client_secret": "shh"}`,
+			Lines:    1,
+			Expected: `{"client_secret": [REDACTED]}`,
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			actual := sanitizeLogTail(tc.Output, tc.Lines)
+			if actual != tc.Expected {
+				t.Errorf("Expected %q, got %q", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+// newTestDatabase sets up an in-memory database with the Terraform tables as
+// the default database.
+func newTestDatabase(t *testing.T) *gorm.DB {
+	testDb, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testDb.CreateTable(models.TerraformDeployment{})
+	testDb.CreateTable(models.TerraformDeploymentLog{})
+	db_service.DbConnection = testDb
+
+	return testDb
+}
+
+func TestDeploymentLog(t *testing.T) {
+	testDb := newTestDatabase(t)
+	defer testDb.Close()
+
+	viper.Set(maxLogBytesProp, 3*logFlushBytes)
+	defer viper.Set(maxLogBytesProp, nil)
+
+	dl := newDeploymentLog(&models.TerraformDeployment{ID: "tf:instance:", LastOperationType: models.ProvisionOperationType})
+	for i := 0; i < 5; i++ {
+		fmt.Fprintf(dl, "%s\n", strings.Repeat(fmt.Sprint(i), logFlushBytes-1))
+	}
+	fmt.Fprintln(dl, "Error: password = hunter2")
+	dl.Close()
+
+	logs, err := db_service.ListTerraformDeploymentLogs(context.Background(), "tf:instance:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored strings.Builder
+	for _, log := range logs {
+		if log.OperationType != models.ProvisionOperationType || log.OperationId != dl.operationId {
+			t.Errorf("Expected chunks to be keyed by the operation, got %q %q", log.OperationType, log.OperationId)
+		}
+
+		stored.WriteString(log.Output)
+	}
+
+	if stored.Len() > 3*logFlushBytes+len("Error: password = [REDACTED]\n") {
+		t.Errorf("Expected the stored log to be capped, got %d bytes", stored.Len())
+	}
+
+	if strings.Contains(stored.String(), "0") || !strings.HasSuffix(stored.String(), "Error: password = [REDACTED]\n") {
+		t.Errorf("Expected the oldest output to be purged and the newest kept redacted")
+	}
+
+	if tail := dl.Tail(); !strings.HasSuffix(tail, "Error: password = [REDACTED]") {
+		t.Errorf("Expected the tail to end with the redacted error, got %q", tail)
+	}
+}
+
+func TestDeploymentLog_RedactsSplitLines(t *testing.T) {
+	testDb := newTestDatabase(t)
+	defer testDb.Close()
+
+	dl := newDeploymentLog(&models.TerraformDeployment{ID: "tf:instance:", LastOperationType: models.ProvisionOperationType})
+
+	// the first write fills the buffer so it's flushed with the assignment
+	// split from its value
+	fmt.Fprintf(dl, "%s\npassword = ", strings.Repeat("x", logFlushBytes))
+	fmt.Fprintln(dl, "hunter2")
+	dl.Close()
+
+	logs, err := db_service.ListTerraformDeploymentLogs(context.Background(), "tf:instance:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stored strings.Builder
+	for _, log := range logs {
+		stored.WriteString(log.Output)
+	}
+
+	if len(logs) != 2 || strings.Contains(stored.String(), "hunter2") || !strings.HasSuffix(stored.String(), "password = [REDACTED]\n") {
+		t.Errorf("Expected the split line to be redacted, got %d chunks ending in %q", len(logs), stored.String()[stored.Len()-30:])
+	}
+}
+
+func TestDeploymentLog_KeepsNewestOperations(t *testing.T) {
+	testDb := newTestDatabase(t)
+	defer testDb.Close()
+
+	viper.Set(maxLogOperationsProp, 2)
+	defer viper.Set(maxLogOperationsProp, nil)
+
+	deployment := &models.TerraformDeployment{ID: "tf:instance:", LastOperationType: models.UpdateOperationType}
+	var operationIds []string
+	for i := 0; i < 3; i++ {
+		dl := newDeploymentLog(deployment)
+		fmt.Fprintf(dl, "operation %d\n", i)
+		dl.Close()
+
+		operationIds = append(operationIds, dl.operationId)
+	}
+
+	logs, err := db_service.ListTerraformDeploymentLogs(context.Background(), "tf:instance:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logs) != 2 || logs[0].OperationId != operationIds[1] || logs[1].OperationId != operationIds[2] {
+		t.Errorf("Expected only the logs of the newest 2 operations to be kept, got %v", logs)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+)
+
+// DriftReport describes whether the resources of a deployment still match its
+// configuration.
+type DriftReport struct {
+	// ID is the ID of the deployment.
+	ID string `json:"id"`
+
+	// Drifted is true if Terraform would change the resources to make them
+	// match the configuration.
+	Drifted bool `json:"drifted"`
+
+	// Summary is the line of the plan that summarizes the changes.
+	Summary string `json:"summary,omitempty"`
+
+	// Error holds the reason the deployment couldn't be checked.
+	Error string `json:"error,omitempty"`
+
+	// Output holds the full output of `terraform plan`.
+	Output string `json:"output,omitempty"`
+}
+
+// Plan runs `terraform plan` against the stored state and configuration of the
+// deployment with the given ID to detect changes made outside the broker.
+// Failures to plan are reported in the Error field of the report rather than
+// returned so they can be shown alongside the other results.
+func (runner *TfJobRunner) Plan(ctx context.Context, id string) DriftReport {
+	deployment, err := db_service.GetTerraformDeploymentById(ctx, id)
+	if err != nil {
+		return DriftReport{ID: id, Error: err.Error()}
+	}
+
+	return runner.planDeployment(ctx, deployment)
+}
+
+// Drift checks every deployment that has resources for drift.
+// Deployments that were destroyed or never created are skipped.
+func (runner *TfJobRunner) Drift(ctx context.Context) ([]DriftReport, error) {
+	deployments, err := db_service.ListTerraformDeployments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []DriftReport{}
+	for i := range deployments {
+		if !hasResources(&deployments[i]) {
+			continue
+		}
+
+		reports = append(reports, runner.planDeployment(ctx, &deployments[i]))
+	}
+
+	return reports, nil
+}
+
+func (runner *TfJobRunner) planDeployment(ctx context.Context, deployment *models.TerraformDeployment) DriftReport {
+	report := DriftReport{ID: deployment.ID}
+
+	if deployment.LastOperationState == Queued || deployment.LastOperationState == InProgress {
+		report.Error = fmt.Sprintf("a %s operation is pending", deployment.LastOperationType)
+		return report
+	}
+
+	workspace, err := runner.hydrateWorkspace(ctx, deployment)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	var output bytes.Buffer
+	workspace.Output = &output
+
+	report.Drifted, err = workspace.Plan()
+	report.Output = output.String()
+	report.Summary = planSummary(report.Output)
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	return report
+}
+
+// hasResources returns false if the deployment was never created or was
+// destroyed, planning those would report everything as missing.
+func hasResources(deployment *models.TerraformDeployment) bool {
+	switch deployment.LastOperationType {
+	case "validation":
+		return false
+	case models.DeprovisionOperationType:
+		return deployment.LastOperationState != Succeeded
+	default:
+		return true
+	}
+}
+
+// planSummary gets the line of `terraform plan` output that summarizes the
+// changes or an empty string if there is none.
+func planSummary(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Plan:") || strings.HasPrefix(line, "No changes.") {
+			return line
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,124 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/providers/tf/wrapper"
+)
+
+// fakePlanExecutor stands in for Terraform, the stored state of each
+// deployment tells it what the plan should report.
+func fakePlanExecutor(cmd *exec.Cmd) error {
+	if cmd.Args[1] != "plan" {
+		return nil
+	}
+
+	state, err := ioutil.ReadFile(path.Join(cmd.Dir, "terraform.tfstate"))
+	if err != nil {
+		return err
+	}
+
+	switch string(state) {
+	case "drifted":
+		fmt.Fprintln(cmd.Stdout, "Plan: 0 to add, 1 to change, 0 to destroy.")
+		return exec.Command("sh", "-c", "exit 2").Run()
+	case "broken":
+		fmt.Fprintln(cmd.Stdout, "Error: refreshing state")
+		return exec.Command("sh", "-c", "exit 1").Run()
+	default:
+		fmt.Fprintln(cmd.Stdout, "No changes. Infrastructure is up-to-date.")
+		return nil
+	}
+}
+
+func TestTfJobRunner_Drift(t *testing.T) {
+	testDb := newTestDatabase(t)
+	defer testDb.Close()
+
+	deployments := []struct {
+		ID             string
+		State          string
+		OperationType  string
+		OperationState string
+	}{
+		{ID: "tf:broken:", State: "broken", OperationType: models.ProvisionOperationType, OperationState: Succeeded},
+		{ID: "tf:clean:", State: "clean", OperationType: models.ProvisionOperationType, OperationState: Succeeded},
+		{ID: "tf:destroyed:", State: "drifted", OperationType: models.DeprovisionOperationType, OperationState: Succeeded},
+		{ID: "tf:drifted:", State: "drifted", OperationType: models.UpdateOperationType, OperationState: Failed},
+		{ID: "tf:running:", State: "drifted", OperationType: models.ProvisionOperationType, OperationState: InProgress},
+		{ID: "tf:staged:", State: "drifted", OperationType: "validation", OperationState: Succeeded},
+	}
+
+	for _, d := range deployments {
+		ws, err := wrapper.NewWorkspace(map[string]interface{}{}, ``)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ws.State = []byte(d.State)
+
+		workspace, err := ws.Serialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = db_service.CreateTerraformDeployment(context.Background(), &models.TerraformDeployment{
+			ID:                 d.ID,
+			Workspace:          workspace,
+			LastOperationType:  d.OperationType,
+			LastOperationState: d.OperationState,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewTfJobRunnerForProject("my-project")
+	runner.Executor = fakePlanExecutor
+
+	reports, err := runner.Drift(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// drop the output to keep the expectations short
+	for i := range reports {
+		reports[i].Output = ""
+	}
+
+	expected := []DriftReport{
+		{ID: "tf:broken:", Error: "exit status 1"},
+		{ID: "tf:clean:", Summary: "No changes. Infrastructure is up-to-date."},
+		{ID: "tf:drifted:", Drifted: true, Summary: "Plan: 0 to add, 1 to change, 0 to destroy."},
+		{ID: "tf:running:", Error: "a provision operation is pending"},
+	}
+
+	if !reflect.DeepEqual(reports, expected) {
+		t.Errorf("Expected reports %#v, got %#v", expected, reports)
+	}
+
+	if report := runner.Plan(context.Background(), "tf:missing:"); report.Error == "" {
+		t.Errorf("Expected an error planning a missing deployment, got %#v", report)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	maxConcurrentJobsProp = "terraform.max_concurrent_jobs"
+)
+
+func init() {
+	viper.SetDefault(maxConcurrentJobsProp, 10)
+}
+
+// MaxConcurrentJobsProperty computes the Viper property name for the maximum
+// number of Terraform jobs the service can run at once. A value less than 1
+// means the service is only limited by the broker wide limit.
+func MaxConcurrentJobsProperty(serviceName string) string {
+	return fmt.Sprintf("service.%s.terraform.max_concurrent_jobs", serviceName)
+}
+
+// defaultJobPool is shared by every TfJobRunner in the process so the limits
+// apply across all services.
+var defaultJobPool = newJobPool()
+
+// jobPool bounds the number of Terraform jobs running at once, both overall
+// and per service. Jobs that can't run yet wait in the order they were queued,
+// though a job may skip ahead of others that are waiting on the limit of a
+// different service. The time a job was queued is stored with its deployment
+// so jobs recovered after a restart keep their place.
+type jobPool struct {
+	mu        sync.Mutex
+	total     int
+	byService map[string]int
+	waiting   []*poolWaiter
+}
+
+type poolWaiter struct {
+	service  string
+	queuedAt time.Time
+	ready    chan struct{}
+}
+
+func newJobPool() *jobPool {
+	return &jobPool{byService: make(map[string]int)}
+}
+
+// acquire blocks until a job for the given service that was queued at the
+// given time can run. The returned function MUST be called to give up the slot
+// once the job finishes.
+func (pool *jobPool) acquire(service string, queuedAt time.Time) (release func()) {
+	waiter := &poolWaiter{service: service, queuedAt: queuedAt, ready: make(chan struct{})}
+
+	pool.mu.Lock()
+	pool.enqueue(waiter)
+	pool.schedule()
+	pool.mu.Unlock()
+
+	<-waiter.ready
+
+	return func() {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+
+		pool.total--
+		pool.byService[service]--
+		pool.schedule()
+	}
+}
+
+// stats gets the number of jobs running and waiting for a slot.
+func (pool *jobPool) stats() (running, queued int) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	return pool.total, len(pool.waiting)
+}
+
+// enqueue adds the waiter behind every job that was queued before it.
+// The caller MUST hold the lock.
+func (pool *jobPool) enqueue(waiter *poolWaiter) {
+	i := len(pool.waiting)
+	for i > 0 && pool.waiting[i-1].queuedAt.After(waiter.queuedAt) {
+		i--
+	}
+
+	pool.waiting = append(pool.waiting, nil)
+	copy(pool.waiting[i+1:], pool.waiting[i:])
+	pool.waiting[i] = waiter
+}
+
+// schedule starts every waiting job that fits within the limits.
+// The caller MUST hold the lock.
+func (pool *jobPool) schedule() {
+	var stillWaiting []*poolWaiter
+	for _, waiter := range pool.waiting {
+		if !pool.hasCapacity(waiter.service) {
+			stillWaiting = append(stillWaiting, waiter)
+			continue
+		}
+
+		pool.total++
+		pool.byService[waiter.service]++
+		close(waiter.ready)
+	}
+
+	pool.waiting = stillWaiting
+}
+
+// hasCapacity checks the limits at the time of the call so operators can
+// change them without a restart. The caller MUST hold the lock.
+func (pool *jobPool) hasCapacity(service string) bool {
+	if max := viper.GetInt(maxConcurrentJobsProp); max > 0 && pool.total >= max {
+		return false
+	}
+
+	if service == "" {
+		return true
+	}
+
+	max := viper.GetInt(MaxConcurrentJobsProperty(service))
+	return max < 1 || pool.byService[service] < max
+}
@@ -0,0 +1,170 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestJobPool_acquire(t *testing.T) {
+	cases := map[string]struct {
+		GlobalLimit  int
+		ServiceLimit int
+		Running      []string
+		Service      string
+		ExpectQueued bool
+	}{
+		"under limits": {
+			GlobalLimit: 2,
+			Running:     []string{"a"},
+			Service:     "a",
+		},
+		"global limit reached": {
+			GlobalLimit:  1,
+			Running:      []string{"a"},
+			Service:      "b",
+			ExpectQueued: true,
+		},
+		"global limit disabled": {
+			GlobalLimit: 0,
+			Running:     []string{"a", "a", "a"},
+			Service:     "a",
+		},
+		"service limit reached": {
+			GlobalLimit:  10,
+			ServiceLimit: 1,
+			Running:      []string{"limited"},
+			Service:      "limited",
+			ExpectQueued: true,
+		},
+		"other service not limited": {
+			GlobalLimit:  10,
+			ServiceLimit: 1,
+			Running:      []string{"limited"},
+			Service:      "b",
+		},
+		"service without name ignores service limit": {
+			GlobalLimit:  10,
+			ServiceLimit: 1,
+			Running:      []string{""},
+			Service:      "",
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			viper.Set(maxConcurrentJobsProp, tc.GlobalLimit)
+			viper.Set(MaxConcurrentJobsProperty("limited"), tc.ServiceLimit)
+
+			pool := newJobPool()
+			var releases []func()
+			for _, service := range tc.Running {
+				releases = append(releases, pool.acquire(service, time.Now()))
+			}
+
+			acquired := make(chan func())
+			go func() { acquired <- pool.acquire(tc.Service, time.Now()) }()
+
+			select {
+			case release := <-acquired:
+				if tc.ExpectQueued {
+					t.Fatal("expected the job to be queued, but it acquired a slot")
+				}
+				release()
+
+			case <-time.After(100 * time.Millisecond):
+				if !tc.ExpectQueued {
+					t.Fatal("expected the job to acquire a slot, but it was queued")
+				}
+
+				// freeing a slot should let the queued job run
+				releases[0]()
+				releases = releases[1:]
+				(<-acquired)()
+			}
+
+			for _, release := range releases {
+				release()
+			}
+		})
+	}
+}
+
+func TestJobPool_acquireOrder(t *testing.T) {
+	viper.Set(maxConcurrentJobsProp, 1)
+	defer viper.Set(maxConcurrentJobsProp, nil)
+
+	pool := newJobPool()
+	release := pool.acquire("a", time.Now())
+
+	// the newer job is queued first, like a job queued while older ones are
+	// being recovered after a restart
+	now := time.Now()
+	newer := make(chan func())
+	go func() { newer <- pool.acquire("a", now) }()
+	waitForQueued(pool, 1)
+
+	older := make(chan func())
+	go func() { older <- pool.acquire("a", now.Add(-time.Hour)) }()
+	waitForQueued(pool, 2)
+
+	release()
+
+	select {
+	case release := <-older:
+		release()
+	case release := <-newer:
+		release()
+		t.Fatal("expected the job that was queued earlier to get the slot")
+	}
+
+	(<-newer)()
+}
+
+// waitForQueued waits up to a second for the pool to have count jobs waiting.
+func waitForQueued(pool *jobPool, count int) {
+	deadline := time.Now().Add(time.Second)
+	for _, queued := pool.stats(); queued < count && time.Now().Before(deadline); _, queued = pool.stats() {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestJobPool_stats(t *testing.T) {
+	viper.Set(maxConcurrentJobsProp, 1)
+	defer viper.Set(maxConcurrentJobsProp, nil)
+
+	pool := newJobPool()
+	release := pool.acquire("a", time.Now())
+
+	acquired := make(chan func())
+	go func() { acquired <- pool.acquire("a", time.Now()) }()
+
+	// wait for the second job to be queued
+	waitForQueued(pool, 1)
+
+	if running, queued := pool.stats(); running != 1 || queued != 1 {
+		t.Errorf("Expected 1 job running and 1 queued, got %d running and %d queued", running, queued)
+	}
+
+	release()
+	(<-acquired)()
+
+	if running, queued := pool.stats(); running != 0 || queued != 0 {
+		t.Errorf("Expected the pool to be empty, got %d running and %d queued", running, queued)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
@@ -27,11 +28,31 @@ import (
 )
 
 const (
+	Queued     = "queued"
 	InProgress = "in progress"
 	Succeeded  = "succeeded"
 	Failed     = "failed"
+
+	// leaseDuration is how long a job holds its deployment without renewing
+	// the lease through a heartbeat.
+	leaseDuration = 2 * time.Minute
+
+	// heartbeatInterval is how often running jobs renew their lease.
+	heartbeatInterval = 30 * time.Second
 )
 
+// jobOwnerId identifies this broker process as the owner of the jobs it runs.
+var jobOwnerId = newJobOwnerId()
+
+func newJobOwnerId() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown-host"
+	}
+
+	return fmt.Sprintf("%s:%d:%d", hostname, os.Getpid(), time.Now().UnixNano())
+}
+
 // NewTfJobRunerFromEnv creates a new TfJobRunner with default configuration values.
 func NewTfJobRunerFromEnv() (*TfJobRunner, error) {
 	projectId, err := utils.GetDefaultProjectId()
@@ -66,11 +87,32 @@ type TfJobRunner struct {
 
 	// Executor holds a custom executor that will be called when commands are run.
 	Executor wrapper.TerraformExecutor
+
+	// ServiceName is the name of the service the jobs belong to, it's used to
+	// apply the per-service concurrency limit. Jobs without a service name are
+	// only subject to the broker wide limit.
+	ServiceName string
 }
 
 // StageJob stages a job to be executed. Before the workspace is saved to the
 // database, the modules and inputs are validated by Terraform.
+// Workspaces without a backend get the one configured for new deployments.
 func (runner *TfJobRunner) StageJob(ctx context.Context, jobId string, workspace *wrapper.TerraformWorkspace) error {
+	if workspace.Backend == nil {
+		backend, err := defaultStateBackend(jobId)
+		if err != nil {
+			return err
+		}
+		workspace.Backend = backend
+	}
+
+	if workspace.Environment == nil {
+		workspace.Environment = make(map[string]string)
+	}
+	for k, v := range stateBackendEnvironment(workspace.Backend) {
+		workspace.Environment[k] = v
+	}
+
 	// Validate that TF is happy with the workspace
 	if err := workspace.Validate(); err != nil {
 		return err
@@ -87,20 +129,58 @@ func (runner *TfJobRunner) StageJob(ctx context.Context, jobId string, workspace
 		LastOperationType: "validation",
 	}
 
-	return runner.operationFinished(nil, workspace, deployment)
+	finishOperation(nil, workspace, deployment)
+	return db_service.SaveTerraformDeployment(ctx, deployment)
 }
 
-func (runner *TfJobRunner) markJobStarted(ctx context.Context, deployment *models.TerraformDeployment, operationType string) error {
+// markJobQueued takes the lease on the deployment and records that the
+// operation is waiting for a slot in the job pool.
+func (runner *TfJobRunner) markJobQueued(ctx context.Context, deployment *models.TerraformDeployment, operationType string) error {
+	// take the lease so no other operation can run on the deployment
+	expiration := time.Now().Add(leaseDuration)
+	acquired, err := db_service.AcquireTerraformDeploymentLease(ctx, deployment.ID, jobOwnerId, expiration, false)
+	if err != nil {
+		return err
+	}
+
+	if !acquired {
+		return fmt.Errorf("another operation is already in progress on %q", deployment.ID)
+	}
+
 	// update the deployment info
+	queuedAt := time.Now()
 	deployment.LastOperationType = operationType
-	deployment.LastOperationState = InProgress
+	deployment.LastOperationState = Queued
 	deployment.LastOperationMessage = ""
+	deployment.LeaseOwner = jobOwnerId
+	deployment.LeaseExpiration = &expiration
+	deployment.QueuedAt = &queuedAt
 
-	if err := db_service.SaveTerraformDeployment(ctx, deployment); err != nil {
-		return err
+	return saveLeasedDeployment(ctx, deployment)
+}
+
+// markJobRunning records that the operation got a slot in the job pool and is
+// now running.
+func (runner *TfJobRunner) markJobRunning(ctx context.Context, deployment *models.TerraformDeployment) error {
+	// The job may have been queued for longer than the lease, so the expiration
+	// is renewed rather than overwritten with the one from when it was queued.
+	expiration := time.Now().Add(leaseDuration)
+	deployment.LastOperationState = InProgress
+	deployment.LeaseExpiration = &expiration
+
+	return saveLeasedDeployment(ctx, deployment)
+}
+
+// saveLeasedDeployment saves the deployment if this process still holds its
+// lease so a job that lost its deployment to another process doesn't
+// overwrite the other process's work.
+func saveLeasedDeployment(ctx context.Context, deployment *models.TerraformDeployment) error {
+	err := db_service.SaveTerraformDeploymentIfLeaseOwner(ctx, deployment, jobOwnerId)
+	if err == db_service.ErrConcurrentModification {
+		return fmt.Errorf("the lease on %q was taken over by another process", deployment.ID)
 	}
 
-	return nil
+	return err
 }
 
 func (runner *TfJobRunner) hydrateWorkspace(ctx context.Context, deployment *models.TerraformDeployment) (*wrapper.TerraformWorkspace, error) {
@@ -110,10 +190,9 @@ func (runner *TfJobRunner) hydrateWorkspace(ctx context.Context, deployment *mod
 	}
 
 	// set environment variables
-	ws.Environment = map[string]string{
-		"GOOGLE_CREDENTIALS": runner.ServiceAccount,
-		"GOOGLE_PROJECT":     runner.ProjectId,
-	}
+	ws.Environment = stateBackendEnvironment(ws.Backend)
+	ws.Environment["GOOGLE_CREDENTIALS"] = runner.ServiceAccount
+	ws.Environment["GOOGLE_PROJECT"] = runner.ProjectId
 
 	if runner.Executor != nil {
 		ws.Executor = runner.Executor
@@ -122,8 +201,8 @@ func (runner *TfJobRunner) hydrateWorkspace(ctx context.Context, deployment *mod
 	return ws, nil
 }
 
-// Create runs `terraform apply` on the given workspace in the background.
-// The status of the job can be found by polling the Status function.
+// Create runs `terraform apply` on the given workspace in the background once
+// there's room in the job pool. The status of the job can be found by polling the Status function.
 func (runner *TfJobRunner) Create(ctx context.Context, id string) error {
 	deployment, err := db_service.GetTerraformDeploymentById(ctx, id)
 	if err != nil {
@@ -135,20 +214,58 @@ func (runner *TfJobRunner) Create(ctx context.Context, id string) error {
 		return err
 	}
 
-	if err := runner.markJobStarted(ctx, deployment, models.ProvisionOperationType); err != nil {
+	if err := runner.markJobQueued(ctx, deployment, models.ProvisionOperationType); err != nil {
 		return err
 	}
 
-	go func() {
-		err := workspace.Apply()
-		runner.operationFinished(err, workspace, deployment)
-	}()
+	runner.runJob(deployment, workspace, workspace.Apply, nil)
+	return nil
+}
+
+// Update applies a change to the configuration of an existing deployment in
+// the background. The module instances are reconfigured with the given
+// variables and `terraform apply` is run against the existing state.
+// If the apply fails, the previous configuration is restored so the next
+// update starts from the last known good inputs. The state Terraform wrote is
+// kept because a partial apply may already have replaced resources.
+// The status of the job can be found by polling the Status function.
+func (runner *TfJobRunner) Update(ctx context.Context, id string, templateVars map[string]interface{}) error {
+	deployment, err := db_service.GetTerraformDeploymentById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	previous, err := wrapper.DeserializeWorkspace(deployment.Workspace)
+	if err != nil {
+		return err
+	}
+
+	workspace, err := runner.hydrateWorkspace(ctx, deployment)
+	if err != nil {
+		return err
+	}
+
+	if err := workspace.UpdateInstanceConfiguration(templateVars); err != nil {
+		return err
+	}
 
+	// Validate that TF is happy with the new configuration before touching
+	// the deployment.
+	if err := workspace.Validate(); err != nil {
+		return err
+	}
+
+	if err := runner.markJobQueued(ctx, deployment, models.UpdateOperationType); err != nil {
+		return err
+	}
+
+	runner.runJob(deployment, workspace, workspace.Apply, previous)
 	return nil
 }
 
 // Destroy runs `terraform destroy` on the given workspace in the background.
 // The status of the job can be found by polling the Status function.
+// Like Create, the job is queued until there's room in the job pool.
 func (runner *TfJobRunner) Destroy(ctx context.Context, id string) error {
 	deployment, err := db_service.GetTerraformDeploymentById(ctx, id)
 	if err != nil {
@@ -160,21 +277,131 @@ func (runner *TfJobRunner) Destroy(ctx context.Context, id string) error {
 		return err
 	}
 
-	if err := runner.markJobStarted(ctx, deployment, models.DeprovisionOperationType); err != nil {
+	if err := runner.markJobQueued(ctx, deployment, models.DeprovisionOperationType); err != nil {
 		return err
 	}
 
+	runner.runJob(deployment, workspace, workspace.Destroy, nil)
+	return nil
+}
+
+// runJob runs the operation in the background once it gets a slot in the job
+// pool and records the result once it completes. The lease on the deployment
+// is renewed both while the job is queued and while it runs.
+// The output of Terraform is stored in the deployment's logs and the end of it
+// is added to the failure message if the operation fails. The logs are deleted
+// once a deprovision succeeds.
+// If rollback is non-nil and the operation fails, the modules and instances
+// of rollback replace those of the workspace. The Terraform state is kept.
+func (runner *TfJobRunner) runJob(deployment *models.TerraformDeployment, workspace *wrapper.TerraformWorkspace, operation func() error, rollback *wrapper.TerraformWorkspace) {
 	go func() {
-		err := workspace.Destroy()
-		runner.operationFinished(err, workspace, deployment)
+		done := make(chan struct{})
+		go runner.heartbeat(deployment.ID, workspace, done)
+
+		queuedAt := time.Now()
+		if deployment.QueuedAt != nil {
+			queuedAt = *deployment.QueuedAt
+		}
+
+		release := defaultJobPool.acquire(runner.ServiceName, queuedAt)
+		defer release()
+
+		log := newDeploymentLog(deployment)
+		workspace.Output = log
+
+		err := runner.markJobRunning(context.Background(), deployment)
+		if err == nil {
+			start := time.Now()
+			err = operation()
+			observeJob(runner.ServiceName, deployment.LastOperationType, start, err)
+		}
+		close(done)
+		log.Close()
+
+		// errors from Terraform are usually just the exit code, the output
+		// explains what went wrong
+		if err != nil {
+			if tail := log.Tail(); tail != "" {
+				err = fmt.Errorf("%v: %s", err, tail)
+			}
+		}
+
+		if err != nil && rollback != nil {
+			workspace.Modules = rollback.Modules
+			workspace.Instances = rollback.Instances
+		}
+
+		saveErr := runner.operationFinished(err, workspace, deployment)
+
+		// the resources are gone so the output that created them isn't needed
+		if err == nil && saveErr == nil && deployment.LastOperationType == models.DeprovisionOperationType {
+			db_service.PurgeOldTerraformDeploymentLogs(context.Background(), deployment.ID, 0)
+		}
 	}()
+}
 
-	return nil
+// heartbeat renews the lease on the deployment until done is closed or the
+// lease is lost to another process.
+// Each renewal also stores a snapshot of the workspace with the partial
+// Terraform state so it can be recovered if the broker stops mid-operation.
+func (runner *TfJobRunner) heartbeat(id string, workspace *wrapper.TerraformWorkspace, done <-chan struct{}) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+
+		case <-ticker.C:
+			// A failed renewal is retried on the next tick, the lease only lapses
+			// if renewals keep failing until it expires.
+			snapshot, err := snapshotWorkspace(workspace)
+			if err != nil {
+				continue
+			}
+
+			expiration := time.Now().Add(leaseDuration)
+			renewed, err := db_service.RenewTerraformDeploymentLease(context.Background(), id, jobOwnerId, expiration, snapshot)
+			if err == nil && !renewed {
+				// Another process recovered the deployment. The job keeps running
+				// because Terraform can't be stopped safely, but it no longer
+				// writes to the deployment.
+				return
+			}
+		}
+	}
+}
+
+// snapshotWorkspace serializes the workspace with the state Terraform has
+// written so far.
+func snapshotWorkspace(workspace *wrapper.TerraformWorkspace) (string, error) {
+	state, err := workspace.CurrentState()
+	if err != nil {
+		return "", err
+	}
+
+	snapshot := wrapper.TerraformWorkspace{
+		Modules:   workspace.Modules,
+		Instances: workspace.Instances,
+		State:     state,
+		Backend:   workspace.Backend,
+	}
+
+	return snapshot.Serialize()
 }
 
 // operationFinished closes out the state of the background job so clients that
-// are polling can get the results.
+// are polling can get the results. The result is only saved if this process
+// still holds the lease on the deployment.
 func (runner *TfJobRunner) operationFinished(err error, workspace *wrapper.TerraformWorkspace, deployment *models.TerraformDeployment) error {
+	finishOperation(err, workspace, deployment)
+	return saveLeasedDeployment(context.Background(), deployment)
+}
+
+// finishOperation records the result of the operation and the final workspace
+// in the deployment and releases its lease.
+func finishOperation(err error, workspace *wrapper.TerraformWorkspace, deployment *models.TerraformDeployment) {
 	if err == nil {
 		deployment.LastOperationState = Succeeded
 		deployment.LastOperationMessage = ""
@@ -190,8 +417,8 @@ func (runner *TfJobRunner) operationFinished(err error, workspace *wrapper.Terra
 	}
 
 	deployment.Workspace = workspaceString
-
-	return db_service.SaveTerraformDeployment(context.Background(), deployment)
+	deployment.LeaseOwner = ""
+	deployment.LeaseExpiration = nil
 }
 
 // Status gets the status of the most recent job on the workspace.
@@ -213,6 +440,27 @@ func (runner *TfJobRunner) Status(ctx context.Context, id string) (isDone bool,
 	}
 }
 
+// Exists checks if a deployment with the given ID exists.
+func (runner *TfJobRunner) Exists(ctx context.Context, id string) (bool, error) {
+	count, err := db_service.CountTerraformDeploymentById(ctx, id)
+	return count > 0, err
+}
+
+// Description gets a human readable description of the most recent job on the
+// workspace to show users while it's pending.
+func (runner *TfJobRunner) Description(ctx context.Context, id string) (string, error) {
+	deployment, err := db_service.GetTerraformDeploymentById(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	if deployment.LastOperationState == Queued {
+		return fmt.Sprintf("%s queued, waiting for other operations to finish", deployment.LastOperationType), nil
+	}
+
+	return deployment.LastOperationMessage, nil
+}
+
 // Outputs gets the output variables for the given module instance in the workspace.
 func (runner *TfJobRunner) Outputs(ctx context.Context, id, instanceName string) (map[string]interface{}, error) {
 	deployment, err := db_service.GetTerraformDeploymentById(ctx, id)
@@ -220,7 +468,7 @@ func (runner *TfJobRunner) Outputs(ctx context.Context, id, instanceName string)
 		return nil, err
 	}
 
-	ws, err := wrapper.DeserializeWorkspace(deployment.Workspace)
+	ws, err := runner.hydrateWorkspace(ctx, deployment)
 	if err != nil {
 		return nil, err
 	}
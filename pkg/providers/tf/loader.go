@@ -0,0 +1,431 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/providers/tf/wrapper"
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	// ServiceDefinitionsProp is the Viper property for the directory or glob of
+	// service definition files and brokerpaks to load on startup.
+	ServiceDefinitionsProp = "terraform.service_definitions"
+
+	// BrokerpakExtension is the file extension of brokerpaks.
+	BrokerpakExtension = ".brokerpak"
+
+	// BrokerpakDirProp is the Viper property for the directory brokerpaks are
+	// extracted into. Each brokerpak gets a subdirectory that's replaced every
+	// time the broker starts so extractions don't pile up.
+	BrokerpakDirProp = "terraform.brokerpak_dir"
+
+	// brokerpakPluginDir is the directory in a brokerpak holding the Terraform
+	// provider plugins its services use.
+	brokerpakPluginDir = "plugins"
+)
+
+func init() {
+	viper.SetDefault(BrokerpakDirProp, filepath.Join(os.TempDir(), "gcp-service-broker-brokerpaks"))
+}
+
+// RegisterServiceDefinitionsFromConfig loads the service definitions the
+// operator configured and registers them with the default registry.
+// Nothing is loaded if the property isn't set.
+func RegisterServiceDefinitionsFromConfig() error {
+	pattern := viper.GetString(ServiceDefinitionsProp)
+	if pattern == "" {
+		return nil
+	}
+
+	return RegisterServiceDefinitions(broker.DefaultRegistry, pattern)
+}
+
+// RegisterServiceDefinitions loads the service definition files and
+// brokerpaks matching the pattern and registers their services.
+// The pattern is either a directory, in which case all the YAML files and
+// brokerpaks in it are loaded, or a glob.
+// All the definitions are validated before any are registered.
+func RegisterServiceDefinitions(registry broker.BrokerRegistry, pattern string) error {
+	definitions, err := loadServiceDefinitions(pattern)
+	if err != nil {
+		return err
+	}
+
+	var services []*broker.ServiceDefinition
+	for _, defn := range definitions {
+		if err := checkUnique(registry, services, defn.definition); err != nil {
+			return fmt.Errorf("couldn't register service definition %q: %v", defn.source, err)
+		}
+
+		service, err := defn.definition.ToService()
+		if err != nil {
+			return fmt.Errorf("couldn't register service definition %q: %v", defn.source, err)
+		}
+
+		services = append(services, service)
+	}
+
+	for _, service := range services {
+		registry.Register(service)
+	}
+
+	return nil
+}
+
+// checkUnique makes sure the name and ID of the definition don't collide with
+// an already registered service or one about to be registered. The registry
+// exits the process on collisions so they're checked beforehand to be able to
+// say which file caused them.
+func checkUnique(registry broker.BrokerRegistry, pending []*broker.ServiceDefinition, defn *TfServiceDefinitionV1) error {
+	existing := append(registry.GetAllServices(), pending...)
+	for _, svc := range existing {
+		if svc.Name == defn.Name {
+			return fmt.Errorf("a service named %q is already registered", defn.Name)
+		}
+
+		entry, err := svc.CatalogEntry()
+		if err != nil {
+			return err
+		}
+
+		if entry.ID == defn.Id {
+			return fmt.Errorf("the service %q already has the ID %q", svc.Name, defn.Id)
+		}
+	}
+
+	return nil
+}
+
+// loadedDefinition is a service definition along with where it was loaded
+// from for error messages.
+type loadedDefinition struct {
+	source     string
+	definition *TfServiceDefinitionV1
+}
+
+// loadServiceDefinitions loads and validates the service definition files and
+// brokerpaks matching the pattern. See RegisterServiceDefinitions for the
+// format of the pattern.
+func loadServiceDefinitions(pattern string) ([]loadedDefinition, error) {
+	paths, err := expandDefinitionPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []loadedDefinition
+	for _, path := range paths {
+		switch {
+		case isYamlFile(path):
+			defn, err := LoadServiceDefinitionFile(path)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, loadedDefinition{source: path, definition: defn})
+
+		case filepath.Ext(path) == BrokerpakExtension:
+			definitions, err := loadBrokerpak(path)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, definitions...)
+
+		default:
+			return nil, fmt.Errorf("unknown service definition format %q, expected a YAML file or a %s", path, BrokerpakExtension)
+		}
+	}
+
+	return out, nil
+}
+
+func expandDefinitionPattern(pattern string) ([]string, error) {
+	if stat, err := os.Stat(pattern); err == nil && stat.IsDir() {
+		files, err := ioutil.ReadDir(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		var paths []string
+		for _, file := range files {
+			path := filepath.Join(pattern, file.Name())
+			if !file.IsDir() && (isYamlFile(path) || filepath.Ext(path) == BrokerpakExtension) {
+				paths = append(paths, path)
+			}
+		}
+
+		return paths, nil
+	}
+
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service definition pattern %q: %v", pattern, err)
+	}
+
+	return paths, nil
+}
+
+func isYamlFile(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".yml" || ext == ".yaml"
+}
+
+// LoadServiceDefinitionFile reads a TfServiceDefinitionV1 from a YAML file,
+// loads the templates it references and validates it. Errors include the path
+// of the file.
+func LoadServiceDefinitionFile(path string) (*TfServiceDefinitionV1, error) {
+	return loadServiceDefinitionFile(path, path)
+}
+
+// loadServiceDefinitionFile is like LoadServiceDefinitionFile, but errors name
+// the file source rather than its path on disk.
+func loadServiceDefinitionFile(path, source string) (*TfServiceDefinitionV1, error) {
+	defn, err := parseServiceDefinitionFile(path, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := defn.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid service definition %q: %v", source, err)
+	}
+
+	return defn, nil
+}
+
+// parseServiceDefinitionFile reads the definition and the templates it
+// references without validating it.
+func parseServiceDefinitionFile(path, source string) (*TfServiceDefinitionV1, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defn := &TfServiceDefinitionV1{}
+	if err := yaml.UnmarshalStrict(contents, defn); err != nil {
+		return nil, fmt.Errorf("couldn't parse service definition %q: %v", source, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, action := range []*TfServiceDefinitionV1Action{&defn.ProvisionSettings, &defn.BindSettings} {
+		if err := action.loadTemplateRef(dir); err != nil {
+			return nil, fmt.Errorf("invalid service definition %q: %v", source, err)
+		}
+	}
+
+	return defn, nil
+}
+
+// loadTemplateRef reads the templates referenced by the TemplateRef of the
+// action and its modules into their Template.
+func (action *TfServiceDefinitionV1Action) loadTemplateRef(dir string) error {
+	if err := readTemplateRef(dir, action.TemplateRef, &action.Template); err != nil {
+		return err
+	}
+
+	for i := range action.Modules {
+		module := &action.Modules[i]
+		if err := readTemplateRef(dir, module.TemplateRef, &module.Template); err != nil {
+			return fmt.Errorf("module %q: %v", module.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// readTemplateRef reads the template at the path ref relative to dir into
+// template if ref is set.
+func readTemplateRef(dir, ref string, template *string) error {
+	if ref == "" {
+		return nil
+	}
+
+	if *template != "" {
+		return errors.New("only one of template and template_ref can be set")
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(dir, ref))
+	if err != nil {
+		return fmt.Errorf("couldn't read template_ref: %v", err)
+	}
+
+	*template = string(contents)
+	return nil
+}
+
+// loadBrokerpak extracts the brokerpak into its directory under
+// BrokerpakDirProp and loads the service definitions in it. The directory is
+// removed if the brokerpak can't be loaded, otherwise it's kept for the
+// services' jobs to use its plugins.
+//
+// A brokerpak is a gzipped tarball holding YAML service definitions at its
+// root along with the templates they reference. Terraform provider plugins
+// the services need can be bundled in a plugins directory, the services'
+// jobs are then run with those plugins rather than downloading them.
+func loadBrokerpak(pakPath string) ([]loadedDefinition, error) {
+	dir, err := brokerpakDir(pakPath)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := loadExtractedBrokerpak(pakPath, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// brokerpakDir gets an empty directory to extract the brokerpak into. The
+// directory is named after the brokerpak and a hash of its absolute path so
+// the same brokerpak reuses it across restarts while brokerpaks with the same
+// file name in different directories don't clash.
+func brokerpakDir(pakPath string) (string, error) {
+	abs, err := filepath.Abs(pakPath)
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(abs), BrokerpakExtension)
+	sum := sha256.Sum256([]byte(abs))
+	dir := filepath.Join(viper.GetString(BrokerpakDirProp), fmt.Sprintf("%s-%x", name, sum[:4]))
+
+	// Clear out the previous extraction so files removed from the brokerpak
+	// don't linger.
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// loadExtractedBrokerpak extracts the brokerpak into dir and loads the service
+// definitions in it.
+func loadExtractedBrokerpak(pakPath, dir string) ([]loadedDefinition, error) {
+	if err := extractTarball(pakPath, dir); err != nil {
+		return nil, fmt.Errorf("couldn't extract brokerpak %q: %v", pakPath, err)
+	}
+
+	var executor wrapper.TerraformExecutor
+	pluginDir := filepath.Join(dir, brokerpakPluginDir)
+	if stat, err := os.Stat(pluginDir); err == nil && stat.IsDir() {
+		executor = func(c *exec.Cmd) error {
+			// c.Path is the Terraform binary exec found on the PATH
+			return wrapper.CustomTerraformExecutor(c.Path, pluginDir, wrapper.DefaultExecutor)(c)
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []loadedDefinition
+	for _, file := range files {
+		if file.IsDir() || !isYamlFile(file.Name()) {
+			continue
+		}
+
+		source := fmt.Sprintf("%s:%s", pakPath, file.Name())
+		defn, err := loadServiceDefinitionFile(filepath.Join(dir, file.Name()), source)
+		if err != nil {
+			return nil, err
+		}
+
+		defn.Executor = executor
+		out = append(out, loadedDefinition{source: source, definition: defn})
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("brokerpak %q has no service definitions", pakPath)
+	}
+
+	return out, nil
+}
+
+// extractTarball extracts the gzipped tarball into dir. Entries that would be
+// written outside of dir are rejected.
+func extractTarball(tarball, dir string) error {
+	file, err := os.Open(tarball)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, header.Name)
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("the entry %q is outside of the brokerpak", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+
+		case tar.TypeReg, tar.TypeRegA:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return err
+			}
+
+			_, err = io.Copy(out, reader)
+			out.Close()
+			if err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("the entry %q isn't a regular file or directory", header.Name)
+		}
+	}
+}
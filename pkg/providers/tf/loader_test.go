@@ -0,0 +1,315 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
+	"github.com/spf13/viper"
+)
+
+const exampleDefinitionYaml = `version: 1
+name: example-bucket
+id: 5d8b4b3c-0ed6-4c06-a3b4-5d5a5e3f3c2b
+description: An example bucket.
+display_name: Example Bucket
+image_url: https://example.com/icon.svg
+documentation_url: https://example.com/docs
+support_url: https://example.com/support
+tags: [example, terraform]
+plans:
+- id: 9b7ac6a5-7dd3-4b6c-9a64-ac3e0a8b4bf1
+  name: standard
+  description: Standard storage class.
+  metadata:
+    displayName: Standard
+  service_properties:
+    storage_class: STANDARD
+provision:
+  plan_inputs:
+  - field_name: storage_class
+    type: string
+    details: The storage class of the bucket.
+  user_inputs:
+  - field_name: name
+    type: string
+    details: The name of the bucket.
+    default: example-${request.instance_id}
+    forces_replacement: true
+  template_ref: bucket.tf
+  outputs:
+  - field_name: bucket_name
+    type: string
+    details: The name of the bucket.
+bind:
+  template: ""
+examples:
+- name: Basic
+  description: Create a bucket.
+  plan_id: 9b7ac6a5-7dd3-4b6c-9a64-ac3e0a8b4bf1
+  provision_params: {}
+  bind_params: {}
+`
+
+const exampleBucketTemplate = `
+variable name {type = "string"}
+variable storage_class {type = "string"}
+
+output bucket_name {value = "${var.name}"}
+`
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func writeBrokerpak(t *testing.T, path string, files map[string]string) {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	defer gz.Close()
+
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	for name, contents := range files {
+		header := &tar.Header{Name: name, Mode: 0755, Size: int64(len(contents)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRegisterServiceDefinitions(t *testing.T) {
+	cases := map[string]struct {
+		Files       map[string]string
+		Brokerpak   map[string]string
+		Pattern     string
+		Expected    []string
+		ErrContains []string
+	}{
+		"directory": {
+			Files:    map[string]string{"example.yml": exampleDefinitionYaml, "bucket.tf": exampleBucketTemplate, "README.md": "ignored"},
+			Expected: []string{"example-bucket"},
+		},
+		"glob": {
+			Files:    map[string]string{"example.yml": exampleDefinitionYaml, "bucket.tf": exampleBucketTemplate},
+			Pattern:  "*.yml",
+			Expected: []string{"example-bucket"},
+		},
+		"unknown format in glob": {
+			Files:       map[string]string{"example.json": "{}"},
+			Pattern:     "*",
+			ErrContains: []string{"example.json", "unknown service definition format"},
+		},
+		"parse error names file": {
+			Files:       map[string]string{"broken.yml": "version: [1"},
+			ErrContains: []string{"broken.yml", "couldn't parse"},
+		},
+		"unknown field names file": {
+			Files:       map[string]string{"typo.yml": exampleDefinitionYaml + "plan_typo: true\n"},
+			ErrContains: []string{"typo.yml", "plan_typo"},
+		},
+		"validation error names file": {
+			Files:       map[string]string{"invalid.yml": strings.Replace(exampleDefinitionYaml, "id: 5d8b4b3c-0ed6-4c06-a3b4-5d5a5e3f3c2b", "id: not-a-uuid", 1), "bucket.tf": exampleBucketTemplate},
+			ErrContains: []string{"invalid.yml", "Id"},
+		},
+		"missing template ref": {
+			Files:       map[string]string{"example.yml": exampleDefinitionYaml},
+			ErrContains: []string{"example.yml", "template_ref"},
+		},
+		"module template ref": {
+			Files: map[string]string{
+				"example.yml":        strings.Replace(exampleDefinitionYaml, "  template_ref: bucket.tf\n", "  template_ref: bucket.tf\n  modules:\n  - name: networking\n    template_ref: modules/network.tf\n", 1),
+				"bucket.tf":          exampleBucketTemplate,
+				"modules/network.tf": `output network_name {value = "default"}`,
+			},
+			Expected: []string{"example-bucket"},
+		},
+		"missing module template ref": {
+			Files: map[string]string{
+				"example.yml": strings.Replace(exampleDefinitionYaml, "  template_ref: bucket.tf\n", "  template_ref: bucket.tf\n  modules:\n  - name: networking\n    template_ref: modules/network.tf\n", 1),
+				"bucket.tf":   exampleBucketTemplate,
+			},
+			ErrContains: []string{"example.yml", `module "networking"`, "template_ref"},
+		},
+		"duplicate service": {
+			Files: map[string]string{
+				"a.yml":     exampleDefinitionYaml,
+				"b.yml":     strings.Replace(exampleDefinitionYaml, "name: example-bucket", "name: other-bucket", 1),
+				"bucket.tf": exampleBucketTemplate,
+			},
+			ErrContains: []string{"b.yml", "already has the ID"},
+		},
+		"brokerpak": {
+			Brokerpak: map[string]string{"example.yml": exampleDefinitionYaml, "bucket.tf": exampleBucketTemplate, "plugins/terraform-provider-google": "binary"},
+			Expected:  []string{"example-bucket"},
+		},
+		"brokerpak error names file in brokerpak": {
+			Brokerpak:   map[string]string{"broken.yml": "version: [1"},
+			ErrContains: []string{"example.brokerpak:broken.yml"},
+		},
+		"brokerpak escaping directory": {
+			Brokerpak:   map[string]string{"../evil.yml": exampleDefinitionYaml},
+			ErrContains: []string{"example.brokerpak", "outside of the brokerpak"},
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "definitions")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			viper.Set(BrokerpakDirProp, filepath.Join(dir, "extracted"))
+			defer viper.Set(BrokerpakDirProp, nil)
+
+			writeFiles(t, dir, tc.Files)
+			if tc.Brokerpak != nil {
+				writeBrokerpak(t, filepath.Join(dir, "example.brokerpak"), tc.Brokerpak)
+			}
+
+			pattern := filepath.Join(dir, tc.Pattern)
+			registry := broker.BrokerRegistry{}
+			err = RegisterServiceDefinitions(registry, pattern)
+
+			for _, expected := range tc.ErrContains {
+				if err == nil || !strings.Contains(err.Error(), expected) {
+					t.Errorf("Expected error containing %q, got %v", expected, err)
+				}
+			}
+
+			if tc.ErrContains == nil && err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			if tc.ErrContains != nil && len(registry) != 0 {
+				t.Errorf("Expected no services to be registered on error, got %d", len(registry))
+			}
+
+			var names []string
+			for _, svc := range registry.GetAllServices() {
+				names = append(names, svc.Name)
+			}
+
+			if strings.Join(names, ",") != strings.Join(tc.Expected, ",") {
+				t.Errorf("Expected services %v to be registered, got %v", tc.Expected, names)
+			}
+		})
+	}
+}
+
+func TestLoadBrokerpak_extractionDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "definitions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	extractDir := filepath.Join(dir, "extracted")
+	viper.Set(BrokerpakDirProp, extractDir)
+	defer viper.Set(BrokerpakDirProp, nil)
+
+	pakPath := filepath.Join(dir, "example.brokerpak")
+	writeBrokerpak(t, pakPath, map[string]string{"example.yml": exampleDefinitionYaml, "bucket.tf": exampleBucketTemplate, "stale.md": "removed later"})
+	for i := 0; i < 2; i++ {
+		if _, err := loadBrokerpak(pakPath); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	pakDir, err := brokerpakDir(pakPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeBrokerpak(t, pakPath, map[string]string{"example.yml": exampleDefinitionYaml, "bucket.tf": exampleBucketTemplate})
+	if _, err := loadBrokerpak(pakPath); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	extracted, err := ioutil.ReadDir(extractDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extracted) != 1 || filepath.Join(extractDir, extracted[0].Name()) != pakDir {
+		t.Errorf("Expected the brokerpak to be extracted into %q only, got %v", pakDir, extracted)
+	}
+
+	if _, err := os.Stat(filepath.Join(pakDir, "stale.md")); !os.IsNotExist(err) {
+		t.Errorf("Expected files of the previous extraction to be removed, got %v", err)
+	}
+
+	writeBrokerpak(t, pakPath, map[string]string{"broken.yml": "version: [1"})
+	if _, err := loadBrokerpak(pakPath); err == nil {
+		t.Fatal("Expected an error loading the broken brokerpak")
+	}
+
+	if _, err := os.Stat(pakDir); !os.IsNotExist(err) {
+		t.Errorf("Expected the extraction directory to be removed on error, got %v", err)
+	}
+}
+
+func TestLoadServiceDefinitionFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "definitions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFiles(t, dir, map[string]string{"example.yml": exampleDefinitionYaml, "bucket.tf": exampleBucketTemplate})
+
+	defn, err := LoadServiceDefinitionFile(filepath.Join(dir, "example.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if defn.ProvisionSettings.Template != exampleBucketTemplate {
+		t.Errorf("Expected the template_ref to be loaded, got %q", defn.ProvisionSettings.Template)
+	}
+
+	if defn.Plans[0].Metadata.DisplayName != "Standard" || defn.Plans[0].ServiceProperties["storage_class"] != "STANDARD" {
+		t.Errorf("Expected the plan to be decoded, got %#v", defn.Plans[0])
+	}
+
+	if !defn.ProvisionSettings.UserInputs[0].ForcesReplacement {
+		t.Errorf("Expected the user input to be decoded, got %#v", defn.ProvisionSettings.UserInputs[0])
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// terraformJobBuckets are the histogram buckets, in seconds, for Terraform
+// jobs which can take from seconds to the better part of an hour.
+var terraformJobBuckets = []float64{5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+var terraformJobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "gsb_terraform_job_duration_seconds",
+	Help:    "Time taken by Terraform to run jobs, excluding the time they were queued.",
+	Buckets: terraformJobBuckets,
+}, []string{"service", "operation", "result"})
+
+func init() {
+	prometheus.MustRegister(
+		terraformJobDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "gsb_terraform_jobs_running",
+			Help: "Terraform jobs running in the job pool.",
+		}, func() float64 {
+			running, _ := defaultJobPool.stats()
+			return float64(running)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "gsb_terraform_jobs_queued",
+			Help: "Terraform jobs waiting for a slot in the job pool.",
+		}, func() float64 {
+			_, queued := defaultJobPool.stats()
+			return float64(queued)
+		}),
+	)
+}
+
+// observeJob records how long a Terraform job that started at start took.
+func observeJob(serviceName, operationType string, start time.Time, err error) {
+	result := "succeeded"
+	if err != nil {
+		result = "failed"
+	}
+
+	terraformJobDuration.WithLabelValues(serviceName, operationType, result).Observe(time.Since(start).Seconds())
+}
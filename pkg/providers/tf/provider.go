@@ -60,22 +60,18 @@ func (provider *terraformProvider) Provision(ctx context.Context, provisionConte
 	}, nil
 }
 
-// Bind creates a new backing Terraform job and executes it, waiting on the result.
+// Bind creates a new backing Terraform job and executes it in the background.
+// The outputs of the job are added to the binding by UpdateBindingDetails.
 func (provider *terraformProvider) Bind(ctx context.Context, bindContext *varcontext.VarContext) (map[string]interface{}, error) {
 	provider.logger.Info("bind", lager.Data{
 		"context": bindContext.ToMap(),
 	})
 
-	tfId, err := provider.create(ctx, bindContext, provider.serviceDefinition.BindSettings)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := provider.jobRunner.Wait(ctx, tfId); err != nil {
+	if _, err := provider.create(ctx, bindContext, provider.serviceDefinition.BindSettings); err != nil {
 		return nil, err
 	}
 
-	return provider.jobRunner.Outputs(ctx, tfId, wrapper.DefaultInstanceName)
+	return map[string]interface{}{}, nil
 }
 
 func (provider *terraformProvider) create(ctx context.Context, vars *varcontext.VarContext, action TfServiceDefinitionV1Action) (string, error) {
@@ -84,7 +80,7 @@ func (provider *terraformProvider) create(ctx context.Context, vars *varcontext.
 		return "", err
 	}
 
-	workspace, err := wrapper.NewWorkspace(vars.ToMap(), action.Template)
+	workspace, err := action.NewWorkspace(vars.ToMap())
 	if err != nil {
 		return tfId, err
 	}
@@ -109,7 +105,7 @@ func (provider *terraformProvider) BuildInstanceCredentials(ctx context.Context,
 	return vc.ToMap(), nil
 }
 
-// Unbind performs a terraform destroy on the binding.
+// Unbind performs a terraform destroy on the binding in the background.
 func (provider *terraformProvider) Unbind(ctx context.Context, instanceRecord models.ServiceInstanceDetails, bindRecord models.ServiceBindingCredentials) error {
 	tfId := generateTfId(instanceRecord.ID, bindRecord.BindingId)
 	provider.logger.Info("unbind", lager.Data{
@@ -118,11 +114,39 @@ func (provider *terraformProvider) Unbind(ctx context.Context, instanceRecord mo
 		"tfId":     tfId,
 	})
 
-	if err := provider.jobRunner.Destroy(ctx, tfId); err != nil {
-		return err
+	return provider.jobRunner.Destroy(ctx, tfId)
+}
+
+// Update applies the new configuration to the instance's existing Terraform
+// deployment in the background.
+func (provider *terraformProvider) Update(ctx context.Context, instance models.ServiceInstanceDetails, updateContext *varcontext.VarContext) (models.ServiceInstanceDetails, error) {
+	provider.logger.Info("update", lager.Data{
+		"instance": instance.ID,
+		"context":  updateContext.ToMap(),
+	})
+
+	tfId := updateContext.GetString("tf_id")
+	if err := updateContext.Error(); err != nil {
+		return models.ServiceInstanceDetails{}, err
 	}
 
-	return provider.jobRunner.Wait(ctx, tfId)
+	// Values that force replacement were checked to be unchanged by the broker,
+	// drop them so generated defaults don't overwrite the originals.
+	vars := updateContext.ToMap()
+	for _, input := range provider.serviceDefinition.ProvisionSettings.UserInputs {
+		if input.ForcesReplacement {
+			delete(vars, input.FieldName)
+		}
+	}
+
+	if err := provider.jobRunner.Update(ctx, tfId, vars); err != nil {
+		return models.ServiceInstanceDetails{}, err
+	}
+
+	instance.OperationId = tfId
+	instance.OperationType = models.UpdateOperationType
+
+	return instance, nil
 }
 
 // Deprovision performs a terraform destroy on the instance.
@@ -144,6 +168,12 @@ func (provider *terraformProvider) PollInstance(ctx context.Context, instance mo
 	return provider.jobRunner.Status(ctx, generateTfId(instance.ID, ""))
 }
 
+// DescribeOperation returns the description of the backing job, which tells
+// users if it's still waiting in the job queue.
+func (provider *terraformProvider) DescribeOperation(ctx context.Context, instance models.ServiceInstanceDetails) (string, error) {
+	return provider.jobRunner.Description(ctx, generateTfId(instance.ID, ""))
+}
+
 // ProvisionsAsync is always true for Terraformprovider.
 func (provider *terraformProvider) ProvisionsAsync() bool {
 	return true
@@ -154,6 +184,63 @@ func (provider *terraformProvider) DeprovisionsAsync() bool {
 	return true
 }
 
+// BindsAsync is always true for Terraformprovider.
+func (provider *terraformProvider) BindsAsync() bool {
+	return true
+}
+
+// PollBinding returns the status of the job backing the binding.
+func (provider *terraformProvider) PollBinding(ctx context.Context, instance models.ServiceInstanceDetails, binding models.ServiceBindingCredentials) (bool, error) {
+	return provider.jobRunner.Status(ctx, generateTfId(instance.ID, binding.BindingId))
+}
+
+// UpdateBindingDetails sets the OtherDetails of the binding to the outputs of
+// its Terraform job.
+func (provider *terraformProvider) UpdateBindingDetails(ctx context.Context, instance models.ServiceInstanceDetails, binding *models.ServiceBindingCredentials) error {
+	outs, err := provider.jobRunner.Outputs(ctx, generateTfId(instance.ID, binding.BindingId), wrapper.DefaultInstanceName)
+	if err != nil {
+		return err
+	}
+
+	serialized, err := json.Marshal(outs)
+	if err != nil {
+		return err
+	}
+
+	binding.OtherDetails = string(serialized)
+	return nil
+}
+
+// Exists checks if the Terraform deployment of the instance still exists.
+// Terraform doesn't track resources deleted outside of it until it's run, so
+// this only finds instances whose deployment is gone.
+func (provider *terraformProvider) Exists(ctx context.Context, instance models.ServiceInstanceDetails) (bool, error) {
+	return provider.jobRunner.Exists(ctx, generateTfId(instance.ID, ""))
+}
+
+// BindingExists checks if the Terraform deployment of the binding still exists.
+func (provider *terraformProvider) BindingExists(ctx context.Context, instance models.ServiceInstanceDetails, binding models.ServiceBindingCredentials) (bool, error) {
+	return provider.jobRunner.Exists(ctx, generateTfId(instance.ID, binding.BindingId))
+}
+
+// DescribeResources returns no resources because Terraform services define
+// their own resources and labels.
+func (provider *terraformProvider) DescribeResources(ctx context.Context) ([]models.ServiceInstanceDetails, error) {
+	return nil, nil
+}
+
+// RotateCredentials isn't supported because Terraform services define their
+// own credentials, and re-applying the bind template would replace them
+// without a grace period.
+func (provider *terraformProvider) RotateCredentials(ctx context.Context, instance models.ServiceInstanceDetails, binding *models.ServiceBindingCredentials) (map[string]interface{}, error) {
+	return nil, broker.ErrCredentialRotationNotSupported
+}
+
+// RetireCredentials does nothing because credentials are never rotated.
+func (provider *terraformProvider) RetireCredentials(ctx context.Context, instance models.ServiceInstanceDetails, retired map[string]interface{}) error {
+	return nil
+}
+
 // UpdateInstanceDetails updates the ServiceInstanceDetails with the most recent state from GCP.
 // This function is optional, but will be called after async provisions, updates, and possibly
 // on broker version changes.
@@ -0,0 +1,157 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// RecoveryInterval is how often RecoverOrphans should be run. Leases held by a
+// process that stopped only expire leaseDuration after their last renewal, so
+// the deployments it abandoned can't be recovered when the broker restarts.
+const RecoveryInterval = leaseDuration
+
+// Recover takes over a deployment whose queued or running operation was
+// abandoned by the broker process that owned it, which is detected by its
+// lease expiring.
+//
+// If resume is true, the operation is queued again in this process starting
+// from the most recent snapshot of the Terraform state. It's run with the
+// executor and job pool limit of the service the deployment belongs to rather
+// than those of the runner. Otherwise, the
+// operation is marked as failed and the snapshot is kept as the state so the
+// resources can still be destroyed. Operations that were still queued never
+// ran Terraform, so they're always queued again in their original place.
+//
+// If force is true, the deployment is taken over even if its lease hasn't
+// expired. This is only safe if the owner is known to have stopped.
+func (runner *TfJobRunner) Recover(ctx context.Context, id string, resume, force bool) error {
+	deployment, err := db_service.GetTerraformDeploymentById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if deployment.LastOperationState != InProgress && deployment.LastOperationState != Queued {
+		return fmt.Errorf("deployment %q has no pending operation", id)
+	}
+
+	if deployment.LastOperationState == Queued {
+		resume = true
+	}
+
+	jobRunner := runner
+	if resume {
+		if jobRunner, err = runner.forDeployment(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	acquired, err := db_service.AcquireTerraformDeploymentLease(ctx, id, jobOwnerId, time.Now().Add(leaseDuration), force)
+	if err != nil {
+		return err
+	}
+
+	if !acquired {
+		return fmt.Errorf("the operation on %q is still running, its lease is held by %q", id, deployment.LeaseOwner)
+	}
+
+	// reload the deployment to get the last snapshot stored before the lease lapsed
+	deployment, err = db_service.GetTerraformDeploymentById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	workspace, err := jobRunner.hydrateWorkspace(ctx, deployment)
+	if err != nil {
+		return err
+	}
+
+	if !resume {
+		return jobRunner.operationFinished(fmt.Errorf("the broker stopped while running the %s operation, the partial state was recovered", deployment.LastOperationType), workspace, deployment)
+	}
+
+	switch deployment.LastOperationType {
+	case models.ProvisionOperationType, models.UpdateOperationType:
+		jobRunner.runJob(deployment, workspace, workspace.Apply, nil)
+	case models.DeprovisionOperationType:
+		jobRunner.runJob(deployment, workspace, workspace.Destroy, nil)
+	default:
+		return jobRunner.operationFinished(fmt.Errorf("the broker stopped while running the %s operation, it can't be resumed", deployment.LastOperationType), workspace, deployment)
+	}
+
+	return nil
+}
+
+// forDeployment gets a copy of the runner set up with the executor and service
+// name of the service the deployment belongs to, which is found through the
+// service instance in the deployment's ID.
+func (runner *TfJobRunner) forDeployment(ctx context.Context, id string) (*TfJobRunner, error) {
+	parts := strings.SplitN(id, ":", 3)
+	if len(parts) != 3 || parts[0] != "tf" {
+		return nil, fmt.Errorf("%q isn't the ID of a Terraform deployment", id)
+	}
+
+	instance, err := db_service.GetServiceInstanceDetailsById(ctx, parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find the service instance of %q: %v", id, err)
+	}
+
+	svc, err := broker.GetServiceById(instance.ServiceId)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, ok := svc.ProviderBuilder(runner.ProjectId, nil, lager.NewLogger("tf-recovery")).(*terraformProvider)
+	if !ok {
+		return nil, fmt.Errorf("the service %q of %q isn't a Terraform service", svc.Name, id)
+	}
+
+	resolved := *runner
+	resolved.ServiceName = provider.jobRunner.ServiceName
+	resolved.Executor = provider.jobRunner.Executor
+	return &resolved, nil
+}
+
+// RecoverOrphans recovers every deployment with a queued or running operation
+// whose lease has expired. It returns the IDs of the recovered deployments.
+// See Recover for the meaning of resume.
+func (runner *TfJobRunner) RecoverOrphans(ctx context.Context, resume bool) ([]string, error) {
+	orphans, err := db_service.ListOrphanedTerraformDeployments(ctx, []string{Queued, InProgress}, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var recovered []string
+	var errs *multierror.Error
+	for _, orphan := range orphans {
+		if err := runner.Recover(ctx, orphan.ID, resume, false); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("couldn't recover %q: %v", orphan.ID, err))
+			continue
+		}
+
+		recovered = append(recovered, orphan.ID)
+	}
+
+	return recovered, errs.ErrorOrNil()
+}
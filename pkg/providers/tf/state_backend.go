@@ -0,0 +1,189 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/providers/tf/wrapper"
+	"github.com/spf13/viper"
+)
+
+const (
+	// StateBackendProp is the Viper property for where new deployments keep
+	// their Terraform state. Existing deployments keep using the backend they
+	// were created with until they're migrated.
+	StateBackendProp = "terraform.state.backend"
+
+	stateLocalDirProp     = "terraform.state.local_dir"
+	stateHttpAddressProp  = "terraform.state.http_address"
+	stateHttpUsernameProp = "terraform.state.http_username"
+	stateHttpPasswordProp = "terraform.state.http_password"
+	stateHttpLockProp     = "terraform.state.http_lock"
+
+	// DatabaseStateBackend keeps the state in the deployment's workspace in the
+	// database.
+	DatabaseStateBackend = "database"
+	// LocalStateBackend keeps the state of each deployment in a file in
+	// terraform.state.local_dir.
+	LocalStateBackend = "local"
+	// HttpStateBackend keeps the state of each deployment at a URL under
+	// terraform.state.http_address.
+	HttpStateBackend = "http"
+)
+
+func init() {
+	viper.SetDefault(StateBackendProp, DatabaseStateBackend)
+	viper.SetDefault(stateHttpLockProp, true)
+}
+
+// stateBackend creates the Terraform backend of the given type for the
+// deployment with the given ID. The database backend is represented by nil.
+func stateBackend(backendType, id string) (*wrapper.Backend, error) {
+	switch backendType {
+	case DatabaseStateBackend:
+		return nil, nil
+
+	case LocalStateBackend:
+		dir := viper.GetString(stateLocalDirProp)
+		if !filepath.IsAbs(dir) {
+			return nil, fmt.Errorf("%s must be an absolute path to use the local state backend, got %q", stateLocalDirProp, dir)
+		}
+
+		return &wrapper.Backend{
+			Type:   "local",
+			Config: map[string]interface{}{"path": filepath.Join(dir, url.PathEscape(id)+".tfstate")},
+		}, nil
+
+	case HttpStateBackend:
+		base := viper.GetString(stateHttpAddressProp)
+		if base == "" {
+			return nil, fmt.Errorf("%s must be set to use the http state backend", stateHttpAddressProp)
+		}
+
+		// the credentials are passed when Terraform runs so they aren't stored
+		// with the workspace, see stateBackendEnvironment
+		address := strings.TrimSuffix(base, "/") + "/" + url.PathEscape(id)
+		config := map[string]interface{}{"address": address}
+
+		// the http backend locks with the LOCK and UNLOCK methods
+		if viper.GetBool(stateHttpLockProp) {
+			config["lock_address"] = address
+			config["unlock_address"] = address
+		}
+
+		return &wrapper.Backend{Type: "http", Config: config}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown Terraform state backend %q, expected one of %s, %s or %s", backendType, DatabaseStateBackend, LocalStateBackend, HttpStateBackend)
+	}
+}
+
+// stateBackendEnvironment gets the environment variables that hold the
+// credentials of the configured state backends. Credentials stored in the
+// backend of older workspaces are removed so they aren't saved again.
+func stateBackendEnvironment(backend *wrapper.Backend) map[string]string {
+	env := make(map[string]string)
+	username := viper.GetString(stateHttpUsernameProp)
+	if username == "" {
+		return env
+	}
+
+	env[wrapper.HttpUsernameEnv] = username
+	env[wrapper.HttpPasswordEnv] = viper.GetString(stateHttpPasswordProp)
+
+	if backend != nil && backend.Type == "http" {
+		delete(backend.Config, "username")
+		delete(backend.Config, "password")
+	}
+
+	return env
+}
+
+// defaultStateBackend creates the backend operators configured for new
+// deployments.
+func defaultStateBackend(id string) (*wrapper.Backend, error) {
+	return stateBackend(viper.GetString(StateBackendProp), id)
+}
+
+// MigrateState moves the Terraform state of the deployment with the given ID
+// to the backend of the given type. The deployment's lease is held while the
+// state is moved so no job can run on it at the same time. It returns false if
+// the deployment already used the backend.
+func (runner *TfJobRunner) MigrateState(ctx context.Context, id, backendType string) (bool, error) {
+	target, err := stateBackend(backendType, id)
+	if err != nil {
+		return false, err
+	}
+
+	acquired, err := db_service.AcquireTerraformDeploymentLease(ctx, id, jobOwnerId, time.Now().Add(leaseDuration), false)
+	if err != nil {
+		return false, err
+	}
+
+	if !acquired {
+		return false, fmt.Errorf("an operation is in progress on %q", id)
+	}
+
+	deployment, err := db_service.GetTerraformDeploymentById(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	migrated, err := migrateWorkspaceState(deployment, target)
+
+	// saving the deployment releases the lease whether or not it migrated
+	deployment.LeaseOwner = ""
+	deployment.LeaseExpiration = nil
+	if saveErr := db_service.SaveTerraformDeployment(ctx, deployment); err == nil {
+		err = saveErr
+	}
+
+	return migrated, err
+}
+
+// migrateWorkspaceState moves the state of the deployment's workspace to the
+// target backend. The workspace is only updated if the state was moved.
+func migrateWorkspaceState(deployment *models.TerraformDeployment, target *wrapper.Backend) (bool, error) {
+	workspace, err := wrapper.DeserializeWorkspace(deployment.Workspace)
+	if err != nil {
+		return false, err
+	}
+	workspace.Environment = stateBackendEnvironment(workspace.Backend)
+
+	if reflect.DeepEqual(workspace.Backend, target) {
+		return false, nil
+	}
+
+	if err := workspace.MigrateState(target); err != nil {
+		return false, fmt.Errorf("couldn't migrate the state of %q: %v", deployment.ID, err)
+	}
+
+	serialized, err := workspace.Serialize()
+	if err != nil {
+		return false, err
+	}
+
+	deployment.Workspace = serialized
+	return true, nil
+}
@@ -0,0 +1,177 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tf
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers/models"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/db_service"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/providers/tf/wrapper"
+	"github.com/spf13/viper"
+)
+
+func TestStateBackend(t *testing.T) {
+	cases := map[string]struct {
+		Type        string
+		Config      map[string]interface{}
+		Expected    *wrapper.Backend
+		ErrContains string
+	}{
+		"database": {
+			Type:     DatabaseStateBackend,
+			Expected: nil,
+		},
+		"local": {
+			Type:     LocalStateBackend,
+			Config:   map[string]interface{}{stateLocalDirProp: "/var/state"},
+			Expected: &wrapper.Backend{Type: "local", Config: map[string]interface{}{"path": "/var/state/tf:instance:.tfstate"}},
+		},
+		"local without dir": {
+			Type:        LocalStateBackend,
+			ErrContains: stateLocalDirProp,
+		},
+		"http": {
+			Type:   HttpStateBackend,
+			Config: map[string]interface{}{stateHttpAddressProp: "https://state.example.com/", stateHttpUsernameProp: "user", stateHttpPasswordProp: "pass"},
+			Expected: &wrapper.Backend{Type: "http", Config: map[string]interface{}{
+				"address":        "https://state.example.com/tf:instance:",
+				"lock_address":   "https://state.example.com/tf:instance:",
+				"unlock_address": "https://state.example.com/tf:instance:",
+			}},
+		},
+		"http without locking": {
+			Type:     HttpStateBackend,
+			Config:   map[string]interface{}{stateHttpAddressProp: "https://state.example.com", stateHttpLockProp: false},
+			Expected: &wrapper.Backend{Type: "http", Config: map[string]interface{}{"address": "https://state.example.com/tf:instance:"}},
+		},
+		"unknown": {
+			Type:        "gcs",
+			ErrContains: `unknown Terraform state backend "gcs"`,
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			for k, v := range tc.Config {
+				viper.Set(k, v)
+				defer viper.Set(k, nil)
+			}
+
+			actual, err := stateBackend(tc.Type, "tf:instance:")
+			if tc.ErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.ErrContains) {
+					t.Fatalf("Expected error containing %q, got %v", tc.ErrContains, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(actual, tc.Expected) {
+				t.Errorf("Expected backend %#v, got %#v", tc.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestTfJobRunner_MigrateState(t *testing.T) {
+	testDb := newTestDatabase(t)
+	defer testDb.Close()
+
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	viper.Set(stateLocalDirProp, dir)
+	defer viper.Set(stateLocalDirProp, nil)
+
+	ws, err := wrapper.NewWorkspace(map[string]interface{}{}, ``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.State = []byte("the-state")
+
+	serialized, err := ws.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	deployment := &models.TerraformDeployment{ID: "tf:instance:", Workspace: serialized, LastOperationState: Succeeded}
+	if err := db_service.SaveTerraformDeployment(ctx, deployment); err != nil {
+		t.Fatal(err)
+	}
+
+	runner := &TfJobRunner{}
+
+	migrated, err := runner.MigrateState(ctx, deployment.ID, LocalStateBackend)
+	if err != nil || !migrated {
+		t.Fatalf("Expected the state to be migrated, got %t, %v", migrated, err)
+	}
+
+	state, err := ioutil.ReadFile(filepath.Join(dir, "tf:instance:.tfstate"))
+	if err != nil || string(state) != "the-state" {
+		t.Errorf("Expected the state to be written to the local backend, got %q, %v", state, err)
+	}
+
+	migrated, err = runner.MigrateState(ctx, deployment.ID, LocalStateBackend)
+	if err != nil || migrated {
+		t.Errorf("Expected the second migration to be skipped, got %t, %v", migrated, err)
+	}
+
+	// an operation in progress holds the lease
+	if _, err := db_service.AcquireTerraformDeploymentLease(ctx, deployment.ID, "other-broker", time.Now().Add(time.Hour), false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := runner.MigrateState(ctx, deployment.ID, DatabaseStateBackend); err == nil {
+		t.Error("Expected migrating a deployment with an operation in progress to fail")
+	}
+
+	db_service.AcquireTerraformDeploymentLease(ctx, deployment.ID, "", time.Now(), true)
+	if _, err := runner.MigrateState(ctx, deployment.ID, DatabaseStateBackend); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := db_service.GetTerraformDeploymentById(ctx, deployment.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.LeaseOwner != "" {
+		t.Errorf("Expected the lease to be released, got %q", stored.LeaseOwner)
+	}
+
+	ws, err = wrapper.DeserializeWorkspace(stored.Workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ws.Backend != nil || string(ws.State) != "the-state" {
+		t.Errorf("Expected the state to be moved back into the database, got %v %q", ws.Backend, ws.State)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// StateStore reads and writes the Terraform state kept in a backend so the
+// broker can use it without running Terraform, for example to get outputs or
+// to move state between backends.
+type StateStore interface {
+	// ReadState gets the state, it's empty if none was written yet.
+	ReadState() ([]byte, error)
+
+	// WriteState replaces the state.
+	WriteState(state []byte) error
+}
+
+// StateStoreFactory creates the StateStore for a backend configuration.
+// Settings Terraform reads from the environment, like credentials, are taken
+// from env.
+type StateStoreFactory func(config map[string]interface{}, env map[string]string) (StateStore, error)
+
+var (
+	stateStoresMu sync.RWMutex
+	stateStores   = map[string]StateStoreFactory{
+		"local": newLocalStateStore,
+		"http":  newHttpStateStore,
+	}
+)
+
+// RegisterStateStore makes a Terraform backend type usable by workspaces.
+// The local and http backends are registered by default.
+func RegisterStateStore(backendType string, factory StateStoreFactory) {
+	stateStoresMu.Lock()
+	defer stateStoresMu.Unlock()
+
+	stateStores[backendType] = factory
+}
+
+const (
+	// HttpUsernameEnv and HttpPasswordEnv are the environment variables
+	// Terraform reads the credentials of the http backend from.
+	HttpUsernameEnv = "TF_HTTP_USERNAME"
+	HttpPasswordEnv = "TF_HTTP_PASSWORD"
+)
+
+// Backend is a Terraform backend that holds the state of a workspace rather
+// than the workspace itself. Terraform reads and writes the state and uses the
+// locking of the backend.
+type Backend struct {
+	Type   string                 `json:"type"`
+	Config map[string]interface{} `json:"config"`
+}
+
+// StateStore gets the store for the state kept in the backend. env holds the
+// environment Terraform runs with.
+func (backend *Backend) StateStore(env map[string]string) (StateStore, error) {
+	stateStoresMu.RLock()
+	factory, ok := stateStores[backend.Type]
+	stateStoresMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported Terraform backend type %q", backend.Type)
+	}
+
+	return factory(backend.Config, env)
+}
+
+// MarshalDefinition converts the backend into a JSON definition of the
+// Terraform backend block.
+func (backend *Backend) MarshalDefinition() (json.RawMessage, error) {
+	defn := map[string]interface{}{
+		"terraform": map[string]interface{}{
+			"backend": map[string]interface{}{
+				backend.Type: backend.Config,
+			},
+		},
+	}
+
+	return json.Marshal(defn)
+}
+
+// localStateStore is the StateStore of the local backend, which keeps the
+// state in a file.
+type localStateStore struct {
+	path string
+}
+
+func newLocalStateStore(config map[string]interface{}, env map[string]string) (StateStore, error) {
+	path, ok := config["path"].(string)
+	if !ok || !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("the local backend needs an absolute path, got %v", config["path"])
+	}
+
+	return &localStateStore{path: path}, nil
+}
+
+func (store *localStateStore) ReadState() ([]byte, error) {
+	state, err := ioutil.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return state, err
+}
+
+func (store *localStateStore) WriteState(state []byte) error {
+	if err := os.MkdirAll(filepath.Dir(store.path), 0700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(store.path, state, 0600)
+}
+
+// httpStateStore is the StateStore of the http backend, which GETs the state
+// from and POSTs it to an address.
+// Like Terraform, it takes the credentials from TF_HTTP_USERNAME and
+// TF_HTTP_PASSWORD if they aren't in the configuration.
+// See https://www.terraform.io/docs/backends/types/http.html for the protocol.
+type httpStateStore struct {
+	address  string
+	username string
+	password string
+}
+
+func newHttpStateStore(config map[string]interface{}, env map[string]string) (StateStore, error) {
+	address, ok := config["address"].(string)
+	if !ok || address == "" {
+		return nil, fmt.Errorf("the http backend needs an address, got %v", config["address"])
+	}
+
+	username, _ := config["username"].(string)
+	password, _ := config["password"].(string)
+	if username == "" {
+		username = env[HttpUsernameEnv]
+		password = env[HttpPasswordEnv]
+	}
+
+	return &httpStateStore{address: address, username: username, password: password}, nil
+}
+
+func (store *httpStateStore) ReadState() ([]byte, error) {
+	resp, err := store.do(http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return ioutil.ReadAll(resp.Body)
+	case http.StatusNoContent, http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("couldn't read state from %q: %s", store.address, resp.Status)
+	}
+}
+
+func (store *httpStateStore) WriteState(state []byte) error {
+	resp, err := store.do(http.MethodPost, state)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("couldn't write state to %q: %s", store.address, resp.Status)
+	}
+
+	return nil
+}
+
+func (store *httpStateStore) do(method string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, store.address, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if store.username != "" {
+		req.SetBasicAuth(store.username, store.password)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return http.DefaultClient.Do(req)
+}
@@ -0,0 +1,209 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wrapper
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeHttpBackend is a local stand-in for a Terraform http backend. If
+// username is set, requests must authenticate with it and password.
+type fakeHttpBackend struct {
+	mu       sync.Mutex
+	states   map[string][]byte
+	username string
+	password string
+}
+
+func (backend *fakeHttpBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	if backend.username != "" {
+		if username, password, _ := r.BasicAuth(); username != backend.username || password != backend.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		state, ok := backend.states[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(state)
+
+	case http.MethodPost:
+		state, _ := ioutil.ReadAll(r.Body)
+		backend.states[r.URL.Path] = state
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestTerraformWorkspace_MigrateState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(&fakeHttpBackend{states: map[string][]byte{}})
+	defer server.Close()
+
+	local := &Backend{Type: "local", Config: map[string]interface{}{"path": filepath.Join(dir, "instance.tfstate")}}
+	remote := &Backend{Type: "http", Config: map[string]interface{}{"address": server.URL + "/instance"}}
+
+	ws, err := NewWorkspace(map[string]interface{}{}, ``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.State = []byte("the-state")
+
+	for _, target := range []*Backend{local, remote, nil} {
+		if err := ws.MigrateState(target); err != nil {
+			t.Fatal(err)
+		}
+
+		if ws.Backend != target {
+			t.Errorf("Expected the workspace to use the backend %v, got %v", target, ws.Backend)
+		}
+
+		if target != nil && ws.State != nil {
+			t.Errorf("Expected the state to be removed from the workspace, got %q", ws.State)
+		}
+
+		state, err := ws.StoredState()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(state) != "the-state" {
+			t.Errorf("Expected the state to be moved, got %q", state)
+		}
+	}
+}
+
+func TestHttpStateStore_credentials(t *testing.T) {
+	server := httptest.NewServer(&fakeHttpBackend{states: map[string][]byte{}, username: "user", password: "pass"})
+	defer server.Close()
+
+	cases := map[string]struct {
+		Config      map[string]interface{}
+		Env         map[string]string
+		ExpectError bool
+	}{
+		"from the environment": {
+			Env: map[string]string{HttpUsernameEnv: "user", HttpPasswordEnv: "pass"},
+		},
+		"from the config": {
+			Config: map[string]interface{}{"username": "user", "password": "pass"},
+		},
+		"missing": {
+			ExpectError: true,
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			config := map[string]interface{}{"address": server.URL + "/instance"}
+			for k, v := range tc.Config {
+				config[k] = v
+			}
+
+			store, err := (&Backend{Type: "http", Config: config}).StateStore(tc.Env)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = store.WriteState([]byte("the-state"))
+			if tc.ExpectError != (err != nil) {
+				t.Errorf("Expected error: %t, got %v", tc.ExpectError, err)
+			}
+		})
+	}
+}
+
+func TestBackend_StateStore(t *testing.T) {
+	cases := map[string]struct {
+		Backend     Backend
+		ErrContains string
+	}{
+		"local":                {Backend: Backend{Type: "local", Config: map[string]interface{}{"path": "/tmp/state"}}},
+		"local relative path":  {Backend: Backend{Type: "local", Config: map[string]interface{}{"path": "state"}}, ErrContains: "absolute path"},
+		"http":                 {Backend: Backend{Type: "http", Config: map[string]interface{}{"address": "http://localhost/state"}}},
+		"http without address": {Backend: Backend{Type: "http", Config: map[string]interface{}{}}, ErrContains: "needs an address"},
+		"unknown":              {Backend: Backend{Type: "gcs"}, ErrContains: `unsupported Terraform backend type "gcs"`},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			_, err := tc.Backend.StateStore(nil)
+			switch {
+			case tc.ErrContains == "" && err != nil:
+				t.Errorf("Expected no error, got %v", err)
+			case tc.ErrContains != "" && (err == nil || !strings.Contains(err.Error(), tc.ErrContains)):
+				t.Errorf("Expected error containing %q, got %v", tc.ErrContains, err)
+			}
+		})
+	}
+}
+
+func TestTerraformWorkspace_Backend(t *testing.T) {
+	ws, err := NewWorkspace(map[string]interface{}{}, ``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.State = []byte("stale-state")
+	ws.Backend = &Backend{Type: "local", Config: map[string]interface{}{"path": "/tmp/state"}}
+
+	ws.Executor = func(cmd *exec.Cmd) error {
+		backend, err := ioutil.ReadFile(path.Join(cmd.Dir, "backend.tf.json"))
+		if err != nil {
+			t.Fatalf("Expected the backend block to be written: %v", err)
+		}
+
+		expected := `{"terraform":{"backend":{"local":{"path":"/tmp/state"}}}}`
+		if string(backend) != expected {
+			t.Errorf("Expected backend block %s, got %s", expected, backend)
+		}
+
+		if _, err := os.Stat(path.Join(cmd.Dir, "terraform.tfstate")); !os.IsNotExist(err) {
+			t.Errorf("Expected no local state to be written, got %v", err)
+		}
+
+		return nil
+	}
+
+	if err := ws.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(ws.State) != "stale-state" {
+		t.Errorf("Expected the workspace state to be left alone, got %q", ws.State)
+	}
+}
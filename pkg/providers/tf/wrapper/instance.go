@@ -14,13 +14,21 @@
 
 package wrapper
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 // ModuleInstance represents the configuration of a single instance of a module.
 type ModuleInstance struct {
 	ModuleName    string                 `json:"module_name"`
 	InstanceName  string                 `json:"instance_name"`
 	Configuration map[string]interface{} `json:"configuration"`
+
+	// Wiring maps inputs of the instance to outputs of other instances in the
+	// same workspace in the form "<instance>.<output>". Wired inputs take
+	// precedence over the configuration.
+	Wiring map[string]string `json:"wiring,omitempty"`
 }
 
 // MarshalDefinition converts the module instance definition into a JSON
@@ -31,6 +39,10 @@ func (instance *ModuleInstance) MarshalDefinition() (json.RawMessage, error) {
 		instanceConfig[k] = v
 	}
 
+	for input, output := range instance.Wiring {
+		instanceConfig[input] = fmt.Sprintf("${module.%s}", output)
+	}
+
 	instanceConfig["source"] = instance.ModuleName
 
 	defn := map[string]interface{}{
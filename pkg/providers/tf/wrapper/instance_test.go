@@ -30,3 +30,19 @@ func ExampleModuleInstance_MarshalDefinition() {
 	// Output: <nil>
 	// {"module":{"instance":{"foo":"bar","source":"foo-module"}}}
 }
+
+func ExampleModuleInstance_MarshalDefinition_wiring() {
+	instance := ModuleInstance{
+		ModuleName:    "foo-module",
+		InstanceName:  "instance",
+		Configuration: map[string]interface{}{"foo": "bar", "network": "ignored"},
+		Wiring:        map[string]string{"network": "networking.network_name"},
+	}
+
+	defnJson, err := instance.MarshalDefinition()
+	fmt.Println(err)
+	fmt.Printf("%s\n", string(defnJson))
+
+	// Output: <nil>
+	// {"module":{"instance":{"foo":"bar","network":"${module.networking.network_name}","source":"foo-module"}}}
+}
@@ -15,15 +15,18 @@
 package wrapper
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
 	"strings"
 	"sync"
+	"syscall"
 
 	"code.cloudfoundry.org/lager"
 )
@@ -49,25 +52,43 @@ func NewWorkspace(templateVars map[string]interface{}, terraformTemplate string)
 		Definition: terraformTemplate,
 	}
 
-	inputList, err := tfModule.Inputs()
-	if err != nil {
-		return nil, err
+	instance := ModuleInstance{
+		ModuleName:   tfModule.Name,
+		InstanceName: DefaultInstanceName,
 	}
 
-	limitedConfig := make(map[string]interface{})
-	for _, name := range inputList {
-		limitedConfig[name] = templateVars[name]
-	}
+	return NewComposedWorkspace(templateVars, []ModuleDefinition{tfModule}, []ModuleInstance{instance})
+}
 
+// NewComposedWorkspace creates a new TerraformWorkspace from several modules
+// and instances of them, possibly wired together. The configuration of each
+// instance is populated from the variables its module accepts, except for the
+// inputs that are wired to the outputs of other instances.
+func NewComposedWorkspace(templateVars map[string]interface{}, modules []ModuleDefinition, instances []ModuleInstance) (*TerraformWorkspace, error) {
 	workspace := TerraformWorkspace{
-		Modules: []ModuleDefinition{tfModule},
-		Instances: []ModuleInstance{
-			{
-				ModuleName:    tfModule.Name,
-				InstanceName:  DefaultInstanceName,
-				Configuration: limitedConfig,
-			},
-		},
+		Modules: modules,
+	}
+
+	for _, instance := range instances {
+		module, err := workspace.module(instance)
+		if err != nil {
+			return nil, err
+		}
+
+		inputList, err := module.Inputs()
+		if err != nil {
+			return nil, err
+		}
+
+		limitedConfig := make(map[string]interface{})
+		for _, name := range inputList {
+			if _, wired := instance.Wiring[name]; !wired {
+				limitedConfig[name] = templateVars[name]
+			}
+		}
+
+		instance.Configuration = limitedConfig
+		workspace.Instances = append(workspace.Instances, instance)
 	}
 
 	return &workspace, nil
@@ -102,12 +123,25 @@ type TerraformWorkspace struct {
 	Instances   []ModuleInstance   `json:"instances"`
 	State       []byte             `json:"tfstate"`
 
+	// Backend holds the Terraform state instead of State if set.
+	Backend *Backend `json:"backend,omitempty"`
+
 	// Executor is a function that gets invoked to shell out to Terraform.
 	// If left nil, the default executor is used.
 	Executor TerraformExecutor `json:"-"`
 
+	// Output receives the combined stdout and stderr of the Terraform commands
+	// as they run, each preceded by the command line. It's passed to the
+	// executor as the command's Stdout and Stderr.
+	// If left nil, the output is only seen by the executor.
+	Output io.Writer `json:"-"`
+
 	dirLock sync.Mutex
 	dir     string
+
+	// stateLock guards reads of the running state from other goroutines
+	// while a command holds dirLock.
+	stateLock sync.Mutex
 }
 
 // String returns a human-friendly representation of the workspace suitable for
@@ -128,6 +162,10 @@ func (workspace *TerraformWorkspace) String() string {
 			fmt.Fprintf(&b, "input.%s = %#v\n", k, v)
 		}
 
+		for k, v := range instance.Wiring {
+			fmt.Fprintf(&b, "input.%s = module.%s\n", k, v)
+		}
+
 		if outputs, err := workspace.Outputs(instance.InstanceName); err != nil {
 			for k, v := range outputs {
 				fmt.Fprintf(&b, "output.%s = %#v\n", k, v)
@@ -149,6 +187,56 @@ func (workspace *TerraformWorkspace) Serialize() (string, error) {
 	return string(ws), nil
 }
 
+// UpdateInstanceConfiguration overlays the given variables on the
+// configuration of every module instance in the workspace. Only the inputs
+// each instance's module accepts are changed; inputs missing from the
+// variables keep their existing value, and wired inputs are never changed.
+// The Terraform state is left untouched
+// so the next Apply updates the existing resources.
+func (workspace *TerraformWorkspace) UpdateInstanceConfiguration(templateVars map[string]interface{}) error {
+	for i, instance := range workspace.Instances {
+		module, err := workspace.module(instance)
+		if err != nil {
+			return err
+		}
+
+		inputList, err := module.Inputs()
+		if err != nil {
+			return err
+		}
+
+		config := make(map[string]interface{})
+		for k, v := range instance.Configuration {
+			config[k] = v
+		}
+
+		for _, name := range inputList {
+			if _, wired := instance.Wiring[name]; wired {
+				continue
+			}
+
+			if value, ok := templateVars[name]; ok {
+				config[name] = value
+			}
+		}
+
+		workspace.Instances[i].Configuration = config
+	}
+
+	return nil
+}
+
+// module gets the definition of the module the instance is of.
+func (workspace *TerraformWorkspace) module(instance ModuleInstance) (*ModuleDefinition, error) {
+	for i, module := range workspace.Modules {
+		if module.Name == instance.ModuleName {
+			return &workspace.Modules[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no module named %q exists for instance %q", instance.ModuleName, instance.InstanceName)
+}
+
 // initializeFs initializes the filesystem directory necessary to run Terraform.
 func (workspace *TerraformWorkspace) initializeFs() error {
 	workspace.dirLock.Lock()
@@ -156,7 +244,9 @@ func (workspace *TerraformWorkspace) initializeFs() error {
 	if dir, err := ioutil.TempDir("", "gsb"); err != nil {
 		return err
 	} else {
+		workspace.stateLock.Lock()
 		workspace.dir = dir
+		workspace.stateLock.Unlock()
 	}
 
 	// write the modulesTerraformWorkspace
@@ -183,8 +273,17 @@ func (workspace *TerraformWorkspace) initializeFs() error {
 		}
 	}
 
-	// write the state if it exists
-	if len(workspace.State) > 0 {
+	// write the backend block or the state if it exists
+	if workspace.Backend != nil {
+		contents, err := workspace.Backend.MarshalDefinition()
+		if err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(path.Join(workspace.dir, "backend.tf.json"), contents, 0755); err != nil {
+			return err
+		}
+	} else if len(workspace.State) > 0 {
 		if err := ioutil.WriteFile(workspace.tfStatePath(), workspace.State, 0755); err != nil {
 			return err
 		}
@@ -199,28 +298,106 @@ func (workspace *TerraformWorkspace) initializeFs() error {
 }
 
 // TeardownFs removes the directory we executed Terraform in and updates the
-// state from it.
+// state from it. Workspaces with a backend leave the state to Terraform.
 func (workspace *TerraformWorkspace) teardownFs() error {
-	bytes, err := ioutil.ReadFile(workspace.tfStatePath())
-	if err != nil {
-		return err
-	}
+	if workspace.Backend == nil {
+		bytes, err := ioutil.ReadFile(workspace.tfStatePath())
+		if err != nil {
+			return err
+		}
 
-	workspace.State = bytes
+		workspace.stateLock.Lock()
+		workspace.State = bytes
+		workspace.stateLock.Unlock()
+	}
 
 	if err := os.RemoveAll(workspace.dir); err != nil {
 		return err
 	}
 
+	workspace.stateLock.Lock()
 	workspace.dir = ""
+	workspace.stateLock.Unlock()
 	workspace.dirLock.Unlock()
 	return nil
 }
 
+// CurrentState gets the most recent Terraform state of the workspace.
+// If a command is running, the state Terraform has written so far is returned,
+// otherwise the stored state is. Unlike the other functions, this one is safe
+// to call while a command is running.
+func (workspace *TerraformWorkspace) CurrentState() ([]byte, error) {
+	workspace.stateLock.Lock()
+	defer workspace.stateLock.Unlock()
+
+	if workspace.dir != "" {
+		bytes, err := ioutil.ReadFile(workspace.tfStatePath())
+		switch {
+		case err == nil:
+			return bytes, nil
+		case !os.IsNotExist(err):
+			return nil, err
+		}
+	}
+
+	return workspace.State, nil
+}
+
+// StoredState gets the Terraform state from the backend of the workspace or
+// State if it has none.
+func (workspace *TerraformWorkspace) StoredState() ([]byte, error) {
+	if workspace.Backend == nil {
+		return workspace.State, nil
+	}
+
+	store, err := workspace.Backend.StateStore(workspace.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.ReadState()
+}
+
+// MigrateState moves the Terraform state of the workspace to the target
+// backend, or into State if the target is nil, and switches the workspace to
+// use it. The state in the previous backend is left in place.
+func (workspace *TerraformWorkspace) MigrateState(target *Backend) error {
+	state, err := workspace.StoredState()
+	if err != nil {
+		return err
+	}
+
+	if target == nil {
+		workspace.State = state
+		workspace.Backend = nil
+		return nil
+	}
+
+	store, err := target.StateStore(workspace.Environment)
+	if err != nil {
+		return err
+	}
+
+	if len(state) > 0 {
+		if err := store.WriteState(state); err != nil {
+			return err
+		}
+	}
+
+	workspace.State = nil
+	workspace.Backend = target
+	return nil
+}
+
 // Outputs gets the Terraform outputs from the state for the instance with the
 // given name. This function DOES NOT invoke Terraform and instead uses the stored state.
 func (workspace *TerraformWorkspace) Outputs(instance string) (map[string]interface{}, error) {
-	state, err := NewTfstate(workspace.State)
+	stored, err := workspace.StoredState()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := NewTfstate(stored)
 	if err != nil {
 		return nil, err
 	}
@@ -270,6 +447,44 @@ func (workspace *TerraformWorkspace) Destroy() error {
 	return workspace.runTf("destroy", "-auto-approve", "-no-color")
 }
 
+// Plan runs `terraform plan` on this workspace to find out if the resources
+// differ from the configuration, for example because they were changed outside
+// of Terraform. It returns true if applying the workspace would make changes.
+// The state of the workspace is not modified.
+// This funciton blocks if another Terraform command is running on this workspace.
+func (workspace *TerraformWorkspace) Plan() (hasChanges bool, err error) {
+	err = workspace.initializeFs()
+	defer workspace.teardownFs()
+	if err != nil {
+		return false, err
+	}
+
+	// With -detailed-exitcode, Terraform exits with 0 if there are no changes,
+	// 1 on errors and 2 if there are changes.
+	err = workspace.runTf("plan", "-detailed-exitcode", "-input=false", "-no-color")
+	if exitStatus(err) == 2 {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// exitStatus gets the exit status of the command that returned err or -1 if the
+// error didn't come from a command exiting.
+func exitStatus(err error) int {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return -1
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return -1
+	}
+
+	return status.ExitStatus()
+}
+
 func (workspace *TerraformWorkspace) tfStatePath() string {
 	return path.Join(workspace.dir, "terraform.tfstate")
 }
@@ -287,6 +502,12 @@ func (workspace *TerraformWorkspace) runTf(subCommand string, args ...string) er
 	c.Env = env
 	c.Dir = workspace.dir
 
+	if workspace.Output != nil {
+		fmt.Fprintf(workspace.Output, "$ terraform %s\n", strings.Join(sub, " "))
+		c.Stdout = workspace.Output
+		c.Stderr = workspace.Output
+	}
+
 	executor := DefaultExecutor
 	if workspace.Executor != nil {
 		executor = workspace.Executor
@@ -312,7 +533,8 @@ func CustomTerraformExecutor(tfBinaryPath, tfPluginDir string, wrapped Terraform
 }
 
 // DefaultExecutor is the default executor that shells out to Terraform
-// and logs results to stdout.
+// and logs results to stdout. If the command has a Stdout set, the output is
+// also streamed to it while the command runs.
 func DefaultExecutor(c *exec.Cmd) error {
 	logger := lager.NewLogger("terraform@" + c.Dir)
 	logger.RegisterSink(lager.NewWriterSink(os.Stderr, lager.ERROR))
@@ -323,9 +545,19 @@ func DefaultExecutor(c *exec.Cmd) error {
 		"args": c.Args,
 		"dir":  c.Dir,
 	})
-	output, err := c.CombinedOutput()
+
+	// Stdout and Stderr get the same writer so exec serializes the writes.
+	var output bytes.Buffer
+	var streamTo io.Writer = &output
+	if c.Stdout != nil {
+		streamTo = io.MultiWriter(&output, c.Stdout)
+	}
+	c.Stdout = streamTo
+	c.Stderr = streamTo
+
+	err := c.Run()
 	logger.Info("results", lager.Data{
-		"output": string(output),
+		"output": output.String(),
 		"error":  err,
 	})
 
@@ -15,6 +15,8 @@
 package wrapper
 
 import (
+	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -94,6 +96,187 @@ func TestTerraformWorkspace_Invariants(t *testing.T) {
 	}
 }
 
+func TestTerraformWorkspace_UpdateInstanceConfiguration(t *testing.T) {
+	template := `
+	variable "name" {type = "string"}
+	variable "size" {type = "string"}
+	`
+
+	ws, err := NewWorkspace(map[string]interface{}{"name": "foo", "size": "1"}, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.State = []byte("existing-state")
+
+	if err := ws.UpdateInstanceConfiguration(map[string]interface{}{"size": "2", "extra": "ignored"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{"name": "foo", "size": "2"}
+	if actual := ws.Instances[0].Configuration; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected configuration %v got %v", expected, actual)
+	}
+
+	if !reflect.DeepEqual(ws.State, []byte("existing-state")) {
+		t.Errorf("Expected state to be untouched, got %v", ws.State)
+	}
+
+	ws.Instances[0].ModuleName = "missing"
+	if err := ws.UpdateInstanceConfiguration(map[string]interface{}{}); err == nil {
+		t.Error("Expected an error for an instance without a module")
+	}
+}
+
+func TestNewComposedWorkspace(t *testing.T) {
+	modules := []ModuleDefinition{
+		{Name: "network", Definition: `variable "region" {type = "string"}`},
+		{Name: "main", Definition: `
+		variable "region" {type = "string"}
+		variable "network" {type = "string"}
+		`},
+	}
+
+	instances := []ModuleInstance{
+		{ModuleName: "network", InstanceName: "network"},
+		{ModuleName: "main", InstanceName: DefaultInstanceName, Wiring: map[string]string{"network": "network.name"}},
+	}
+
+	ws, err := NewComposedWorkspace(map[string]interface{}{"region": "us", "network": "ignored"}, modules, instances)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []map[string]interface{}{{"region": "us"}, {"region": "us"}}
+	for i, instance := range ws.Instances {
+		if !reflect.DeepEqual(instance.Configuration, expected[i]) {
+			t.Errorf("Expected instance %q configuration %v got %v", instance.InstanceName, expected[i], instance.Configuration)
+		}
+	}
+
+	if err := ws.UpdateInstanceConfiguration(map[string]interface{}{"network": "still-ignored"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := ws.Instances[1].Configuration["network"]; ok {
+		t.Errorf("Expected wired inputs not to be configured, got %v", ws.Instances[1].Configuration)
+	}
+
+	instances[0].ModuleName = "missing"
+	if _, err := NewComposedWorkspace(map[string]interface{}{}, modules, instances); err == nil {
+		t.Error("Expected an error for an instance without a module")
+	}
+}
+
+func TestTerraformWorkspace_CurrentState(t *testing.T) {
+	ws, err := NewWorkspace(map[string]interface{}{}, ``)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.State = []byte("stored")
+
+	var runningState []byte
+	ws.Executor = func(cmd *exec.Cmd) error {
+		if err := ioutil.WriteFile(path.Join(cmd.Dir, "terraform.tfstate"), []byte("partial"), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		runningState, err = ws.CurrentState()
+		return err
+	}
+
+	if state, err := ws.CurrentState(); err != nil || string(state) != "stored" {
+		t.Errorf("Expected stored state before running, got %q, %v", state, err)
+	}
+
+	if err := ws.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(runningState) != "partial" {
+		t.Errorf("Expected partial state while running, got %q", runningState)
+	}
+}
+
+func TestTerraformWorkspace_Output(t *testing.T) {
+	ws, err := NewWorkspace(map[string]interface{}{}, ``)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	ws.Output = &output
+	ws.Executor = func(cmd *exec.Cmd) error {
+		fmt.Fprintf(cmd.Stdout, "%s done\n", cmd.Args[1])
+		return nil
+	}
+
+	if err := ws.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "$ terraform init -no-color\ninit done\n$ terraform apply -auto-approve -no-color\napply done\n"
+	if output.String() != expected {
+		t.Errorf("Expected output %q, got %q", expected, output.String())
+	}
+}
+
+func TestTerraformWorkspace_Plan(t *testing.T) {
+	cases := map[string]struct {
+		ExitCommand string
+		Changes     bool
+		ErrExpected bool
+	}{
+		"no changes":   {ExitCommand: "exit 0", Changes: false},
+		"changes":      {ExitCommand: "exit 2", Changes: true},
+		"plan failure": {ExitCommand: "exit 1", ErrExpected: true},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			ws, err := NewWorkspace(map[string]interface{}{}, ``)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ws.Executor = func(cmd *exec.Cmd) error {
+				if cmd.Args[1] != "plan" {
+					return nil
+				}
+
+				if !reflect.DeepEqual(cmd.Args[2:], []string{"-detailed-exitcode", "-input=false", "-no-color"}) {
+					t.Errorf("unexpected plan arguments: %v", cmd.Args[2:])
+				}
+
+				// run a real process so the error is the same type Terraform returns
+				return exec.Command("sh", "-c", tc.ExitCommand).Run()
+			}
+
+			changes, err := ws.Plan()
+			if changes != tc.Changes {
+				t.Errorf("Expected changes to be %v, got %v", tc.Changes, changes)
+			}
+
+			if (err != nil) != tc.ErrExpected {
+				t.Errorf("Expected error: %v, got %v", tc.ErrExpected, err)
+			}
+		})
+	}
+}
+
+func TestDefaultExecutor_Output(t *testing.T) {
+	var output bytes.Buffer
+	cmd := exec.Command("sh", "-c", "echo out; echo err >&2")
+	cmd.Stdout = &output
+
+	if err := DefaultExecutor(cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	if output.String() != "out\nerr\n" {
+		t.Errorf("Expected stdout and stderr to be streamed, got %q", output.String())
+	}
+}
+
 func TestCustomTerraformExecutor(t *testing.T) {
 	customBinary := "/path/to/terraform"
 	customPlugins := "/path/to/terraform-plugins"
@@ -0,0 +1,147 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/gorilla/mux"
+	"github.com/pivotal-cf/brokerapi"
+)
+
+var (
+	errServiceIdMissing = errors.New("service_id missing")
+	errPlanIdMissing    = errors.New("plan_id missing")
+)
+
+type asyncOperationResponse struct {
+	OperationData string `json:"operation,omitempty"`
+}
+
+func (h *handler) bind(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	instanceID := vars["instance_id"]
+	bindingID := vars["binding_id"]
+	logger := h.logger.Session("bind", lager.Data{"instance-id": instanceID, "binding-id": bindingID})
+	if !h.checkAPIVersion(w, req, logger) {
+		return
+	}
+
+	var details brokerapi.BindDetails
+	if err := json.NewDecoder(req.Body).Decode(&details); err != nil {
+		logger.Error("invalid-bind-details", err)
+		h.respond(w, http.StatusUnprocessableEntity, brokerapi.ErrorResponse{Description: err.Error()})
+		return
+	}
+
+	if !h.checkServiceAndPlan(w, details.ServiceID, details.PlanID, logger) {
+		return
+	}
+
+	asyncAllowed := req.FormValue("accepts_incomplete") == "true"
+	binding, err := h.binder.BindAsync(req.Context(), instanceID, bindingID, details, asyncAllowed)
+	if err != nil {
+		// brokerapi responds 404 rather than 410 when binding to a missing instance
+		if err == brokerapi.ErrInstanceDoesNotExist {
+			logger.Error("instance-missing", err)
+			h.respond(w, http.StatusNotFound, brokerapi.ErrorResponse{Description: err.Error()})
+			return
+		}
+
+		h.respondError(w, err, logger)
+		return
+	}
+
+	if binding.IsAsync {
+		h.respond(w, http.StatusAccepted, asyncOperationResponse{OperationData: binding.OperationData})
+		return
+	}
+
+	h.respond(w, http.StatusCreated, binding.Binding)
+}
+
+func (h *handler) unbind(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	instanceID := vars["instance_id"]
+	bindingID := vars["binding_id"]
+	logger := h.logger.Session("unbind", lager.Data{"instance-id": instanceID, "binding-id": bindingID})
+	if !h.checkAPIVersion(w, req, logger) {
+		return
+	}
+
+	details := brokerapi.UnbindDetails{
+		PlanID:    req.FormValue("plan_id"),
+		ServiceID: req.FormValue("service_id"),
+	}
+
+	if !h.checkServiceAndPlan(w, details.ServiceID, details.PlanID, logger) {
+		return
+	}
+
+	asyncAllowed := req.FormValue("accepts_incomplete") == "true"
+	unbind, err := h.binder.UnbindAsync(req.Context(), instanceID, bindingID, details, asyncAllowed)
+	if err != nil {
+		h.respondError(w, err, logger)
+		return
+	}
+
+	if unbind.IsAsync {
+		h.respond(w, http.StatusAccepted, asyncOperationResponse{OperationData: unbind.OperationData})
+		return
+	}
+
+	h.respond(w, http.StatusOK, brokerapi.EmptyResponse{})
+}
+
+func (h *handler) lastBindingOperation(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	instanceID := vars["instance_id"]
+	bindingID := vars["binding_id"]
+	logger := h.logger.Session("last-binding-operation", lager.Data{"instance-id": instanceID, "binding-id": bindingID})
+	if !h.checkAPIVersion(w, req, logger) {
+		return
+	}
+
+	op, err := h.binder.LastBindingOperation(req.Context(), instanceID, bindingID, req.FormValue("operation"))
+	if err != nil {
+		h.respondError(w, err, logger)
+		return
+	}
+
+	h.respond(w, http.StatusOK, brokerapi.LastOperationResponse{
+		State:       op.State,
+		Description: op.Description,
+	})
+}
+
+// checkServiceAndPlan mirrors the checks brokerapi does on its own routes.
+func (h *handler) checkServiceAndPlan(w http.ResponseWriter, serviceID, planID string, logger lager.Logger) bool {
+	var err error
+	switch {
+	case serviceID == "":
+		err = errServiceIdMissing
+	case planID == "":
+		err = errPlanIdMissing
+	default:
+		return true
+	}
+
+	logger.Error("invalid-details", err)
+	h.respond(w, http.StatusBadRequest, brokerapi.ErrorResponse{Description: err.Error()})
+	return false
+}
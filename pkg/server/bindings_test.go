@@ -0,0 +1,169 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers"
+	"github.com/pivotal-cf/brokerapi"
+)
+
+type stubAsyncBroker struct {
+	stubBroker
+
+	async bool
+}
+
+func (b stubAsyncBroker) BindAsync(ctx context.Context, instanceID, bindingID string, details brokerapi.BindDetails, asyncAllowed bool) (brokers.BindingSpec, error) {
+	if instanceID == "missing" {
+		return brokers.BindingSpec{}, brokerapi.ErrInstanceDoesNotExist
+	}
+
+	if b.async && asyncAllowed {
+		return brokers.BindingSpec{IsAsync: true, OperationData: "bind"}, nil
+	}
+
+	return brokers.BindingSpec{Binding: brokerapi.Binding{Credentials: map[string]interface{}{"key": "value"}}}, nil
+}
+
+func (b stubAsyncBroker) UnbindAsync(ctx context.Context, instanceID, bindingID string, details brokerapi.UnbindDetails, asyncAllowed bool) (brokers.UnbindSpec, error) {
+	if b.async && asyncAllowed {
+		return brokers.UnbindSpec{IsAsync: true, OperationData: "unbind"}, nil
+	}
+
+	return brokers.UnbindSpec{}, nil
+}
+
+func (b stubAsyncBroker) LastBindingOperation(ctx context.Context, instanceID, bindingID, operationData string) (brokerapi.LastOperation, error) {
+	if bindingID == "missing" {
+		return brokerapi.LastOperation{}, brokerapi.ErrBindingDoesNotExist
+	}
+
+	return brokerapi.LastOperation{State: brokerapi.InProgress, Description: operationData}, nil
+}
+
+func TestNewBrokerHandler_bindings(t *testing.T) {
+	const bindingPath = "/v2/service_instances/instance/service_bindings/binding"
+
+	cases := map[string]struct {
+		Async          bool
+		Method         string
+		Path           string
+		Body           string
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		"async bind": {
+			Async:          true,
+			Method:         http.MethodPut,
+			Path:           bindingPath + "?accepts_incomplete=true",
+			Body:           `{"service_id":"svc-id","plan_id":"plan-id"}`,
+			ExpectedStatus: http.StatusAccepted,
+			ExpectedBody:   `{"operation":"bind"}`,
+		},
+		"sync bind": {
+			Async:          true,
+			Method:         http.MethodPut,
+			Path:           bindingPath,
+			Body:           `{"service_id":"svc-id","plan_id":"plan-id"}`,
+			ExpectedStatus: http.StatusCreated,
+			ExpectedBody:   `{"credentials":{"key":"value"}}`,
+		},
+		"bind missing instance": {
+			Method:         http.MethodPut,
+			Path:           "/v2/service_instances/missing/service_bindings/binding",
+			Body:           `{"service_id":"svc-id","plan_id":"plan-id"}`,
+			ExpectedStatus: http.StatusNotFound,
+		},
+		"bind missing plan": {
+			Method:         http.MethodPut,
+			Path:           bindingPath,
+			Body:           `{"service_id":"svc-id"}`,
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedBody:   `{"description":"plan_id missing"}`,
+		},
+		"async unbind": {
+			Async:          true,
+			Method:         http.MethodDelete,
+			Path:           bindingPath + "?accepts_incomplete=true&service_id=svc-id&plan_id=plan-id",
+			ExpectedStatus: http.StatusAccepted,
+			ExpectedBody:   `{"operation":"unbind"}`,
+		},
+		"sync unbind": {
+			Method:         http.MethodDelete,
+			Path:           bindingPath + "?service_id=svc-id&plan_id=plan-id",
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   `{}`,
+		},
+		"unbind missing service": {
+			Method:         http.MethodDelete,
+			Path:           bindingPath + "?plan_id=plan-id",
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedBody:   `{"description":"service_id missing"}`,
+		},
+		"last operation": {
+			Method:         http.MethodGet,
+			Path:           bindingPath + "/last_operation?operation=bind",
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   `{"state":"in progress","description":"bind"}`,
+		},
+		"last operation of deleted binding": {
+			Method:         http.MethodGet,
+			Path:           "/v2/service_instances/instance/service_bindings/missing/last_operation",
+			ExpectedStatus: http.StatusGone,
+			ExpectedBody:   `{}`,
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			credentials := brokerapi.BrokerCredentials{Username: "user", Password: "pass"}
+			handler := NewBrokerHandler(stubAsyncBroker{async: tc.Async}, stubFetcher{}, lager.NewLogger("test"), credentials)
+
+			req := httptest.NewRequest(tc.Method, tc.Path, strings.NewReader(tc.Body))
+			req.Header.Set("X-Broker-API-Version", "2.14")
+			req.SetBasicAuth(credentials.Username, credentials.Password)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.ExpectedStatus {
+				t.Errorf("Expected status %d got %d", tc.ExpectedStatus, recorder.Code)
+			}
+
+			if tc.ExpectedBody == "" {
+				return
+			}
+
+			var actual, expected interface{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+				t.Fatalf("Error decoding response %q: %s", recorder.Body.String(), err)
+			}
+			json.Unmarshal([]byte(tc.ExpectedBody), &expected)
+
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("Expected body %s got %s", tc.ExpectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
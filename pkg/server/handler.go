@@ -0,0 +1,222 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package server serves the Open Service Broker API, adding the endpoints the
+// vendored brokerapi library doesn't implement.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
+	"github.com/gorilla/mux"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/pivotal-cf/brokerapi/auth"
+)
+
+// Fetcher gets existing service instances and bindings.
+type Fetcher interface {
+	GetInstance(ctx context.Context, instanceID string) (brokers.GetInstanceDetailsSpec, error)
+	GetBinding(ctx context.Context, instanceID, bindingID string) (brokers.GetBindingSpec, error)
+}
+
+// AsyncBinder creates and deletes bindings that may finish asynchronously.
+type AsyncBinder interface {
+	BindAsync(ctx context.Context, instanceID, bindingID string, details brokerapi.BindDetails, asyncAllowed bool) (brokers.BindingSpec, error)
+	UnbindAsync(ctx context.Context, instanceID, bindingID string, details brokerapi.UnbindDetails, asyncAllowed bool) (brokers.UnbindSpec, error)
+	LastBindingOperation(ctx context.Context, instanceID, bindingID, operationData string) (brokerapi.LastOperation, error)
+}
+
+// retrievableService is a catalog entry that advertises the instances and
+// bindings of the service can be fetched.
+type retrievableService struct {
+	brokerapi.Service
+	InstancesRetrievable bool `json:"instances_retrievable"`
+	BindingsRetrievable  bool `json:"bindings_retrievable"`
+}
+
+type catalogResponse struct {
+	Services []retrievableService `json:"services"`
+}
+
+// NewBrokerHandler creates the HTTP handler for the broker. It serves the
+// brokerapi routes for serviceBroker along with the GET instance and binding
+// endpoints backed by fetcher, all behind basic auth.
+// If serviceBroker is an AsyncBinder, bindings are served by it so they can
+// be asynchronous.
+// The originating identity of requests is available to the broker through
+// broker.OriginatingIdentityFromContext.
+func NewBrokerHandler(serviceBroker brokerapi.ServiceBroker, fetcher Fetcher, logger lager.Logger, credentials brokerapi.BrokerCredentials) http.Handler {
+	h := &handler{serviceBroker: serviceBroker, fetcher: fetcher, logger: logger}
+
+	// mux matches routes in the order they were added so these take precedence
+	// over the ones brokerapi attaches.
+	router := mux.NewRouter()
+	router.HandleFunc("/v2/catalog", h.catalog).Methods("GET")
+	router.HandleFunc("/v2/service_instances/{instance_id}", h.getInstance).Methods("GET")
+	router.HandleFunc("/v2/service_instances/{instance_id}/service_bindings/{binding_id}", h.getBinding).Methods("GET")
+
+	if binder, ok := serviceBroker.(AsyncBinder); ok {
+		h.binder = binder
+		router.HandleFunc("/v2/service_instances/{instance_id}/service_bindings/{binding_id}", h.bind).Methods("PUT")
+		router.HandleFunc("/v2/service_instances/{instance_id}/service_bindings/{binding_id}", h.unbind).Methods("DELETE")
+		router.HandleFunc("/v2/service_instances/{instance_id}/service_bindings/{binding_id}/last_operation", h.lastBindingOperation).Methods("GET")
+	}
+
+	brokerapi.AttachRoutes(router, serviceBroker, logger)
+
+	return auth.NewWrapper(credentials.Username, credentials.Password).Wrap(h.withOriginatingIdentity(router))
+}
+
+// withOriginatingIdentity decodes the X-Broker-API-Originating-Identity header
+// into the request context so the broker knows who made the request.
+// Headers that can't be decoded are logged and ignored so they don't block
+// the platform.
+func (h *handler) withOriginatingIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if header := req.Header.Get("X-Broker-API-Originating-Identity"); header != "" {
+			identity, err := broker.ParseOriginatingIdentity(header)
+			if err != nil {
+				h.logger.Error("originating-identity-invalid", err)
+			} else {
+				req = req.WithContext(broker.WithOriginatingIdentity(req.Context(), identity))
+			}
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+type handler struct {
+	serviceBroker brokerapi.ServiceBroker
+	fetcher       Fetcher
+	binder        AsyncBinder
+	logger        lager.Logger
+}
+
+func (h *handler) catalog(w http.ResponseWriter, req *http.Request) {
+	logger := h.logger.Session("catalog")
+	if !h.checkAPIVersion(w, req, logger) {
+		return
+	}
+
+	services, err := h.serviceBroker.Services(req.Context())
+	if err != nil {
+		h.respondError(w, err, logger)
+		return
+	}
+
+	catalog := catalogResponse{Services: []retrievableService{}}
+	for _, svc := range services {
+		catalog.Services = append(catalog.Services, retrievableService{
+			Service:              svc,
+			InstancesRetrievable: true,
+			BindingsRetrievable:  true,
+		})
+	}
+
+	h.respond(w, http.StatusOK, catalog)
+}
+
+func (h *handler) getInstance(w http.ResponseWriter, req *http.Request) {
+	instanceID := mux.Vars(req)["instance_id"]
+	logger := h.logger.Session("get-instance", lager.Data{"instance-id": instanceID})
+	if !h.checkAPIVersion(w, req, logger) {
+		return
+	}
+
+	instance, err := h.fetcher.GetInstance(req.Context(), instanceID)
+	if err != nil {
+		h.respondFetchError(w, err, logger)
+		return
+	}
+
+	h.respond(w, http.StatusOK, instance)
+}
+
+func (h *handler) getBinding(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	instanceID := vars["instance_id"]
+	bindingID := vars["binding_id"]
+	logger := h.logger.Session("get-binding", lager.Data{"instance-id": instanceID, "binding-id": bindingID})
+	if !h.checkAPIVersion(w, req, logger) {
+		return
+	}
+
+	binding, err := h.fetcher.GetBinding(req.Context(), instanceID, bindingID)
+	if err != nil {
+		h.respondFetchError(w, err, logger)
+		return
+	}
+
+	h.respond(w, http.StatusOK, binding)
+}
+
+// checkAPIVersion mirrors the version check brokerapi does on its own routes.
+func (h *handler) checkAPIVersion(w http.ResponseWriter, req *http.Request, logger lager.Logger) bool {
+	apiVersion := req.Header.Get("X-Broker-API-Version")
+
+	var err error
+	switch {
+	case apiVersion == "":
+		err = errors.New("X-Broker-API-Version Header not set")
+	case !strings.HasPrefix(apiVersion, "2."):
+		err = errors.New("X-Broker-API-Version Header must be 2.x")
+	default:
+		return true
+	}
+
+	logger.Error("broker-api-version-invalid", err)
+	h.respond(w, http.StatusPreconditionFailed, brokerapi.ErrorResponse{Description: err.Error()})
+	return false
+}
+
+// respondFetchError responds with 404 Not Found for missing instances and
+// bindings as the fetch endpoints require, and like respondError otherwise.
+func (h *handler) respondFetchError(w http.ResponseWriter, err error, logger lager.Logger) {
+	switch err {
+	case brokerapi.ErrInstanceDoesNotExist, brokerapi.ErrBindingDoesNotExist:
+		logger.Error("not-found", err)
+		h.respond(w, http.StatusNotFound, brokerapi.EmptyResponse{})
+		return
+	}
+
+	h.respondError(w, err, logger)
+}
+
+func (h *handler) respondError(w http.ResponseWriter, err error, logger lager.Logger) {
+	if failure, ok := err.(*brokerapi.FailureResponse); ok {
+		logger.Error(failure.LoggerAction(), err)
+		h.respond(w, failure.ValidatedStatusCode(logger), failure.ErrorResponse())
+		return
+	}
+
+	logger.Error("unknown-error", err)
+	h.respond(w, http.StatusInternalServerError, brokerapi.ErrorResponse{Description: err.Error()})
+}
+
+func (h *handler) respond(w http.ResponseWriter, status int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("encoding response", err, lager.Data{"status": status})
+	}
+}
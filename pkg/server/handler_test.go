@@ -0,0 +1,217 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers"
+	"github.com/GoogleCloudPlatform/gcp-service-broker/pkg/broker"
+	"github.com/pivotal-cf/brokerapi"
+)
+
+type stubBroker struct {
+	brokerapi.ServiceBroker
+}
+
+func (stubBroker) Services(ctx context.Context) ([]brokerapi.Service, error) {
+	return []brokerapi.Service{{ID: "svc-id", Name: "svc"}}, nil
+}
+
+type stubFetcher struct {
+	instances map[string]brokers.GetInstanceDetailsSpec
+	bindings  map[string]brokers.GetBindingSpec
+	err       error
+}
+
+func (f stubFetcher) GetInstance(ctx context.Context, instanceID string) (brokers.GetInstanceDetailsSpec, error) {
+	if f.err != nil {
+		return brokers.GetInstanceDetailsSpec{}, f.err
+	}
+
+	instance, ok := f.instances[instanceID]
+	if !ok {
+		return instance, brokerapi.ErrInstanceDoesNotExist
+	}
+	return instance, nil
+}
+
+func (f stubFetcher) GetBinding(ctx context.Context, instanceID, bindingID string) (brokers.GetBindingSpec, error) {
+	if f.err != nil {
+		return brokers.GetBindingSpec{}, f.err
+	}
+
+	binding, ok := f.bindings[instanceID+"/"+bindingID]
+	if !ok {
+		return binding, brokerapi.ErrBindingDoesNotExist
+	}
+	return binding, nil
+}
+
+func TestNewBrokerHandler(t *testing.T) {
+	fetcher := stubFetcher{
+		instances: map[string]brokers.GetInstanceDetailsSpec{
+			"instance": {ServiceID: "svc-id", PlanID: "plan-id", Parameters: json.RawMessage(`{"name":"foo"}`)},
+		},
+		bindings: map[string]brokers.GetBindingSpec{
+			"instance/binding": {Credentials: map[string]interface{}{"key": "value"}},
+		},
+	}
+
+	cases := map[string]struct {
+		Fetcher        stubFetcher
+		Path           string
+		APIVersion     string
+		Password       string
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		"catalog": {
+			Fetcher:        fetcher,
+			Path:           "/v2/catalog",
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   `{"services":[{"id":"svc-id","name":"svc","description":"","bindable":false,"plan_updateable":false,"plans":null,"instances_retrievable":true,"bindings_retrievable":true}]}`,
+		},
+		"instance": {
+			Fetcher:        fetcher,
+			Path:           "/v2/service_instances/instance",
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   `{"service_id":"svc-id","plan_id":"plan-id","parameters":{"name":"foo"}}`,
+		},
+		"missing instance": {
+			Fetcher:        fetcher,
+			Path:           "/v2/service_instances/missing",
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedBody:   `{}`,
+		},
+		"binding": {
+			Fetcher:        fetcher,
+			Path:           "/v2/service_instances/instance/service_bindings/binding",
+			ExpectedStatus: http.StatusOK,
+			ExpectedBody:   `{"credentials":{"key":"value"}}`,
+		},
+		"missing binding": {
+			Fetcher:        fetcher,
+			Path:           "/v2/service_instances/instance/service_bindings/missing",
+			ExpectedStatus: http.StatusNotFound,
+			ExpectedBody:   `{}`,
+		},
+		"concurrent operation": {
+			Fetcher:        stubFetcher{err: brokers.ErrConcurrentInstanceAccess},
+			Path:           "/v2/service_instances/instance",
+			ExpectedStatus: http.StatusUnprocessableEntity,
+			ExpectedBody:   `{"error":"ConcurrencyError","description":"Another operation for this service instance is in progress"}`,
+		},
+		"unknown error": {
+			Fetcher:        stubFetcher{err: errors.New("boom")},
+			Path:           "/v2/service_instances/instance/service_bindings/binding",
+			ExpectedStatus: http.StatusInternalServerError,
+			ExpectedBody:   `{"description":"boom"}`,
+		},
+		"bad api version": {
+			Fetcher:        fetcher,
+			Path:           "/v2/service_instances/instance",
+			APIVersion:     "1.0",
+			ExpectedStatus: http.StatusPreconditionFailed,
+			ExpectedBody:   `{"description":"X-Broker-API-Version Header must be 2.x"}`,
+		},
+		"bad credentials": {
+			Fetcher:        fetcher,
+			Path:           "/v2/service_instances/instance",
+			Password:       "wrong",
+			ExpectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			credentials := brokerapi.BrokerCredentials{Username: "user", Password: "pass"}
+			handler := NewBrokerHandler(stubBroker{}, tc.Fetcher, lager.NewLogger("test"), credentials)
+
+			req := httptest.NewRequest(http.MethodGet, tc.Path, nil)
+			req.Header.Set("X-Broker-API-Version", "2.14")
+			if tc.APIVersion != "" {
+				req.Header.Set("X-Broker-API-Version", tc.APIVersion)
+			}
+			password := credentials.Password
+			if tc.Password != "" {
+				password = tc.Password
+			}
+			req.SetBasicAuth(credentials.Username, password)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.ExpectedStatus {
+				t.Errorf("Expected status %d got %d", tc.ExpectedStatus, recorder.Code)
+			}
+
+			if tc.ExpectedBody == "" {
+				return
+			}
+
+			var actual, expected interface{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &actual); err != nil {
+				t.Fatalf("Error decoding response %q: %s", recorder.Body.String(), err)
+			}
+			json.Unmarshal([]byte(tc.ExpectedBody), &expected)
+
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("Expected body %s got %s", tc.ExpectedBody, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestWithOriginatingIdentity(t *testing.T) {
+	cases := map[string]struct {
+		Header   string
+		Expected *broker.OriginatingIdentity
+	}{
+		"no header": {Header: "", Expected: nil},
+		"cloud foundry": {
+			Header:   "cloudfoundry eyJ1c2VyX2lkIjoiYWJjIn0=",
+			Expected: &broker.OriginatingIdentity{Platform: "cloudfoundry", Value: map[string]interface{}{"user_id": "abc"}},
+		},
+		"invalid": {Header: "cloudfoundry not-base64!", Expected: nil},
+	}
+
+	h := &handler{logger: lager.NewLogger("test")}
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			var actual *broker.OriginatingIdentity
+			handler := h.withOriginatingIdentity(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				actual = broker.OriginatingIdentityFromContext(req.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/v2/catalog", nil)
+			if tc.Header != "" {
+				req.Header.Set("X-Broker-API-Originating-Identity", tc.Header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !reflect.DeepEqual(actual, tc.Expected) {
+				t.Errorf("Expected identity %v, got %v", tc.Expected, actual)
+			}
+		})
+	}
+}
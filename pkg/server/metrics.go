@@ -0,0 +1,178 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/GoogleCloudPlatform/gcp-service-broker/brokerapi/brokers"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	osbRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gsb_osb_requests_total",
+		Help: "OSB requests handled by the broker by whether they succeeded.",
+	}, []string{"operation", "service_id", "plan_id", "result"})
+
+	osbRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "gsb_osb_request_duration_seconds",
+		Help: "Time taken by the broker to handle OSB requests.",
+	}, []string{"operation", "service_id", "plan_id"})
+
+	asyncOperationPolls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gsb_async_operation_polls_total",
+		Help: "Polls of asynchronous operations by the state they reported.",
+	}, []string{"operation", "state"})
+)
+
+func init() {
+	prometheus.MustRegister(osbRequests, osbRequestDuration, asyncOperationPolls)
+}
+
+// InstrumentedBroker records metrics about the requests made to a broker.
+// It serves asynchronous bindings if the wrapped broker does and falls back to
+// synchronous ones if it doesn't.
+type InstrumentedBroker struct {
+	broker  brokerapi.ServiceBroker
+	fetcher Fetcher
+}
+
+var _ brokerapi.ServiceBroker = (*InstrumentedBroker)(nil)
+var _ AsyncBinder = (*InstrumentedBroker)(nil)
+var _ Fetcher = (*InstrumentedBroker)(nil)
+
+// NewInstrumentedBroker wraps the broker and fetcher so their requests are
+// recorded in the default Prometheus registry.
+func NewInstrumentedBroker(serviceBroker brokerapi.ServiceBroker, fetcher Fetcher) *InstrumentedBroker {
+	return &InstrumentedBroker{broker: serviceBroker, fetcher: fetcher}
+}
+
+// observe records a request that started at start.
+func (ib *InstrumentedBroker) observe(operation, serviceID, planID string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+
+	osbRequests.WithLabelValues(operation, serviceID, planID, result).Inc()
+	osbRequestDuration.WithLabelValues(operation, serviceID, planID).Observe(time.Since(start).Seconds())
+}
+
+// observePoll records a poll of an asynchronous operation.
+func (ib *InstrumentedBroker) observePoll(operation string, lastOperation brokerapi.LastOperation, err error) {
+	state := string(lastOperation.State)
+	if state == "" && err != nil {
+		state = "error"
+	}
+
+	asyncOperationPolls.WithLabelValues(operation, state).Inc()
+}
+
+// Services implements brokerapi.ServiceBroker.
+func (ib *InstrumentedBroker) Services(ctx context.Context) (services []brokerapi.Service, err error) {
+	defer func(start time.Time) { ib.observe("catalog", "", "", start, err) }(time.Now())
+	return ib.broker.Services(ctx)
+}
+
+// Provision implements brokerapi.ServiceBroker.
+func (ib *InstrumentedBroker) Provision(ctx context.Context, instanceID string, details brokerapi.ProvisionDetails, asyncAllowed bool) (spec brokerapi.ProvisionedServiceSpec, err error) {
+	defer func(start time.Time) { ib.observe("provision", details.ServiceID, details.PlanID, start, err) }(time.Now())
+	return ib.broker.Provision(ctx, instanceID, details, asyncAllowed)
+}
+
+// Deprovision implements brokerapi.ServiceBroker.
+func (ib *InstrumentedBroker) Deprovision(ctx context.Context, instanceID string, details brokerapi.DeprovisionDetails, asyncAllowed bool) (spec brokerapi.DeprovisionServiceSpec, err error) {
+	defer func(start time.Time) { ib.observe("deprovision", details.ServiceID, details.PlanID, start, err) }(time.Now())
+	return ib.broker.Deprovision(ctx, instanceID, details, asyncAllowed)
+}
+
+// Bind implements brokerapi.ServiceBroker.
+func (ib *InstrumentedBroker) Bind(ctx context.Context, instanceID, bindingID string, details brokerapi.BindDetails) (binding brokerapi.Binding, err error) {
+	defer func(start time.Time) { ib.observe("bind", details.ServiceID, details.PlanID, start, err) }(time.Now())
+	return ib.broker.Bind(ctx, instanceID, bindingID, details)
+}
+
+// Unbind implements brokerapi.ServiceBroker.
+func (ib *InstrumentedBroker) Unbind(ctx context.Context, instanceID, bindingID string, details brokerapi.UnbindDetails) (err error) {
+	defer func(start time.Time) { ib.observe("unbind", details.ServiceID, details.PlanID, start, err) }(time.Now())
+	return ib.broker.Unbind(ctx, instanceID, bindingID, details)
+}
+
+// Update implements brokerapi.ServiceBroker.
+func (ib *InstrumentedBroker) Update(ctx context.Context, instanceID string, details brokerapi.UpdateDetails, asyncAllowed bool) (spec brokerapi.UpdateServiceSpec, err error) {
+	defer func(start time.Time) { ib.observe("update", details.ServiceID, details.PlanID, start, err) }(time.Now())
+	return ib.broker.Update(ctx, instanceID, details, asyncAllowed)
+}
+
+// LastOperation implements brokerapi.ServiceBroker.
+func (ib *InstrumentedBroker) LastOperation(ctx context.Context, instanceID, operationData string) (lastOperation brokerapi.LastOperation, err error) {
+	defer func(start time.Time) {
+		ib.observe("last_operation", "", "", start, err)
+		ib.observePoll("last_operation", lastOperation, err)
+	}(time.Now())
+	return ib.broker.LastOperation(ctx, instanceID, operationData)
+}
+
+// BindAsync implements AsyncBinder.
+func (ib *InstrumentedBroker) BindAsync(ctx context.Context, instanceID, bindingID string, details brokerapi.BindDetails, asyncAllowed bool) (spec brokers.BindingSpec, err error) {
+	defer func(start time.Time) { ib.observe("bind", details.ServiceID, details.PlanID, start, err) }(time.Now())
+
+	if binder, ok := ib.broker.(AsyncBinder); ok {
+		return binder.BindAsync(ctx, instanceID, bindingID, details, asyncAllowed)
+	}
+
+	binding, err := ib.broker.Bind(ctx, instanceID, bindingID, details)
+	return brokers.BindingSpec{Binding: binding}, err
+}
+
+// UnbindAsync implements AsyncBinder.
+func (ib *InstrumentedBroker) UnbindAsync(ctx context.Context, instanceID, bindingID string, details brokerapi.UnbindDetails, asyncAllowed bool) (spec brokers.UnbindSpec, err error) {
+	defer func(start time.Time) { ib.observe("unbind", details.ServiceID, details.PlanID, start, err) }(time.Now())
+
+	if binder, ok := ib.broker.(AsyncBinder); ok {
+		return binder.UnbindAsync(ctx, instanceID, bindingID, details, asyncAllowed)
+	}
+
+	return brokers.UnbindSpec{}, ib.broker.Unbind(ctx, instanceID, bindingID, details)
+}
+
+// LastBindingOperation implements AsyncBinder.
+func (ib *InstrumentedBroker) LastBindingOperation(ctx context.Context, instanceID, bindingID, operationData string) (lastOperation brokerapi.LastOperation, err error) {
+	defer func(start time.Time) {
+		ib.observe("last_binding_operation", "", "", start, err)
+		ib.observePoll("last_binding_operation", lastOperation, err)
+	}(time.Now())
+
+	if binder, ok := ib.broker.(AsyncBinder); ok {
+		return binder.LastBindingOperation(ctx, instanceID, bindingID, operationData)
+	}
+
+	return brokerapi.LastOperation{}, brokerapi.ErrAsyncRequired
+}
+
+// GetInstance implements Fetcher.
+func (ib *InstrumentedBroker) GetInstance(ctx context.Context, instanceID string) (instance brokers.GetInstanceDetailsSpec, err error) {
+	defer func(start time.Time) { ib.observe("get_instance", instance.ServiceID, instance.PlanID, start, err) }(time.Now())
+	return ib.fetcher.GetInstance(ctx, instanceID)
+}
+
+// GetBinding implements Fetcher.
+func (ib *InstrumentedBroker) GetBinding(ctx context.Context, instanceID, bindingID string) (binding brokers.GetBindingSpec, err error) {
+	defer func(start time.Time) { ib.observe("get_binding", "", "", start, err) }(time.Now())
+	return ib.fetcher.GetBinding(ctx, instanceID, bindingID)
+}
@@ -0,0 +1,140 @@
+// Copyright 2018 the Service Broker Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestInstrumentedBroker(t *testing.T) {
+	cases := map[string]struct {
+		Call      func(ib *InstrumentedBroker) error
+		Operation string
+		ServiceID string
+		PlanID    string
+		Result    string
+		PollState string
+	}{
+		"catalog": {
+			Call: func(ib *InstrumentedBroker) error {
+				_, err := ib.Services(context.Background())
+				return err
+			},
+			Operation: "catalog",
+			Result:    "success",
+		},
+		"bind": {
+			Call: func(ib *InstrumentedBroker) error {
+				_, err := ib.BindAsync(context.Background(), "instance", "binding", brokerapi.BindDetails{ServiceID: "svc-id", PlanID: "plan-id"}, true)
+				return err
+			},
+			Operation: "bind",
+			ServiceID: "svc-id",
+			PlanID:    "plan-id",
+			Result:    "success",
+		},
+		"failed bind": {
+			Call: func(ib *InstrumentedBroker) error {
+				_, err := ib.BindAsync(context.Background(), "missing", "binding", brokerapi.BindDetails{ServiceID: "svc-id", PlanID: "plan-id"}, true)
+				return err
+			},
+			Operation: "bind",
+			ServiceID: "svc-id",
+			PlanID:    "plan-id",
+			Result:    "error",
+		},
+		"binding poll": {
+			Call: func(ib *InstrumentedBroker) error {
+				_, err := ib.LastBindingOperation(context.Background(), "instance", "binding", "")
+				return err
+			},
+			Operation: "last_binding_operation",
+			Result:    "success",
+			PollState: string(brokerapi.InProgress),
+		},
+		"failed binding poll": {
+			Call: func(ib *InstrumentedBroker) error {
+				_, err := ib.LastBindingOperation(context.Background(), "instance", "missing", "")
+				return err
+			},
+			Operation: "last_binding_operation",
+			Result:    "error",
+			PollState: "error",
+		},
+		"get instance": {
+			Call: func(ib *InstrumentedBroker) error {
+				_, err := ib.GetInstance(context.Background(), "missing")
+				return err
+			},
+			Operation: "get_instance",
+			Result:    "error",
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			ib := NewInstrumentedBroker(stubAsyncBroker{async: true}, stubFetcher{})
+
+			requestsBefore := testutil.ToFloat64(osbRequests.WithLabelValues(tc.Operation, tc.ServiceID, tc.PlanID, tc.Result))
+			durationsBefore := requestsTimed(t, tc.Operation, tc.ServiceID, tc.PlanID)
+			pollsBefore := testutil.ToFloat64(asyncOperationPolls.WithLabelValues(tc.Operation, tc.PollState))
+
+			err := tc.Call(ib)
+			if (err != nil) != (tc.Result == "error") {
+				t.Fatalf("Expected result %q, got error: %v", tc.Result, err)
+			}
+
+			if delta := testutil.ToFloat64(osbRequests.WithLabelValues(tc.Operation, tc.ServiceID, tc.PlanID, tc.Result)) - requestsBefore; delta != 1 {
+				t.Errorf("Expected the request counter to increase by 1, got %v", delta)
+			}
+
+			if delta := requestsTimed(t, tc.Operation, tc.ServiceID, tc.PlanID) - durationsBefore; delta != 1 {
+				t.Errorf("Expected 1 duration observation, got %v", delta)
+			}
+
+			if tc.PollState != "" {
+				if delta := testutil.ToFloat64(asyncOperationPolls.WithLabelValues(tc.Operation, tc.PollState)) - pollsBefore; delta != 1 {
+					t.Errorf("Expected the poll counter to increase by 1, got %v", delta)
+				}
+			}
+		})
+	}
+}
+
+// requestsTimed gets the number of requests osbRequestDuration observed with
+// the given labels.
+func requestsTimed(t *testing.T, labels ...string) uint64 {
+	metric := &dto.Metric{}
+	if err := osbRequestDuration.WithLabelValues(labels...).(prometheus.Metric).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func TestInstrumentedBroker_syncFallback(t *testing.T) {
+	ib := NewInstrumentedBroker(stubBroker{}, stubFetcher{})
+
+	_, err := ib.LastBindingOperation(context.Background(), "instance", "binding", "")
+	if err != brokerapi.ErrAsyncRequired {
+		t.Errorf("Expected ErrAsyncRequired, got %v", err)
+	}
+}
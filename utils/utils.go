@@ -169,36 +169,78 @@ func GetServiceAccountJson() string {
 	return viper.GetString("google.account")
 }
 
+const (
+	// InstanceIdLabel is the default label holding the ID of the service
+	// instance a resource was created for.
+	InstanceIdLabel = "pcf-instance-id"
+
+	// OrganizationGuidLabel and SpaceGuidLabel are the default labels holding
+	// the Cloud Foundry org and space a resource was created in.
+	OrganizationGuidLabel = "pcf-organization-guid"
+	SpaceGuidLabel        = "pcf-space-guid"
+
+	// KubernetesNamespaceLabel and KubernetesClusterIdLabel are the default
+	// labels holding the Kubernetes namespace and cluster a resource was created
+	// in. They're only set for requests from Kubernetes.
+	KubernetesNamespaceLabel = "k8s-namespace"
+	KubernetesClusterIdLabel = "k8s-clusterid"
+)
+
+// ExtractRequestContext parses the OSB context object of a request. Contexts
+// that are missing or can't be parsed are treated as empty.
+func ExtractRequestContext(rawContext json.RawMessage) map[string]interface{} {
+	requestContext := map[string]interface{}{}
+	if len(rawContext) > 0 {
+		if err := json.Unmarshal(rawContext, &requestContext); err != nil || requestContext == nil {
+			return map[string]interface{}{}
+		}
+	}
+
+	return requestContext
+}
+
 // ExtractDefaultLabels creates a map[string]string of labels that should be
 // applied to a resource on creation if the resource supports labels.
-// These include the organization, space, and instance id.
+// These include the organization, space, and instance id, and the namespace
+// and cluster for requests from Kubernetes.
 func ExtractDefaultLabels(instanceId string, details brokerapi.ProvisionDetails) map[string]string {
 	labels := map[string]string{
-		"pcf-organization-guid": details.OrganizationGUID,
-		"pcf-space-guid":        details.SpaceGUID,
-		"pcf-instance-id":       instanceId,
+		OrganizationGuidLabel: details.OrganizationGUID,
+		SpaceGuidLabel:        details.SpaceGUID,
+		InstanceIdLabel:       instanceId,
 	}
 
-	// After v 2.14 of the OSB the top-level organization_guid and space_guid are
-	// deprecated in favor of context, so we'll override those.
-	requestContext := map[string]string{}
-	json.Unmarshal(details.GetRawContext(), &requestContext) // explicitly ignore parse errors
-	if orgGuid, ok := requestContext["organization_guid"]; ok {
-		labels["pcf-organization-guid"] = orgGuid
+	contextLabels := map[string]string{
+		// After v 2.14 of the OSB the top-level organization_guid and space_guid
+		// are deprecated in favor of context, so we'll override those.
+		"organization_guid": OrganizationGuidLabel,
+		"space_guid":        SpaceGuidLabel,
+
+		// Kubernetes platforms send these instead of an org and space.
+		"namespace": KubernetesNamespaceLabel,
+		"clusterid": KubernetesClusterIdLabel,
 	}
 
-	if spaceGuid, ok := requestContext["space_guid"]; ok {
-		labels["pcf-space-guid"] = spaceGuid
+	requestContext := ExtractRequestContext(details.GetRawContext())
+	for contextKey, label := range contextLabels {
+		if value, ok := requestContext[contextKey].(string); ok {
+			labels[label] = value
+		}
 	}
 
 	sanitized := map[string]string{}
 	for key, value := range labels {
-		sanitized[key] = invalidLabelChars.ReplaceAllString(value, "_")
+		sanitized[key] = SanitizeLabelValue(value)
 	}
 
 	return sanitized
 }
 
+// SanitizeLabelValue replaces the characters GCP doesn't allow in label values.
+func SanitizeLabelValue(value string) string {
+	return invalidLabelChars.ReplaceAllString(value, "_")
+}
+
 // SingleLineErrorFormatter creates a single line error string from an array of errors.
 func SingleLineErrorFormatter(es []error) string {
 	points := make([]string, len(es))
@@ -87,6 +87,33 @@ func ExampleGetDefaultProjectId() {
 	// Output: my-project-123, <nil>
 }
 
+func TestExtractRequestContext(t *testing.T) {
+	tests := map[string]struct {
+		rawContext json.RawMessage
+		expected   map[string]interface{}
+	}{
+		"missing":    {rawContext: nil, expected: map[string]interface{}{}},
+		"null":       {rawContext: json.RawMessage(`null`), expected: map[string]interface{}{}},
+		"not object": {rawContext: json.RawMessage(`[1, 2]`), expected: map[string]interface{}{}},
+		"kubernetes": {
+			rawContext: json.RawMessage(`{"platform":"kubernetes","namespace":"team-a","clusterid":"c1"}`),
+			expected:   map[string]interface{}{"platform": "kubernetes", "namespace": "team-a", "clusterid": "c1"},
+		},
+		"nested": {
+			rawContext: json.RawMessage(`{"platform":"cloudfoundry","space_annotations":{"a":"b"}}`),
+			expected:   map[string]interface{}{"platform": "cloudfoundry", "space_annotations": map[string]interface{}{"a": "b"}},
+		},
+	}
+
+	for tn, tc := range tests {
+		actual := ExtractRequestContext(tc.rawContext)
+
+		if !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("Error running case %q, expected: %v got: %v", tn, tc.expected, actual)
+		}
+	}
+}
+
 func TestExtractDefaultLabels(t *testing.T) {
 	tests := map[string]struct {
 		instanceId string
@@ -124,6 +151,32 @@ func TestExtractDefaultLabels(t *testing.T) {
 				"pcf-instance-id":       "my-instance",
 			},
 		},
+		"osb context with non-string values": {
+			instanceId: "my-instance",
+			details: brokerapi.ProvisionDetails{
+				OrganizationGUID: "org-guid",
+				SpaceGUID:        "space-guid",
+				RawContext:       json.RawMessage(`{"platform":"cloudfoundry","organization_guid":"org-override","organization_annotations":{"a":"b"}}`),
+			},
+			expected: map[string]string{
+				"pcf-organization-guid": "org-override",
+				"pcf-space-guid":        "space-guid",
+				"pcf-instance-id":       "my-instance",
+			},
+		},
+		"kubernetes": {
+			instanceId: "my-instance",
+			details: brokerapi.ProvisionDetails{
+				RawContext: json.RawMessage(`{"platform":"kubernetes","namespace":"team-a","clusterid":"Cluster.1"}`),
+			},
+			expected: map[string]string{
+				"pcf-organization-guid": "",
+				"pcf-space-guid":        "",
+				"pcf-instance-id":       "my-instance",
+				"k8s-namespace":         "team-a",
+				"k8s-clusterid":         "Cluster_1",
+			},
+		},
 		"osb special characters": {
 			instanceId: "my~instance.",
 			details:    brokerapi.ProvisionDetails{},